@@ -0,0 +1,173 @@
+package agent
+
+/*
+ * This file contains structs and functions related to the protocol spoken
+ * between the cluster package and the experimental remote agent.
+ *
+ * The agent is a small, long-running process on each host that reads
+ * newline-delimited JSON Requests from its standard input, runs each command
+ * with bash, and writes a Response for each to its standard output.  Requests
+ * run concurrently, so responses may arrive in a different order than their
+ * requests and are matched up by ID.  This avoids the cost of establishing an
+ * ssh connection and spawning a shell for every command on a large cluster.
+ */
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os/exec"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+type Request struct {
+	ID      uint64 `json:"id"`
+	Command string `json:"command"`
+}
+
+/*
+ * A Response holds the result of running a Request's command.  Error is set
+ * only if the command could not be run at all, in which case ExitCode is -1.
+ */
+type Response struct {
+	ID       uint64 `json:"id"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+}
+
+/*
+ * Serve handles requests read from reader until it is closed, writing each
+ * response to writer, and returns once every request has been answered.
+ */
+func Serve(reader io.Reader, writer io.Writer) error {
+	decoder := json.NewDecoder(reader)
+	encoder := json.NewEncoder(writer)
+	var writeMutex sync.Mutex
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		var request Request
+		if err := decoder.Decode(&request); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return errors.Wrap(err, "Failed to read agent request")
+		}
+		wg.Add(1)
+		go func(request Request) {
+			defer wg.Done()
+			response := runCommand(request)
+			writeMutex.Lock()
+			defer writeMutex.Unlock()
+			_ = encoder.Encode(response)
+		}(request)
+	}
+}
+
+func runCommand(request Request) Response {
+	cmd := exec.Command("bash", "-c", request.Command)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	response := Response{ID: request.ID, Stdout: stdout.String(), Stderr: stderr.String(), ExitCode: -1}
+	if cmd.ProcessState != nil {
+		response.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		response.Error = err.Error()
+	}
+	return response
+}
+
+/*
+ * A Client sends requests to a single agent.  It is safe for concurrent use,
+ * and any number of requests may be outstanding at once.
+ */
+type Client struct {
+	mutex      sync.Mutex
+	writeMutex sync.Mutex
+	encoder    *json.Encoder
+	nextID     uint64
+	pending    map[uint64]chan Response
+	err        error
+}
+
+// NewClient returns a Client writing requests to writer and reading responses from reader.
+func NewClient(writer io.Writer, reader io.Reader) *Client {
+	client := &Client{
+		encoder: json.NewEncoder(writer),
+		pending: make(map[uint64]chan Response),
+	}
+	go client.readResponses(reader)
+	return client
+}
+
+func (client *Client) readResponses(reader io.Reader) {
+	decoder := json.NewDecoder(reader)
+	for {
+		var response Response
+		if err := decoder.Decode(&response); err != nil {
+			client.mutex.Lock()
+			client.err = errors.New("Agent connection closed")
+			if err != io.EOF {
+				client.err = errors.Wrap(err, "Failed to read agent response")
+			}
+			for id, responseChan := range client.pending {
+				close(responseChan)
+				delete(client.pending, id)
+			}
+			client.mutex.Unlock()
+			return
+		}
+		client.mutex.Lock()
+		responseChan, ok := client.pending[response.ID]
+		delete(client.pending, response.ID)
+		client.mutex.Unlock()
+		if ok {
+			responseChan <- response
+		}
+	}
+}
+
+/*
+ * Run sends a command to the agent and waits for its response.  An error is
+ * returned only if the agent could not be reached; a command that fails is
+ * reported through the Response's ExitCode and Error.
+ */
+func (client *Client) Run(command string) (*Response, error) {
+	responseChan := make(chan Response, 1)
+	client.mutex.Lock()
+	if client.err != nil {
+		err := client.err
+		client.mutex.Unlock()
+		return nil, err
+	}
+	client.nextID++
+	id := client.nextID
+	client.pending[id] = responseChan
+	client.mutex.Unlock()
+
+	client.writeMutex.Lock()
+	err := client.encoder.Encode(Request{ID: id, Command: command})
+	client.writeMutex.Unlock()
+	if err != nil {
+		client.mutex.Lock()
+		delete(client.pending, id)
+		client.mutex.Unlock()
+		return nil, errors.Wrap(err, "Failed to send agent request")
+	}
+
+	response, ok := <-responseChan
+	if !ok {
+		client.mutex.Lock()
+		defer client.mutex.Unlock()
+		return nil, client.err
+	}
+	return &response, nil
+}
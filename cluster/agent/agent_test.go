@@ -0,0 +1,81 @@
+package agent_test
+
+import (
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster/agent"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestAgent(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "agent tests")
+}
+
+var _ = Describe("agent tests", func() {
+	var (
+		client      *agent.Client
+		requestW    *io.PipeWriter
+		responseR   *io.PipeReader
+		serveResult chan error
+	)
+	BeforeEach(func() {
+		var requestR *io.PipeReader
+		var responseW *io.PipeWriter
+		requestR, requestW = io.Pipe()
+		responseR, responseW = io.Pipe()
+		result := make(chan error, 1)
+		serveResult = result
+		go func() {
+			err := agent.Serve(requestR, responseW)
+			responseW.Close()
+			result <- err
+		}()
+		client = agent.NewClient(requestW, responseR)
+	})
+	AfterEach(func() {
+		requestW.Close()
+	})
+	It("runs a command and returns its output", func() {
+		response, err := client.Run("echo out; echo err >&2")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(response.Stdout).To(Equal("out\n"))
+		Expect(response.Stderr).To(Equal("err\n"))
+		Expect(response.ExitCode).To(Equal(0))
+		Expect(response.Error).To(BeEmpty())
+	})
+	It("returns the exit code of a failed command", func() {
+		response, err := client.Run("exit 3")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(response.ExitCode).To(Equal(3))
+	})
+	It("matches concurrent requests to their responses", func() {
+		var wg sync.WaitGroup
+		outputs := make([]string, 10)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(index int) {
+				defer wg.Done()
+				defer GinkgoRecover()
+				// Later requests finish first, so responses arrive out of order
+				response, err := client.Run("sleep 0.0" + string(rune('9'-index)) + "; echo " + string(rune('0'+index)))
+				Expect(err).ToNot(HaveOccurred())
+				outputs[index] = response.Stdout
+			}(i)
+		}
+		wg.Wait()
+		for i, output := range outputs {
+			Expect(output).To(Equal(string(rune('0'+i)) + "\n"))
+		}
+	})
+	It("returns an error once the agent has exited", func() {
+		requestW.Close()
+		Eventually(serveResult).Should(Receive(BeNil()))
+		_, err := client.Run("true")
+		Expect(err).To(HaveOccurred())
+	})
+})
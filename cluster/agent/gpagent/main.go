@@ -0,0 +1,20 @@
+/*
+ * gpagent is the experimental remote agent used by cluster.AgentPool; see
+ * the agent package for details.  It is started over ssh by the pool and
+ * exits when its standard input is closed.
+ */
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster/agent"
+)
+
+func main() {
+	if err := agent.Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,308 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to the experimental
+ * remote agent execution mode; see the agent package for the protocol.
+ */
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"sync"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster/agent"
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/pkg/errors"
+)
+
+/*
+ * An AgentDialer starts an agent on the given host, connecting to target,
+ * which is the hostname itself or one of its addresses, and returns pipes to
+ * the agent's standard input and output.  Closing stdin must cause the agent
+ * to exit.
+ */
+type AgentDialer func(host string, target string, useLocal bool) (stdin io.WriteCloser, stdout io.ReadCloser, err error)
+
+/*
+ * An AgentPool holds one running agent per host, started on first use.  By
+ * default, the agent binary at BinaryPath (built from cluster/agent/gpagent)
+ * is copied to RemotePath on each remote host with scp, then started with
+ * ssh; on the coordinator host, BinaryPath is run directly.  Set Dial to
+ * start agents some other way.
+ *
+ * A relative RemotePath is relative to the ssh user's home directory.  As
+ * the binary copied there is then run, the directory containing RemotePath
+ * is created with mode 0700 if it does not exist, and the agent is not
+ * started unless that directory is a real directory with mode 0700 owned by
+ * the ssh user, so that no other user can replace the binary.
+ *
+ * This mode is experimental, and the protocol may change between releases.
+ */
+type AgentPool struct {
+	BinaryPath string
+	RemotePath string
+	SSHOptions SSHOptions
+	Dial       AgentDialer
+
+	mutex  sync.Mutex
+	agents map[string]*poolAgent
+}
+
+type poolAgent struct {
+	once   sync.Once
+	client *agent.Client
+	stdin  io.WriteCloser
+	cmd    *exec.Cmd
+	err    error
+}
+
+func NewAgentPool(binaryPath string, sshOptions SSHOptions) *AgentPool {
+	return &AgentPool{
+		BinaryPath: binaryPath,
+		RemotePath: ".gpagent/gpagent",
+		SSHOptions: sshOptions,
+		agents:     make(map[string]*poolAgent),
+	}
+}
+
+/*
+ * Run runs command on host through that host's agent, starting the agent if
+ * necessary by connecting to target, which is the hostname itself or one of
+ * its addresses; the ssh user is that of the hostname, as for ssh commands.
+ * If the agent cannot be started, every later Run for that host returns the
+ * same error.
+ */
+func (pool *AgentPool) Run(host string, target string, useLocal bool, command string) (*agent.Response, error) {
+	pool.mutex.Lock()
+	if pool.agents == nil {
+		pool.agents = make(map[string]*poolAgent)
+	}
+	hostAgent, ok := pool.agents[host]
+	if !ok {
+		hostAgent = &poolAgent{}
+		pool.agents[host] = hostAgent
+	}
+	pool.mutex.Unlock()
+
+	hostAgent.once.Do(func() {
+		dial := pool.Dial
+		if dial == nil {
+			dial = pool.dialWithSSH(hostAgent)
+		}
+		stdin, stdout, err := dial(host, target, useLocal)
+		if err != nil {
+			hostAgent.err = errors.Wrapf(err, "Failed to start agent on host %s", host)
+			return
+		}
+		hostAgent.stdin = stdin
+		hostAgent.client = agent.NewClient(stdin, stdout)
+	})
+	if hostAgent.err != nil {
+		return nil, hostAgent.err
+	}
+	return hostAgent.client.Run(command)
+}
+
+func (pool *AgentPool) dialWithSSH(hostAgent *poolAgent) AgentDialer {
+	return func(host string, target string, useLocal bool) (io.WriteCloser, io.ReadCloser, error) {
+		var cmd *exec.Cmd
+		if useLocal {
+			cmd = exec.Command(pool.BinaryPath)
+		} else {
+			options := pool.SSHOptions.forHost(host)
+			if err := pool.preparePrivateDir(target, options); err != nil {
+				return nil, nil, err
+			}
+			scpOptions := options
+			scpOptions.ForwardAgent = false // scp has no -A flag
+			scpCmd := append([]string{"scp"}, scpOptions.flags()...)
+			scpCmd = append(scpCmd, pool.BinaryPath, FormatRemotePath(options.User, target, pool.RemotePath))
+			if output, err := exec.Command(scpCmd[0], scpCmd[1:]...).CombinedOutput(); err != nil {
+				return nil, nil, errors.Wrapf(err, "Failed to copy agent: %s", output)
+			}
+			sshCmd := ConstructSSHCommandWithOptions(false, target, shellQuote(pool.RemotePath), options)
+			cmd = exec.Command(sshCmd[0], sshCmd[1:]...)
+		}
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, nil, err
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, nil, err
+		}
+		hostAgent.cmd = cmd
+		return stdin, stdout, nil
+	}
+}
+
+/*
+ * preparePrivateDir creates the directory containing RemotePath on the host
+ * with mode 0700 if necessary, and returns an error if it is a symbolic link,
+ * has any other mode, or is not owned by the ssh user.
+ */
+func (pool *AgentPool) preparePrivateDir(target string, options SSHOptions) error {
+	dir := shellQuote(path.Dir(pool.RemotePath))
+	checkCmd := fmt.Sprintf(`mkdir -p -m 0700 %[1]s && test -d %[1]s && test ! -L %[1]s && test -O %[1]s && test -n "$(find %[1]s -prune -perm 0700)"`, dir)
+	sshCmd := ConstructSSHCommandWithOptions(false, target, checkCmd, options)
+	if output, err := exec.Command(sshCmd[0], sshCmd[1:]...).CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "Refusing to install agent at %s: %s is not a directory with mode 0700 owned by %s: %s", pool.RemotePath, path.Dir(pool.RemotePath), options.User, output)
+	}
+	return nil
+}
+
+// Close stops every agent in the pool, waiting for any it started itself to exit.
+func (pool *AgentPool) Close() error {
+	pool.mutex.Lock()
+	agents := pool.agents
+	pool.agents = make(map[string]*poolAgent)
+	pool.mutex.Unlock()
+
+	var closeErr error
+	for host, hostAgent := range agents {
+		if hostAgent.stdin == nil {
+			continue
+		}
+		if err := hostAgent.stdin.Close(); err != nil && closeErr == nil {
+			closeErr = errors.Wrapf(err, "Failed to stop agent on host %s", host)
+		}
+		if hostAgent.cmd != nil {
+			if err := hostAgent.cmd.Wait(); err != nil && closeErr == nil {
+				closeErr = errors.Wrapf(err, "Agent on host %s exited with an error", host)
+			}
+		}
+	}
+	return closeErr
+}
+
+/*
+ * ExecuteWithAgents generates one shell command per segment or host in scope,
+ * as with GenerateSSHCommandList, and runs them all concurrently through the
+ * pool's agents instead of spawning ssh for each command.  The generator must
+ * be a func(int) string or func(string) string, or the equivalent typed
+ * generator.  The RemoteOutput is populated as by ExecuteClusterCommand.
+ *
+ * As with ssh, each command runs through the Middleware of the cluster's
+ * GPDBExecutor, and is checked against the cluster's Guardrails, without
+ * force, failing without running if it does not pass.
+ */
+func (cluster *Cluster) ExecuteWithAgents(pool *AgentPool, scope Scope, generator interface{}) *RemoteOutput {
+	commandList, requests := cluster.generateAgentCommands(scope, generator)
+	return cluster.runWithAgents(pool, scope, commandList, requests, false)
+}
+
+/*
+ * This function behaves like ExecuteWithAgents, but checks the generated
+ * commands against the cluster's Guardrails before executing them and returns
+ * an error without executing anything if any check fails, as with
+ * GenerateAndExecuteGuardedCommand.
+ */
+func (cluster *Cluster) ExecuteGuardedWithAgents(pool *AgentPool, scope Scope, generator interface{}, force bool) (*RemoteOutput, error) {
+	commandList, requests := cluster.generateAgentCommands(scope, generator)
+	if err := cluster.Guardrails.CheckCommands(commandList, force); err != nil {
+		return nil, err
+	}
+	return cluster.runWithAgents(pool, scope, commandList, requests, force), nil
+}
+
+func (cluster *Cluster) generateAgentCommands(scope Scope, generator interface{}) ([]ShellCommand, []agentRequest) {
+	requests := make([]agentRequest, 0)
+	var commandList []ShellCommand
+	switch generateCommand := generator.(type) {
+	case func(content int) string:
+		commandList = cluster.generateAgentContentCommands(scope, generateCommand, &requests)
+	case ContentShellGenerator:
+		commandList = cluster.generateAgentContentCommands(scope, generateCommand, &requests)
+	case func(host string) string:
+		commandList = cluster.generateAgentHostCommands(scope, generateCommand, &requests)
+	case HostShellGenerator:
+		commandList = cluster.generateAgentHostCommands(scope, generateCommand, &requests)
+	default:
+		gplog.Fatal(nil, "Generator function passed to ExecuteWithAgents had an invalid function header.")
+	}
+	return commandList, requests
+}
+
+func (cluster *Cluster) runWithAgents(pool *AgentPool, scope Scope, commandList []ShellCommand, requests []agentRequest, force bool) *RemoteOutput {
+	localHost := cluster.GetHostForContent(-1)
+	var wg sync.WaitGroup
+	for i := range commandList {
+		wg.Add(1)
+		go func(index int, request agentRequest) {
+			defer wg.Done()
+			runCommand := cluster.chainForAgents(force, func(command ShellCommand) ShellCommand {
+				useLocal := request.host == localHost || scopeIsLocal(scope)
+				response, err := pool.Run(request.host, request.target, useLocal, request.command)
+				command.Completed = true
+				if err != nil {
+					command.Error = err
+					return command
+				}
+				command.Stdout = response.Stdout
+				command.Stderr = response.Stderr
+				if response.Error != "" {
+					command.Error = errors.New(response.Error)
+				} else if response.ExitCode != 0 {
+					command.Error = errors.Errorf("exit status %d", response.ExitCode)
+				}
+				return command
+			})
+			commandList[index] = runCommand(commandList[index])
+		}(i, requests[i])
+	}
+	wg.Wait()
+
+	numErrors := 0
+	for _, command := range commandList {
+		if command.Error != nil {
+			numErrors++
+		}
+	}
+	return NewRemoteOutput(scope, numErrors, commandList)
+}
+
+// chainForAgents wraps run in the cluster's Guardrails, then the Middleware of its GPDBExecutor, if any.
+func (cluster *Cluster) chainForAgents(force bool, run CommandFunc) CommandFunc {
+	if cluster.Guardrails != nil {
+		run = GuardrailMiddleware(cluster.Guardrails, force)(run)
+	}
+	if executor, ok := cluster.Executor.(*GPDBExecutor); ok {
+		run = executor.chain(run)
+	}
+	return run
+}
+
+type agentRequest struct {
+	host    string
+	target  string
+	command string
+}
+
+func (cluster *Cluster) generateAgentContentCommands(scope Scope, generator ContentShellGenerator, requests *[]agentRequest) []ShellCommand {
+	return cluster.GenerateContentCommandList(scope, func(content int) []string {
+		request := agentRequest{
+			host:    cluster.GetHostForContent(content),
+			target:  cluster.getTargetForContent(content, cluster.Target),
+			command: generator(content),
+		}
+		*requests = append(*requests, request)
+		return []string{"bash", "-c", request.command}
+	})
+}
+
+func (cluster *Cluster) generateAgentHostCommands(scope Scope, generator HostShellGenerator, requests *[]agentRequest) []ShellCommand {
+	return cluster.GenerateHostCommandList(scope, func(host string) []string {
+		request := agentRequest{
+			host:    host,
+			target:  cluster.getTargetForHost(host, cluster.Target),
+			command: generator(host),
+		}
+		*requests = append(*requests, request)
+		return []string{"bash", "-c", request.command}
+	})
+}
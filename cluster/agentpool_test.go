@@ -0,0 +1,132 @@
+package cluster_test
+
+import (
+	"io"
+	"sync"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+	"github.com/cloudberrydb/gp-common-go-libs/cluster/agent"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/agentpool tests", func() {
+	var (
+		pool        *cluster.AgentPool
+		dialed      []string
+		targets     []string
+		dialMutex   sync.Mutex
+		dialErr     error
+		testCluster *cluster.Cluster
+	)
+	BeforeEach(func() {
+		dialed = []string{}
+		targets = []string{}
+		dialErr = nil
+		pool = cluster.NewAgentPool("/usr/local/bin/gpagent", cluster.SSHOptions{})
+		pool.Dial = func(host string, target string, useLocal bool) (io.WriteCloser, io.ReadCloser, error) {
+			dialMutex.Lock()
+			dialed = append(dialed, host)
+			targets = append(targets, target)
+			dialMutex.Unlock()
+			if dialErr != nil {
+				return nil, nil, dialErr
+			}
+			requestR, requestW := io.Pipe()
+			responseR, responseW := io.Pipe()
+			go func() {
+				_ = agent.Serve(requestR, responseW)
+				responseW.Close()
+			}()
+			return requestW, responseR, nil
+		}
+		testCluster = cluster.NewCluster([]cluster.SegConfig{
+			{DbID: 1, ContentID: -1, Role: "p", Port: 5432, Hostname: "localhost", DataDir: "/data/gpseg-1"},
+			{DbID: 2, ContentID: 0, Role: "p", Port: 20000, Hostname: "localhost", DataDir: "/data/gpseg0"},
+			{DbID: 3, ContentID: 1, Role: "p", Port: 20001, Hostname: "remotehost1", DataDir: "/data/gpseg1"},
+			{DbID: 4, ContentID: 2, Role: "p", Port: 20002, Hostname: "remotehost1", DataDir: "/data/gpseg2"},
+		})
+	})
+	AfterEach(func() {
+		Expect(pool.Close()).To(Succeed())
+	})
+	It("starts one agent per host and runs each command through it", func() {
+		output := testCluster.ExecuteWithAgents(pool, cluster.ON_SEGMENTS, func(content int) string {
+			return "echo content " + []string{"0", "1", "2"}[content]
+		})
+		Expect(output.NumErrors).To(Equal(0))
+		Expect(output.Commands).To(HaveLen(3))
+		Expect(output.Commands[0].Stdout).To(Equal("content 0\n"))
+		Expect(output.Commands[2].Stdout).To(Equal("content 2\n"))
+		Expect(output.Commands[2].Completed).To(BeTrue())
+		Expect(dialed).To(ConsistOf("localhost", "remotehost1"))
+	})
+	It("records failed commands", func() {
+		output := testCluster.ExecuteWithAgents(pool, cluster.ON_HOSTS, cluster.HostShellGenerator(func(host string) string {
+			if host == "remotehost1" {
+				return "echo failed >&2; exit 1"
+			}
+			return "true"
+		}))
+		Expect(output.NumErrors).To(Equal(1))
+		Expect(output.FailedCommands).To(HaveLen(1))
+		Expect(output.FailedCommands[0].Host).To(Equal("remotehost1"))
+		Expect(output.FailedCommands[0].Stderr).To(Equal("failed\n"))
+		Expect(output.FailedCommands[0].Error).To(MatchError("exit status 1"))
+	})
+	It("reports an error for every command on a host whose agent cannot be started", func() {
+		dialErr = errors.New("connection refused")
+		output := testCluster.ExecuteWithAgents(pool, cluster.ON_SEGMENTS, func(content int) string { return "true" })
+		Expect(output.NumErrors).To(Equal(3))
+		Expect(output.FailedCommands[0].Error).To(MatchError("Failed to start agent on host localhost: connection refused"))
+		Expect(dialed).To(HaveLen(2))
+	})
+	It("dials each host by address when the cluster targets addresses", func() {
+		testCluster.Segments[2].Address = "10.0.0.1"
+		testCluster.Segments[3].Address = "10.0.0.1"
+		testCluster.Target = cluster.TARGET_ADDRESS
+		output := testCluster.ExecuteWithAgents(pool, cluster.ON_SEGMENTS, func(content int) string { return "true" })
+		Expect(output.NumErrors).To(Equal(0))
+		Expect(dialed).To(ConsistOf("localhost", "remotehost1"))
+		Expect(targets).To(ConsistOf("localhost", "10.0.0.1"))
+	})
+	It("runs each command through the executor's middleware", func() {
+		testCluster.Executor.(*cluster.GPDBExecutor).Use(cluster.DryRunMiddleware())
+		output := testCluster.ExecuteWithAgents(pool, cluster.ON_SEGMENTS, func(content int) string { return "echo ran" })
+		Expect(output.NumErrors).To(Equal(0))
+		Expect(output.Commands[0].Completed).To(BeTrue())
+		Expect(output.Commands[0].Stdout).To(BeEmpty())
+		Expect(dialed).To(BeEmpty())
+	})
+	It("fails commands that do not pass the cluster's guardrails without running them", func() {
+		testCluster.Guardrails = cluster.NewGuardrails(cluster.RequireForce{})
+		output := testCluster.ExecuteWithAgents(pool, cluster.ON_SEGMENTS, func(content int) string {
+			if content == 1 {
+				return "rm -rf /data/gpseg1"
+			}
+			return "true"
+		})
+		Expect(output.NumErrors).To(Equal(1))
+		Expect(output.FailedCommands[0].Content).To(Equal(1))
+		Expect(output.FailedCommands[0].Error).To(MatchError(ContainSubstring("--force")))
+		Expect(dialed).To(ConsistOf("localhost", "remotehost1"))
+	})
+	Describe("ExecuteGuardedWithAgents", func() {
+		It("runs nothing if any command does not pass the cluster's guardrails", func() {
+			testCluster.Guardrails = cluster.NewGuardrails(cluster.RequireForce{})
+			generator := func(content int) string { return "rm -rf /data/gpseg" + []string{"0", "1", "2"}[content] + "/pg_log" }
+			output, err := testCluster.ExecuteGuardedWithAgents(pool, cluster.ON_SEGMENTS, generator, false)
+			Expect(err).To(MatchError(ContainSubstring("--force")))
+			Expect(output).To(BeNil())
+			Expect(dialed).To(BeEmpty())
+		})
+		It("runs the commands with force", func() {
+			testCluster.Guardrails = cluster.NewGuardrails(cluster.RequireForce{})
+			output, err := testCluster.ExecuteGuardedWithAgents(pool, cluster.ON_SEGMENTS, func(content int) string { return "rm -f /nonexistent/file" }, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(output.NumErrors).To(Equal(0))
+		})
+	})
+})
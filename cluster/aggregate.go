@@ -0,0 +1,93 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to summarizing the output
+ * of a cluster command across many hosts or segments.
+ */
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+ * An OutputGroup holds every successful command in a RemoteOutput that
+ * produced the same Stdout, ignoring leading and trailing whitespace.  Hosts
+ * is set for per-host commands and Contents for per-segment commands.
+ */
+type OutputGroup struct {
+	Stdout   string
+	Commands []ShellCommand
+	Hosts    []string
+	Contents []int
+}
+
+/*
+ * GroupByStdout groups the successful commands in the output by their Stdout,
+ * which makes it easy to find the few hosts that differ from the rest when
+ * verifying e.g. a version string or checksum.  The largest group is first,
+ * and groups of the same size are ordered by Stdout.  Failed commands are not
+ * included, as they are already listed in FailedCommands.
+ */
+func (remoteOutput *RemoteOutput) GroupByStdout() []OutputGroup {
+	groupsByStdout := make(map[string]*OutputGroup)
+	for _, command := range remoteOutput.Commands {
+		if command.Error != nil {
+			continue
+		}
+		stdout := strings.TrimSpace(command.Stdout)
+		group, ok := groupsByStdout[stdout]
+		if !ok {
+			group = &OutputGroup{Stdout: stdout, Commands: []ShellCommand{}, Hosts: []string{}, Contents: []int{}}
+			groupsByStdout[stdout] = group
+		}
+		group.Commands = append(group.Commands, command)
+		if scopeIsHosts(command.Scope) {
+			group.Hosts = append(group.Hosts, command.Host)
+		} else {
+			group.Contents = append(group.Contents, command.Content)
+		}
+	}
+	groups := make([]OutputGroup, 0, len(groupsByStdout))
+	for _, group := range groupsByStdout {
+		sort.Strings(group.Hosts)
+		sort.Ints(group.Contents)
+		groups = append(groups, *group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if len(groups[i].Commands) != len(groups[j].Commands) {
+			return len(groups[i].Commands) > len(groups[j].Commands)
+		}
+		return groups[i].Stdout < groups[j].Stdout
+	})
+	return groups
+}
+
+/*
+ * SummarizeStdout returns a one-line summary of GroupByStdout, such as
+ *
+ *   42 hosts returned "7.1.0", 2 hosts returned "7.0.0"
+ *
+ * or "No commands succeeded" if every command failed.
+ */
+func (remoteOutput *RemoteOutput) SummarizeStdout() string {
+	groups := remoteOutput.GroupByStdout()
+	if len(groups) == 0 {
+		return "No commands succeeded"
+	}
+	noun := "segment"
+	if scopeIsHosts(remoteOutput.Scope) {
+		noun = "host"
+	}
+	summaries := make([]string, 0, len(groups))
+	for _, group := range groups {
+		count := len(group.Commands)
+		plural := "s"
+		if count == 1 {
+			plural = ""
+		}
+		summaries = append(summaries, fmt.Sprintf("%d %s%s returned %q", count, noun, plural, group.Stdout))
+	}
+	return strings.Join(summaries, ", ")
+}
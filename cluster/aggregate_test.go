@@ -0,0 +1,71 @@
+package cluster_test
+
+import (
+	"errors"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/aggregate tests", func() {
+	hostCommand := func(host string, stdout string) cluster.ShellCommand {
+		command := cluster.NewShellCommand(cluster.ON_HOSTS, -2, host, []string{"cat", "/etc/version"})
+		command.Stdout = stdout
+		return command
+	}
+	segCommand := func(content int, stdout string) cluster.ShellCommand {
+		command := cluster.NewShellCommand(cluster.ON_SEGMENTS, content, "", []string{"md5sum", "file"})
+		command.Stdout = stdout
+		return command
+	}
+	Describe("GroupByStdout", func() {
+		It("groups hosts by identical output, largest group first", func() {
+			failed := hostCommand("host5", "")
+			failed.Error = errors.New("exit status 1")
+			output := cluster.NewRemoteOutput(cluster.ON_HOSTS, 1, []cluster.ShellCommand{
+				hostCommand("host3", "7.0.0\n"),
+				hostCommand("host2", "7.1.0\n"),
+				hostCommand("host1", "7.1.0"),
+				failed,
+			})
+			groups := output.GroupByStdout()
+			Expect(groups).To(HaveLen(2))
+			Expect(groups[0].Stdout).To(Equal("7.1.0"))
+			Expect(groups[0].Hosts).To(Equal([]string{"host1", "host2"}))
+			Expect(groups[0].Contents).To(BeEmpty())
+			Expect(groups[1].Stdout).To(Equal("7.0.0"))
+			Expect(groups[1].Hosts).To(Equal([]string{"host3"}))
+		})
+		It("groups segments by content", func() {
+			output := cluster.NewRemoteOutput(cluster.ON_SEGMENTS, 0, []cluster.ShellCommand{
+				segCommand(1, "abc"),
+				segCommand(0, "abc"),
+			})
+			groups := output.GroupByStdout()
+			Expect(groups).To(HaveLen(1))
+			Expect(groups[0].Contents).To(Equal([]int{0, 1}))
+		})
+	})
+	Describe("SummarizeStdout", func() {
+		It("summarizes the output of each group", func() {
+			output := cluster.NewRemoteOutput(cluster.ON_HOSTS, 0, []cluster.ShellCommand{
+				hostCommand("host1", "7.1.0"),
+				hostCommand("host2", "7.1.0"),
+				hostCommand("host3", "7.0.0"),
+			})
+			Expect(output.SummarizeStdout()).To(Equal(`2 hosts returned "7.1.0", 1 host returned "7.0.0"`))
+		})
+		It("uses segments for per-segment output", func() {
+			output := cluster.NewRemoteOutput(cluster.ON_SEGMENTS, 0, []cluster.ShellCommand{segCommand(0, "abc"), segCommand(1, "abc")})
+			Expect(output.SummarizeStdout()).To(Equal(`2 segments returned "abc"`))
+		})
+		It("reports when no commands succeeded", func() {
+			failed := hostCommand("host1", "")
+			failed.Error = errors.New("exit status 1")
+			output := cluster.NewRemoteOutput(cluster.ON_HOSTS, 1, []cluster.ShellCommand{failed})
+			Expect(output.SummarizeStdout()).To(Equal("No commands succeeded"))
+		})
+	})
+})
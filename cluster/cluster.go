@@ -11,20 +11,24 @@ import (
 	"context"
 	joinerrs "errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
 	"github.com/cloudberrydb/gp-common-go-libs/gplog"
-	"github.com/cloudberrydb/gp-common-go-libs/operating"
 	"github.com/pkg/errors"
 )
 
+// The logger for messages from this package, whose level can be set with gplog.SetLevelFor("cluster", ...).
+var log = gplog.Component("cluster")
+
 type Executor interface {
 	ExecuteLocalCommand(commandStr string) (string, error)
 	ExecuteLocalCommandWithContext(commandStr string, ctx context.Context) (string, error)
@@ -32,8 +36,14 @@ type Executor interface {
 	ExecuteClusterCommandWithRetries(scope Scope, commandList []ShellCommand, maxAttempts int, retrySleep time.Duration) *RemoteOutput
 }
 
-// This type only exists to allow us to mock Execute[...]Command functions for testing
-type GPDBExecutor struct{}
+/*
+ * This type exists to allow us to mock Execute[...]Command functions for testing,
+ * and to hold any Middleware to run around each command it executes; see
+ * middleware.go for details.
+ */
+type GPDBExecutor struct {
+	Middleware []Middleware
+}
 
 /*
  * A Cluster object stores information about the cluster in three ways:
@@ -52,6 +62,20 @@ type Cluster struct {
 	ByContent  map[int][]*SegConfig
 	ByHost     map[string][]*SegConfig
 	Executor
+	Guardrails *Guardrails
+	SSHOptions SSHOptions
+	Target     TargetSelection
+	// The number of attempts GenerateAndExecuteCommand makes for each command; 5 if unset.
+	SyncRetries int
+	// Whether per-host commands include the coordinator and standby hosts; see HostInclusion.
+	CoordinatorHostInclusion HostInclusion
+	StandbyHostInclusion     HostInclusion
+
+	// The middleware added to the executor by ApplyGpsshConfig, if any.
+	gpsshStagger *stagger
+
+	mutex                 sync.RWMutex
+	preferredRoleOrdering bool
 }
 
 type SegConfig struct {
@@ -162,6 +186,49 @@ func scopeIncludesMirrors(scope Scope) bool {
 	return scope&INCLUDE_MIRRORS == INCLUDE_MIRRORS
 }
 
+const allScopeBits = ON_HOSTS | INCLUDE_COORDINATOR | ON_LOCAL | INCLUDE_MIRRORS
+
+/*
+ * String returns every component of the scope, including the zero-valued
+ * ones, e.g. "ON_HOSTS|INCLUDE_COORDINATOR|ON_REMOTE|EXCLUDE_MIRRORS", so
+ * that a scope printed in a log or error message is unambiguous.
+ */
+func (scope Scope) String() string {
+	parts := []string{"ON_SEGMENTS", "EXCLUDE_COORDINATOR", "ON_REMOTE", "EXCLUDE_MIRRORS"}
+	if scopeIsHosts(scope) {
+		parts[0] = "ON_HOSTS"
+	}
+	if scopeIncludesCoordinator(scope) {
+		parts[1] = "INCLUDE_COORDINATOR"
+	}
+	if scopeIsLocal(scope) {
+		parts[2] = "ON_LOCAL"
+	}
+	if scopeIncludesMirrors(scope) {
+		parts[3] = "INCLUDE_MIRRORS"
+	}
+	if unknown := scope &^ allScopeBits; unknown != 0 {
+		parts = append(parts, fmt.Sprintf("UNKNOWN(%#x)", uint8(unknown)))
+	}
+	return strings.Join(parts, "|")
+}
+
+/*
+ * Validate returns an error if the scope sets bits that do not correspond to
+ * any scope constant, or if it combines INCLUDE_MIRRORS with ON_SEGMENTS.
+ * Per-segment commands are generated once per content, so that combination
+ * would silently run nothing on the mirrors.
+ */
+func (scope Scope) Validate() error {
+	if unknown := scope &^ allScopeBits; unknown != 0 {
+		return errors.Errorf("Invalid scope %s: bits %#x do not correspond to any scope", scope, uint8(unknown))
+	}
+	if scopeIsSegments(scope) && scopeIncludesMirrors(scope) {
+		return errors.Errorf("Invalid scope %s: INCLUDE_MIRRORS is only supported with ON_HOSTS", scope)
+	}
+	return nil
+}
+
 /*
  * A ShellCommand stores a command to be executed (in both executable and
  * display form), as well as the results of the command execution and the
@@ -205,6 +272,8 @@ type RemoteOutput struct {
 	Commands        []ShellCommand
 	FailedCommands  []ShellCommand
 	RetriedCommands []ShellCommand
+	// Set by SyncDirectory, keyed by host; nil for other commands.
+	TransferStats map[string]TransferStats
 }
 
 func NewRemoteOutput(scope Scope, numErrors int, commands []ShellCommand) *RemoteOutput {
@@ -230,18 +299,63 @@ func NewRemoteOutput(scope Scope, numErrors int, commands []ShellCommand) *Remot
  * Base cluster functions
  */
 
-func NewCluster(segConfigs []SegConfig) *Cluster {
+/*
+ * NewCluster accepts ClusterOptions to customize the cluster; see options.go.
+ * If any hostname normalizers are given, Segments holds a normalized copy of
+ * segConfigs rather than segConfigs itself.
+ */
+func NewCluster(segConfigs []SegConfig, opts ...ClusterOption) *Cluster {
+	options := clusterOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if len(options.hostnameNormalizers) > 0 {
+		normalized := make([]SegConfig, len(segConfigs))
+		for i, seg := range segConfigs {
+			for _, normalize := range options.hostnameNormalizers {
+				seg.Hostname = normalize(seg.Hostname)
+			}
+			normalized[i] = seg
+		}
+		segConfigs = normalized
+	}
+	if options.strictValidation {
+		gplog.FatalOnError(ValidateSegConfigs(segConfigs))
+	}
+
 	cluster := Cluster{}
+	cluster.Executor = &GPDBExecutor{}
+	if options.executor != nil {
+		cluster.Executor = options.executor
+	}
+	cluster.preferredRoleOrdering = options.preferredRoleOrdering
+	cluster.CoordinatorHostInclusion = options.coordinatorHostInclusion
+	cluster.StandbyHostInclusion = options.standbyHostInclusion
+	cluster.index(segConfigs)
+	return &cluster
+}
+
+/*
+ * index sets Segments to the given segments and rebuilds the lookup maps and
+ * lists from them.  The caller must hold the write lock, unless the cluster has
+ * not yet been returned from NewCluster.
+ */
+func (cluster *Cluster) index(segConfigs []SegConfig) {
 	cluster.Segments = segConfigs
 	cluster.ByContent = make(map[int][]*SegConfig, 0)
 	cluster.ByHost = make(map[string][]*SegConfig, 0)
-	cluster.Executor = &GPDBExecutor{}
+	cluster.Hostnames = nil
+	cluster.ContentIDs = nil
 
 	for i := range cluster.Segments {
 		segment := &cluster.Segments[i]
 		cluster.ByContent[segment.ContentID] = append(cluster.ByContent[segment.ContentID], segment)
 		segmentList := cluster.ByContent[segment.ContentID]
-		if len(segmentList) == 2 && segmentList[0].Role == "m" {
+		if cluster.preferredRoleOrdering {
+			if len(segmentList) == 2 && segmentList[0].PreferredRole == "m" {
+				segmentList[0], segmentList[1] = segmentList[1], segmentList[0]
+			}
+		} else if len(segmentList) == 2 && segmentList[0].Role == "m" {
 			/*
 			 * GetSegmentConfiguration always returns primaries before mirrors,
 			 * but we can't guarantee the []SegConfig passed in was created by
@@ -258,13 +372,14 @@ func NewCluster(segConfigs []SegConfig) *Cluster {
 		cluster.ContentIDs = append(cluster.ContentIDs, content)
 	}
 	sort.Ints(cluster.ContentIDs)
-	return &cluster
 }
 
 /*
  * Because cluster commands can be executed either per-segment or per-host, the
- * "generator" argument to this function can accept one of two types:
+ * "generator" argument to this function can accept one of three types:
  * - func(int) []string, which takes a content id, for per-segment commands
+ * - func(SegConfig) []string, which takes a segment, for per-segment commands
+ *   that need more than the content id
  * - func(string) []string, which takes a hostname, for per-host commands
  * The function uses a type switch to identify the right one, and panics if
  * an invalid function type is passed in via programmer error.
@@ -273,67 +388,237 @@ func NewCluster(segConfigs []SegConfig) *Cluster {
  * content and hostname regardless of scope or using some sort of helper struct.
  */
 func (cluster *Cluster) GenerateCommandList(scope Scope, generator interface{}) []ShellCommand {
-	commands := []ShellCommand{}
 	switch generateCommand := generator.(type) {
 	case func(content int) []string:
-		for _, content := range cluster.ContentIDs {
-			if content == -1 && scopeExcludesCoordinator(scope) {
-				continue
-			}
-			commands = append(commands, NewShellCommand(scope, content, "", generateCommand(content)))
-		}
+		return cluster.GenerateContentCommandList(scope, generateCommand)
+	case ContentGenerator:
+		return cluster.GenerateContentCommandList(scope, generateCommand)
+	case func(seg SegConfig) []string:
+		return cluster.GenerateSegmentCommandList(scope, generateCommand)
+	case SegmentGenerator:
+		return cluster.GenerateSegmentCommandList(scope, generateCommand)
 	case func(host string) []string:
-		for _, host := range cluster.Hostnames {
-			hostHasOneContent := len(cluster.GetContentsForHost(host)) == 1
-			if host == cluster.GetHostForContent(-1, "p") && scopeExcludesCoordinator(scope) && hostHasOneContent {
-				// Only exclude the coordinator host if there are no local segments
-				continue
-			}
-			if host == cluster.GetHostForContent(-1, "m") && scopeExcludesMirrors(scope) && hostHasOneContent {
-				// Only exclude the standby coordinator host if there are no segments there
-				continue
-			}
-			commands = append(commands, NewShellCommand(scope, -2, host, generateCommand(host)))
-		}
+		return cluster.GenerateHostCommandList(scope, generateCommand)
+	case HostGenerator:
+		return cluster.GenerateHostCommandList(scope, generateCommand)
 	default:
 		gplog.Fatal(nil, "Generator function passed to GenerateCommandList had an invalid function header.")
 	}
+	return []ShellCommand{}
+}
+
+/*
+ * The typed generators below are the preferred way to generate commands, as
+ * passing the wrong kind of function is caught at compile time instead of at
+ * runtime.  GenerateCommandList and GenerateSSHCommandList remain for backwards
+ * compatibility, and simply dispatch to these functions.
+ */
+type ContentGenerator func(content int) []string
+type SegmentGenerator func(seg SegConfig) []string
+type HostGenerator func(host string) []string
+type ContentShellGenerator func(content int) string
+type HostShellGenerator func(host string) string
+
+func (cluster *Cluster) GenerateContentCommandList(scope Scope, generator ContentGenerator) []ShellCommand {
+	cluster.mutex.RLock()
+	contentIDs := append([]int{}, cluster.ContentIDs...)
+	cluster.mutex.RUnlock()
+
+	commands := []ShellCommand{}
+	for _, content := range contentIDs {
+		if content == -1 && scopeExcludesCoordinator(scope) {
+			continue
+		}
+		commands = append(commands, NewShellCommand(scope, content, "", generator(content)))
+	}
 	return commands
 }
 
-func ConstructSSHCommand(useLocal bool, host string, cmd string) []string {
-	if useLocal {
-		return []string{"bash", "-c", cmd}
+/*
+ * GenerateSegmentCommandList passes the generator the segment that
+ * GetDbidForContent and related functions would return for each content, so
+ * that it can use the segment's port, data directory, and so on directly.
+ */
+func (cluster *Cluster) GenerateSegmentCommandList(scope Scope, generator SegmentGenerator) []ShellCommand {
+	commands := []ShellCommand{}
+	for _, seg := range cluster.segmentsInScope(scope) {
+		commands = append(commands, NewShellCommand(scope, seg.ContentID, "", generator(seg)))
+	}
+	return commands
+}
+
+func (cluster *Cluster) segmentsInScope(scope Scope) []SegConfig {
+	cluster.mutex.RLock()
+	defer cluster.mutex.RUnlock()
+	segments := make([]SegConfig, 0, len(cluster.ContentIDs))
+	for _, content := range cluster.ContentIDs {
+		if content == -1 && scopeExcludesCoordinator(scope) {
+			continue
+		}
+		if seg := getSegmentByRole(cluster.ByContent[content]); seg != nil {
+			segments = append(segments, *seg)
+		}
+	}
+	return segments
+}
+
+func (cluster *Cluster) GenerateHostCommandList(scope Scope, generator HostGenerator) []ShellCommand {
+	commands := []ShellCommand{}
+	for _, host := range cluster.hostsInScope(scope) {
+		commands = append(commands, NewShellCommand(scope, -2, host, generator(host)))
+	}
+	return commands
+}
+
+/*
+ * The generator may itself call accessor functions, so the hosts are chosen
+ * under the read lock but the generator is called after releasing it.
+ */
+func (cluster *Cluster) hostsInScope(scope Scope) []string {
+	cluster.mutex.RLock()
+	defer cluster.mutex.RUnlock()
+	coordinatorHost, standbyHost := "", ""
+	if seg := getSegmentByRole(cluster.ByContent[-1], "p"); seg != nil {
+		coordinatorHost = seg.Hostname
+	}
+	if seg := getSegmentByRole(cluster.ByContent[-1], "m"); seg != nil {
+		standbyHost = seg.Hostname
+	}
+	hosts := make([]string, 0, len(cluster.Hostnames))
+	for _, host := range cluster.Hostnames {
+		hostHasOneContent := len(cluster.ByHost[host]) == 1
+		if host == coordinatorHost && !cluster.CoordinatorHostInclusion.includes(scopeExcludesCoordinator(scope), hostHasOneContent) {
+			continue
+		}
+		if host == standbyHost && !cluster.StandbyHostInclusion.includes(scopeExcludesMirrors(scope), hostHasOneContent) {
+			continue
+		}
+		hosts = append(hosts, host)
 	}
-	currentUser, _ := operating.System.CurrentUser()
-	user := currentUser.Username
-	return []string{"ssh", "-o", "StrictHostKeyChecking=no", fmt.Sprintf("%s@%s", user, host), cmd}
+	return hosts
+}
+
+/*
+ * A HostInclusion determines whether per-host commands are generated for the
+ * coordinator host or the standby coordinator host:
+ *
+ * HOST_INCLUSION_DEFAULT: The host is excluded if the scope excludes it
+ *                         (EXCLUDE_COORDINATOR or EXCLUDE_MIRRORS, respectively)
+ *                         but only if no segments are colocated on it, so that
+ *                         on a single-host cluster it is always included.
+ * HOST_INCLUSION_SCOPE:   The host is excluded whenever the scope excludes it,
+ *                         even if segments are colocated on it.
+ * HOST_INCLUSION_ALWAYS:  The host is always included.
+ * HOST_INCLUSION_NEVER:   The host is never included.
+ */
+type HostInclusion int
+
+const (
+	HOST_INCLUSION_DEFAULT HostInclusion = iota
+	HOST_INCLUSION_SCOPE
+	HOST_INCLUSION_ALWAYS
+	HOST_INCLUSION_NEVER
+)
+
+func (inclusion HostInclusion) includes(scopeExcludesHost bool, hostHasOneContent bool) bool {
+	switch inclusion {
+	case HOST_INCLUSION_SCOPE:
+		return !scopeExcludesHost
+	case HOST_INCLUSION_ALWAYS:
+		return true
+	case HOST_INCLUSION_NEVER:
+		return false
+	default:
+		return !scopeExcludesHost || !hostHasOneContent
+	}
+}
+
+func ConstructSSHCommand(useLocal bool, host string, cmd string) []string {
+	return ConstructSSHCommandWithOptions(useLocal, host, cmd, SSHOptions{})
 }
 
+/*
+ * A TargetSelection determines which SegConfig field is used as the ssh target
+ * when dispatching commands to remote hosts.  Hostname is used by default, but
+ * on multi-NIC clusters the interconnect Address may be the one reachable from
+ * the coordinator.  If a segment has no Address, its Hostname is used instead.
+ */
+type TargetSelection int
+
+const (
+	TARGET_HOSTNAME TargetSelection = iota
+	TARGET_ADDRESS
+)
+
 /*
  * This function essentially wraps GenerateCommandList such that commands to be
  * executed on other hosts are sent through SSH and local commands use Bash.
  */
 func (cluster *Cluster) GenerateSSHCommandList(scope Scope, generator interface{}) []ShellCommand {
+	return cluster.GenerateSSHCommandListWithTarget(scope, cluster.Target, generator)
+}
+
+/*
+ * This function is identical to GenerateSSHCommandList, but overrides the
+ * cluster's Target for this call only.  Per-host generators are still passed
+ * the hostname; only the ssh destination changes.
+ */
+func (cluster *Cluster) GenerateSSHCommandListWithTarget(scope Scope, target TargetSelection, generator interface{}) []ShellCommand {
 	var commands []ShellCommand
-	localHost := cluster.GetHostForContent(-1)
 	switch generateCommand := generator.(type) {
 	case func(content int) string:
-		commands = cluster.GenerateCommandList(scope, func(content int) []string {
-			useLocal := (cluster.GetHostForContent(content) == localHost || scopeIsLocal(scope))
-			cmd := generateCommand(content)
-			return ConstructSSHCommand(useLocal, cluster.GetHostForContent(content), cmd)
-		})
+		commands = cluster.GenerateContentSSHCommandList(scope, target, generateCommand)
+	case ContentShellGenerator:
+		commands = cluster.GenerateContentSSHCommandList(scope, target, generateCommand)
 	case func(host string) string:
-		commands = cluster.GenerateCommandList(scope, func(host string) []string {
-			useLocal := (host == localHost || scopeIsLocal(scope))
-			cmd := generateCommand(host)
-			return ConstructSSHCommand(useLocal, host, cmd)
-		})
+		commands = cluster.GenerateHostSSHCommandList(scope, target, generateCommand)
+	case HostShellGenerator:
+		commands = cluster.GenerateHostSSHCommandList(scope, target, generateCommand)
 	}
 	return commands
 }
 
+func (cluster *Cluster) GenerateContentSSHCommandList(scope Scope, target TargetSelection, generator ContentShellGenerator) []ShellCommand {
+	localHost := cluster.GetHostForContent(-1)
+	return cluster.GenerateContentCommandList(scope, func(content int) []string {
+		useLocal := (cluster.GetHostForContent(content) == localHost || scopeIsLocal(scope))
+		cmd := generator(content)
+		options := cluster.SSHOptions.forHost(cluster.GetHostForContent(content))
+		return ConstructSSHCommandWithOptions(useLocal, cluster.getTargetForContent(content, target), cmd, options)
+	})
+}
+
+func (cluster *Cluster) GenerateHostSSHCommandList(scope Scope, target TargetSelection, generator HostShellGenerator) []ShellCommand {
+	localHost := cluster.GetHostForContent(-1)
+	return cluster.GenerateHostCommandList(scope, func(host string) []string {
+		useLocal := (host == localHost || scopeIsLocal(scope))
+		cmd := generator(host)
+		return ConstructSSHCommandWithOptions(useLocal, cluster.getTargetForHost(host, target), cmd, cluster.SSHOptions.forHost(host))
+	})
+}
+
+func (cluster *Cluster) getTargetForContent(contentID int, target TargetSelection) string {
+	if target == TARGET_ADDRESS {
+		if address := cluster.GetAddressForContent(contentID); address != "" {
+			return address
+		}
+	}
+	return cluster.GetHostForContent(contentID)
+}
+
+func (cluster *Cluster) getTargetForHost(hostname string, target TargetSelection) string {
+	if target == TARGET_ADDRESS {
+		cluster.mutex.RLock()
+		defer cluster.mutex.RUnlock()
+		for _, seg := range cluster.ByHost[hostname] {
+			if seg.Address != "" {
+				return seg.Address
+			}
+		}
+	}
+	return hostname
+}
+
 func (executor *GPDBExecutor) ExecuteLocalCommand(commandStr string) (string, error) {
 	output, err := exec.Command("bash", "-c", commandStr).CombinedOutput()
 	return string(output), err
@@ -369,34 +654,12 @@ func (executor *GPDBExecutor) ExecuteClusterCommandWithRetries(scope Scope, comm
 	length := len(commandList)
 	finished := make(chan int)
 	numErrors := 0
+	runCommand := executor.chain(func(command ShellCommand) ShellCommand {
+		return runWithRetries(command, maxAttempts, retrySleep)
+	})
 	for i := range commandList {
 		go func(index int) {
-			var (
-				out    []byte
-				err    error
-				stderr bytes.Buffer
-			)
-			command := commandList[index]
-			for attempt := 1; attempt <= maxAttempts; attempt++ {
-				stderr.Reset()
-				cmd := resetCmd(command.Command)
-				cmd.Stderr = &stderr
-				out, err = cmd.Output()
-				if err == nil {
-					break
-				} else {
-					newRetryErr := fmt.Errorf("attempt %d: error was %w: %s", attempt, err, stderr.String())
-					command.RetryError = joinerrs.Join(command.RetryError, newRetryErr)
-					if attempt != maxAttempts {
-						time.Sleep(retrySleep)
-					}
-				}
-			}
-			command.Stdout = string(out)
-			command.Stderr = stderr.String()
-			command.Error = err
-			command.Completed = true
-			commandList[index] = command
+			commandList[index] = runCommand(commandList[index])
 			finished <- index
 		}(i)
 	}
@@ -409,6 +672,42 @@ func (executor *GPDBExecutor) ExecuteClusterCommandWithRetries(scope Scope, comm
 	return NewRemoteOutput(scope, numErrors, commandList)
 }
 
+func runWithRetries(command ShellCommand, maxAttempts int, retrySleep time.Duration) ShellCommand {
+	return runWithRetriesNotify(command, maxAttempts, retrySleep, nil)
+}
+
+// If onAttempt is not nil, it is called with the attempt number before each attempt.
+func runWithRetriesNotify(command ShellCommand, maxAttempts int, retrySleep time.Duration, onAttempt func(attempt int)) ShellCommand {
+	var (
+		out    []byte
+		err    error
+		stderr bytes.Buffer
+	)
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if onAttempt != nil {
+			onAttempt(attempt)
+		}
+		stderr.Reset()
+		cmd := resetCmd(command.Command)
+		cmd.Stderr = &stderr
+		out, err = cmd.Output()
+		if err == nil {
+			break
+		} else {
+			newRetryErr := fmt.Errorf("attempt %d: error was %w: %s", attempt, err, stderr.String())
+			command.RetryError = joinerrs.Join(command.RetryError, newRetryErr)
+			if attempt != maxAttempts {
+				time.Sleep(retrySleep)
+			}
+		}
+	}
+	command.Stdout = string(out)
+	command.Stderr = stderr.String()
+	command.Error = err
+	command.Completed = true
+	return command
+}
+
 /*
  * GenerateAndExecuteCommand and CheckClusterError are generic wrapper functions
  * to simplify execution of...
@@ -418,9 +717,16 @@ func (executor *GPDBExecutor) ExecuteClusterCommandWithRetries(scope Scope, comm
  *    - e.g. running multiple scps on coordinator to push a file to all segments
  */
 func (cluster *Cluster) GenerateAndExecuteCommand(verboseMsg string, scope Scope, generator interface{}) *RemoteOutput {
-	gplog.Verbose(verboseMsg)
+	log.Verbose(verboseMsg)
 	commandList := cluster.GenerateSSHCommandList(scope, generator)
-	return cluster.ExecuteClusterCommandWithRetries(scope, commandList, 5, 1*time.Second)
+	return cluster.ExecuteClusterCommandWithRetries(scope, commandList, cluster.maxAttempts(), 1*time.Second)
+}
+
+func (cluster *Cluster) maxAttempts() int {
+	if cluster.SyncRetries > 0 {
+		return cluster.SyncRetries
+	}
+	return 5
 }
 
 func (cluster *Cluster) CheckClusterError(remoteOutput *RemoteOutput, finalErrMsg string, messageFunc interface{}, noFatal ...bool) {
@@ -429,12 +735,12 @@ func (cluster *Cluster) CheckClusterError(remoteOutput *RemoteOutput, finalErrMs
 		case func(content int) string:
 			content := retriedCommand.Content
 			host := cluster.GetHostForContent(content)
-			gplog.Debug("Command failed before passing on segment %d on host %s with error:\n%v", content, host, retriedCommand.RetryError)
+			log.Debug("Command failed before passing on segment %d on host %s with error:\n%v", content, host, retriedCommand.RetryError)
 		case func(host string) string:
 			host := retriedCommand.Host
-			gplog.Debug("Command failed before passing on host %s with error:\n%v", host, retriedCommand.RetryError)
+			log.Debug("Command failed before passing on host %s with error:\n%v", host, retriedCommand.RetryError)
 		}
-		gplog.Debug("Command was: %s", retriedCommand.CommandString)
+		log.Debug("Command was: %s", retriedCommand.CommandString)
 	}
 
 	if remoteOutput.NumErrors == 0 {
@@ -446,16 +752,16 @@ func (cluster *Cluster) CheckClusterError(remoteOutput *RemoteOutput, finalErrMs
 		case func(content int) string:
 			content := failedCommand.Content
 			host := cluster.GetHostForContent(content)
-			gplog.Custom(gplog.LOGERROR, gplog.LOGVERBOSE, "%s on segment %d on host %s %s", getMessage(content), content, host, errStr)
+			log.Custom(gplog.LOGERROR, gplog.LOGVERBOSE, "%s on segment %d on host %s %s", getMessage(content), content, host, errStr)
 		case func(host string) string:
 			host := failedCommand.Host
-			gplog.Custom(gplog.LOGERROR, gplog.LOGVERBOSE, "%s on host %s %s", getMessage(host), host, errStr)
+			log.Custom(gplog.LOGERROR, gplog.LOGVERBOSE, "%s on host %s %s", getMessage(host), host, errStr)
 		}
-		gplog.Verbose("Command was: %s", failedCommand.CommandString)
+		log.Verbose("Command was: %s", failedCommand.CommandString)
 	}
 
 	if len(noFatal) == 1 && noFatal[0] == true {
-		gplog.Error(finalErrMsg)
+		log.Error(finalErrMsg)
 	} else {
 		LogFatalClusterError(finalErrMsg, remoteOutput.Scope, remoteOutput.NumErrors)
 	}
@@ -500,6 +806,8 @@ func getSegmentByRole(segmentList []*SegConfig, role ...string) *SegConfig {
 }
 
 func (cluster *Cluster) GetDbidForContent(contentID int, role ...string) int {
+	cluster.mutex.RLock()
+	defer cluster.mutex.RUnlock()
 	segConfig := getSegmentByRole(cluster.ByContent[contentID], role...)
 	if segConfig == nil {
 		return -1
@@ -508,6 +816,8 @@ func (cluster *Cluster) GetDbidForContent(contentID int, role ...string) int {
 }
 
 func (cluster *Cluster) GetPortForContent(contentID int, role ...string) int {
+	cluster.mutex.RLock()
+	defer cluster.mutex.RUnlock()
 	segConfig := getSegmentByRole(cluster.ByContent[contentID], role...)
 	if segConfig == nil {
 		return -1
@@ -516,6 +826,8 @@ func (cluster *Cluster) GetPortForContent(contentID int, role ...string) int {
 }
 
 func (cluster *Cluster) GetHostForContent(contentID int, role ...string) string {
+	cluster.mutex.RLock()
+	defer cluster.mutex.RUnlock()
 	segConfig := getSegmentByRole(cluster.ByContent[contentID], role...)
 	if segConfig == nil {
 		return ""
@@ -523,7 +835,19 @@ func (cluster *Cluster) GetHostForContent(contentID int, role ...string) string
 	return segConfig.Hostname
 }
 
+func (cluster *Cluster) GetAddressForContent(contentID int, role ...string) string {
+	cluster.mutex.RLock()
+	defer cluster.mutex.RUnlock()
+	segConfig := getSegmentByRole(cluster.ByContent[contentID], role...)
+	if segConfig == nil {
+		return ""
+	}
+	return segConfig.Address
+}
+
 func (cluster *Cluster) GetDirForContent(contentID int, role ...string) string {
+	cluster.mutex.RLock()
+	defer cluster.mutex.RUnlock()
 	segConfig := getSegmentByRole(cluster.ByContent[contentID], role...)
 	if segConfig == nil {
 		return ""
@@ -532,6 +856,8 @@ func (cluster *Cluster) GetDirForContent(contentID int, role ...string) string {
 }
 
 func (cluster *Cluster) GetDbidsForHost(hostname string) []int {
+	cluster.mutex.RLock()
+	defer cluster.mutex.RUnlock()
 	dbids := make([]int, len(cluster.ByHost[hostname]))
 	for i, seg := range cluster.ByHost[hostname] {
 		dbids[i] = seg.DbID
@@ -540,6 +866,8 @@ func (cluster *Cluster) GetDbidsForHost(hostname string) []int {
 }
 
 func (cluster *Cluster) GetContentsForHost(hostname string) []int {
+	cluster.mutex.RLock()
+	defer cluster.mutex.RUnlock()
 	contents := make([]int, len(cluster.ByHost[hostname]))
 	for i, seg := range cluster.ByHost[hostname] {
 		contents[i] = seg.ContentID
@@ -548,6 +876,8 @@ func (cluster *Cluster) GetContentsForHost(hostname string) []int {
 }
 
 func (cluster *Cluster) GetPortsForHost(hostname string) []int {
+	cluster.mutex.RLock()
+	defer cluster.mutex.RUnlock()
 	ports := make([]int, len(cluster.ByHost[hostname]))
 	for i, seg := range cluster.ByHost[hostname] {
 		ports[i] = seg.Port
@@ -556,6 +886,8 @@ func (cluster *Cluster) GetPortsForHost(hostname string) []int {
 }
 
 func (cluster *Cluster) GetDirsForHost(hostname string) []string {
+	cluster.mutex.RLock()
+	defer cluster.mutex.RUnlock()
 	dirs := make([]string, len(cluster.ByHost[hostname]))
 	for i, seg := range cluster.ByHost[hostname] {
 		dirs[i] = seg.DataDir
@@ -563,6 +895,135 @@ func (cluster *Cluster) GetDirsForHost(hostname string) []string {
 	return dirs
 }
 
+/*
+ * The Get*ForContent functions above return -1 or "" if the content or role
+ * does not exist, which is easy to pass along unchecked into a command.  The
+ * Lookup* functions return an error instead, which wraps one of the errors
+ * below so that callers can distinguish the cases with errors.Is.
+ */
+var (
+	ErrContentNotFound = errors.New("content not found")
+	ErrRoleNotFound    = errors.New("role not found")
+	ErrHostNotFound    = errors.New("host not found")
+	ErrInvalidRole     = errors.New("invalid role")
+)
+
+type SegmentNotFoundError struct {
+	ContentID int
+	Role      string
+	Hostname  string
+	Err       error
+}
+
+func (e *SegmentNotFoundError) Error() string {
+	switch e.Err {
+	case ErrHostNotFound:
+		return fmt.Sprintf("No segments found on host %s", e.Hostname)
+	case ErrContentNotFound:
+		return fmt.Sprintf("No segment found for content %d", e.ContentID)
+	case ErrInvalidRole:
+		return fmt.Sprintf("Invalid role %q for content %d; role must be \"p\" or \"m\"", e.Role, e.ContentID)
+	default:
+		return fmt.Sprintf("No segment found for content %d with role %s", e.ContentID, e.Role)
+	}
+}
+
+func (e *SegmentNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+/*
+ * LookupSegment returns the segment with the given content and role, with the
+ * same meaning of role as in GetDbidForContent and related functions, or a
+ * *SegmentNotFoundError if there is no such segment.
+ */
+func (cluster *Cluster) LookupSegment(contentID int, role ...string) (*SegConfig, error) {
+	roleStr := "p"
+	if len(role) > 1 || (len(role) == 1 && role[0] != "p" && role[0] != "m") {
+		return nil, &SegmentNotFoundError{ContentID: contentID, Role: strings.Join(role, ","), Err: ErrInvalidRole}
+	} else if len(role) == 1 {
+		roleStr = role[0]
+	}
+	cluster.mutex.RLock()
+	defer cluster.mutex.RUnlock()
+	segmentList, ok := cluster.ByContent[contentID]
+	if !ok || len(segmentList) == 0 {
+		return nil, &SegmentNotFoundError{ContentID: contentID, Role: roleStr, Err: ErrContentNotFound}
+	}
+	segConfig := getSegmentByRole(segmentList, roleStr)
+	if segConfig == nil {
+		return nil, &SegmentNotFoundError{ContentID: contentID, Role: roleStr, Err: ErrRoleNotFound}
+	}
+	return segConfig, nil
+}
+
+func (cluster *Cluster) LookupSegmentsForHost(hostname string) ([]*SegConfig, error) {
+	cluster.mutex.RLock()
+	defer cluster.mutex.RUnlock()
+	segments, ok := cluster.ByHost[hostname]
+	if !ok || len(segments) == 0 {
+		return nil, &SegmentNotFoundError{Hostname: hostname, Err: ErrHostNotFound}
+	}
+	return segments, nil
+}
+
+/*
+ * The role-optional accessors above look segments up by position, which only
+ * matches their current role if the segments were ordered by role when the
+ * cluster was built.  GetActingPrimaryForContent and GetActingMirrorForContent
+ * instead check each segment's current Role, so after a failover they return
+ * the segment actually serving as primary even if its PreferredRole is "m".
+ */
+func (cluster *Cluster) GetActingPrimaryForContent(contentID int) (*SegConfig, error) {
+	return cluster.getSegmentWithCurrentRole(contentID, "p")
+}
+
+func (cluster *Cluster) GetActingMirrorForContent(contentID int) (*SegConfig, error) {
+	return cluster.getSegmentWithCurrentRole(contentID, "m")
+}
+
+func (cluster *Cluster) getSegmentWithCurrentRole(contentID int, role string) (*SegConfig, error) {
+	cluster.mutex.RLock()
+	defer cluster.mutex.RUnlock()
+	segmentList, ok := cluster.ByContent[contentID]
+	if !ok || len(segmentList) == 0 {
+		return nil, &SegmentNotFoundError{ContentID: contentID, Role: role, Err: ErrContentNotFound}
+	}
+	for _, segment := range segmentList {
+		if segment.Role == role {
+			return segment, nil
+		}
+	}
+	return nil, &SegmentNotFoundError{ContentID: contentID, Role: role, Err: ErrRoleNotFound}
+}
+
+// GetSegmentsNotInPreferredRole returns every segment whose current role differs from its preferred role.
+func (cluster *Cluster) GetSegmentsNotInPreferredRole() []SegConfig {
+	cluster.mutex.RLock()
+	defer cluster.mutex.RUnlock()
+	segments := make([]SegConfig, 0)
+	for _, segment := range cluster.Segments {
+		if segment.PreferredRole != "" && segment.Role != segment.PreferredRole {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// GetContentsNotInPreferredRole returns, in ascending order, each content with a segment not in its preferred role.
+func (cluster *Cluster) GetContentsNotInPreferredRole() []int {
+	contents := make([]int, 0)
+	seen := make(map[int]bool)
+	for _, segment := range cluster.GetSegmentsNotInPreferredRole() {
+		if !seen[segment.ContentID] {
+			seen[segment.ContentID] = true
+			contents = append(contents, segment.ContentID)
+		}
+	}
+	sort.Ints(contents)
+	return contents
+}
+
 /*
  * Helper functions
  */
@@ -574,10 +1035,54 @@ func (cluster *Cluster) GetDirsForHost(hostname string) []string {
  * If the second is set to true, it retrieves only mirror and standby information, regardless of the value of the first boolean.
  */
 func GetSegmentConfiguration(connection *dbconn.DBConn, getMirrors ...bool) ([]SegConfig, error) {
-	includeMirrors := len(getMirrors) == 1 && getMirrors[0]
-	includeOnlyMirrors := len(getMirrors) == 2 && getMirrors[1]
+	return GetSegmentConfigurationWithOptions(connection, SegmentConfigOptions{
+		IncludeMirrors:     len(getMirrors) == 1 && getMirrors[0],
+		IncludeOnlyMirrors: len(getMirrors) == 2 && getMirrors[1],
+	})
+}
+
+/*
+ * SegmentConfigOptions replaces the positional booleans accepted by
+ * GetSegmentConfiguration; the zero value retrieves only primaries and the
+ * coordinator.  If Timeout is set, the query is canceled if it has not
+ * completed within that time.
+ */
+type SegmentConfigOptions struct {
+	IncludeMirrors     bool
+	IncludeOnlyMirrors bool
+	Timeout            time.Duration
+}
+
+func GetSegmentConfigurationWithOptions(connection *dbconn.DBConn, opts SegmentConfigOptions) ([]SegConfig, error) {
+	return GetSegmentConfigurationContext(context.Background(), connection, opts)
+}
+
+/*
+ * GetSegmentConfigurationContext bounds the catalog query by ctx, as the
+ * query can hang indefinitely while FTS is in the middle of a failover.
+ */
+func GetSegmentConfigurationContext(ctx context.Context, connection *dbconn.DBConn, opts SegmentConfigOptions) ([]SegConfig, error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	results := make([]SegConfig, 0)
+	err := connection.SelectContext(ctx, &results, segmentConfigurationQuery(connection, opts))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, errors.Wrap(ctx.Err(), "Failed to retrieve segment configuration")
+		}
+		return nil, err
+	}
+	return results, nil
+}
+
+func segmentConfigurationQuery(connection *dbconn.DBConn, opts SegmentConfigOptions) string {
+	includeMirrors := opts.IncludeMirrors
+	includeOnlyMirrors := opts.IncludeOnlyMirrors
 	query := ""
-	if connection.Version.IsGPDB() && connection.Version.Before("6") {
+	if connection.Version.SupportsFilespaces() {
 		whereClause := "WHERE%s f.fsname = 'pg_system'"
 		if includeOnlyMirrors {
 			whereClause = fmt.Sprintf(whereClause, " s.role = 'm' AND")
@@ -626,13 +1131,7 @@ FROM gp_segment_configuration
 %s
 ORDER BY content, role DESC;`, whereClause)
 	}
-
-	results := make([]SegConfig, 0)
-	err := connection.Select(&results, query)
-	if err != nil {
-		return nil, err
-	}
-	return results, nil
+	return query
 }
 
 func MustGetSegmentConfiguration(connection *dbconn.DBConn, getMirrors ...bool) []SegConfig {
@@ -702,8 +1201,12 @@ func GetSegmentConfigurationFromFile(coordinatorDataDir string) ([]SegConfig, er
 	}
 	defer fd.Close()
 
+	return parseGpsegconfigDump(fd, gpsegconfigDump)
+}
+
+func parseGpsegconfigDump(reader io.Reader, gpsegconfigDump string) ([]SegConfig, error) {
 	results := make([]SegConfig, 0)
-	scanner := bufio.NewScanner(fd)
+	scanner := bufio.NewScanner(reader)
 
 	/*scanning file line by line to extract the fields into SegConfig struct*/
 	for scanner.Scan() {
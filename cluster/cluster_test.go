@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/user"
 	"path"
+	"strconv"
 	"testing"
 	"time"
 
@@ -80,6 +81,28 @@ var _ = Describe("cluster/cluster tests", func() {
 		testCluster.Executor = testExecutor
 		logfile.Clear()
 	})
+	Describe("Scope", func() {
+		DescribeTable("String", func(scope cluster.Scope, expected string) {
+			Expect(scope.String()).To(Equal(expected))
+		},
+			Entry("default scope", cluster.ON_SEGMENTS, "ON_SEGMENTS|EXCLUDE_COORDINATOR|ON_REMOTE|EXCLUDE_MIRRORS"),
+			Entry("all bits set", cluster.ON_HOSTS|cluster.INCLUDE_COORDINATOR|cluster.ON_LOCAL|cluster.INCLUDE_MIRRORS, "ON_HOSTS|INCLUDE_COORDINATOR|ON_LOCAL|INCLUDE_MIRRORS"),
+			Entry("unknown bits", cluster.ON_HOSTS|cluster.Scope(1<<5), "ON_HOSTS|EXCLUDE_COORDINATOR|ON_REMOTE|EXCLUDE_MIRRORS|UNKNOWN(0x20)"),
+		)
+		It("accepts valid scopes", func() {
+			Expect(cluster.ON_SEGMENTS.Validate()).To(Succeed())
+			Expect((cluster.ON_SEGMENTS | cluster.INCLUDE_COORDINATOR | cluster.ON_LOCAL).Validate()).To(Succeed())
+			Expect((cluster.ON_HOSTS | cluster.INCLUDE_MIRRORS).Validate()).To(Succeed())
+		})
+		It("rejects unknown bits", func() {
+			err := (cluster.ON_HOSTS | cluster.Scope(1<<4)).Validate()
+			Expect(err).To(MatchError("Invalid scope ON_HOSTS|EXCLUDE_COORDINATOR|ON_REMOTE|EXCLUDE_MIRRORS|UNKNOWN(0x10): bits 0x10 do not correspond to any scope"))
+		})
+		It("rejects INCLUDE_MIRRORS with ON_SEGMENTS", func() {
+			err := (cluster.ON_SEGMENTS | cluster.INCLUDE_MIRRORS).Validate()
+			Expect(err).To(MatchError("Invalid scope ON_SEGMENTS|EXCLUDE_COORDINATOR|ON_REMOTE|INCLUDE_MIRRORS: INCLUDE_MIRRORS is only supported with ON_HOSTS"))
+		})
+	})
 	Describe("ConstructSSHCommand", func() {
 		It("constructs a local ssh command", func() {
 			cmd := cluster.ConstructSSHCommand(true, "some-host", "ls")
@@ -355,6 +378,26 @@ var _ = Describe("cluster/cluster tests", func() {
 			Expect(results[1]).To(Equal(localSegTwoValue))
 			Expect(results[2]).To(Equal(remoteSegOneValue))
 		})
+		It("returns mirrors when using an options struct", func() {
+			fakeResult := sqlmock.NewRows(header).AddRow(localSegTwo...)
+			mock.ExpectQuery("SELECT (.*)role = 'm'(.*)").WillReturnRows(fakeResult)
+			results, err := cluster.GetSegmentConfigurationWithOptions(connection, cluster.SegmentConfigOptions{IncludeOnlyMirrors: true})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(Equal([]cluster.SegConfig{localSegTwoValue}))
+		})
+		It("returns an error if the query does not complete before the timeout", func() {
+			fakeResult := sqlmock.NewRows(header).AddRow(localSegOne...)
+			mock.ExpectQuery("SELECT (.*)").WillDelayFor(time.Second).WillReturnRows(fakeResult)
+			_, err := cluster.GetSegmentConfigurationContext(context.Background(), connection, cluster.SegmentConfigOptions{Timeout: 10 * time.Millisecond})
+			Expect(err).To(MatchError("Failed to retrieve segment configuration: context deadline exceeded"))
+		})
+		It("returns an error if the context is canceled", func() {
+			mock.ExpectQuery("SELECT (.*)").WillDelayFor(time.Second).WillReturnRows(sqlmock.NewRows(header))
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := cluster.GetSegmentConfigurationContext(ctx, connection, cluster.SegmentConfigOptions{})
+			Expect(joinerrs.Is(err, context.Canceled)).To(BeTrue())
+		})
 	})
 
 	Describe("GenerateSSHCommandList", func() {
@@ -447,6 +490,46 @@ var _ = Describe("cluster/cluster tests", func() {
 			Entry("returns a list of ssh commands for one local host and two remote hosts, including the coordinator host", cluster.ON_HOSTS|cluster.INCLUDE_COORDINATOR, true, false, standbyCoordinator, 0, 2),
 			Entry("returns a list of ssh commands for one local host and two remote hosts, excluding the coordinator host", cluster.ON_HOSTS, false, false, standbyCoordinator, 0, 2),
 		)
+
+		It("accepts typed generators", func() {
+			testCluster := cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, localSegOne, remoteSegOne})
+			contentCommands := testCluster.GenerateSSHCommandList(cluster.ON_SEGMENTS, cluster.ContentShellGenerator(func(_ int) string {
+				return "ls"
+			}))
+			Expect(contentCommands).To(Equal([]cluster.ShellCommand{
+				cluster.NewShellCommand(cluster.ON_SEGMENTS, 0, "", localSegCmd),
+				cluster.NewShellCommand(cluster.ON_SEGMENTS, 1, "", remoteSegOneCmd),
+			}))
+			hostCommands := testCluster.GenerateHostSSHCommandList(cluster.ON_HOSTS, cluster.TARGET_HOSTNAME, func(_ string) string {
+				return "ls"
+			})
+			Expect(hostCommands).To(Equal([]cluster.ShellCommand{
+				cluster.NewShellCommand(cluster.ON_HOSTS, -2, "localhost", localSegCmd),
+				cluster.NewShellCommand(cluster.ON_HOSTS, -2, "remotehost1", remoteSegOneCmd),
+			}))
+		})
+	})
+	Describe("GenerateCommandList", func() {
+		It("passes each segment to a SegConfig generator", func() {
+			generator := func(seg cluster.SegConfig) []string {
+				return []string{"pg_ctl", "-D", seg.DataDir, "-o", fmt.Sprintf("-p %d", seg.Port), "restart"}
+			}
+			commands := testCluster.GenerateCommandList(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, generator)
+			Expect(commands).To(Equal([]cluster.ShellCommand{
+				cluster.NewShellCommand(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, -1, "", []string{"pg_ctl", "-D", "/data/gpseg-1", "-o", "-p 5432", "restart"}),
+				cluster.NewShellCommand(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, 0, "", []string{"pg_ctl", "-D", "/data/gpseg0", "-o", "-p 20000", "restart"}),
+				cluster.NewShellCommand(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, 1, "", []string{"pg_ctl", "-D", "/data/gpseg1", "-o", "-p 20001", "restart"}),
+			}))
+		})
+		It("excludes the coordinator from a typed SegmentGenerator unless requested", func() {
+			commands := testCluster.GenerateSegmentCommandList(cluster.ON_SEGMENTS, func(seg cluster.SegConfig) []string {
+				return []string{"echo", strconv.Itoa(seg.DbID)}
+			})
+			Expect(commands).To(Equal([]cluster.ShellCommand{
+				cluster.NewShellCommand(cluster.ON_SEGMENTS, 0, "", []string{"echo", "2"}),
+				cluster.NewShellCommand(cluster.ON_SEGMENTS, 1, "", []string{"echo", "3"}),
+			}))
+		})
 	})
 	Describe("ExecuteLocalCommand", func() {
 		BeforeEach(func() {
@@ -923,5 +1006,71 @@ fi`
 			Expect(mirrorCluster.GetPortsForHost("localhost")).To(Equal([]int{5432, 20000}))
 			Expect(mirrorCluster.GetDirsForHost("localhost")).To(Equal([]string{"/data/gpseg-1", "/data/primary/gpseg0"}))
 		})
+		It("looks up segments by content and role", func() {
+			segConfig, err := mirrorCluster.LookupSegment(0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(segConfig.DbID).To(Equal(2))
+			segConfig, err = mirrorCluster.LookupSegment(0, "m")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(segConfig.DbID).To(Equal(3))
+		})
+		It("returns distinct errors for missing contents, roles, and hosts", func() {
+			_, err := mirrorCluster.LookupSegment(5)
+			Expect(joinerrs.Is(err, cluster.ErrContentNotFound)).To(BeTrue())
+			Expect(err).To(MatchError("No segment found for content 5"))
+
+			_, err = mirrorCluster.LookupSegment(-1, "m")
+			Expect(joinerrs.Is(err, cluster.ErrRoleNotFound)).To(BeTrue())
+			Expect(err).To(MatchError("No segment found for content -1 with role m"))
+
+			_, err = mirrorCluster.LookupSegment(0, "x")
+			Expect(joinerrs.Is(err, cluster.ErrInvalidRole)).To(BeTrue())
+
+			_, err = mirrorCluster.LookupSegmentsForHost("nohost")
+			Expect(joinerrs.Is(err, cluster.ErrHostNotFound)).To(BeTrue())
+			var notFoundErr *cluster.SegmentNotFoundError
+			Expect(joinerrs.As(err, &notFoundErr)).To(BeTrue())
+			Expect(notFoundErr.Hostname).To(Equal("nohost"))
+		})
+		Describe("acting roles after failover", func() {
+			var failedOverCluster *cluster.Cluster
+			BeforeEach(func() {
+				failedOverCluster = cluster.NewCluster([]cluster.SegConfig{
+					{DbID: 1, ContentID: -1, Role: "p", PreferredRole: "p", Port: 5432, Hostname: "localhost"},
+					{DbID: 2, ContentID: 0, Role: "m", PreferredRole: "p", Port: 20000, Hostname: "localhost"},
+					{DbID: 3, ContentID: 0, Role: "p", PreferredRole: "m", Port: 21000, Hostname: "otherhost"},
+					{DbID: 4, ContentID: 1, Role: "p", PreferredRole: "p", Port: 20001, Hostname: "otherhost"},
+				}, cluster.WithPreferredRoleOrdering())
+			})
+			It("returns the segment currently acting as primary or mirror", func() {
+				Expect(failedOverCluster.GetDbidForContent(0)).To(Equal(2))
+				primary, err := failedOverCluster.GetActingPrimaryForContent(0)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(primary.DbID).To(Equal(3))
+				mirror, err := failedOverCluster.GetActingMirrorForContent(0)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(mirror.DbID).To(Equal(2))
+			})
+			It("returns an error if no segment has the role", func() {
+				_, err := failedOverCluster.GetActingMirrorForContent(1)
+				Expect(joinerrs.Is(err, cluster.ErrRoleNotFound)).To(BeTrue())
+				_, err = failedOverCluster.GetActingPrimaryForContent(7)
+				Expect(joinerrs.Is(err, cluster.ErrContentNotFound)).To(BeTrue())
+			})
+			It("lists segments and contents not in their preferred role", func() {
+				segments := failedOverCluster.GetSegmentsNotInPreferredRole()
+				Expect(segments).To(HaveLen(2))
+				Expect(segments[0].DbID).To(Equal(2))
+				Expect(segments[1].DbID).To(Equal(3))
+				Expect(failedOverCluster.GetContentsNotInPreferredRole()).To(Equal([]int{0}))
+				Expect(mirrorCluster.GetContentsNotInPreferredRole()).To(BeEmpty())
+			})
+		})
+		It("looks up segments by host", func() {
+			segments, err := mirrorCluster.LookupSegmentsForHost("otherhost")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(segments).To(HaveLen(1))
+			Expect(segments[0].DbID).To(Equal(3))
+		})
 	})
 })
@@ -0,0 +1,148 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to comparing segment
+ * configurations from different sources, such as gpsegconfig_dump and
+ * gp_segment_configuration.
+ */
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+)
+
+/*
+ * A SegConfigDiscrepancy records a single field that differs between two
+ * segment configurations for the segment with the given dbid.  If a segment
+ * is present in only one configuration, Field is "Segment" and the value for
+ * the configuration missing it is "missing".
+ */
+type SegConfigDiscrepancy struct {
+	DbID         int
+	ContentID    int
+	Field        string
+	FileValue    string
+	CatalogValue string
+}
+
+func (discrepancy SegConfigDiscrepancy) String() string {
+	return fmt.Sprintf("dbid %d (content %d): %s is %q in gpsegconfig_dump but %q in catalog",
+		discrepancy.DbID, discrepancy.ContentID, discrepancy.Field, discrepancy.FileValue, discrepancy.CatalogValue)
+}
+
+type SegConfigComparison struct {
+	Discrepancies []SegConfigDiscrepancy
+	FileModTime   time.Time
+}
+
+func (comparison SegConfigComparison) IsConsistent() bool {
+	return len(comparison.Discrepancies) == 0
+}
+
+/*
+ * FileAge returns how long ago gpsegconfig_dump was last written, relative to
+ * the given time, to help quantify how stale the file's view may be.
+ */
+func (comparison SegConfigComparison) FileAge(now time.Time) time.Duration {
+	return now.Sub(comparison.FileModTime)
+}
+
+/*
+ * CompareSegConfigs compares two segment configurations, matched by dbid, and
+ * returns every discrepancy ordered by dbid.  Older gpsegconfig_dump files do
+ * not record DataDir, so DataDir is only compared if fileConfigs has it set.
+ */
+func CompareSegConfigs(fileConfigs []SegConfig, catalogConfigs []SegConfig) []SegConfigDiscrepancy {
+	fileByDbid := make(map[int]SegConfig, len(fileConfigs))
+	for _, seg := range fileConfigs {
+		fileByDbid[seg.DbID] = seg
+	}
+	catalogByDbid := make(map[int]SegConfig, len(catalogConfigs))
+	for _, seg := range catalogConfigs {
+		catalogByDbid[seg.DbID] = seg
+	}
+
+	discrepancies := make([]SegConfigDiscrepancy, 0)
+	for dbid, fileSeg := range fileByDbid {
+		catalogSeg, ok := catalogByDbid[dbid]
+		if !ok {
+			discrepancies = append(discrepancies, SegConfigDiscrepancy{DbID: dbid, ContentID: fileSeg.ContentID, Field: "Segment", FileValue: "present", CatalogValue: "missing"})
+			continue
+		}
+		discrepancies = append(discrepancies, compareSegConfig(fileSeg, catalogSeg)...)
+	}
+	for dbid, catalogSeg := range catalogByDbid {
+		if _, ok := fileByDbid[dbid]; !ok {
+			discrepancies = append(discrepancies, SegConfigDiscrepancy{DbID: dbid, ContentID: catalogSeg.ContentID, Field: "Segment", FileValue: "missing", CatalogValue: "present"})
+		}
+	}
+	sort.SliceStable(discrepancies, func(i, j int) bool {
+		return discrepancies[i].DbID < discrepancies[j].DbID
+	})
+	return discrepancies
+}
+
+type fieldComparison struct {
+	name         string
+	fileValue    interface{}
+	catalogValue interface{}
+}
+
+func compareSegConfig(fileSeg SegConfig, catalogSeg SegConfig) []SegConfigDiscrepancy {
+	fields := []fieldComparison{
+		{"ContentID", fileSeg.ContentID, catalogSeg.ContentID},
+		{"Role", fileSeg.Role, catalogSeg.Role},
+		{"PreferredRole", fileSeg.PreferredRole, catalogSeg.PreferredRole},
+		{"Mode", fileSeg.Mode, catalogSeg.Mode},
+		{"Status", fileSeg.Status, catalogSeg.Status},
+		{"Port", fileSeg.Port, catalogSeg.Port},
+		{"Hostname", fileSeg.Hostname, catalogSeg.Hostname},
+		{"Address", fileSeg.Address, catalogSeg.Address},
+	}
+	if fileSeg.DataDir != "" {
+		fields = append(fields, fieldComparison{"DataDir", fileSeg.DataDir, catalogSeg.DataDir})
+	}
+
+	discrepancies := make([]SegConfigDiscrepancy, 0)
+	for _, field := range fields {
+		if field.fileValue != field.catalogValue {
+			discrepancies = append(discrepancies, SegConfigDiscrepancy{
+				DbID:         fileSeg.DbID,
+				ContentID:    catalogSeg.ContentID,
+				Field:        field.name,
+				FileValue:    fmt.Sprint(field.fileValue),
+				CatalogValue: fmt.Sprint(field.catalogValue),
+			})
+		}
+	}
+	return discrepancies
+}
+
+/*
+ * CompareSegmentConfigurationWithFile reads the segment configuration, including
+ * mirrors, from both gpsegconfig_dump and gp_segment_configuration and reports
+ * any discrepancies between them along with the modification time of the file.
+ */
+func CompareSegmentConfigurationWithFile(connection *dbconn.DBConn, coordinatorDataDir string) (SegConfigComparison, error) {
+	fileConfigs, err := GetSegmentConfigurationFromFile(coordinatorDataDir)
+	if err != nil {
+		return SegConfigComparison{}, err
+	}
+	info, err := os.Stat(path.Join(coordinatorDataDir, "gpsegconfig_dump"))
+	if err != nil {
+		return SegConfigComparison{}, err
+	}
+	catalogConfigs, err := GetSegmentConfiguration(connection, true)
+	if err != nil {
+		return SegConfigComparison{}, err
+	}
+	return SegConfigComparison{
+		Discrepancies: CompareSegConfigs(fileConfigs, catalogConfigs),
+		FileModTime:   info.ModTime(),
+	}, nil
+}
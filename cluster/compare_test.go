@@ -0,0 +1,78 @@
+package cluster_test
+
+import (
+	"os"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/compare tests", func() {
+	coordinator := cluster.SegConfig{DbID: 1, ContentID: -1, Role: "p", PreferredRole: "p", Mode: "n", Status: "u", Port: 5432, Hostname: "cdw", Address: "cdw", DataDir: "/data/qddir"}
+	primary := cluster.SegConfig{DbID: 2, ContentID: 0, Role: "p", PreferredRole: "p", Mode: "s", Status: "u", Port: 6000, Hostname: "sdw1", Address: "sdw1", DataDir: "/data/gpseg0"}
+	mirror := cluster.SegConfig{DbID: 3, ContentID: 0, Role: "m", PreferredRole: "m", Mode: "s", Status: "u", Port: 7000, Hostname: "sdw2", Address: "sdw2", DataDir: "/data/mirror0"}
+
+	Describe("CompareSegConfigs", func() {
+		It("returns no discrepancies for identical configurations", func() {
+			configs := []cluster.SegConfig{coordinator, primary, mirror}
+			Expect(cluster.CompareSegConfigs(configs, configs)).To(BeEmpty())
+		})
+		It("reports a failover that the dump has not yet caught up with", func() {
+			failedPrimary := primary
+			failedPrimary.Role, failedPrimary.Mode, failedPrimary.Status = "m", "n", "d"
+			actingPrimary := mirror
+			actingPrimary.Role, actingPrimary.Mode = "p", "n"
+
+			discrepancies := cluster.CompareSegConfigs([]cluster.SegConfig{coordinator, primary, mirror}, []cluster.SegConfig{coordinator, failedPrimary, actingPrimary})
+			Expect(discrepancies).To(Equal([]cluster.SegConfigDiscrepancy{
+				{DbID: 2, ContentID: 0, Field: "Role", FileValue: "p", CatalogValue: "m"},
+				{DbID: 2, ContentID: 0, Field: "Mode", FileValue: "s", CatalogValue: "n"},
+				{DbID: 2, ContentID: 0, Field: "Status", FileValue: "u", CatalogValue: "d"},
+				{DbID: 3, ContentID: 0, Field: "Role", FileValue: "m", CatalogValue: "p"},
+				{DbID: 3, ContentID: 0, Field: "Mode", FileValue: "s", CatalogValue: "n"},
+			}))
+			Expect(discrepancies[0].String()).To(Equal(`dbid 2 (content 0): Role is "p" in gpsegconfig_dump but "m" in catalog`))
+		})
+		It("reports segments present in only one configuration", func() {
+			discrepancies := cluster.CompareSegConfigs([]cluster.SegConfig{coordinator, primary}, []cluster.SegConfig{coordinator, mirror})
+			Expect(discrepancies).To(Equal([]cluster.SegConfigDiscrepancy{
+				{DbID: 2, ContentID: 0, Field: "Segment", FileValue: "present", CatalogValue: "missing"},
+				{DbID: 3, ContentID: 0, Field: "Segment", FileValue: "missing", CatalogValue: "present"},
+			}))
+		})
+		It("ignores DataDir if the dump file does not record it", func() {
+			oldFormat := primary
+			oldFormat.DataDir = ""
+			Expect(cluster.CompareSegConfigs([]cluster.SegConfig{oldFormat}, []cluster.SegConfig{primary})).To(BeEmpty())
+		})
+	})
+	Describe("CompareSegmentConfigurationWithFile", func() {
+		It("compares the dump file with the catalog", func() {
+			tempConfFile := createSegConfigFile("1 -1 p p n u 5432 cdw cdw /data/qddir\n2 0 p p s u 6000 sdw1 sdw1 /data/gpseg0\n")
+			defer os.Remove(tempConfFile.Name())
+
+			header := []string{"dbid", "contentid", "role", "preferredrole", "mode", "status", "port", "hostname", "address", "datadir"}
+			fakeResult := sqlmock.NewRows(header).
+				AddRow(1, -1, "p", "p", "n", "u", 5432, "cdw", "cdw", "/data/qddir").
+				AddRow(2, 0, "p", "p", "s", "u", 6000, "sdw1", "sdw1", "/data/gpseg0").
+				AddRow(3, 0, "m", "m", "s", "u", 7000, "sdw2", "sdw2", "/data/mirror0")
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(fakeResult)
+
+			comparison, err := cluster.CompareSegmentConfigurationWithFile(connection, os.TempDir())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(comparison.IsConsistent()).To(BeFalse())
+			Expect(comparison.Discrepancies).To(Equal([]cluster.SegConfigDiscrepancy{
+				{DbID: 3, ContentID: 0, Field: "Segment", FileValue: "missing", CatalogValue: "present"},
+			}))
+			Expect(comparison.FileAge(time.Now())).To(BeNumerically("<", time.Minute))
+		})
+		It("returns an error if the dump file cannot be read", func() {
+			_, err := cluster.CompareSegmentConfigurationWithFile(connection, "/nonexistent/dir")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,210 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to reading segment
+ * configuration dumps in formats other than gpsegconfig_dump.
+ */
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type SegConfigFormat int
+
+const (
+	SEGCONFIG_FORMAT_AUTO SegConfigFormat = iota
+	SEGCONFIG_FORMAT_GPSEGCONFIG_DUMP
+	SEGCONFIG_FORMAT_JSON
+	SEGCONFIG_FORMAT_CSV
+)
+
+func (format SegConfigFormat) String() string {
+	switch format {
+	case SEGCONFIG_FORMAT_GPSEGCONFIG_DUMP:
+		return "gpsegconfig_dump"
+	case SEGCONFIG_FORMAT_JSON:
+		return "json"
+	case SEGCONFIG_FORMAT_CSV:
+		return "csv"
+	default:
+		return "auto"
+	}
+}
+
+/*
+ * JSON and CSV dumps name their fields after the columns of
+ * gp_segment_configuration, e.g. "content" and "preferred_role", and also
+ * accept the aliases used by GetSegmentConfiguration, e.g. "contentid".
+ * Field names are case-insensitive, and any other fields are ignored.
+ */
+var segConfigFieldAliases = map[string]string{
+	"dbid":           "dbid",
+	"content":        "contentid",
+	"contentid":      "contentid",
+	"role":           "role",
+	"preferred_role": "preferredrole",
+	"preferredrole":  "preferredrole",
+	"mode":           "mode",
+	"status":         "status",
+	"port":           "port",
+	"hostname":       "hostname",
+	"address":        "address",
+	"datadir":        "datadir",
+}
+
+/*
+ * ReadSegmentConfigurationFile reads a segment configuration dump in any
+ * supported format.  The format is determined by the file's extension if it
+ * is ".json" or ".csv", and otherwise from its contents.
+ */
+func ReadSegmentConfigurationFile(filename string) ([]SegConfig, error) {
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Errorf("Failed to open file %s. Error: %s", filename, err.Error())
+	}
+	format := SEGCONFIG_FORMAT_AUTO
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		format = SEGCONFIG_FORMAT_JSON
+	case ".csv":
+		format = SEGCONFIG_FORMAT_CSV
+	}
+	return ParseSegmentConfiguration(bytes.NewReader(contents), format)
+}
+
+func ParseSegmentConfiguration(reader io.Reader, format SegConfigFormat) ([]SegConfig, error) {
+	contents, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read segment configuration")
+	}
+	if format == SEGCONFIG_FORMAT_AUTO {
+		format = DetectSegConfigFormat(contents)
+	}
+	switch format {
+	case SEGCONFIG_FORMAT_JSON:
+		return parseSegConfigJSON(contents)
+	case SEGCONFIG_FORMAT_CSV:
+		return parseSegConfigCSV(contents)
+	default:
+		return parseGpsegconfigDump(bytes.NewReader(contents), "gpsegconfig_dump")
+	}
+}
+
+/*
+ * DetectSegConfigFormat treats contents starting with "[" as JSON and those
+ * whose first line is a comma-separated header naming a "dbid" field as CSV;
+ * anything else is assumed to be in gpsegconfig_dump format.
+ */
+func DetectSegConfigFormat(contents []byte) SegConfigFormat {
+	trimmed := bytes.TrimSpace(contents)
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		return SEGCONFIG_FORMAT_JSON
+	}
+	firstLine, _, _ := bufio.NewReader(bytes.NewReader(trimmed)).ReadLine()
+	if bytes.Contains(firstLine, []byte(",")) {
+		for _, field := range strings.Split(string(firstLine), ",") {
+			if strings.EqualFold(strings.Trim(strings.TrimSpace(field), `"`), "dbid") {
+				return SEGCONFIG_FORMAT_CSV
+			}
+		}
+	}
+	return SEGCONFIG_FORMAT_GPSEGCONFIG_DUMP
+}
+
+func parseSegConfigJSON(contents []byte) ([]SegConfig, error) {
+	records := make([]map[string]interface{}, 0)
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.UseNumber()
+	if err := decoder.Decode(&records); err != nil {
+		return nil, errors.Wrap(err, "Failed to parse JSON segment configuration")
+	}
+	results := make([]SegConfig, 0, len(records))
+	for i, record := range records {
+		fields := make(map[string]string, len(record))
+		for key, value := range record {
+			if value == nil {
+				continue
+			}
+			fields[key] = fmt.Sprint(value)
+		}
+		seg, err := segConfigFromFields(fields)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid segment at index %d", i)
+		}
+		results = append(results, seg)
+	}
+	return results, nil
+}
+
+func parseSegConfigCSV(contents []byte) ([]SegConfig, error) {
+	reader := csv.NewReader(bytes.NewReader(contents))
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to parse CSV segment configuration")
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("CSV segment configuration has no header row")
+	}
+	header := rows[0]
+	results := make([]SegConfig, 0, len(rows)-1)
+	for i, row := range rows[1:] {
+		fields := make(map[string]string, len(header))
+		for j, name := range header {
+			fields[name] = row[j]
+		}
+		seg, err := segConfigFromFields(fields)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid segment on line %d", i+2)
+		}
+		results = append(results, seg)
+	}
+	return results, nil
+}
+
+func segConfigFromFields(fields map[string]string) (SegConfig, error) {
+	values := make(map[string]string, len(fields))
+	for name, value := range fields {
+		if canonical, ok := segConfigFieldAliases[strings.ToLower(strings.TrimSpace(name))]; ok {
+			values[canonical] = strings.TrimSpace(value)
+		}
+	}
+	seg := SegConfig{
+		Role:          values["role"],
+		PreferredRole: values["preferredrole"],
+		Mode:          values["mode"],
+		Status:        values["status"],
+		Hostname:      values["hostname"],
+		Address:       values["address"],
+		DataDir:       values["datadir"],
+	}
+	for _, field := range []struct {
+		name  string
+		value *int
+	}{{"dbid", &seg.DbID}, {"contentid", &seg.ContentID}, {"port", &seg.Port}} {
+		valueStr, ok := values[field.name]
+		if !ok {
+			return SegConfig{}, errors.Errorf("Missing required field %s", field.name)
+		}
+		value, err := strconv.Atoi(valueStr)
+		if err != nil {
+			return SegConfig{}, errors.Errorf("Failed to convert %s with value %s to an int", field.name, valueStr)
+		}
+		*field.value = value
+	}
+	if seg.Hostname == "" {
+		return SegConfig{}, errors.New("Missing required field hostname")
+	}
+	return seg, nil
+}
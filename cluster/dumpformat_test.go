@@ -0,0 +1,60 @@
+package cluster_test
+
+import (
+	"os"
+	"path"
+	"strings"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/dumpformat tests", func() {
+	expected := []cluster.SegConfig{
+		{DbID: 1, ContentID: -1, Role: "p", PreferredRole: "p", Mode: "n", Status: "u", Port: 7000, Hostname: "cdw", Address: "cdw", DataDir: "/data/qddir/demoDataDir-1"},
+		{DbID: 2, ContentID: 0, Role: "p", PreferredRole: "p", Mode: "s", Status: "u", Port: 7002, Hostname: "sdw1", Address: "sdw1-ic", DataDir: "/data/primary/gpseg0"},
+	}
+	dumpContents := "1 -1 p p n u 7000 cdw cdw /data/qddir/demoDataDir-1\n2 0 p p s u 7002 sdw1 sdw1-ic /data/primary/gpseg0\n"
+	jsonContents := `[
+  {"dbid": 1, "content": -1, "role": "p", "preferred_role": "p", "mode": "n", "status": "u", "port": 7000, "hostname": "cdw", "address": "cdw", "datadir": "/data/qddir/demoDataDir-1"},
+  {"DBID": 2, "contentid": 0, "role": "p", "preferredrole": "p", "mode": "s", "status": "u", "port": 7002, "hostname": "sdw1", "address": "sdw1-ic", "datadir": "/data/primary/gpseg0", "extra": true}
+]`
+	csvContents := `dbid,content,role,preferred_role,mode,status,port,hostname,address,datadir
+1,-1,p,p,n,u,7000,cdw,cdw,/data/qddir/demoDataDir-1
+2, 0, p, p, s, u, 7002, sdw1, sdw1-ic, /data/primary/gpseg0
+`
+	DescribeTable("DetectSegConfigFormat", func(contents string, format cluster.SegConfigFormat) {
+		Expect(cluster.DetectSegConfigFormat([]byte(contents))).To(Equal(format))
+	},
+		Entry("gpsegconfig_dump", dumpContents, cluster.SEGCONFIG_FORMAT_GPSEGCONFIG_DUMP),
+		Entry("JSON", jsonContents, cluster.SEGCONFIG_FORMAT_JSON),
+		Entry("CSV", csvContents, cluster.SEGCONFIG_FORMAT_CSV),
+	)
+	DescribeTable("ParseSegmentConfiguration", func(contents string, format cluster.SegConfigFormat) {
+		results, err := cluster.ParseSegmentConfiguration(strings.NewReader(contents), format)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).To(Equal(expected))
+	},
+		Entry("detects gpsegconfig_dump", dumpContents, cluster.SEGCONFIG_FORMAT_AUTO),
+		Entry("detects JSON", jsonContents, cluster.SEGCONFIG_FORMAT_AUTO),
+		Entry("detects CSV", csvContents, cluster.SEGCONFIG_FORMAT_AUTO),
+		Entry("parses an explicit format", csvContents, cluster.SEGCONFIG_FORMAT_CSV),
+	)
+	It("returns an error for a segment missing a required field", func() {
+		_, err := cluster.ParseSegmentConfiguration(strings.NewReader(`[{"dbid": 1, "port": 7000, "hostname": "cdw"}]`), cluster.SEGCONFIG_FORMAT_AUTO)
+		Expect(err).To(MatchError("Invalid segment at index 0: Missing required field contentid"))
+	})
+	It("returns an error for a non-numeric port in a CSV dump", func() {
+		_, err := cluster.ParseSegmentConfiguration(strings.NewReader("dbid,content,port,hostname\n1,-1,abc,cdw\n"), cluster.SEGCONFIG_FORMAT_AUTO)
+		Expect(err).To(MatchError("Invalid segment on line 2: Failed to convert port with value abc to an int"))
+	})
+	It("uses the file extension to choose the format", func() {
+		filename := path.Join(GinkgoT().TempDir(), "segments.json")
+		Expect(os.WriteFile(filename, []byte(jsonContents), 0600)).To(Succeed())
+		results, err := cluster.ReadSegmentConfigurationFile(filename)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).To(Equal(expected))
+	})
+})
@@ -0,0 +1,208 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to executing cluster
+ * commands in the background while polling their progress, e.g. to display
+ * the state of a long-running operation in a status page.
+ */
+
+import (
+	"sync"
+	"time"
+)
+
+/*
+ * A CommandState describes where a command is in its execution:
+ *
+ * COMMAND_RUNNING:   The command's first attempt has not yet finished.
+ * COMMAND_RETRYING:  A previous attempt failed and the command is being retried.
+ * COMMAND_SUCCEEDED: The command has finished successfully.
+ * COMMAND_FAILED:    The command has finished, and its final attempt failed.
+ */
+type CommandState int
+
+const (
+	COMMAND_RUNNING CommandState = iota
+	COMMAND_RETRYING
+	COMMAND_SUCCEEDED
+	COMMAND_FAILED
+)
+
+func (state CommandState) String() string {
+	switch state {
+	case COMMAND_RETRYING:
+		return "retrying"
+	case COMMAND_SUCCEEDED:
+		return "succeeded"
+	case COMMAND_FAILED:
+		return "failed"
+	default:
+		return "running"
+	}
+}
+
+/*
+ * A CommandStatus is a snapshot of a single command in an Execution.  Command
+ * only has its output and error fields populated once the command finishes.
+ */
+type CommandStatus struct {
+	Command ShellCommand
+	State   CommandState
+	Attempt int
+}
+
+type ExecutionProgress struct {
+	Total     int
+	Running   int
+	Retrying  int
+	Succeeded int
+	Failed    int
+}
+
+// Finished returns the number of commands that have succeeded or failed.
+func (progress ExecutionProgress) Finished() int {
+	return progress.Succeeded + progress.Failed
+}
+
+/*
+ * An Execution is a handle to a set of cluster commands running in the
+ * background.  Its methods are safe to call from any goroutine while the
+ * commands run.
+ */
+type Execution struct {
+	scope    Scope
+	mutex    sync.Mutex
+	statuses []CommandStatus
+	done     chan struct{}
+	output   *RemoteOutput
+}
+
+/*
+ * StartClusterCommandWithRetries behaves like ExecuteClusterCommandWithRetries,
+ * including running any Middleware, but returns immediately with a handle to
+ * the running commands instead of waiting for them to finish.
+ */
+func (executor *GPDBExecutor) StartClusterCommandWithRetries(scope Scope, commandList []ShellCommand, maxAttempts int, retrySleep time.Duration) *Execution {
+	execution := newExecution(scope, commandList)
+	var wg sync.WaitGroup
+	for i := range commandList {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			runCommand := executor.chain(func(command ShellCommand) ShellCommand {
+				return runWithRetriesNotify(command, maxAttempts, retrySleep, func(attempt int) {
+					execution.setAttempt(index, attempt)
+				})
+			})
+			execution.finish(index, runCommand(commandList[index]))
+		}(i)
+	}
+	go func() {
+		wg.Wait()
+		execution.complete()
+	}()
+	return execution
+}
+
+/*
+ * StartCommand is the background equivalent of GenerateAndExecuteCommand.  If
+ * the cluster's Executor is not a GPDBExecutor, per-command progress is not
+ * available, so every command is reported as running until all have finished.
+ */
+func (cluster *Cluster) StartCommand(verboseMsg string, scope Scope, generator interface{}) *Execution {
+	log.Verbose(verboseMsg)
+	commandList := cluster.GenerateSSHCommandList(scope, generator)
+	if executor, ok := cluster.Executor.(*GPDBExecutor); ok {
+		return executor.StartClusterCommandWithRetries(scope, commandList, cluster.maxAttempts(), 1*time.Second)
+	}
+	execution := newExecution(scope, commandList)
+	go func() {
+		remoteOutput := cluster.ExecuteClusterCommandWithRetries(scope, commandList, cluster.maxAttempts(), 1*time.Second)
+		for i, command := range remoteOutput.Commands {
+			execution.finish(i, command)
+		}
+		execution.complete()
+	}()
+	return execution
+}
+
+func newExecution(scope Scope, commandList []ShellCommand) *Execution {
+	statuses := make([]CommandStatus, len(commandList))
+	for i, command := range commandList {
+		statuses[i] = CommandStatus{Command: command, State: COMMAND_RUNNING}
+	}
+	return &Execution{scope: scope, statuses: statuses, done: make(chan struct{})}
+}
+
+func (execution *Execution) setAttempt(index int, attempt int) {
+	execution.mutex.Lock()
+	defer execution.mutex.Unlock()
+	execution.statuses[index].Attempt = attempt
+	if attempt > 1 {
+		execution.statuses[index].State = COMMAND_RETRYING
+	}
+}
+
+func (execution *Execution) finish(index int, command ShellCommand) {
+	execution.mutex.Lock()
+	defer execution.mutex.Unlock()
+	execution.statuses[index].Command = command
+	execution.statuses[index].State = COMMAND_SUCCEEDED
+	if command.Error != nil {
+		execution.statuses[index].State = COMMAND_FAILED
+	}
+}
+
+func (execution *Execution) complete() {
+	execution.mutex.Lock()
+	commandList := make([]ShellCommand, len(execution.statuses))
+	numErrors := 0
+	for i, status := range execution.statuses {
+		commandList[i] = status.Command
+		if status.State == COMMAND_FAILED {
+			numErrors++
+		}
+	}
+	execution.output = NewRemoteOutput(execution.scope, numErrors, commandList)
+	execution.mutex.Unlock()
+	close(execution.done)
+}
+
+func (execution *Execution) Progress() ExecutionProgress {
+	execution.mutex.Lock()
+	defer execution.mutex.Unlock()
+	progress := ExecutionProgress{Total: len(execution.statuses)}
+	for _, status := range execution.statuses {
+		switch status.State {
+		case COMMAND_RUNNING:
+			progress.Running++
+		case COMMAND_RETRYING:
+			progress.Retrying++
+		case COMMAND_SUCCEEDED:
+			progress.Succeeded++
+		case COMMAND_FAILED:
+			progress.Failed++
+		}
+	}
+	return progress
+}
+
+// PartialResults returns the current status of every command, in the order they were given.
+func (execution *Execution) PartialResults() []CommandStatus {
+	execution.mutex.Lock()
+	defer execution.mutex.Unlock()
+	return append([]CommandStatus{}, execution.statuses...)
+}
+
+// Done returns a channel that is closed once every command has finished.
+func (execution *Execution) Done() <-chan struct{} {
+	return execution.done
+}
+
+// Wait blocks until every command has finished and returns their output.
+func (execution *Execution) Wait() *RemoteOutput {
+	<-execution.done
+	execution.mutex.Lock()
+	defer execution.mutex.Unlock()
+	return execution.output
+}
@@ -0,0 +1,66 @@
+package cluster_test
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/execution tests", func() {
+	var (
+		executor *cluster.GPDBExecutor
+		tempDir  string
+		release  string
+	)
+	BeforeEach(func() {
+		executor = &cluster.GPDBExecutor{}
+		tempDir = GinkgoT().TempDir()
+		release = path.Join(tempDir, "release")
+	})
+	waitForRelease := func() string {
+		return fmt.Sprintf("while [ ! -f %s ]; do sleep 0.01; done", release)
+	}
+	It("reports running, retrying, and finished commands while they execute", func() {
+		marker := path.Join(tempDir, "marker")
+		commandList := []cluster.ShellCommand{
+			cluster.NewShellCommand(cluster.ON_SEGMENTS, 0, "", []string{"bash", "-c", "echo done"}),
+			cluster.NewShellCommand(cluster.ON_SEGMENTS, 1, "", []string{"bash", "-c", waitForRelease()}),
+			cluster.NewShellCommand(cluster.ON_SEGMENTS, 2, "", []string{"bash", "-c",
+				fmt.Sprintf("if [ ! -f %s ]; then touch %s; exit 1; fi; %s", marker, marker, waitForRelease())}),
+		}
+		execution := executor.StartClusterCommandWithRetries(cluster.ON_SEGMENTS, commandList, 2, 0)
+
+		Eventually(execution.Progress).Should(Equal(cluster.ExecutionProgress{Total: 3, Running: 1, Retrying: 1, Succeeded: 1}))
+		results := execution.PartialResults()
+		Expect(results[0].State).To(Equal(cluster.COMMAND_SUCCEEDED))
+		Expect(results[0].Command.Stdout).To(Equal("done\n"))
+		Expect(results[1].State).To(Equal(cluster.COMMAND_RUNNING))
+		Expect(results[1].Command.Completed).To(BeFalse())
+		Expect(results[2].State).To(Equal(cluster.COMMAND_RETRYING))
+		Expect(results[2].Attempt).To(Equal(2))
+		Consistently(execution.Done(), "50ms").ShouldNot(BeClosed())
+
+		Expect(os.WriteFile(release, []byte{}, 0600)).To(Succeed())
+		output := execution.Wait()
+		Expect(output.NumErrors).To(Equal(0))
+		Expect(output.RetriedCommands).To(HaveLen(1))
+		Expect(output.RetriedCommands[0].Content).To(Equal(2))
+		Expect(execution.Progress()).To(Equal(cluster.ExecutionProgress{Total: 3, Succeeded: 3}))
+		Expect(execution.Progress().Finished()).To(Equal(3))
+	})
+	It("reports failed commands", func() {
+		commandList := []cluster.ShellCommand{
+			cluster.NewShellCommand(cluster.ON_SEGMENTS, 0, "", []string{"bash", "-c", "exit 1"}),
+		}
+		execution := executor.StartClusterCommandWithRetries(cluster.ON_SEGMENTS, commandList, 1, 0)
+		output := execution.Wait()
+		Expect(output.NumErrors).To(Equal(1))
+		Expect(execution.PartialResults()[0].State).To(Equal(cluster.COMMAND_FAILED))
+		Expect(execution.Progress().Failed).To(Equal(1))
+	})
+})
@@ -0,0 +1,196 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to reading gpssh.conf, the
+ * configuration file used by gpssh and the other Python management utilities,
+ * and applying its settings to cluster command execution.
+ */
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+/*
+ * GpsshConfig holds the settings from the [gpssh] section of gpssh.conf:
+ *
+ * - DelayBeforeSend (delaybeforesend) is the minimum delay between starting
+ *   successive commands, to avoid overwhelming sshd on busy hosts.
+ * - PromptValidationTimeout (prompt_validation_timeout) is how long to wait for
+ *   a remote host to respond; it is passed to ssh as its ConnectTimeout.
+ * - SyncRetries (sync_retries) is the number of attempts made for each command
+ *   by GenerateAndExecuteCommand before it is considered to have failed.
+ */
+type GpsshConfig struct {
+	DelayBeforeSend         time.Duration
+	PromptValidationTimeout time.Duration
+	SyncRetries             int
+}
+
+// These defaults match those used by gpssh when gpssh.conf is absent.
+func DefaultGpsshConfig() GpsshConfig {
+	return GpsshConfig{
+		DelayBeforeSend:         50 * time.Millisecond,
+		PromptValidationTimeout: 1 * time.Second,
+		SyncRetries:             3,
+	}
+}
+
+// GetGpsshConfigPath returns the location of gpssh.conf in the current installation.
+func GetGpsshConfigPath() string {
+	return path.Join(operating.System.Getenv("GPHOME"), "bin", "gpssh.conf")
+}
+
+/*
+ * ReadGpsshConfig parses the given gpssh.conf file.  Settings not present in
+ * the file keep their default values, and sections and keys other than those
+ * described above are ignored, as gpssh itself does.
+ */
+func ReadGpsshConfig(filename string) (GpsshConfig, error) {
+	config := DefaultGpsshConfig()
+	fd, err := os.Open(filename)
+	if err != nil {
+		return config, errors.Errorf("Failed to open file %s. Error: %s", filename, err.Error())
+	}
+	defer fd.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(fd)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if section != "gpssh" {
+			continue
+		}
+		separator := strings.IndexAny(line, "=:")
+		if separator == -1 {
+			return config, errors.Errorf("Invalid line %d in %s: %s", lineNum, filename, line)
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:separator]))
+		value := strings.TrimSpace(line[separator+1:])
+		switch key {
+		case "delaybeforesend":
+			config.DelayBeforeSend, err = parseSeconds(value)
+		case "prompt_validation_timeout":
+			config.PromptValidationTimeout, err = parseSeconds(value)
+		case "sync_retries":
+			config.SyncRetries, err = strconv.Atoi(value)
+			if err == nil && config.SyncRetries < 1 {
+				err = errors.New("must be at least 1")
+			}
+		}
+		if err != nil {
+			return config, errors.Errorf("Invalid value for %s in %s: %s", key, filename, err.Error())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return config, errors.Errorf("Failed to read file %s. Error: %s", filename, err.Error())
+	}
+	return config, nil
+}
+
+func parseSeconds(value string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if seconds < 0 {
+		return 0, errors.New("must not be negative")
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+/*
+ * ApplyGpsshConfig configures the cluster to behave as gpssh would with the
+ * given settings.  The delay between commands is enforced by middleware, and so
+ * only applies if the cluster uses a GPDBExecutor.
+ *
+ * Settings that are zero leave the cluster as it is.  Calling this again
+ * replaces the earlier settings rather than adding to them: any ConnectTimeout
+ * already in the ssh flags is replaced, and the middleware is only added to
+ * the executor once, with its delay updated by later calls.
+ */
+func (cluster *Cluster) ApplyGpsshConfig(config GpsshConfig) {
+	if config.PromptValidationTimeout > 0 {
+		timeout := int(math.Ceil(config.PromptValidationTimeout.Seconds()))
+		cluster.SSHOptions.ExtraFlags = append(withoutSSHOption(cluster.SSHOptions.ExtraFlags, "ConnectTimeout"), "-o", fmt.Sprintf("ConnectTimeout=%d", timeout))
+	}
+	if executor, ok := cluster.Executor.(*GPDBExecutor); ok && config.DelayBeforeSend > 0 {
+		if cluster.gpsshStagger == nil {
+			cluster.gpsshStagger = &stagger{}
+			executor.Use(cluster.gpsshStagger.middleware)
+		}
+		cluster.gpsshStagger.setDelay(config.DelayBeforeSend)
+	}
+	if config.SyncRetries > 0 {
+		cluster.SyncRetries = config.SyncRetries
+	}
+}
+
+// withoutSSHOption returns flags without any "-o name=value" or "-oname=value" setting the given option.
+func withoutSSHOption(flags []string, name string) []string {
+	prefix := strings.ToLower(name) + "="
+	remaining := make([]string, 0, len(flags))
+	for i := 0; i < len(flags); i++ {
+		if flags[i] == "-o" && i+1 < len(flags) && strings.HasPrefix(strings.ToLower(flags[i+1]), prefix) {
+			i++
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(flags[i]), "-o"+prefix) {
+			continue
+		}
+		remaining = append(remaining, flags[i])
+	}
+	return remaining
+}
+
+/*
+ * StaggerMiddleware ensures that at least delay elapses between the start of
+ * any two commands, while still allowing them to run in parallel once started.
+ */
+func StaggerMiddleware(delay time.Duration) Middleware {
+	return (&stagger{delay: delay}).middleware
+}
+
+type stagger struct {
+	mutex     sync.Mutex
+	delay     time.Duration
+	nextStart time.Time
+}
+
+func (stagger *stagger) setDelay(delay time.Duration) {
+	stagger.mutex.Lock()
+	defer stagger.mutex.Unlock()
+	stagger.delay = delay
+}
+
+func (stagger *stagger) middleware(next CommandFunc) CommandFunc {
+	return func(command ShellCommand) ShellCommand {
+		stagger.mutex.Lock()
+		now := time.Now()
+		start := stagger.nextStart
+		if start.Before(now) {
+			start = now
+		}
+		stagger.nextStart = start.Add(stagger.delay)
+		stagger.mutex.Unlock()
+		time.Sleep(time.Until(start))
+		return next(command)
+	}
+}
@@ -0,0 +1,115 @@
+package cluster_test
+
+import (
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/gpssh_conf tests", func() {
+	var confFile string
+	writeConf := func(contents string) {
+		Expect(os.WriteFile(confFile, []byte(contents), 0644)).To(Succeed())
+	}
+	BeforeEach(func() {
+		confFile = path.Join(GinkgoT().TempDir(), "gpssh.conf")
+	})
+	Describe("ReadGpsshConfig", func() {
+		It("reads settings from the gpssh section", func() {
+			writeConf(`# gpssh settings
+[gpssh]
+delaybeforesend = 0.5
+prompt_validation_timeout = 2.5
+sync_retries = 7
+
+[other]
+sync_retries = 9
+`)
+			config, err := cluster.ReadGpsshConfig(confFile)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config).To(Equal(cluster.GpsshConfig{
+				DelayBeforeSend:         500 * time.Millisecond,
+				PromptValidationTimeout: 2500 * time.Millisecond,
+				SyncRetries:             7,
+			}))
+		})
+		It("uses defaults for settings not present in the file", func() {
+			writeConf("[gpssh]\nsync_retries: 4\n")
+			config, err := cluster.ReadGpsshConfig(confFile)
+			Expect(err).ToNot(HaveOccurred())
+			expected := cluster.DefaultGpsshConfig()
+			expected.SyncRetries = 4
+			Expect(config).To(Equal(expected))
+		})
+		It("returns an error for an invalid value", func() {
+			writeConf("[gpssh]\ndelaybeforesend = soon\n")
+			_, err := cluster.ReadGpsshConfig(confFile)
+			Expect(err).To(MatchError(ContainSubstring("Invalid value for delaybeforesend")))
+		})
+		It("returns an error for a missing file", func() {
+			_, err := cluster.ReadGpsshConfig(path.Join(path.Dir(confFile), "missing.conf"))
+			Expect(err).To(MatchError(ContainSubstring("Failed to open file")))
+		})
+		It("finds the file under GPHOME", func() {
+			operating.System.Getenv = func(key string) string { return "/usr/local/cloudberry" }
+			defer func() { operating.System.Getenv = os.Getenv }()
+			Expect(cluster.GetGpsshConfigPath()).To(Equal("/usr/local/cloudberry/bin/gpssh.conf"))
+		})
+	})
+	Describe("Cluster.ApplyGpsshConfig", func() {
+		It("sets the ssh connect timeout, stagger delay, and retry count", func() {
+			executor := &cluster.GPDBExecutor{}
+			testCluster := cluster.NewCluster([]cluster.SegConfig{{ContentID: -1, Hostname: "localhost", Role: "p"}})
+			testCluster.Executor = executor
+			testCluster.ApplyGpsshConfig(cluster.GpsshConfig{DelayBeforeSend: time.Millisecond, PromptValidationTimeout: 1500 * time.Millisecond, SyncRetries: 2})
+
+			Expect(testCluster.SSHOptions.ExtraFlags).To(Equal([]string{"-o", "ConnectTimeout=2"}))
+			Expect(executor.Middleware).To(HaveLen(1))
+			Expect(testCluster.SyncRetries).To(Equal(2))
+		})
+		It("replaces the settings of an earlier call rather than adding to them", func() {
+			executor := &cluster.GPDBExecutor{}
+			testCluster := cluster.NewCluster([]cluster.SegConfig{{ContentID: -1, Hostname: "localhost", Role: "p"}})
+			testCluster.Executor = executor
+			testCluster.SSHOptions.ExtraFlags = []string{"-oConnectTimeout=30", "-o", "ServerAliveInterval=10"}
+			testCluster.ApplyGpsshConfig(cluster.GpsshConfig{DelayBeforeSend: time.Millisecond, PromptValidationTimeout: time.Second})
+			testCluster.ApplyGpsshConfig(cluster.GpsshConfig{DelayBeforeSend: time.Millisecond, PromptValidationTimeout: 5 * time.Second})
+
+			Expect(testCluster.SSHOptions.ExtraFlags).To(Equal([]string{"-o", "ServerAliveInterval=10", "-o", "ConnectTimeout=5"}))
+			Expect(executor.Middleware).To(HaveLen(1))
+		})
+	})
+	Describe("StaggerMiddleware", func() {
+		It("spaces out the start of each command", func() {
+			delay := 20 * time.Millisecond
+			var mutex sync.Mutex
+			starts := make([]time.Time, 0)
+			run := cluster.StaggerMiddleware(delay)(func(command cluster.ShellCommand) cluster.ShellCommand {
+				mutex.Lock()
+				starts = append(starts, time.Now())
+				mutex.Unlock()
+				return command
+			})
+			var wg sync.WaitGroup
+			for i := 0; i < 3; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					run(cluster.ShellCommand{})
+				}()
+			}
+			wg.Wait()
+			Expect(starts).To(HaveLen(3))
+			sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+			Expect(starts[2].Sub(starts[0])).To(BeNumerically(">=", 2*delay-time.Millisecond))
+		})
+	})
+})
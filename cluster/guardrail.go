@@ -0,0 +1,265 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to guarding destructive
+ * cluster operations behind configurable policies.
+ */
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * An OperationKind identifies the type of destructive operation a command
+ * performs, so that policies can decide which operations they apply to.
+ * NOT_DESTRUCTIVE commands are never checked against any policy.
+ */
+type OperationKind int
+
+const (
+	NOT_DESTRUCTIVE OperationKind = iota
+	REMOVE_FILES
+	STOP_SEGMENT
+	CATALOG_UPDATE
+)
+
+// String returns a verb phrase for the kind of operation, for messages such as "Cannot stop a segment without the --force flag".
+func (kind OperationKind) String() string {
+	switch kind {
+	case REMOVE_FILES:
+		return "remove files"
+	case STOP_SEGMENT:
+		return "stop a segment"
+	case CATALOG_UPDATE:
+		return "update the catalog"
+	default:
+		return "perform a non-destructive operation"
+	}
+}
+
+/*
+ * An Operation describes a single destructive action to be checked against
+ * the configured policies.  Paths holds any filesystem paths the operation
+ * touches, and Force records whether the user passed a --force flag or
+ * equivalent for the operation.
+ */
+type Operation struct {
+	Kind        OperationKind
+	Description string
+	Paths       []string
+	Force       bool
+}
+
+type Policy interface {
+	Check(op Operation) error
+}
+
+/*
+ * PathAllowList rejects any operation touching a path that is not contained in
+ * one of the allowed directories.  Paths are cleaned before comparison, so
+ * "/data/../etc" will not pass an allow-list containing "/data".
+ */
+type PathAllowList struct {
+	AllowedDirs []string
+}
+
+func (policy PathAllowList) Check(op Operation) error {
+	for _, opPath := range op.Paths {
+		if !policy.allows(opPath) {
+			return errors.Errorf("Cannot %s: path %s is not in the list of allowed directories", op.Kind, opPath)
+		}
+	}
+	return nil
+}
+
+func (policy PathAllowList) allows(opPath string) bool {
+	cleanPath := filepath.Clean(opPath)
+	if !filepath.IsAbs(cleanPath) {
+		return false
+	}
+	for _, dir := range policy.AllowedDirs {
+		cleanDir := filepath.Clean(dir)
+		if cleanPath == cleanDir || strings.HasPrefix(cleanPath, cleanDir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * RequireForce rejects any operation of the listed kinds that was not called
+ * with Force set.  If Kinds is empty, all destructive operations require it.
+ */
+type RequireForce struct {
+	Kinds []OperationKind
+}
+
+func (policy RequireForce) Check(op Operation) error {
+	if op.Force {
+		return nil
+	}
+	if len(policy.Kinds) == 0 {
+		return errors.Errorf("Cannot %s without the --force flag", op.Kind)
+	}
+	for _, kind := range policy.Kinds {
+		if kind == op.Kind {
+			return errors.Errorf("Cannot %s without the --force flag", op.Kind)
+		}
+	}
+	return nil
+}
+
+/*
+ * A ConfirmationHook is called with each operation before it is performed, so
+ * that utilities can prompt the user interactively.  Returning false cancels
+ * the operation; returning an error aborts it with that error.
+ */
+type ConfirmationHook func(op Operation) (bool, error)
+
+func (hook ConfirmationHook) Check(op Operation) error {
+	confirmed, err := hook(op)
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		return errors.Errorf("Cannot %s: operation was not confirmed", op.Kind)
+	}
+	return nil
+}
+
+/*
+ * Guardrails holds the set of policies every destructive operation must pass.
+ * A nil *Guardrails allows all operations, so callers that do not configure
+ * any policies keep the previous behavior.
+ */
+type Guardrails struct {
+	Policies []Policy
+}
+
+func NewGuardrails(policies ...Policy) *Guardrails {
+	return &Guardrails{Policies: policies}
+}
+
+func (guardrails *Guardrails) Check(op Operation) error {
+	if guardrails == nil || op.Kind == NOT_DESTRUCTIVE {
+		return nil
+	}
+	for _, policy := range guardrails.Policies {
+		if err := policy.Check(op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * CheckCommands classifies each command in the list and checks every
+ * destructive one against the configured policies, returning the first error
+ * encountered.  All commands are checked before any are executed, so a single
+ * disallowed command prevents the entire list from running.
+ */
+func (guardrails *Guardrails) CheckCommands(commandList []ShellCommand, force bool) error {
+	if guardrails == nil {
+		return nil
+	}
+	for _, command := range commandList {
+		if err := guardrails.checkCommand(command.CommandString, force); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCommand checks every operation a single command string performs.
+func (guardrails *Guardrails) checkCommand(commandStr string, force bool) error {
+	for _, op := range ClassifyCommand(commandStr) {
+		op.Force = force
+		if err := guardrails.Check(op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	removeCommandRegex = regexp.MustCompile("(?:^|[\\s;&|('\"`])(?:[^\\s;&|('\"`]*/)?rm\\s+([^;&|)`]*)")
+	redirectionRegex   = regexp.MustCompile(`^[0-9]*(?:<|>>?)`)
+	stopCommandRegex   = regexp.MustCompile(`\bpg_ctl\b[^;&|]*\bstop\b`)
+	catalogUpdateRegex = regexp.MustCompile(`(?i)\b(UPDATE|DELETE\s+FROM|INSERT\s+INTO|ALTER\s+TABLE)\s+(pg_catalog\.)?(gp|pg)_\w+`)
+)
+
+/*
+ * ClassifyCommand makes a best-effort determination of which destructive
+ * operations a shell command string performs, based on the commands it
+ * contains, and returns one Operation for each kind found.  A command such as
+ * "rm -f /data/x; pg_ctl stop" yields both a REMOVE_FILES and a STOP_SEGMENT
+ * operation, and a non-destructive command yields none.
+ *
+ * It recognizes rm by path (e.g. /bin/rm) and inside "sh -c '...'" and
+ * "$(...)", and ignores redirections such as 2>/dev/null when collecting the
+ * paths removed.  It is intended as a safety net for commands generated by this
+ * library's callers, not as a defense against deliberately obfuscated commands.
+ */
+func ClassifyCommand(commandStr string) []Operation {
+	var ops []Operation
+	if matches := removeCommandRegex.FindAllStringSubmatch(commandStr, -1); matches != nil {
+		op := Operation{Kind: REMOVE_FILES, Description: commandStr}
+		for _, match := range matches {
+			op.Paths = append(op.Paths, removedPaths(match[1])...)
+		}
+		ops = append(ops, op)
+	}
+	if stopCommandRegex.MatchString(commandStr) {
+		ops = append(ops, Operation{Kind: STOP_SEGMENT, Description: commandStr})
+	}
+	if catalogUpdateRegex.MatchString(commandStr) {
+		ops = append(ops, Operation{Kind: CATALOG_UPDATE, Description: commandStr})
+	}
+	return ops
+}
+
+// removedPaths returns the path arguments of an rm command, skipping flags and redirections.
+func removedPaths(args string) []string {
+	var paths []string
+	fields := strings.Fields(args)
+	for i := 0; i < len(fields); i++ {
+		arg := strings.Trim(fields[i], `'"`)
+		if operator := redirectionRegex.FindString(arg); operator != "" {
+			if operator == arg {
+				i++ // The redirection target is the next word, as in "2> /dev/null".
+			}
+			continue
+		}
+		if arg != "" && !strings.HasPrefix(arg, "-") {
+			paths = append(paths, arg)
+		}
+	}
+	return paths
+}
+
+/*
+ * This function behaves like GenerateAndExecuteCommand, but checks the
+ * generated commands against the cluster's Guardrails before executing them
+ * and returns an error without executing anything if any check fails.
+ */
+func (cluster *Cluster) GenerateAndExecuteGuardedCommand(verboseMsg string, scope Scope, generator interface{}, force bool) (*RemoteOutput, error) {
+	log.Verbose(verboseMsg)
+	return cluster.ExecuteGuardedCommandList(scope, cluster.GenerateSSHCommandList(scope, generator), force)
+}
+
+/*
+ * ExecuteGuardedCommandList checks an already generated command list against
+ * the cluster's Guardrails and, if every check passes, executes it with the
+ * cluster's usual retries.
+ */
+func (cluster *Cluster) ExecuteGuardedCommandList(scope Scope, commandList []ShellCommand, force bool) (*RemoteOutput, error) {
+	if err := cluster.Guardrails.CheckCommands(commandList, force); err != nil {
+		return nil, err
+	}
+	return cluster.ExecuteClusterCommandWithRetries(scope, commandList, cluster.maxAttempts(), 1*time.Second), nil
+}
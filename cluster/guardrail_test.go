@@ -0,0 +1,157 @@
+package cluster_test
+
+import (
+	"errors"
+	"os/user"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/guardrail tests", func() {
+	Describe("ClassifyCommand", func() {
+		It("classifies an rm command and extracts its paths", func() {
+			ops := cluster.ClassifyCommand("ssh -o StrictHostKeyChecking=no user@host rm -rf /data/gpseg0 '/data/gpseg1'")
+			Expect(ops).To(HaveLen(1))
+			Expect(ops[0].Kind).To(Equal(cluster.REMOVE_FILES))
+			Expect(ops[0].Paths).To(Equal([]string{"/data/gpseg0", "/data/gpseg1"}))
+		})
+		It("classifies a pg_ctl stop command", func() {
+			ops := cluster.ClassifyCommand("bash -c pg_ctl -D /data/gpseg0 stop -m fast")
+			Expect(ops).To(HaveLen(1))
+			Expect(ops[0].Kind).To(Equal(cluster.STOP_SEGMENT))
+		})
+		It("classifies a catalog update", func() {
+			ops := cluster.ClassifyCommand(`psql -c "UPDATE gp_segment_configuration SET role = 'p'"`)
+			Expect(ops).To(HaveLen(1))
+			Expect(ops[0].Kind).To(Equal(cluster.CATALOG_UPDATE))
+		})
+		It("does not classify a non-destructive command", func() {
+			Expect(cluster.ClassifyCommand("bash -c ls /data/gpseg0; echo firm")).To(BeEmpty())
+		})
+		It("returns every operation in a chained command", func() {
+			ops := cluster.ClassifyCommand("rm -f /data/x; pg_ctl stop -D /data/gpseg0 && psql -c 'DELETE FROM gp_configuration_history'")
+			Expect(ops).To(HaveLen(3))
+			Expect(ops[0].Kind).To(Equal(cluster.REMOVE_FILES))
+			Expect(ops[0].Paths).To(Equal([]string{"/data/x"}))
+			Expect(ops[1].Kind).To(Equal(cluster.STOP_SEGMENT))
+			Expect(ops[2].Kind).To(Equal(cluster.CATALOG_UPDATE))
+		})
+		DescribeTable("finds rm however it is invoked", func(commandStr string) {
+			ops := cluster.ClassifyCommand(commandStr)
+			Expect(ops).To(HaveLen(1))
+			Expect(ops[0].Kind).To(Equal(cluster.REMOVE_FILES))
+			Expect(ops[0].Paths).To(Equal([]string{"/data/x"}))
+		},
+			Entry("by absolute path", "/bin/rm -rf /data/x"),
+			Entry("inside sh -c", "sh -c 'rm -rf /data/x'"),
+			Entry("inside a command substitution", "echo $(rm -rf /data/x)"),
+			Entry("inside backticks", "echo `rm -rf /data/x`"),
+		)
+		DescribeTable("does not treat redirections as paths", func(commandStr string) {
+			ops := cluster.ClassifyCommand(commandStr)
+			Expect(ops).To(HaveLen(1))
+			Expect(ops[0].Paths).To(Equal([]string{"/data/x"}))
+		},
+			Entry("attached to the operator", "rm -rf /data/x 2>/dev/null"),
+			Entry("separated from the operator", "rm -rf /data/x > /tmp/rm.log"),
+			Entry("appending", "rm -rf /data/x >>/tmp/rm.log"),
+			Entry("duplicating a descriptor", "rm -rf /data/x 2>&1"),
+		)
+	})
+	Describe("Guardrails.Check", func() {
+		It("allows everything if no guardrails are configured", func() {
+			var guardrails *cluster.Guardrails
+			err := guardrails.Check(cluster.Operation{Kind: cluster.REMOVE_FILES, Paths: []string{"/"}})
+			Expect(err).ToNot(HaveOccurred())
+		})
+		It("allows paths inside the allow-list", func() {
+			guardrails := cluster.NewGuardrails(cluster.PathAllowList{AllowedDirs: []string{"/data"}})
+			err := guardrails.Check(cluster.Operation{Kind: cluster.REMOVE_FILES, Paths: []string{"/data/gpseg0"}})
+			Expect(err).ToNot(HaveOccurred())
+		})
+		It("rejects paths that escape the allow-list", func() {
+			guardrails := cluster.NewGuardrails(cluster.PathAllowList{AllowedDirs: []string{"/data"}})
+			err := guardrails.Check(cluster.Operation{Kind: cluster.REMOVE_FILES, Paths: []string{"/data/../etc"}})
+			Expect(err).To(MatchError("Cannot remove files: path /data/../etc is not in the list of allowed directories"))
+		})
+		It("rejects relative paths and sibling directories sharing a prefix", func() {
+			guardrails := cluster.NewGuardrails(cluster.PathAllowList{AllowedDirs: []string{"/data"}})
+			Expect(guardrails.Check(cluster.Operation{Kind: cluster.REMOVE_FILES, Paths: []string{"gpseg0"}})).To(HaveOccurred())
+			Expect(guardrails.Check(cluster.Operation{Kind: cluster.REMOVE_FILES, Paths: []string{"/data2/gpseg0"}})).To(HaveOccurred())
+		})
+		It("requires force for the configured operation kinds only", func() {
+			guardrails := cluster.NewGuardrails(cluster.RequireForce{Kinds: []cluster.OperationKind{cluster.STOP_SEGMENT}})
+			Expect(guardrails.Check(cluster.Operation{Kind: cluster.STOP_SEGMENT})).To(MatchError("Cannot stop a segment without the --force flag"))
+			Expect(guardrails.Check(cluster.Operation{Kind: cluster.STOP_SEGMENT, Force: true})).To(Succeed())
+			Expect(guardrails.Check(cluster.Operation{Kind: cluster.CATALOG_UPDATE})).To(Succeed())
+		})
+		It("requires force for all operations if no kinds are given", func() {
+			guardrails := cluster.NewGuardrails(cluster.RequireForce{})
+			Expect(guardrails.Check(cluster.Operation{Kind: cluster.CATALOG_UPDATE})).To(MatchError("Cannot update the catalog without the --force flag"))
+		})
+		It("calls the confirmation hook and honors its answer", func() {
+			var confirmed cluster.Operation
+			guardrails := cluster.NewGuardrails(cluster.ConfirmationHook(func(op cluster.Operation) (bool, error) {
+				confirmed = op
+				return false, nil
+			}))
+			err := guardrails.Check(cluster.Operation{Kind: cluster.STOP_SEGMENT, Description: "stop it"})
+			Expect(err).To(MatchError("Cannot stop a segment: operation was not confirmed"))
+			Expect(confirmed.Description).To(Equal("stop it"))
+		})
+		It("returns an error from the confirmation hook", func() {
+			guardrails := cluster.NewGuardrails(cluster.ConfirmationHook(func(op cluster.Operation) (bool, error) {
+				return false, errors.New("no terminal")
+			}))
+			Expect(guardrails.Check(cluster.Operation{Kind: cluster.STOP_SEGMENT})).To(MatchError("no terminal"))
+		})
+	})
+	Describe("Guardrails.CheckCommands", func() {
+		It("checks every operation in a chained command", func() {
+			guardrails := cluster.NewGuardrails(cluster.PathAllowList{AllowedDirs: []string{"/allowed"}}, cluster.RequireForce{Kinds: []cluster.OperationKind{cluster.STOP_SEGMENT}})
+			commandList := []cluster.ShellCommand{{CommandString: "rm -f /allowed/x; pg_ctl stop -D /data"}}
+			Expect(guardrails.CheckCommands(commandList, false)).To(MatchError("Cannot stop a segment without the --force flag"))
+			Expect(guardrails.CheckCommands(commandList, true)).To(Succeed())
+		})
+		It("allows a removal whose output is redirected", func() {
+			guardrails := cluster.NewGuardrails(cluster.PathAllowList{AllowedDirs: []string{"/data"}})
+			commandList := []cluster.ShellCommand{{CommandString: "rm -rf /data/x 2>/dev/null"}}
+			Expect(guardrails.CheckCommands(commandList, false)).To(Succeed())
+		})
+	})
+	Describe("GenerateAndExecuteGuardedCommand", func() {
+		var (
+			testCluster  *cluster.Cluster
+			testExecutor *testhelper.TestExecutor
+		)
+		BeforeEach(func() {
+			operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "testUser", HomeDir: "testDir"}, nil }
+			testExecutor = &testhelper.TestExecutor{ClusterOutput: &cluster.RemoteOutput{}}
+			testCluster = cluster.NewCluster([]cluster.SegConfig{
+				{DbID: 1, ContentID: -1, Port: 5432, Hostname: "localhost", DataDir: "/data/gpseg-1", Role: "p"},
+				{DbID: 2, ContentID: 0, Port: 20000, Hostname: "remotehost", DataDir: "/data/gpseg0", Role: "p"},
+			})
+			testCluster.Executor = testExecutor
+			testCluster.Guardrails = cluster.NewGuardrails(cluster.PathAllowList{AllowedDirs: []string{"/data"}}, cluster.RequireForce{})
+		})
+		It("executes commands that pass all policies", func() {
+			_, err := testCluster.GenerateAndExecuteGuardedCommand("Removing files", cluster.ON_SEGMENTS, func(content int) string {
+				return "rm -rf /data/gpseg0/pg_log"
+			}, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(testExecutor.NumExecutions).To(Equal(1))
+		})
+		It("does not execute any commands if a policy fails", func() {
+			_, err := testCluster.GenerateAndExecuteGuardedCommand("Removing files", cluster.ON_SEGMENTS, func(content int) string {
+				return "rm -rf /data/gpseg0/pg_log"
+			}, false)
+			Expect(err).To(MatchError("Cannot remove files without the --force flag"))
+			Expect(testExecutor.NumExecutions).To(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,245 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to periodically probing
+ * segment postmasters to track their availability.
+ */
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+)
+
+type Availability int
+
+const (
+	AVAILABILITY_UNKNOWN Availability = iota
+	AVAILABLE
+	UNAVAILABLE
+)
+
+func (availability Availability) String() string {
+	switch availability {
+	case AVAILABLE:
+		return "available"
+	case UNAVAILABLE:
+		return "unavailable"
+	default:
+		return "unknown"
+	}
+}
+
+/*
+ * SegmentHealth holds the rolling availability state of a single segment.
+ * A segment is only marked UNAVAILABLE after FailureThreshold consecutive
+ * failed probes, so a single dropped packet does not generate an event, but
+ * it is marked AVAILABLE again as soon as one probe succeeds.
+ */
+type SegmentHealth struct {
+	Segment             SegConfig
+	Availability        Availability
+	ConsecutiveFailures int
+	LastError           error
+	LastProbe           time.Time
+}
+
+type AvailabilityEvent struct {
+	Segment  SegConfig
+	Previous Availability
+	Current  Availability
+	Error    error
+	Time     time.Time
+}
+
+/*
+ * A ProbeFunc checks whether a single segment is reachable, returning nil if
+ * it is.  It should return promptly once ctx is done.
+ */
+type ProbeFunc func(ctx context.Context, segment SegConfig) error
+
+func TCPProbe(ctx context.Context, segment SegConfig) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(unbracketHost(segment.Hostname), strconv.Itoa(segment.Port)))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+/*
+ * UtilityModeProbe returns a ProbeFunc that, in addition to checking that the
+ * postmaster port is open, connects to the segment in utility mode and runs
+ * SELECT 1, which also catches segments that accept connections but cannot
+ * service queries (e.g. a mirror still in recovery).
+ */
+func UtilityModeProbe(dbname string) ProbeFunc {
+	return func(ctx context.Context, segment SegConfig) error {
+		if err := TCPProbe(ctx, segment); err != nil {
+			return err
+		}
+		conn, err := ConnectToSegConfig(segment, SegConnDatabase(dbname), SegConnUtilityMode(true), SegConnUseHostname())
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		_, err = conn.ExecContext(ctx, "SELECT 1")
+		return err
+	}
+}
+
+/*
+ * A HealthPoller probes every segment in a cluster once per Interval and
+ * calls OnChange whenever a segment's availability changes.  The default
+ * probe is TCPProbe with a timeout of Timeout per segment, and all segments
+ * are probed in parallel.  OnChange is called from the goroutine running
+ * PollOnce after all probes in the poll have finished, one event at a time
+ * in segment order, so calls never overlap.
+ *
+ * A HealthPoller may be created with NewHealthPoller or as a struct literal;
+ * a zero Timeout or nil Probe gets the same default as NewHealthPoller.  The
+ * fields should be set before calling Run and not modified afterward.
+ */
+type HealthPoller struct {
+	Cluster          *Cluster
+	Interval         time.Duration
+	Timeout          time.Duration
+	FailureThreshold int
+	Probe            ProbeFunc
+	OnChange         func(event AvailabilityEvent)
+
+	mutex sync.Mutex
+	state map[int]*SegmentHealth
+}
+
+const defaultProbeTimeout = 5 * time.Second
+
+func NewHealthPoller(cluster *Cluster, interval time.Duration) *HealthPoller {
+	return &HealthPoller{
+		Cluster:          cluster,
+		Interval:         interval,
+		Timeout:          defaultProbeTimeout,
+		FailureThreshold: 1,
+		Probe:            TCPProbe,
+		state:            make(map[int]*SegmentHealth),
+	}
+}
+
+func (poller *HealthPoller) timeout() time.Duration {
+	if poller.Timeout <= 0 {
+		return defaultProbeTimeout
+	}
+	return poller.Timeout
+}
+
+func (poller *HealthPoller) probe() ProbeFunc {
+	if poller.Probe == nil {
+		return TCPProbe
+	}
+	return poller.Probe
+}
+
+/*
+ * Run polls the cluster until ctx is cancelled.  The first poll happens
+ * immediately, so that the state is populated as soon as possible.
+ */
+func (poller *HealthPoller) Run(ctx context.Context) {
+	ticker := time.NewTicker(poller.Interval)
+	defer ticker.Stop()
+	for {
+		poller.PollOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+/*
+ * PollOnce probes every segment once.  Probes that fail because ctx itself
+ * was cancelled say nothing about the segment, so they are not recorded and
+ * do not generate events.
+ */
+func (poller *HealthPoller) PollOnce(ctx context.Context) {
+	segments := poller.Cluster.SegmentsSnapshot()
+	events := make([]*AvailabilityEvent, len(segments))
+	probe := poller.probe()
+	var wg sync.WaitGroup
+	for i, segment := range segments {
+		wg.Add(1)
+		go func(i int, segment SegConfig) {
+			defer wg.Done()
+			probeCtx, cancel := context.WithTimeout(ctx, poller.timeout())
+			defer cancel()
+			err := probe(probeCtx, segment)
+			if ctx.Err() != nil {
+				return
+			}
+			events[i] = poller.record(segment, err)
+		}(i, segment)
+	}
+	wg.Wait()
+	if poller.OnChange == nil {
+		return
+	}
+	for _, event := range events {
+		if event != nil {
+			poller.OnChange(*event)
+		}
+	}
+}
+
+// record updates the segment's state and returns an event if its availability changed, or nil otherwise.
+func (poller *HealthPoller) record(segment SegConfig, err error) *AvailabilityEvent {
+	poller.mutex.Lock()
+	defer poller.mutex.Unlock()
+	if poller.state == nil {
+		poller.state = make(map[int]*SegmentHealth)
+	}
+	health, ok := poller.state[segment.DbID]
+	if !ok {
+		health = &SegmentHealth{Segment: segment}
+		poller.state[segment.DbID] = health
+	}
+	previous := health.Availability
+	health.Segment = segment
+	health.LastError = err
+	health.LastProbe = operating.System.Now()
+	if err == nil {
+		health.ConsecutiveFailures = 0
+		health.Availability = AVAILABLE
+	} else {
+		health.ConsecutiveFailures++
+		if health.ConsecutiveFailures >= poller.FailureThreshold {
+			health.Availability = UNAVAILABLE
+		}
+	}
+	if previous == health.Availability {
+		return nil
+	}
+	return &AvailabilityEvent{Segment: segment, Previous: previous, Current: health.Availability, Error: err, Time: health.LastProbe}
+}
+
+/*
+ * StateForContent returns a copy of the current health of each segment with
+ * the given content id, or an empty slice if none have been probed yet.
+ */
+func (poller *HealthPoller) StateForContent(contentID int) []SegmentHealth {
+	poller.mutex.Lock()
+	defer poller.mutex.Unlock()
+	states := make([]SegmentHealth, 0)
+	for _, segment := range poller.Cluster.SegmentsSnapshot() {
+		if segment.ContentID != contentID {
+			continue
+		}
+		if health, ok := poller.state[segment.DbID]; ok {
+			states = append(states, *health)
+		}
+	}
+	return states
+}
@@ -0,0 +1,154 @@
+package cluster_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/health tests", func() {
+	var (
+		testCluster *cluster.Cluster
+		poller      *cluster.HealthPoller
+		failing     map[int]bool
+		events      []cluster.AvailabilityEvent
+		eventMutex  sync.Mutex
+	)
+	BeforeEach(func() {
+		testCluster = cluster.NewCluster([]cluster.SegConfig{
+			{DbID: 1, ContentID: -1, Port: 5432, Hostname: "localhost", Role: "p"},
+			{DbID: 2, ContentID: 0, Port: 20000, Hostname: "remotehost", Role: "p"},
+			{DbID: 3, ContentID: 0, Port: 21000, Hostname: "remotehost2", Role: "m"},
+		})
+		failing = map[int]bool{}
+		events = nil
+		poller = cluster.NewHealthPoller(testCluster, time.Millisecond)
+		poller.Probe = func(ctx context.Context, segment cluster.SegConfig) error {
+			if failing[segment.DbID] {
+				return errors.New("connection refused")
+			}
+			return nil
+		}
+		poller.OnChange = func(event cluster.AvailabilityEvent) {
+			eventMutex.Lock()
+			defer eventMutex.Unlock()
+			events = append(events, event)
+		}
+	})
+	Describe("PollOnce", func() {
+		It("marks every segment available and emits an event for each on the first poll", func() {
+			poller.PollOnce(context.Background())
+			Expect(events).To(HaveLen(3))
+			states := poller.StateForContent(0)
+			Expect(states).To(HaveLen(2))
+			Expect(states[0].Availability).To(Equal(cluster.AVAILABLE))
+			Expect(states[1].Availability).To(Equal(cluster.AVAILABLE))
+		})
+		It("only emits events when availability changes", func() {
+			poller.PollOnce(context.Background())
+			events = nil
+			poller.PollOnce(context.Background())
+			Expect(events).To(BeEmpty())
+
+			failing[3] = true
+			poller.PollOnce(context.Background())
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Segment.DbID).To(Equal(3))
+			Expect(events[0].Previous).To(Equal(cluster.AVAILABLE))
+			Expect(events[0].Current).To(Equal(cluster.UNAVAILABLE))
+			Expect(events[0].Error).To(MatchError("connection refused"))
+		})
+		It("waits for the failure threshold before marking a segment unavailable", func() {
+			poller.FailureThreshold = 2
+			poller.PollOnce(context.Background())
+			failing[2] = true
+			events = nil
+
+			poller.PollOnce(context.Background())
+			Expect(events).To(BeEmpty())
+			Expect(poller.StateForContent(0)[0].ConsecutiveFailures).To(Equal(1))
+
+			poller.PollOnce(context.Background())
+			Expect(events).To(HaveLen(1))
+			Expect(poller.StateForContent(0)[0].Availability).To(Equal(cluster.UNAVAILABLE))
+		})
+		It("does not record probes that fail because the context was cancelled", func() {
+			poller.PollOnce(context.Background())
+			events = nil
+			ctx, cancel := context.WithCancel(context.Background())
+			poller.Probe = func(probeCtx context.Context, segment cluster.SegConfig) error {
+				cancel()
+				<-probeCtx.Done()
+				return probeCtx.Err()
+			}
+			poller.PollOnce(ctx)
+			Expect(events).To(BeEmpty())
+			Expect(poller.StateForContent(0)[0].Availability).To(Equal(cluster.AVAILABLE))
+			Expect(poller.StateForContent(0)[0].LastError).ToNot(HaveOccurred())
+		})
+		It("calls OnChange one event at a time in segment order", func() {
+			var active, maxActive int32
+			var dbIDs []int
+			poller.OnChange = func(event cluster.AvailabilityEvent) {
+				current := atomic.AddInt32(&active, 1)
+				defer atomic.AddInt32(&active, -1)
+				if current > atomic.LoadInt32(&maxActive) {
+					atomic.StoreInt32(&maxActive, current)
+				}
+				time.Sleep(time.Millisecond)
+				dbIDs = append(dbIDs, event.Segment.DbID)
+			}
+			poller.PollOnce(context.Background())
+			Expect(dbIDs).To(Equal([]int{1, 2, 3}))
+			Expect(maxActive).To(Equal(int32(1)))
+		})
+		It("works when created as a struct literal", func() {
+			literalPoller := &cluster.HealthPoller{Cluster: testCluster, Probe: poller.Probe}
+			literalPoller.PollOnce(context.Background())
+			Expect(literalPoller.StateForContent(0)).To(HaveLen(2))
+			Expect(literalPoller.StateForContent(0)[0].Availability).To(Equal(cluster.AVAILABLE))
+		})
+		It("returns no state for contents that have not been probed", func() {
+			Expect(poller.StateForContent(0)).To(BeEmpty())
+		})
+	})
+	Describe("Run", func() {
+		It("polls until the context is cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				poller.Run(ctx)
+				close(done)
+			}()
+			Eventually(func() int { return len(poller.StateForContent(-1)) }).Should(Equal(1))
+			cancel()
+			Eventually(done).Should(BeClosed())
+		})
+	})
+	Describe("TCPProbe", func() {
+		It("succeeds if the port is open", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			defer listener.Close()
+			port := listener.Addr().(*net.TCPAddr).Port
+			err = cluster.TCPProbe(context.Background(), cluster.SegConfig{Hostname: "127.0.0.1", Port: port})
+			Expect(err).ToNot(HaveOccurred())
+		})
+		It("fails if the port is closed", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			port := listener.Addr().(*net.TCPAddr).Port
+			listener.Close()
+			err = cluster.TCPProbe(context.Background(), cluster.SegConfig{Hostname: "127.0.0.1", Port: port})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,145 @@
+package loadtest
+
+/*
+ * This file contains structs and functions related to generating synthetic
+ * load against a cluster.Executor and measuring how it performs, so changes to
+ * the execution engine can be validated at realistic cluster scale without a
+ * real cluster.
+ *
+ * The commands run against localhost without ssh, so the results measure the
+ * overhead of dispatching commands rather than of the network.
+ */
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+)
+
+/*
+ * Config describes a single load test run:
+ *
+ * - NumCommands is the number of commands to execute at once, defaulting to 1000.
+ * - Command is the command each of them runs, defaulting to "true".
+ * - Executor defaults to a GPDBExecutor with no middleware.
+ * - SampleInterval is how often goroutine counts and memory usage are sampled
+ *   while the commands run, defaulting to 10ms.
+ */
+type Config struct {
+	NumCommands    int
+	Command        []string
+	Executor       cluster.Executor
+	SampleInterval time.Duration
+}
+
+type Result struct {
+	NumCommands    int
+	NumErrors      int
+	Duration       time.Duration
+	PeakGoroutines int
+	PeakHeapAlloc  uint64
+	TotalAlloc     uint64
+}
+
+// Throughput returns the number of commands completed per second.
+func (result Result) Throughput() float64 {
+	if result.Duration <= 0 {
+		return 0
+	}
+	return float64(result.NumCommands) / result.Duration.Seconds()
+}
+
+func (result Result) String() string {
+	return fmt.Sprintf("%d commands (%d errors) in %v: %.1f commands/sec, peak %d goroutines, peak heap %.1f MiB, %.1f MiB allocated",
+		result.NumCommands, result.NumErrors, result.Duration, result.Throughput(), result.PeakGoroutines,
+		float64(result.PeakHeapAlloc)/(1<<20), float64(result.TotalAlloc)/(1<<20))
+}
+
+func (config Config) withDefaults() Config {
+	if config.NumCommands <= 0 {
+		config.NumCommands = 1000
+	}
+	if len(config.Command) == 0 {
+		config.Command = []string{"true"}
+	}
+	if config.Executor == nil {
+		config.Executor = &cluster.GPDBExecutor{}
+	}
+	if config.SampleInterval <= 0 {
+		config.SampleInterval = 10 * time.Millisecond
+	}
+	return config
+}
+
+// GenerateCommands returns numCommands copies of command, one per fake content.
+func GenerateCommands(numCommands int, command []string) []cluster.ShellCommand {
+	commandList := make([]cluster.ShellCommand, numCommands)
+	for i := range commandList {
+		commandList[i] = cluster.NewShellCommand(cluster.ON_SEGMENTS, i, "", command)
+	}
+	return commandList
+}
+
+/*
+ * Run executes the configured commands in a single call to
+ * ExecuteClusterCommand and reports its performance.  Memory figures come from
+ * runtime.MemStats, so they include any other activity in the process.
+ */
+func Run(config Config) Result {
+	config = config.withDefaults()
+	commandList := GenerateCommands(config.NumCommands, config.Command)
+
+	var before runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	peakGoroutines := runtime.NumGoroutine()
+	peakHeapAlloc := before.HeapAlloc
+	sample := func() {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		if goroutines := runtime.NumGoroutine(); goroutines > peakGoroutines {
+			peakGoroutines = goroutines
+		}
+		if stats.HeapAlloc > peakHeapAlloc {
+			peakHeapAlloc = stats.HeapAlloc
+		}
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(config.SampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				sample()
+			}
+		}
+	}()
+
+	start := time.Now()
+	output := config.Executor.ExecuteClusterCommand(cluster.ON_SEGMENTS, commandList)
+	duration := time.Since(start)
+	close(done)
+	wg.Wait()
+	sample()
+
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+	return Result{
+		NumCommands:    config.NumCommands,
+		NumErrors:      output.NumErrors,
+		Duration:       duration,
+		PeakGoroutines: peakGoroutines,
+		PeakHeapAlloc:  peakHeapAlloc,
+		TotalAlloc:     after.TotalAlloc - before.TotalAlloc,
+	}
+}
@@ -0,0 +1,54 @@
+package loadtest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+	"github.com/cloudberrydb/gp-common-go-libs/cluster/loadtest"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestLoadTest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "loadtest tests")
+}
+
+var _ = BeforeSuite(func() {
+	testhelper.SetupTestEnvironment()
+})
+
+var _ = Describe("loadtest tests", func() {
+	Describe("GenerateCommands", func() {
+		It("generates one command per fake content", func() {
+			commandList := loadtest.GenerateCommands(3, []string{"echo", "hello"})
+			Expect(commandList).To(HaveLen(3))
+			Expect(commandList[2].Content).To(Equal(2))
+			Expect(commandList[2].CommandString).To(Equal("echo hello"))
+		})
+	})
+	Describe("Run", func() {
+		It("executes every command and reports its performance", func() {
+			result := loadtest.Run(loadtest.Config{NumCommands: 50, SampleInterval: time.Millisecond})
+			Expect(result.NumCommands).To(Equal(50))
+			Expect(result.NumErrors).To(Equal(0))
+			Expect(result.Duration).To(BeNumerically(">", 0))
+			Expect(result.Throughput()).To(BeNumerically(">", 0))
+			Expect(result.PeakGoroutines).To(BeNumerically(">", 1))
+			Expect(result.String()).To(ContainSubstring("50 commands (0 errors)"))
+		})
+		It("counts failed commands", func() {
+			result := loadtest.Run(loadtest.Config{NumCommands: 5, Command: []string{"false"}})
+			Expect(result.NumErrors).To(Equal(5))
+		})
+		It("uses the given executor", func() {
+			executor := &cluster.GPDBExecutor{}
+			executor.Use(cluster.DryRunMiddleware())
+			result := loadtest.Run(loadtest.Config{NumCommands: 5, Command: []string{"false"}, Executor: executor})
+			Expect(result.NumErrors).To(Equal(0))
+		})
+	})
+})
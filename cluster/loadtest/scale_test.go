@@ -0,0 +1,27 @@
+//go:build loadtest
+
+package loadtest_test
+
+import (
+	"fmt"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster/loadtest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+/*
+ * These tests run the executor at the scale of a large cluster, and so only
+ * run when built with the "loadtest" tag, e.g. via "make loadtest".
+ */
+var _ = Describe("loadtest scale tests", func() {
+	for _, numCommands := range []int{1000, 5000, 10000} {
+		numCommands := numCommands
+		It(fmt.Sprintf("executes %d commands", numCommands), func() {
+			result := loadtest.Run(loadtest.Config{NumCommands: numCommands})
+			GinkgoWriter.Println(result.String())
+			Expect(result.NumErrors).To(Equal(0))
+		})
+	}
+})
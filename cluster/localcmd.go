@@ -0,0 +1,84 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to executing commands on
+ * the local host with more control than ExecuteLocalCommand provides.
+ */
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * LocalCommandOptions controls how ExecuteLocalCommandWithOptions runs a
+ * command; the zero value behaves like ExecuteLocalCommand, apart from
+ * capturing stdout and stderr separately.
+ *
+ * - Env holds "KEY=value" pairs added to the current process's environment, or
+ *   replacing it entirely if ReplaceEnv is set.
+ * - Dir is the working directory, defaulting to the current one.
+ * - Stdin, if set, is read as the command's standard input.
+ * - Context, if set, kills the command when it is done.
+ */
+type LocalCommandOptions struct {
+	Env        []string
+	ReplaceEnv bool
+	Dir        string
+	Stdin      io.Reader
+	Context    context.Context
+}
+
+/*
+ * LocalCommandResult holds the output of a local command.  ExitCode is -1 if
+ * the command could not be started or was killed by a signal.
+ */
+type LocalCommandResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+func (result *LocalCommandResult) Succeeded() bool {
+	return result.ExitCode == 0
+}
+
+/*
+ * ExecuteLocalCommandWithOptions runs commandStr with bash, as in
+ * ExecuteLocalCommand.  The result is returned even if the command fails, so
+ * that the caller can inspect its output and exit code along with the error.
+ */
+func (executor *GPDBExecutor) ExecuteLocalCommandWithOptions(commandStr string, opts LocalCommandOptions) (*LocalCommandResult, error) {
+	var cmd *exec.Cmd
+	if opts.Context != nil {
+		cmd = exec.CommandContext(opts.Context, "bash", "-c", commandStr)
+	} else {
+		cmd = exec.Command("bash", "-c", commandStr)
+	}
+	if opts.ReplaceEnv {
+		cmd.Env = append([]string{}, opts.Env...)
+	} else if len(opts.Env) > 0 {
+		cmd.Env = append(os.Environ(), opts.Env...)
+	}
+	cmd.Dir = opts.Dir
+	cmd.Stdin = opts.Stdin
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	result := &LocalCommandResult{Stdout: stdout.String(), Stderr: stderr.String(), ExitCode: -1}
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		err = errors.Wrapf(err, "Failed to execute command %s", commandStr)
+	}
+	return result, err
+}
@@ -0,0 +1,65 @@
+package cluster_test
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/localcmd tests", func() {
+	var executor *cluster.GPDBExecutor
+	BeforeEach(func() {
+		executor = &cluster.GPDBExecutor{}
+	})
+	Describe("ExecuteLocalCommandWithOptions", func() {
+		It("captures stdout and stderr separately", func() {
+			result, err := executor.ExecuteLocalCommandWithOptions("echo out; echo err >&2", cluster.LocalCommandOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Stdout).To(Equal("out\n"))
+			Expect(result.Stderr).To(Equal("err\n"))
+			Expect(result.ExitCode).To(Equal(0))
+			Expect(result.Succeeded()).To(BeTrue())
+		})
+		It("returns the exit code and output of a failed command", func() {
+			result, err := executor.ExecuteLocalCommandWithOptions("echo partial; exit 3", cluster.LocalCommandOptions{})
+			var exitErr *exec.ExitError
+			Expect(err).To(BeAssignableToTypeOf(exitErr))
+			Expect(result.Stdout).To(Equal("partial\n"))
+			Expect(result.ExitCode).To(Equal(3))
+			Expect(result.Succeeded()).To(BeFalse())
+		})
+		It("sets environment variables, working directory, and stdin", func() {
+			dir := GinkgoT().TempDir()
+			result, err := executor.ExecuteLocalCommandWithOptions(`echo "$GREETING"; pwd; cat`, cluster.LocalCommandOptions{
+				Env:   []string{"GREETING=hello"},
+				Dir:   dir,
+				Stdin: strings.NewReader("from stdin"),
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Stdout).To(Equal("hello\n" + dir + "\nfrom stdin"))
+		})
+		It("replaces the environment if requested", func() {
+			result, err := executor.ExecuteLocalCommandWithOptions(`echo "${HOME:-unset}"`, cluster.LocalCommandOptions{ReplaceEnv: true, Env: []string{"PATH=/usr/bin:/bin"}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Stdout).To(Equal("unset\n"))
+		})
+		It("kills the command when the context is done", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			result, err := executor.ExecuteLocalCommandWithOptions("sleep 5", cluster.LocalCommandOptions{Context: ctx})
+			Expect(err).To(HaveOccurred())
+			Expect(result.ExitCode).To(Equal(-1))
+		})
+		It("returns an error if the working directory does not exist", func() {
+			result, err := executor.ExecuteLocalCommandWithOptions("true", cluster.LocalCommandOptions{Dir: "/nonexistent/dir"})
+			Expect(err).To(MatchError(ContainSubstring("Failed to execute command true")))
+			Expect(result.ExitCode).To(Equal(-1))
+		})
+	})
+})
@@ -0,0 +1,104 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to composing middleware
+ * around the execution of each command in a cluster command list.
+ */
+
+import (
+	"regexp"
+)
+
+/*
+ * A CommandFunc executes a single command and returns it with its output and
+ * error fields populated.  A Middleware wraps a CommandFunc to add behavior
+ * before and/or after the command runs; it may also skip calling next entirely,
+ * e.g. to prevent a command from running.
+ *
+ * Middleware is applied in the order given, so the first Middleware is the
+ * outermost and sees each command first and its result last.  As commands are
+ * executed in parallel, each Middleware must be safe for concurrent use.
+ */
+type CommandFunc func(command ShellCommand) ShellCommand
+type Middleware func(next CommandFunc) CommandFunc
+
+func (executor *GPDBExecutor) Use(middleware ...Middleware) {
+	executor.Middleware = append(executor.Middleware, middleware...)
+}
+
+func (executor *GPDBExecutor) chain(run CommandFunc) CommandFunc {
+	for i := len(executor.Middleware) - 1; i >= 0; i-- {
+		run = executor.Middleware[i](run)
+	}
+	return run
+}
+
+/*
+ * LoggingMiddleware writes each command and its result to the log file at
+ * debug level, providing an audit trail of every command executed.
+ */
+func LoggingMiddleware() Middleware {
+	return func(next CommandFunc) CommandFunc {
+		return func(command ShellCommand) ShellCommand {
+			log.Debug("Executing command: %s", command.CommandString)
+			command = next(command)
+			if command.Error != nil {
+				log.Debug("Command failed with error %v: %s", command.Error, command.CommandString)
+			} else {
+				log.Debug("Command succeeded: %s", command.CommandString)
+			}
+			return command
+		}
+	}
+}
+
+/*
+ * DryRunMiddleware logs each command instead of executing it, and marks it as
+ * completed successfully with no output.
+ */
+func DryRunMiddleware() Middleware {
+	return func(next CommandFunc) CommandFunc {
+		return func(command ShellCommand) ShellCommand {
+			log.Info("Dry run; would execute: %s", command.CommandString)
+			command.Completed = true
+			return command
+		}
+	}
+}
+
+/*
+ * GuardrailMiddleware checks each command against the given Guardrails before
+ * executing it, and fails any command that does not pass without running it.
+ * Unlike Guardrails.CheckCommands, this checks each command independently, so
+ * allowed commands in the same list still run.
+ */
+func GuardrailMiddleware(guardrails *Guardrails, force bool) Middleware {
+	return func(next CommandFunc) CommandFunc {
+		return func(command ShellCommand) ShellCommand {
+			if err := guardrails.checkCommand(command.CommandString, force); err != nil {
+				command.Error = err
+				command.Completed = true
+				return command
+			}
+			return next(command)
+		}
+	}
+}
+
+/*
+ * RedactionMiddleware replaces any text matching the given patterns in each
+ * command's stdout and stderr with "********", so that secrets printed by a
+ * command do not end up in logs or error messages.
+ */
+func RedactionMiddleware(patterns ...*regexp.Regexp) Middleware {
+	return func(next CommandFunc) CommandFunc {
+		return func(command ShellCommand) ShellCommand {
+			command = next(command)
+			for _, pattern := range patterns {
+				command.Stdout = pattern.ReplaceAllString(command.Stdout, "********")
+				command.Stderr = pattern.ReplaceAllString(command.Stderr, "********")
+			}
+			return command
+		}
+	}
+}
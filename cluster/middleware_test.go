@@ -0,0 +1,91 @@
+package cluster_test
+
+import (
+	"regexp"
+	"sync"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/middleware tests", func() {
+	var executor *cluster.GPDBExecutor
+	BeforeEach(func() {
+		executor = &cluster.GPDBExecutor{}
+	})
+	Describe("GPDBExecutor.Use", func() {
+		It("runs middleware in order around each command", func() {
+			var mutex sync.Mutex
+			calls := make([]string, 0)
+			record := func(name string) cluster.Middleware {
+				return func(next cluster.CommandFunc) cluster.CommandFunc {
+					return func(command cluster.ShellCommand) cluster.ShellCommand {
+						mutex.Lock()
+						calls = append(calls, "before "+name)
+						mutex.Unlock()
+						command = next(command)
+						mutex.Lock()
+						calls = append(calls, "after "+name)
+						mutex.Unlock()
+						return command
+					}
+				}
+			}
+			executor.Use(record("outer"), record("inner"))
+			commandList := []cluster.ShellCommand{cluster.NewShellCommand(cluster.ON_SEGMENTS, 0, "", []string{"echo", "hello"})}
+
+			output := executor.ExecuteClusterCommand(cluster.ON_SEGMENTS, commandList)
+			Expect(output.Commands[0].Stdout).To(Equal("hello\n"))
+			Expect(calls).To(Equal([]string{"before outer", "before inner", "after inner", "after outer"}))
+		})
+	})
+	Describe("DryRunMiddleware", func() {
+		It("does not execute commands", func() {
+			executor.Use(cluster.DryRunMiddleware())
+			commandList := []cluster.ShellCommand{cluster.NewShellCommand(cluster.ON_SEGMENTS, 0, "", []string{"some-non-existent-command"})}
+
+			output := executor.ExecuteClusterCommand(cluster.ON_SEGMENTS, commandList)
+			Expect(output.NumErrors).To(Equal(0))
+			Expect(output.Commands[0].Completed).To(BeTrue())
+			testhelper.ExpectRegexp(logfile, "Dry run; would execute: some-non-existent-command")
+		})
+	})
+	Describe("GuardrailMiddleware", func() {
+		It("fails disallowed commands without running them and runs allowed ones", func() {
+			guardrails := cluster.NewGuardrails(cluster.RequireForce{})
+			executor.Use(cluster.GuardrailMiddleware(guardrails, false))
+			commandList := []cluster.ShellCommand{
+				cluster.NewShellCommand(cluster.ON_SEGMENTS, 0, "", []string{"bash", "-c", "rm -rf /tmp/nonexistent_gp_common_go_libs_dir"}),
+				cluster.NewShellCommand(cluster.ON_SEGMENTS, 1, "", []string{"echo", "hello"}),
+			}
+
+			output := executor.ExecuteClusterCommand(cluster.ON_SEGMENTS, commandList)
+			Expect(output.NumErrors).To(Equal(1))
+			Expect(output.FailedCommands[0].Content).To(Equal(0))
+			Expect(output.FailedCommands[0].Error).To(MatchError("Cannot remove files without the --force flag"))
+			Expect(output.Commands[1].Stdout).To(Equal("hello\n"))
+		})
+	})
+	Describe("RedactionMiddleware", func() {
+		It("redacts matching output", func() {
+			executor.Use(cluster.RedactionMiddleware(regexp.MustCompile(`password=\S+`)))
+			commandList := []cluster.ShellCommand{cluster.NewShellCommand(cluster.ON_SEGMENTS, 0, "", []string{"echo", "user=gpadmin password=hunter2"})}
+
+			output := executor.ExecuteClusterCommand(cluster.ON_SEGMENTS, commandList)
+			Expect(output.Commands[0].Stdout).To(Equal("user=gpadmin ********\n"))
+		})
+	})
+	Describe("LoggingMiddleware", func() {
+		It("logs each command and its result", func() {
+			executor.Use(cluster.LoggingMiddleware())
+			commandList := []cluster.ShellCommand{cluster.NewShellCommand(cluster.ON_SEGMENTS, 0, "", []string{"echo", "hello"})}
+
+			executor.ExecuteClusterCommand(cluster.ON_SEGMENTS, commandList)
+			testhelper.ExpectRegexp(logfile, "Executing command: echo hello")
+			testhelper.ExpectRegexp(logfile, "Command succeeded: echo hello")
+		})
+	})
+})
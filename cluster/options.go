@@ -0,0 +1,144 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to the options accepted by
+ * NewCluster.
+ */
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type clusterOptions struct {
+	executor                 Executor
+	preferredRoleOrdering    bool
+	hostnameNormalizers      []HostnameNormalizer
+	strictValidation         bool
+	coordinatorHostInclusion HostInclusion
+	standbyHostInclusion     HostInclusion
+}
+
+type ClusterOption func(*clusterOptions)
+
+// WithExecutor sets the Executor used to run commands, in place of a GPDBExecutor.
+func WithExecutor(executor Executor) ClusterOption {
+	return func(opts *clusterOptions) { opts.executor = executor }
+}
+
+/*
+ * By default, the segment for a content with the "p" role is treated as its
+ * primary by GetDbidForContent and related functions.  WithPreferredRoleOrdering
+ * uses the preferred role instead, so that after a failover the original
+ * primary is still returned for the "p" role until the cluster is rebalanced.
+ */
+func WithPreferredRoleOrdering() ClusterOption {
+	return func(opts *clusterOptions) { opts.preferredRoleOrdering = true }
+}
+
+/*
+ * A HostnameNormalizer rewrites a segment's Hostname before the cluster is
+ * built, so that hosts recorded inconsistently in the catalog (for instance
+ * "SDW1" and "sdw1.example.com") are treated as the same host.
+ */
+type HostnameNormalizer func(hostname string) string
+
+func LowercaseHostname(hostname string) string {
+	return strings.ToLower(hostname)
+}
+
+// StripHostnameDomain removes everything after the first "." in a hostname, unless it is an IP address.
+func StripHostnameDomain(hostname string) string {
+	if IsIPv6Literal(hostname) || isIPv4Literal(hostname) {
+		return hostname
+	}
+	if index := strings.Index(hostname, "."); index > 0 {
+		return hostname[:index]
+	}
+	return hostname
+}
+
+func isIPv4Literal(host string) bool {
+	parts := strings.Split(host, ".")
+	if len(parts) != 4 {
+		return false
+	}
+	for _, part := range parts {
+		if part == "" || strings.Trim(part, "0123456789") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// WithHostnameNormalizers applies the given normalizers, in order, to each segment's Hostname.
+func WithHostnameNormalizers(normalizers ...HostnameNormalizer) ClusterOption {
+	return func(opts *clusterOptions) {
+		opts.hostnameNormalizers = append(opts.hostnameNormalizers, normalizers...)
+	}
+}
+
+// WithCoordinatorHost sets whether per-host commands include the coordinator host; see HostInclusion.
+func WithCoordinatorHost(inclusion HostInclusion) ClusterOption {
+	return func(opts *clusterOptions) { opts.coordinatorHostInclusion = inclusion }
+}
+
+// WithStandbyHost sets whether per-host commands include the standby coordinator host; see HostInclusion.
+func WithStandbyHost(inclusion HostInclusion) ClusterOption {
+	return func(opts *clusterOptions) { opts.standbyHostInclusion = inclusion }
+}
+
+/*
+ * WithStrictValidation causes NewCluster to check the segment configuration
+ * with ValidateSegConfigs and exit with a Fatal error if it is invalid, rather
+ * than building a cluster whose lookups may silently return the wrong segment.
+ */
+func WithStrictValidation() ClusterOption {
+	return func(opts *clusterOptions) { opts.strictValidation = true }
+}
+
+/*
+ * ValidateSegConfigs checks that a segment configuration is internally
+ * consistent, returning an error describing every problem found.
+ */
+func ValidateSegConfigs(segConfigs []SegConfig) error {
+	problems := make([]string, 0)
+	dbids := make(map[int]bool)
+	rolesByContent := make(map[int]map[string]bool)
+	hostPorts := make(map[string]int)
+	for _, seg := range segConfigs {
+		if dbids[seg.DbID] {
+			problems = append(problems, fmt.Sprintf("dbid %d appears more than once", seg.DbID))
+		}
+		dbids[seg.DbID] = true
+		if seg.Hostname == "" {
+			problems = append(problems, fmt.Sprintf("dbid %d has no hostname", seg.DbID))
+		}
+		if seg.Port <= 0 {
+			problems = append(problems, fmt.Sprintf("dbid %d has invalid port %d", seg.DbID, seg.Port))
+		} else {
+			hostPort := fmt.Sprintf("%s:%d", seg.Hostname, seg.Port)
+			if otherDbid, ok := hostPorts[hostPort]; ok {
+				problems = append(problems, fmt.Sprintf("dbids %d and %d both use %s", otherDbid, seg.DbID, hostPort))
+			}
+			hostPorts[hostPort] = seg.DbID
+		}
+		if seg.Role != "p" && seg.Role != "m" {
+			problems = append(problems, fmt.Sprintf("dbid %d has invalid role %q", seg.DbID, seg.Role))
+			continue
+		}
+		if rolesByContent[seg.ContentID] == nil {
+			rolesByContent[seg.ContentID] = make(map[string]bool)
+		}
+		if rolesByContent[seg.ContentID][seg.Role] {
+			problems = append(problems, fmt.Sprintf("content %d has more than one segment with role %s", seg.ContentID, seg.Role))
+		}
+		rolesByContent[seg.ContentID][seg.Role] = true
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("Invalid segment configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package cluster_test
+
+import (
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/options tests", func() {
+	coordinator := cluster.SegConfig{DbID: 1, ContentID: -1, Role: "p", PreferredRole: "p", Port: 5432, Hostname: "cdw"}
+	// A failed-over content, where the preferred primary is acting as a mirror
+	actingPrimary := cluster.SegConfig{DbID: 3, ContentID: 0, Role: "p", PreferredRole: "m", Port: 21000, Hostname: "sdw2"}
+	actingMirror := cluster.SegConfig{DbID: 2, ContentID: 0, Role: "m", PreferredRole: "p", Port: 20000, Hostname: "sdw1"}
+
+	Describe("NewCluster", func() {
+		It("uses the given executor", func() {
+			executor := &testhelper.TestExecutor{}
+			testCluster := cluster.NewCluster([]cluster.SegConfig{coordinator}, cluster.WithExecutor(executor))
+			Expect(testCluster.Executor).To(Equal(executor))
+		})
+		It("orders segments by role by default", func() {
+			testCluster := cluster.NewCluster([]cluster.SegConfig{coordinator, actingMirror, actingPrimary})
+			Expect(testCluster.GetDbidForContent(0)).To(Equal(3))
+			Expect(testCluster.GetDbidForContent(0, "m")).To(Equal(2))
+		})
+		It("orders segments by preferred role if requested", func() {
+			testCluster := cluster.NewCluster([]cluster.SegConfig{coordinator, actingPrimary, actingMirror}, cluster.WithPreferredRoleOrdering())
+			Expect(testCluster.GetDbidForContent(0)).To(Equal(2))
+			Expect(testCluster.GetDbidForContent(0, "m")).To(Equal(3))
+		})
+		It("normalizes hostnames without modifying the given segments", func() {
+			segConfigs := []cluster.SegConfig{
+				{DbID: 1, ContentID: -1, Role: "p", Port: 5432, Hostname: "CDW.example.com"},
+				{DbID: 2, ContentID: 0, Role: "p", Port: 20000, Hostname: "cdw"},
+				{DbID: 3, ContentID: 1, Role: "p", Port: 20001, Hostname: "10.0.0.1"},
+			}
+			testCluster := cluster.NewCluster(segConfigs, cluster.WithHostnameNormalizers(cluster.LowercaseHostname, cluster.StripHostnameDomain))
+			Expect(testCluster.Hostnames).To(Equal([]string{"cdw", "10.0.0.1"}))
+			Expect(testCluster.GetContentsForHost("cdw")).To(Equal([]int{-1, 0}))
+			Expect(segConfigs[0].Hostname).To(Equal("CDW.example.com"))
+		})
+		It("exits on an invalid configuration in strict mode", func() {
+			defer testhelper.ShouldPanicWithMessage("Invalid segment configuration: dbid 1 appears more than once")
+			cluster.NewCluster([]cluster.SegConfig{coordinator, coordinator}, cluster.WithStrictValidation())
+		})
+	})
+	Describe("ValidateSegConfigs", func() {
+		It("accepts a valid configuration", func() {
+			Expect(cluster.ValidateSegConfigs([]cluster.SegConfig{coordinator, actingPrimary, actingMirror})).To(Succeed())
+		})
+		It("reports every problem found", func() {
+			err := cluster.ValidateSegConfigs([]cluster.SegConfig{
+				coordinator,
+				{DbID: 2, ContentID: 0, Role: "p", Port: 5432, Hostname: "cdw"},
+				{DbID: 3, ContentID: 0, Role: "p", Port: 0, Hostname: ""},
+				{DbID: 4, ContentID: 1, Role: "x", Port: 20001, Hostname: "sdw1"},
+			})
+			Expect(err).To(MatchError("Invalid segment configuration: dbids 1 and 2 both use cdw:5432; " +
+				"dbid 3 has no hostname; dbid 3 has invalid port 0; content 0 has more than one segment with role p; " +
+				`dbid 4 has invalid role "x"`))
+		})
+	})
+	Describe("host inclusion", func() {
+		// Segments are colocated on both the coordinator and standby hosts
+		segConfigs := []cluster.SegConfig{
+			{DbID: 1, ContentID: -1, Role: "p", Port: 5432, Hostname: "cdw"},
+			{DbID: 2, ContentID: 0, Role: "p", Port: 20000, Hostname: "cdw"},
+			{DbID: 3, ContentID: 1, Role: "p", Port: 20001, Hostname: "sdw1"},
+			{DbID: 4, ContentID: -1, Role: "m", Port: 5432, Hostname: "sdw1"},
+			{DbID: 5, ContentID: 2, Role: "p", Port: 20002, Hostname: "sdw2"},
+		}
+		hostsFor := func(testCluster *cluster.Cluster, scope cluster.Scope) []string {
+			hosts := []string{}
+			for _, command := range testCluster.GenerateHostCommandList(scope, func(host string) []string { return []string{"true"} }) {
+				hosts = append(hosts, command.Host)
+			}
+			return hosts
+		}
+		DescribeTable("includes the coordinator and standby hosts as requested", func(scope cluster.Scope, coordinator cluster.HostInclusion, standby cluster.HostInclusion, expected []string) {
+			testCluster := cluster.NewCluster(segConfigs, cluster.WithCoordinatorHost(coordinator), cluster.WithStandbyHost(standby))
+			Expect(hostsFor(testCluster, scope)).To(Equal(expected))
+		},
+			Entry("includes colocated hosts by default", cluster.ON_HOSTS, cluster.HOST_INCLUSION_DEFAULT, cluster.HOST_INCLUSION_DEFAULT, []string{"cdw", "sdw1", "sdw2"}),
+			Entry("excludes hosts the scope excludes, regardless of colocation", cluster.ON_HOSTS, cluster.HOST_INCLUSION_SCOPE, cluster.HOST_INCLUSION_SCOPE, []string{"sdw2"}),
+			Entry("includes hosts the scope includes", cluster.ON_HOSTS|cluster.INCLUDE_COORDINATOR|cluster.INCLUDE_MIRRORS, cluster.HOST_INCLUSION_SCOPE, cluster.HOST_INCLUSION_SCOPE, []string{"cdw", "sdw1", "sdw2"}),
+			Entry("always includes hosts", cluster.ON_HOSTS, cluster.HOST_INCLUSION_ALWAYS, cluster.HOST_INCLUSION_ALWAYS, []string{"cdw", "sdw1", "sdw2"}),
+			Entry("never includes hosts", cluster.ON_HOSTS|cluster.INCLUDE_COORDINATOR|cluster.INCLUDE_MIRRORS, cluster.HOST_INCLUSION_NEVER, cluster.HOST_INCLUSION_NEVER, []string{"sdw2"}),
+		)
+		It("always includes a coordinator-only host if requested", func() {
+			testCluster := cluster.NewCluster([]cluster.SegConfig{segConfigs[0], segConfigs[2]}, cluster.WithCoordinatorHost(cluster.HOST_INCLUSION_ALWAYS))
+			Expect(hostsFor(testCluster, cluster.ON_HOSTS)).To(Equal([]string{"cdw", "sdw1"}))
+		})
+	})
+})
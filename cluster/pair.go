@@ -0,0 +1,127 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to running corresponding
+ * commands against two clusters at once, such as the source and target
+ * clusters of an upgrade or migration.
+ */
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type ClusterPair struct {
+	Source *Cluster
+	Target *Cluster
+}
+
+func NewClusterPair(source *Cluster, target *Cluster) *ClusterPair {
+	return &ClusterPair{Source: source, Target: target}
+}
+
+/*
+ * A CommandPair correlates the commands run on each cluster for the same
+ * content (for per-segment commands) or host (for per-host commands).  Source
+ * or Target is nil if that content or host only exists in the other cluster.
+ */
+type CommandPair struct {
+	Content int
+	Host    string
+	Source  *ShellCommand
+	Target  *ShellCommand
+}
+
+// Failed returns true if the command on either cluster failed.
+func (pair CommandPair) Failed() bool {
+	return (pair.Source != nil && pair.Source.Error != nil) || (pair.Target != nil && pair.Target.Error != nil)
+}
+
+type PairedOutput struct {
+	Source *RemoteOutput
+	Target *RemoteOutput
+	Pairs  []CommandPair
+}
+
+func (output *PairedOutput) NumErrors() int {
+	return output.Source.NumErrors + output.Target.NumErrors
+}
+
+// FailedPairs returns each pair in which the command on either cluster failed.
+func (output *PairedOutput) FailedPairs() []CommandPair {
+	failed := make([]CommandPair, 0)
+	for _, pair := range output.Pairs {
+		if pair.Failed() {
+			failed = append(failed, pair)
+		}
+	}
+	return failed
+}
+
+/*
+ * GenerateAndExecuteCommand runs GenerateAndExecuteCommand on both clusters
+ * concurrently, with a separate generator for each, and pairs up the results.
+ * The generators accept the same types as GenerateSSHCommandList, and should
+ * both be per-segment or both per-host to match the scope.  Pairs are ordered
+ * by content for per-segment commands and by host for per-host commands.
+ */
+func (clusterPair *ClusterPair) GenerateAndExecuteCommand(verboseMsg string, scope Scope, sourceGenerator interface{}, targetGenerator interface{}) *PairedOutput {
+	log.Verbose(verboseMsg)
+	output := &PairedOutput{}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		commandList := clusterPair.Source.GenerateSSHCommandList(scope, sourceGenerator)
+		output.Source = clusterPair.Source.ExecuteClusterCommandWithRetries(scope, commandList, clusterPair.Source.maxAttempts(), 1*time.Second)
+	}()
+	go func() {
+		defer wg.Done()
+		commandList := clusterPair.Target.GenerateSSHCommandList(scope, targetGenerator)
+		output.Target = clusterPair.Target.ExecuteClusterCommandWithRetries(scope, commandList, clusterPair.Target.maxAttempts(), 1*time.Second)
+	}()
+	wg.Wait()
+	output.Pairs = pairCommands(scope, output.Source.Commands, output.Target.Commands)
+	return output
+}
+
+func pairCommands(scope Scope, sourceCommands []ShellCommand, targetCommands []ShellCommand) []CommandPair {
+	type pairKey struct {
+		content int
+		host    string
+	}
+	keyFor := func(command ShellCommand) pairKey {
+		if scopeIsHosts(scope) {
+			return pairKey{content: -2, host: command.Host}
+		}
+		return pairKey{content: command.Content}
+	}
+	pairsByKey := make(map[pairKey]*CommandPair)
+	getPair := func(key pairKey) *CommandPair {
+		pair, ok := pairsByKey[key]
+		if !ok {
+			pair = &CommandPair{Content: key.content, Host: key.host}
+			pairsByKey[key] = pair
+		}
+		return pair
+	}
+	for i := range sourceCommands {
+		getPair(keyFor(sourceCommands[i])).Source = &sourceCommands[i]
+	}
+	for i := range targetCommands {
+		getPair(keyFor(targetCommands[i])).Target = &targetCommands[i]
+	}
+
+	pairs := make([]CommandPair, 0, len(pairsByKey))
+	for _, pair := range pairsByKey {
+		pairs = append(pairs, *pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Content != pairs[j].Content {
+			return pairs[i].Content < pairs[j].Content
+		}
+		return pairs[i].Host < pairs[j].Host
+	})
+	return pairs
+}
@@ -0,0 +1,80 @@
+package cluster_test
+
+import (
+	"errors"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/pair tests", func() {
+	var (
+		sourceExecutor *testhelper.TestExecutor
+		targetExecutor *testhelper.TestExecutor
+		clusterPair    *cluster.ClusterPair
+	)
+	BeforeEach(func() {
+		sourceExecutor = &testhelper.TestExecutor{}
+		targetExecutor = &testhelper.TestExecutor{}
+		source := cluster.NewCluster([]cluster.SegConfig{
+			{DbID: 1, ContentID: -1, Role: "p", Port: 5432, Hostname: "localhost", DataDir: "/data/old/gpseg-1"},
+			{DbID: 2, ContentID: 0, Role: "p", Port: 20000, Hostname: "localhost", DataDir: "/data/old/gpseg0"},
+			{DbID: 3, ContentID: 1, Role: "p", Port: 20001, Hostname: "localhost", DataDir: "/data/old/gpseg1"},
+		}, cluster.WithExecutor(sourceExecutor))
+		target := cluster.NewCluster([]cluster.SegConfig{
+			{DbID: 1, ContentID: -1, Role: "p", Port: 6432, Hostname: "localhost", DataDir: "/data/new/gpseg-1"},
+			{DbID: 2, ContentID: 0, Role: "p", Port: 30000, Hostname: "localhost", DataDir: "/data/new/gpseg0"},
+		}, cluster.WithExecutor(targetExecutor))
+		clusterPair = cluster.NewClusterPair(source, target)
+	})
+	It("runs commands on both clusters and pairs them by content", func() {
+		sourceCommands := []cluster.ShellCommand{
+			cluster.NewShellCommand(cluster.ON_SEGMENTS, 0, "", []string{"bash", "-c", "ls /data/old/gpseg0"}),
+			cluster.NewShellCommand(cluster.ON_SEGMENTS, 1, "", []string{"bash", "-c", "ls /data/old/gpseg1"}),
+		}
+		targetCommands := []cluster.ShellCommand{
+			cluster.NewShellCommand(cluster.ON_SEGMENTS, 0, "", []string{"bash", "-c", "ls /data/new/gpseg0"}),
+		}
+		targetCommands[0].Error = errors.New("exit status 2")
+		sourceExecutor.ClusterOutput = cluster.NewRemoteOutput(cluster.ON_SEGMENTS, 0, sourceCommands)
+		targetExecutor.ClusterOutput = cluster.NewRemoteOutput(cluster.ON_SEGMENTS, 1, targetCommands)
+
+		output := clusterPair.GenerateAndExecuteCommand("Listing data directories", cluster.ON_SEGMENTS,
+			func(content int) string { return "ls " + clusterPair.Source.GetDirForContent(content) },
+			func(content int) string { return "ls " + clusterPair.Target.GetDirForContent(content) })
+
+		Expect(sourceExecutor.ClusterCommands[0][1].CommandString).To(Equal("bash -c ls /data/old/gpseg1"))
+		Expect(targetExecutor.ClusterCommands[0]).To(HaveLen(1))
+		Expect(targetExecutor.ClusterCommands[0][0].CommandString).To(Equal("bash -c ls /data/new/gpseg0"))
+
+		Expect(output.NumErrors()).To(Equal(1))
+		Expect(output.Pairs).To(HaveLen(2))
+		Expect(output.Pairs[0].Content).To(Equal(0))
+		Expect(output.Pairs[0].Source.CommandString).To(Equal("bash -c ls /data/old/gpseg0"))
+		Expect(output.Pairs[0].Target.CommandString).To(Equal("bash -c ls /data/new/gpseg0"))
+		Expect(output.Pairs[1].Content).To(Equal(1))
+		Expect(output.Pairs[1].Target).To(BeNil())
+
+		failed := output.FailedPairs()
+		Expect(failed).To(HaveLen(1))
+		Expect(failed[0].Content).To(Equal(0))
+	})
+	It("pairs per-host commands by host", func() {
+		sourceExecutor.ClusterOutput = cluster.NewRemoteOutput(cluster.ON_HOSTS, 0, []cluster.ShellCommand{
+			cluster.NewShellCommand(cluster.ON_HOSTS, -2, "localhost", []string{"bash", "-c", "true"}),
+		})
+		targetExecutor.ClusterOutput = cluster.NewRemoteOutput(cluster.ON_HOSTS, 0, []cluster.ShellCommand{
+			cluster.NewShellCommand(cluster.ON_HOSTS, -2, "localhost", []string{"bash", "-c", "true"}),
+		})
+		output := clusterPair.GenerateAndExecuteCommand("Checking hosts", cluster.ON_HOSTS,
+			func(host string) string { return "true" }, func(host string) string { return "true" })
+		Expect(output.Pairs).To(HaveLen(1))
+		Expect(output.Pairs[0].Host).To(Equal("localhost"))
+		Expect(output.Pairs[0].Source).ToNot(BeNil())
+		Expect(output.Pairs[0].Target).ToNot(BeNil())
+		Expect(output.FailedPairs()).To(BeEmpty())
+	})
+})
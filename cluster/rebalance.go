@@ -0,0 +1,90 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to detecting contents whose
+ * segments are not in their preferred roles and planning how to rebalance them,
+ * as gprecoverseg -r does.
+ */
+
+import (
+	"sort"
+)
+
+/*
+ * UnbalancedContents returns, in ascending order, each content with a segment
+ * whose current role differs from its preferred role.
+ */
+func (cluster *Cluster) UnbalancedContents() []int {
+	return cluster.GetContentsNotInPreferredRole()
+}
+
+/*
+ * A RebalanceStep describes how to return one content to its preferred roles:
+ * Demote is the segment currently acting as primary that should become the
+ * mirror, and Promote is the segment that should become the primary again.
+ *
+ * Ready is false if the step cannot safely be performed yet, in which case
+ * Reason explains why; a content can only be rebalanced once both of its
+ * segments are up and synchronized.
+ */
+type RebalanceStep struct {
+	ContentID int
+	Demote    SegConfig
+	Promote   SegConfig
+	Ready     bool
+	Reason    string
+}
+
+/*
+ * RebalancePlan returns a step for each unbalanced content other than the
+ * coordinator, whose standby is not rebalanced by gprecoverseg.  Steps that
+ * are ready come first, followed by those that are not, and steps are ordered
+ * by content ID within each group.
+ */
+func (cluster *Cluster) RebalancePlan() []RebalanceStep {
+	cluster.mutex.RLock()
+	defer cluster.mutex.RUnlock()
+	steps := make([]RebalanceStep, 0)
+	for contentID, segmentList := range cluster.ByContent {
+		if contentID == -1 {
+			continue
+		}
+		var demote, promote *SegConfig
+		for _, segment := range segmentList {
+			if segment.Role == "p" && segment.PreferredRole == "m" {
+				demote = segment
+			} else if segment.Role == "m" && segment.PreferredRole == "p" {
+				promote = segment
+			}
+		}
+		if demote == nil && promote == nil {
+			continue
+		}
+		step := RebalanceStep{ContentID: contentID, Ready: true}
+		switch {
+		case demote == nil || promote == nil:
+			step.Ready = false
+			step.Reason = "content does not have both a primary and a mirror out of their preferred roles"
+		case demote.Status != "u" || promote.Status != "u":
+			step.Ready = false
+			step.Reason = "a segment is down"
+		case demote.Mode != "s" || promote.Mode != "s":
+			step.Ready = false
+			step.Reason = "segments are not synchronized"
+		}
+		if demote != nil {
+			step.Demote = *demote
+		}
+		if promote != nil {
+			step.Promote = *promote
+		}
+		steps = append(steps, step)
+	}
+	sort.Slice(steps, func(i, j int) bool {
+		if steps[i].Ready != steps[j].Ready {
+			return steps[i].Ready
+		}
+		return steps[i].ContentID < steps[j].ContentID
+	})
+	return steps
+}
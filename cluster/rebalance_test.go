@@ -0,0 +1,56 @@
+package cluster_test
+
+import (
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/rebalance tests", func() {
+	var testCluster *cluster.Cluster
+	BeforeEach(func() {
+		testCluster = cluster.NewCluster([]cluster.SegConfig{
+			{DbID: 1, ContentID: -1, Role: "m", PreferredRole: "p", Mode: "s", Status: "u", Port: 5432, Hostname: "cdw"},
+			{DbID: 10, ContentID: -1, Role: "p", PreferredRole: "m", Mode: "s", Status: "u", Port: 5432, Hostname: "scdw"},
+			{DbID: 2, ContentID: 0, Role: "p", PreferredRole: "p", Mode: "s", Status: "u", Port: 20000, Hostname: "sdw1"},
+			{DbID: 3, ContentID: 0, Role: "m", PreferredRole: "m", Mode: "s", Status: "u", Port: 21000, Hostname: "sdw2"},
+			{DbID: 4, ContentID: 1, Role: "m", PreferredRole: "p", Mode: "n", Status: "d", Port: 20001, Hostname: "sdw1"},
+			{DbID: 5, ContentID: 1, Role: "p", PreferredRole: "m", Mode: "n", Status: "u", Port: 21001, Hostname: "sdw2"},
+			{DbID: 6, ContentID: 2, Role: "m", PreferredRole: "p", Mode: "s", Status: "u", Port: 20002, Hostname: "sdw2"},
+			{DbID: 7, ContentID: 2, Role: "p", PreferredRole: "m", Mode: "s", Status: "u", Port: 21002, Hostname: "sdw1"},
+		})
+	})
+	Describe("UnbalancedContents", func() {
+		It("returns each content not in its preferred roles", func() {
+			Expect(testCluster.UnbalancedContents()).To(Equal([]int{-1, 1, 2}))
+		})
+		It("returns an empty list for a balanced cluster", func() {
+			balanced := cluster.NewCluster([]cluster.SegConfig{
+				{DbID: 1, ContentID: -1, Role: "p", PreferredRole: "p", Port: 5432, Hostname: "cdw"},
+			})
+			Expect(balanced.UnbalancedContents()).To(BeEmpty())
+		})
+	})
+	Describe("RebalancePlan", func() {
+		It("orders ready steps before steps that are not ready and skips the coordinator", func() {
+			steps := testCluster.RebalancePlan()
+			Expect(steps).To(HaveLen(2))
+
+			Expect(steps[0].ContentID).To(Equal(2))
+			Expect(steps[0].Ready).To(BeTrue())
+			Expect(steps[0].Demote.DbID).To(Equal(7))
+			Expect(steps[0].Promote.DbID).To(Equal(6))
+
+			Expect(steps[1].ContentID).To(Equal(1))
+			Expect(steps[1].Ready).To(BeFalse())
+			Expect(steps[1].Reason).To(Equal("a segment is down"))
+		})
+		It("marks unsynchronized contents as not ready", func() {
+			Expect(testCluster.UpdateSegmentStatus(4, "u")).To(Succeed())
+			steps := testCluster.RebalancePlan()
+			Expect(steps[1].ContentID).To(Equal(1))
+			Expect(steps[1].Reason).To(Equal("segments are not synchronized"))
+		})
+	})
+})
@@ -0,0 +1,124 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to connecting directly to
+ * an individual segment described by a SegConfig.
+ */
+
+import (
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+)
+
+type segConnOptions struct {
+	dbname            string
+	user              string
+	numConns          int
+	utilityMode       *bool
+	useHostname       bool
+	startupParameters map[string]string
+	driver            dbconn.DBDriver
+}
+
+/*
+ * A SegConnOption modifies how ConnectToSegConfig connects to a segment.  By
+ * default, it connects to the "postgres" database as $PGUSER (or the current
+ * user if that is not set) with one connection, using utility mode for every
+ * segment other than the coordinator.
+ */
+type SegConnOption func(*segConnOptions)
+
+func SegConnDatabase(dbname string) SegConnOption {
+	return func(opts *segConnOptions) { opts.dbname = dbname }
+}
+
+func SegConnUser(user string) SegConnOption {
+	return func(opts *segConnOptions) { opts.user = user }
+}
+
+func SegConnNumConns(numConns int) SegConnOption {
+	return func(opts *segConnOptions) { opts.numConns = numConns }
+}
+
+func SegConnUtilityMode(utilityMode bool) SegConnOption {
+	return func(opts *segConnOptions) { opts.utilityMode = &utilityMode }
+}
+
+// SegConnUseHostname connects using the segment's Hostname even if it has an Address.
+func SegConnUseHostname() SegConnOption {
+	return func(opts *segConnOptions) { opts.useHostname = true }
+}
+
+func SegConnStartupParameters(params map[string]string) SegConnOption {
+	return func(opts *segConnOptions) { opts.startupParameters = params }
+}
+
+// SegConnDriver replaces the database driver, which is primarily useful for testing.
+func SegConnDriver(driver dbconn.DBDriver) SegConnOption {
+	return func(opts *segConnOptions) { opts.driver = driver }
+}
+
+/*
+ * SegConnTarget returns the host that should be used to connect to the given
+ * segment: its Address, which is the interface the database itself uses to
+ * reach the segment, or its Hostname if no Address is recorded.
+ */
+func SegConnTarget(seg SegConfig) string {
+	if seg.Address != "" {
+		return seg.Address
+	}
+	return seg.Hostname
+}
+
+/*
+ * ConnectToSegConfig returns a connection to the given segment, so callers
+ * need not assemble the host, port, and utility mode settings themselves.
+ */
+func ConnectToSegConfig(seg SegConfig, opts ...SegConnOption) (*dbconn.DBConn, error) {
+	options := segConnOptions{dbname: "postgres", numConns: 1}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.user == "" {
+		options.user = operating.System.Getenv("PGUSER")
+	}
+	if options.user == "" {
+		currentUser, _ := operating.System.CurrentUser()
+		options.user = currentUser.Username
+	}
+	host := SegConnTarget(seg)
+	if options.useHostname {
+		host = seg.Hostname
+	}
+	utilityMode := seg.ContentID != -1
+	if options.utilityMode != nil {
+		utilityMode = *options.utilityMode
+	}
+
+	conn := dbconn.NewDBConn(options.dbname, options.user, unbracketHost(host), seg.Port)
+	if options.driver != nil {
+		conn.Driver = options.driver
+	}
+	err := conn.ConnectWithOptions(dbconn.ConnectOptions{
+		NumConns:          options.numConns,
+		UtilityMode:       utilityMode,
+		StartupParameters: options.startupParameters,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+/*
+ * ConnectToSegment looks up the segment with the given content and role ("p"
+ * or "m") in the cluster and connects to it as ConnectToSegConfig does.  It
+ * returns a *SegmentNotFoundError if there is no such segment.
+ */
+func (cluster *Cluster) ConnectToSegment(contentID int, role string, opts ...SegConnOption) (*dbconn.DBConn, error) {
+	seg, err := cluster.LookupSegment(contentID, role)
+	if err != nil {
+		return nil, err
+	}
+	return ConnectToSegConfig(*seg, opts...)
+}
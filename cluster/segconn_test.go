@@ -0,0 +1,111 @@
+package cluster_test
+
+import (
+	"errors"
+	"os/user"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+	"github.com/jmoiron/sqlx"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type recordingDriver struct {
+	*testhelper.TestDriver
+	ConnStrs []string
+}
+
+func (driver *recordingDriver) Connect(driverName string, dataSourceName string) (*sqlx.DB, error) {
+	driver.ConnStrs = append(driver.ConnStrs, dataSourceName)
+	return driver.TestDriver.Connect(driverName, dataSourceName)
+}
+
+var _ = Describe("cluster/segconn tests", func() {
+	var driver *recordingDriver
+	segment := cluster.SegConfig{DbID: 2, ContentID: 0, Role: "p", Port: 20000, Hostname: "sdw1", Address: "sdw1-ic"}
+	BeforeEach(func() {
+		mockdb, mock := testhelper.CreateMockDB()
+		testhelper.ExpectVersionQuery(mock, "7.0.0")
+		driver = &recordingDriver{TestDriver: &testhelper.TestDriver{DB: mockdb}}
+		operating.System.Getenv = func(key string) string { return "" }
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "testUser"}, nil }
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+	Describe("SegConnTarget", func() {
+		It("prefers the address and falls back to the hostname", func() {
+			Expect(cluster.SegConnTarget(segment)).To(Equal("sdw1-ic"))
+			Expect(cluster.SegConnTarget(cluster.SegConfig{Hostname: "sdw1"})).To(Equal("sdw1"))
+		})
+	})
+	Describe("ConnectToSegConfig", func() {
+		It("connects to a segment's address in utility mode by default", func() {
+			// Skip the initial connection used to check the utility mode setting
+			driver.ErrsToReturn = []error{nil}
+			conn, err := cluster.ConnectToSegConfig(segment, cluster.SegConnDriver(driver))
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+			Expect(conn.Host).To(Equal("sdw1-ic"))
+			Expect(conn.Port).To(Equal(20000))
+			Expect(conn.User).To(Equal("testUser"))
+			Expect(conn.DBName).To(Equal("postgres"))
+			Expect(driver.ConnStrs[0]).To(ContainSubstring("host=sdw1-ic port=20000"))
+			Expect(driver.ConnStrs[0]).To(ContainSubstring("gp_session_role=utility"))
+		})
+		It("does not use utility mode for the coordinator", func() {
+			coordinator := cluster.SegConfig{DbID: 1, ContentID: -1, Role: "p", Port: 5432, Hostname: "cdw"}
+			conn, err := cluster.ConnectToSegConfig(coordinator, cluster.SegConnDriver(driver))
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+			Expect(driver.ConnStrs).To(HaveLen(1))
+			Expect(driver.ConnStrs[0]).ToNot(ContainSubstring("utility"))
+			Expect(conn.Host).To(Equal("cdw"))
+		})
+		It("applies the given options", func() {
+			conn, err := cluster.ConnectToSegConfig(segment, cluster.SegConnDriver(driver),
+				cluster.SegConnDatabase("testdb"), cluster.SegConnUser("gpadmin"), cluster.SegConnNumConns(2),
+				cluster.SegConnUtilityMode(false), cluster.SegConnUseHostname(),
+				cluster.SegConnStartupParameters(map[string]string{"search_path": "myschema"}))
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+			Expect(conn.NumConns).To(Equal(2))
+			Expect(conn.Host).To(Equal("sdw1"))
+			Expect(conn.User).To(Equal("gpadmin"))
+			Expect(conn.DBName).To(Equal("testdb"))
+			Expect(driver.ConnStrs[0]).To(ContainSubstring("search_path='myschema'"))
+			Expect(driver.ConnStrs[0]).ToNot(ContainSubstring("utility"))
+		})
+		It("returns connection errors", func() {
+			_, err := cluster.ConnectToSegConfig(segment, cluster.SegConnDriver(driver), cluster.SegConnNumConns(0))
+			Expect(err).To(MatchError("Must specify a connection pool size that is a positive integer"))
+		})
+	})
+	Describe("Cluster.ConnectToSegment", func() {
+		var testCluster *cluster.Cluster
+		BeforeEach(func() {
+			testCluster = cluster.NewCluster([]cluster.SegConfig{
+				{DbID: 1, ContentID: -1, Role: "p", Port: 5432, Hostname: "cdw"},
+				{DbID: 2, ContentID: 0, Role: "p", Port: 20000, Hostname: "sdw1"},
+				{DbID: 3, ContentID: 0, Role: "m", Port: 21000, Hostname: "sdw2"},
+			})
+		})
+		It("connects to the segment with the given content and role", func() {
+			driver.ErrsToReturn = []error{nil}
+			conn, err := testCluster.ConnectToSegment(0, "m", cluster.SegConnDriver(driver))
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+			Expect(conn.Host).To(Equal("sdw2"))
+			Expect(conn.Port).To(Equal(21000))
+			Expect(driver.ConnStrs[0]).To(ContainSubstring("gp_session_role=utility"))
+		})
+		It("returns an error if there is no such segment", func() {
+			_, err := testCluster.ConnectToSegment(1, "p", cluster.SegConnDriver(driver))
+			Expect(errors.Is(err, cluster.ErrContentNotFound)).To(BeTrue())
+			Expect(driver.ConnStrs).To(BeEmpty())
+		})
+	})
+})
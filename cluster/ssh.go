@@ -0,0 +1,170 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to constructing the ssh
+ * commands used to execute commands on remote hosts.
+ */
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+)
+
+/*
+ * A HostKeyPolicy determines how ssh verifies the host keys of remote hosts:
+ *
+ * HOST_KEY_INSECURE:   Do not verify host keys at all (StrictHostKeyChecking=no).
+ *                      This is the default, for backwards compatibility.
+ * HOST_KEY_ACCEPT_NEW: Add keys for previously unknown hosts to known_hosts, but
+ *                      refuse to connect if a known host's key has changed.
+ * HOST_KEY_STRICT:     Refuse to connect to any host whose key is not already in
+ *                      known_hosts.
+ */
+type HostKeyPolicy int
+
+const (
+	HOST_KEY_INSECURE HostKeyPolicy = iota
+	HOST_KEY_ACCEPT_NEW
+	HOST_KEY_STRICT
+)
+
+func (policy HostKeyPolicy) String() string {
+	switch policy {
+	case HOST_KEY_ACCEPT_NEW:
+		return "accept-new"
+	case HOST_KEY_STRICT:
+		return "yes"
+	default:
+		return "no"
+	}
+}
+
+/*
+ * SSHOptions controls how ConstructSSHCommandWithOptions builds ssh commands.
+ * The zero value produces the same commands as ConstructSSHCommand.
+ *
+ * - If KnownHostsFile is set, it is passed to ssh as the UserKnownHostsFile in
+ *   place of the default ~/.ssh/known_hosts; it is ignored by HOST_KEY_INSECURE.
+ * - Binary is the path to the ssh executable (or a wrapper script accepting
+ *   the same arguments), defaulting to "ssh" on the PATH.
+ * - ForwardAgent enables ssh agent forwarding with -A.
+ * - ExtraFlags are passed to ssh verbatim after all other flags, e.g. to set
+ *   "-J jumphost" or "-o ConnectTimeout=10".
+ * - User, HostUsers, and UserLookup determine the user to log in as on each
+ *   host; see UserForHost.
+ */
+type SSHOptions struct {
+	HostKeyPolicy  HostKeyPolicy
+	KnownHostsFile string
+	Binary         string
+	ForwardAgent   bool
+	ExtraFlags     []string
+	User           string
+	HostUsers      map[string]string
+	UserLookup     func(host string) string
+}
+
+/*
+ * UserForHost returns the user to log in as on the given host, for clusters
+ * where the OS user differs across hosts.  It checks, in order, the HostUsers
+ * map, the UserLookup function, and the User field, using the first non-empty
+ * result; if all are empty, it returns the current user on the coordinator.
+ */
+func (options SSHOptions) UserForHost(host string) string {
+	if user := options.HostUsers[host]; user != "" {
+		return user
+	}
+	if options.UserLookup != nil {
+		if user := options.UserLookup(host); user != "" {
+			return user
+		}
+	}
+	if options.User != "" {
+		return options.User
+	}
+	currentUser, _ := operating.System.CurrentUser()
+	return currentUser.Username
+}
+
+/*
+ * Per-host users are keyed by hostname, but the ssh target may be a segment's
+ * address instead, so the cluster resolves the user before choosing a target.
+ */
+func (options SSHOptions) forHost(host string) SSHOptions {
+	options.User = options.UserForHost(host)
+	options.HostUsers = nil
+	options.UserLookup = nil
+	return options
+}
+
+func (options SSHOptions) binary() string {
+	if options.Binary == "" {
+		return "ssh"
+	}
+	return options.Binary
+}
+
+func (options SSHOptions) flags() []string {
+	flags := []string{"-o", fmt.Sprintf("StrictHostKeyChecking=%s", options.HostKeyPolicy)}
+	if options.HostKeyPolicy != HOST_KEY_INSECURE && options.KnownHostsFile != "" {
+		flags = append(flags, "-o", fmt.Sprintf("UserKnownHostsFile=%s", options.KnownHostsFile))
+	}
+	if options.ForwardAgent {
+		flags = append(flags, "-A")
+	}
+	return append(flags, options.ExtraFlags...)
+}
+
+func ConstructSSHCommandWithOptions(useLocal bool, host string, cmd string, options SSHOptions) []string {
+	if useLocal {
+		return []string{"bash", "-c", cmd}
+	}
+	sshCmd := []string{options.binary()}
+	sshCmd = append(sshCmd, options.flags()...)
+	return append(sshCmd, FormatSSHDestination(options.UserForHost(host), host), cmd)
+}
+
+// shellQuote quotes arg so that a POSIX shell treats it as a single word.
+func shellQuote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+/*
+ * IPv6 literals need different handling depending on the tool: ssh splits its
+ * destination on the last "@" and expects a bare address, while scp and rsync
+ * split on ":" and so require the address to be bracketed.  Hosts are accepted
+ * with or without brackets, and scoped addresses such as "fe80::1%eth0" keep
+ * their zone in either form.
+ */
+func IsIPv6Literal(host string) bool {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if zoneIndex := strings.LastIndex(host, "%"); zoneIndex != -1 {
+		host = host[:zoneIndex]
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && strings.Contains(host, ":")
+}
+
+func unbracketHost(host string) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
+
+// FormatSSHDestination returns a "user@host" destination suitable for ssh.
+func FormatSSHDestination(user string, host string) string {
+	return fmt.Sprintf("%s@%s", user, unbracketHost(host))
+}
+
+// FormatRemotePath returns a "user@host:path" argument suitable for scp or rsync.
+func FormatRemotePath(user string, host string, remotePath string) string {
+	host = unbracketHost(host)
+	if IsIPv6Literal(host) {
+		host = "[" + host + "]"
+	}
+	return fmt.Sprintf("%s@%s:%s", user, host, remotePath)
+}
@@ -0,0 +1,150 @@
+package cluster_test
+
+import (
+	"os/user"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/ssh tests", func() {
+	BeforeEach(func() {
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "testUser", HomeDir: "testDir"}, nil }
+	})
+	Describe("ConstructSSHCommandWithOptions", func() {
+		It("constructs a local command regardless of options", func() {
+			cmd := cluster.ConstructSSHCommandWithOptions(true, "some-host", "ls", cluster.SSHOptions{HostKeyPolicy: cluster.HOST_KEY_STRICT})
+			Expect(cmd).To(Equal([]string{"bash", "-c", "ls"}))
+		})
+		It("disables host key checking by default", func() {
+			cmd := cluster.ConstructSSHCommandWithOptions(false, "some-host", "ls", cluster.SSHOptions{})
+			Expect(cmd).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=no", "testUser@some-host", "ls"}))
+		})
+		It("ignores the known hosts file for the insecure policy", func() {
+			cmd := cluster.ConstructSSHCommandWithOptions(false, "some-host", "ls", cluster.SSHOptions{KnownHostsFile: "/tmp/known_hosts"})
+			Expect(cmd).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=no", "testUser@some-host", "ls"}))
+		})
+		It("accepts new host keys", func() {
+			cmd := cluster.ConstructSSHCommandWithOptions(false, "some-host", "ls", cluster.SSHOptions{HostKeyPolicy: cluster.HOST_KEY_ACCEPT_NEW})
+			Expect(cmd).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=accept-new", "testUser@some-host", "ls"}))
+		})
+		It("checks host keys strictly against a known hosts file", func() {
+			cmd := cluster.ConstructSSHCommandWithOptions(false, "some-host", "ls", cluster.SSHOptions{HostKeyPolicy: cluster.HOST_KEY_STRICT, KnownHostsFile: "/tmp/known_hosts"})
+			Expect(cmd).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=yes", "-o", "UserKnownHostsFile=/tmp/known_hosts", "testUser@some-host", "ls"}))
+		})
+		It("uses a custom ssh binary", func() {
+			cmd := cluster.ConstructSSHCommandWithOptions(false, "some-host", "ls", cluster.SSHOptions{Binary: "/opt/bin/ssh-wrapper"})
+			Expect(cmd).To(Equal([]string{"/opt/bin/ssh-wrapper", "-o", "StrictHostKeyChecking=no", "testUser@some-host", "ls"}))
+		})
+		It("enables agent forwarding and passes extra flags", func() {
+			cmd := cluster.ConstructSSHCommandWithOptions(false, "some-host", "ls", cluster.SSHOptions{ForwardAgent: true, ExtraFlags: []string{"-J", "jumphost"}})
+			Expect(cmd).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=no", "-A", "-J", "jumphost", "testUser@some-host", "ls"}))
+		})
+	})
+	Describe("SSHOptions.UserForHost", func() {
+		options := cluster.SSHOptions{
+			User:      "defaultuser",
+			HostUsers: map[string]string{"sdw1": "mappeduser"},
+			UserLookup: func(host string) string {
+				if host == "sdw2" {
+					return "lookupuser"
+				}
+				return ""
+			},
+		}
+		DescribeTable("resolves users in priority order", func(options cluster.SSHOptions, host string, expected string) {
+			Expect(options.UserForHost(host)).To(Equal(expected))
+		},
+			Entry("mapped host", options, "sdw1", "mappeduser"),
+			Entry("looked-up host", options, "sdw2", "lookupuser"),
+			Entry("default user", options, "sdw3", "defaultuser"),
+			Entry("current user", cluster.SSHOptions{}, "sdw3", "testUser"),
+		)
+		It("uses the per-host user in the ssh destination", func() {
+			cmd := cluster.ConstructSSHCommandWithOptions(false, "sdw1", "ls", options)
+			Expect(cmd).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=no", "mappeduser@sdw1", "ls"}))
+		})
+	})
+	Describe("IPv6 targets", func() {
+		DescribeTable("IsIPv6Literal", func(host string, expected bool) {
+			Expect(cluster.IsIPv6Literal(host)).To(Equal(expected))
+		},
+			Entry("hostname", "sdw1", false),
+			Entry("IPv4 address", "10.0.0.1", false),
+			Entry("IPv6 address", "2001:db8::1", true),
+			Entry("bracketed IPv6 address", "[2001:db8::1]", true),
+			Entry("scoped IPv6 address", "fe80::1%eth0", true),
+		)
+		It("does not bracket IPv6 addresses for ssh", func() {
+			cmd := cluster.ConstructSSHCommandWithOptions(false, "[fe80::1%eth0]", "ls", cluster.SSHOptions{})
+			Expect(cmd).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=no", "testUser@fe80::1%eth0", "ls"}))
+		})
+		DescribeTable("FormatRemotePath", func(host string, expected string) {
+			Expect(cluster.FormatRemotePath("gpadmin", host, "/data")).To(Equal(expected))
+		},
+			Entry("hostname", "sdw1", "gpadmin@sdw1:/data"),
+			Entry("IPv4 address", "10.0.0.1", "gpadmin@10.0.0.1:/data"),
+			Entry("IPv6 address", "2001:db8::1", "gpadmin@[2001:db8::1]:/data"),
+			Entry("bracketed IPv6 address", "[2001:db8::1]", "gpadmin@[2001:db8::1]:/data"),
+			Entry("scoped IPv6 address", "fe80::1%eth0", "gpadmin@[fe80::1%eth0]:/data"),
+		)
+	})
+	Describe("GenerateSSHCommandList", func() {
+		It("uses the cluster's ssh options", func() {
+			testCluster := cluster.NewCluster([]cluster.SegConfig{
+				{DbID: 1, ContentID: -1, Port: 5432, Hostname: "localhost", DataDir: "/data/gpseg-1", Role: "p"},
+				{DbID: 2, ContentID: 0, Port: 20000, Hostname: "remotehost", DataDir: "/data/gpseg0", Role: "p"},
+			})
+			testCluster.SSHOptions = cluster.SSHOptions{HostKeyPolicy: cluster.HOST_KEY_ACCEPT_NEW}
+			commandList := testCluster.GenerateSSHCommandList(cluster.ON_SEGMENTS, func(content int) string {
+				return "ls"
+			})
+			Expect(commandList).To(HaveLen(1))
+			Expect(commandList[0].CommandString).To(Equal("ssh -o StrictHostKeyChecking=accept-new testUser@remotehost ls"))
+		})
+		Describe("target selection", func() {
+			var testCluster *cluster.Cluster
+			BeforeEach(func() {
+				testCluster = cluster.NewCluster([]cluster.SegConfig{
+					{DbID: 1, ContentID: -1, Port: 5432, Hostname: "localhost", Address: "localhost-ic", Role: "p"},
+					{DbID: 2, ContentID: 0, Port: 20000, Hostname: "remotehost", Address: "remotehost-ic", Role: "p"},
+					{DbID: 3, ContentID: 1, Port: 20001, Hostname: "remotehost2", Role: "p"},
+				})
+			})
+			It("targets hostnames by default", func() {
+				commandList := testCluster.GenerateSSHCommandList(cluster.ON_SEGMENTS, func(content int) string { return "ls" })
+				Expect(commandList[0].CommandString).To(Equal("ssh -o StrictHostKeyChecking=no testUser@remotehost ls"))
+			})
+			It("targets addresses per segment if configured, falling back to the hostname", func() {
+				testCluster.Target = cluster.TARGET_ADDRESS
+				commandList := testCluster.GenerateSSHCommandList(cluster.ON_SEGMENTS, func(content int) string { return "ls" })
+				Expect(commandList[0].CommandString).To(Equal("ssh -o StrictHostKeyChecking=no testUser@remotehost-ic ls"))
+				Expect(commandList[1].CommandString).To(Equal("ssh -o StrictHostKeyChecking=no testUser@remotehost2 ls"))
+			})
+			It("targets addresses per host while passing hostnames to the generator", func() {
+				hosts := make([]string, 0)
+				commandList := testCluster.GenerateSSHCommandListWithTarget(cluster.ON_HOSTS, cluster.TARGET_ADDRESS, func(host string) string {
+					hosts = append(hosts, host)
+					return "ls"
+				})
+				Expect(hosts).To(Equal([]string{"remotehost", "remotehost2"}))
+				Expect(commandList[0].CommandString).To(Equal("ssh -o StrictHostKeyChecking=no testUser@remotehost-ic ls"))
+				Expect(commandList[1].CommandString).To(Equal("ssh -o StrictHostKeyChecking=no testUser@remotehost2 ls"))
+			})
+			It("resolves per-host users by hostname when targeting addresses", func() {
+				testCluster.Target = cluster.TARGET_ADDRESS
+				testCluster.SSHOptions = cluster.SSHOptions{HostUsers: map[string]string{"remotehost": "otheruser"}}
+				commandList := testCluster.GenerateSSHCommandList(cluster.ON_SEGMENTS, func(content int) string { return "ls" })
+				Expect(commandList[0].CommandString).To(Equal("ssh -o StrictHostKeyChecking=no otheruser@remotehost-ic ls"))
+				Expect(commandList[1].CommandString).To(Equal("ssh -o StrictHostKeyChecking=no testUser@remotehost2 ls"))
+			})
+			It("returns the address for a content", func() {
+				Expect(testCluster.GetAddressForContent(0)).To(Equal("remotehost-ic"))
+				Expect(testCluster.GetAddressForContent(0, "m")).To(Equal(""))
+			})
+		})
+	})
+})
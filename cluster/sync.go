@@ -0,0 +1,212 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to synchronizing a local
+ * directory tree to every host in the cluster with rsync.
+ */
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ * SyncOptions controls the rsync command run by SyncDirectory:
+ *
+ * - Delete removes files on each host that do not exist locally (--delete).
+ * - Excludes are passed as --exclude patterns, in order.
+ * - Checksum compares files by checksum rather than size and modification
+ *   time (--checksum), which is slower but catches same-size edits.
+ * - ExtraFlags are passed to rsync verbatim after all other flags.
+ *
+ * Pushing a large tree to hundreds of hosts at once can saturate the
+ * coordinator's network interface, so transfers may be throttled:
+ *
+ * - BandwidthLimit caps each transfer, in KiB per second (--bwlimit).
+ * - AggregateBandwidthLimit caps the total of all transfers running at once,
+ *   in KiB per second, by dividing it evenly between them.
+ * - MaxConcurrentTransfers limits how many hosts are synchronized at once;
+ *   each of the rest starts as soon as any running transfer finishes.
+ *
+ * Zero means no limit for each of these.
+ */
+type SyncOptions struct {
+	Delete                  bool
+	Excludes                []string
+	Checksum                bool
+	ExtraFlags              []string
+	BandwidthLimit          int
+	AggregateBandwidthLimit int
+	MaxConcurrentTransfers  int
+}
+
+/*
+ * TransferStats holds the statistics reported by rsync --stats for a single
+ * host.  Sizes are in bytes.
+ */
+type TransferStats struct {
+	FilesTransferred    int
+	TotalFileSize       int64
+	TransferredFileSize int64
+}
+
+/*
+ * SyncDirectory copies the contents of localDir into the directory returned by
+ * remoteDirGenerator on each host in scope, running rsync from the coordinator
+ * host.  Commands are always generated per host and run locally, whatever the
+ * ON_SEGMENTS/ON_HOSTS and ON_REMOTE/ON_LOCAL bits of scope are.  The stats for
+ * each host that was synchronized successfully are recorded in the returned
+ * RemoteOutput's TransferStats.
+ */
+func (cluster *Cluster) SyncDirectory(localDir string, remoteDirGenerator HostShellGenerator, scope Scope, opts SyncOptions) *RemoteOutput {
+	scope = scope | ON_HOSTS | ON_LOCAL
+	localHost := cluster.GetHostForContent(-1)
+	source := strings.TrimSuffix(localDir, "/") + "/"
+	hosts := cluster.hostsInScope(scope)
+	maxTransfers := len(hosts)
+	if opts.MaxConcurrentTransfers > 0 && opts.MaxConcurrentTransfers < maxTransfers {
+		maxTransfers = opts.MaxConcurrentTransfers
+	}
+	bandwidthLimit := opts.transferBandwidthLimit(maxTransfers)
+	commandList := cluster.GenerateHostCommandList(scope, func(host string) []string {
+		remoteDir := remoteDirGenerator(host)
+		options := cluster.SSHOptions.forHost(host)
+		rsyncCmd := append([]string{"rsync"}, opts.flags(options, bandwidthLimit)...)
+		if host == localHost {
+			return append(rsyncCmd, source, remoteDir)
+		}
+		target := cluster.getTargetForHost(host, cluster.Target)
+		return append(rsyncCmd, source, FormatRemotePath(options.User, target, remoteDir))
+	})
+	log.Verbose("Synchronizing %s to %d hosts", localDir, len(commandList))
+
+	var remoteOutput *RemoteOutput
+	if maxTransfers == len(commandList) {
+		remoteOutput = cluster.ExecuteClusterCommandWithRetries(scope, commandList, cluster.maxAttempts(), 1*time.Second)
+	} else {
+		remoteOutput = cluster.executeWithLimit(scope, commandList, maxTransfers)
+	}
+	remoteOutput.TransferStats = make(map[string]TransferStats)
+	for _, command := range remoteOutput.Commands {
+		if command.Error == nil {
+			remoteOutput.TransferStats[command.Host] = ParseRsyncStats(command.Stdout)
+		}
+	}
+	return remoteOutput
+}
+
+/*
+ * executeWithLimit runs the commands in order, with at most limit running at
+ * once, starting each as soon as a running one finishes rather than waiting
+ * for a whole batch, so that one slow host does not hold up the rest.
+ */
+func (cluster *Cluster) executeWithLimit(scope Scope, commandList []ShellCommand, limit int) *RemoteOutput {
+	outputs := make([]*RemoteOutput, len(commandList))
+	slots := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := range commandList {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(index int) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			outputs[index] = cluster.ExecuteClusterCommandWithRetries(scope, commandList[index:index+1], cluster.maxAttempts(), 1*time.Second)
+		}(i)
+	}
+	wg.Wait()
+
+	executedCommands := make([]ShellCommand, 0, len(commandList))
+	numErrors := 0
+	for _, output := range outputs {
+		executedCommands = append(executedCommands, output.Commands...)
+		numErrors += output.NumErrors
+	}
+	return NewRemoteOutput(scope, numErrors, executedCommands)
+}
+
+/*
+ * transferBandwidthLimit returns the --bwlimit for each of numTransfers
+ * concurrent transfers, or 0 if there is no limit.
+ */
+func (opts SyncOptions) transferBandwidthLimit(numTransfers int) int {
+	limit := opts.BandwidthLimit
+	if opts.AggregateBandwidthLimit > 0 && numTransfers > 0 {
+		share := opts.AggregateBandwidthLimit / numTransfers
+		if share < 1 {
+			share = 1
+		}
+		if limit == 0 || share < limit {
+			limit = share
+		}
+	}
+	return limit
+}
+
+func (opts SyncOptions) flags(sshOptions SSHOptions, bandwidthLimit int) []string {
+	flags := []string{"-a", "--stats"}
+	if bandwidthLimit > 0 {
+		flags = append(flags, fmt.Sprintf("--bwlimit=%d", bandwidthLimit))
+	}
+	if opts.Delete {
+		flags = append(flags, "--delete")
+	}
+	if opts.Checksum {
+		flags = append(flags, "--checksum")
+	}
+	for _, exclude := range opts.Excludes {
+		flags = append(flags, fmt.Sprintf("--exclude=%s", exclude))
+	}
+	sshCmd := append([]string{sshOptions.binary()}, sshOptions.flags()...)
+	for i, arg := range sshCmd {
+		sshCmd[i] = rsyncQuote(arg)
+	}
+	flags = append(flags, "-e", strings.Join(sshCmd, " "))
+	return append(flags, opts.ExtraFlags...)
+}
+
+/*
+ * rsyncQuote quotes arg, if necessary, for the command given to rsync with
+ * -e.  rsync splits that command into arguments itself rather than through a
+ * shell: arguments are separated by spaces, and may be quoted with single or
+ * double quotes, within which a doubled quote stands for a literal one.
+ */
+func rsyncQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, ` '"`) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", "''") + "'"
+}
+
+var (
+	rsyncFilesTransferredRegex    = regexp.MustCompile(`(?m)^Number of (?:regular )?files transferred: ([\d,]+)`)
+	rsyncTotalFileSizeRegex       = regexp.MustCompile(`(?m)^Total file size: ([\d,]+) bytes`)
+	rsyncTransferredFileSizeRegex = regexp.MustCompile(`(?m)^Total transferred file size: ([\d,]+) bytes`)
+)
+
+/*
+ * ParseRsyncStats extracts TransferStats from the output of rsync --stats.
+ * Older versions of rsync report "files transferred" and newer ones "regular
+ * files transferred"; both are accepted.  Any statistic not found is zero.
+ */
+func ParseRsyncStats(output string) TransferStats {
+	return TransferStats{
+		FilesTransferred:    int(parseRsyncNumber(rsyncFilesTransferredRegex, output)),
+		TotalFileSize:       parseRsyncNumber(rsyncTotalFileSizeRegex, output),
+		TransferredFileSize: parseRsyncNumber(rsyncTransferredFileSizeRegex, output),
+	}
+}
+
+func parseRsyncNumber(regex *regexp.Regexp, output string) int64 {
+	match := regex.FindStringSubmatch(output)
+	if match == nil {
+		return 0
+	}
+	value, _ := strconv.ParseInt(strings.ReplaceAll(match[1], ",", ""), 10, 64)
+	return value
+}
@@ -0,0 +1,184 @@
+package cluster_test
+
+import (
+	"errors"
+	"os/user"
+	"sync"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+/*
+ * blockingExecutor runs each command list, which SyncDirectory passes one
+ * command at a time when limiting concurrent transfers, only once the
+ * channel for its host is closed, recording the order in which they start.
+ */
+type blockingExecutor struct {
+	*testhelper.TestExecutor
+	release map[string]chan struct{}
+	mutex   sync.Mutex
+	started []string
+}
+
+func (executor *blockingExecutor) ExecuteClusterCommandWithRetries(scope cluster.Scope, commandList []cluster.ShellCommand, maxAttempts int, retrySleep time.Duration) *cluster.RemoteOutput {
+	executor.mutex.Lock()
+	executor.started = append(executor.started, commandList[0].Host)
+	executor.mutex.Unlock()
+	<-executor.release[commandList[0].Host]
+	return cluster.NewRemoteOutput(scope, 0, commandList)
+}
+
+func (executor *blockingExecutor) Started() []string {
+	executor.mutex.Lock()
+	defer executor.mutex.Unlock()
+	return append([]string{}, executor.started...)
+}
+
+var _ = Describe("cluster/sync tests", func() {
+	newStats := `
+Number of files: 12 (reg: 10, dir: 2)
+Number of created files: 3 (reg: 3)
+Number of regular files transferred: 3
+Total file size: 1,234,567 bytes
+Total transferred file size: 4,096 bytes
+`
+	oldStats := `
+Number of files: 12
+Number of files transferred: 2
+Total file size: 100 bytes
+Total transferred file size: 50 bytes
+`
+	Describe("ParseRsyncStats", func() {
+		It("parses the output of newer versions of rsync", func() {
+			Expect(cluster.ParseRsyncStats(newStats)).To(Equal(cluster.TransferStats{FilesTransferred: 3, TotalFileSize: 1234567, TransferredFileSize: 4096}))
+		})
+		It("parses the output of older versions of rsync", func() {
+			Expect(cluster.ParseRsyncStats(oldStats)).To(Equal(cluster.TransferStats{FilesTransferred: 2, TotalFileSize: 100, TransferredFileSize: 50}))
+		})
+		It("returns zero stats for unrecognized output", func() {
+			Expect(cluster.ParseRsyncStats("")).To(Equal(cluster.TransferStats{}))
+		})
+	})
+	Describe("SyncDirectory", func() {
+		var (
+			testCluster  *cluster.Cluster
+			testExecutor *testhelper.TestExecutor
+		)
+		BeforeEach(func() {
+			operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "testUser", HomeDir: "testDir"}, nil }
+			testExecutor = &testhelper.TestExecutor{}
+			testCluster = cluster.NewCluster([]cluster.SegConfig{
+				{DbID: 1, ContentID: -1, Role: "p", Port: 5432, Hostname: "localhost", DataDir: "/data/gpseg-1"},
+				{DbID: 2, ContentID: 0, Role: "p", Port: 20000, Hostname: "localhost", DataDir: "/data/gpseg0"},
+				{DbID: 3, ContentID: 1, Role: "p", Port: 20001, Hostname: "remotehost1", DataDir: "/data/gpseg1"},
+			}, cluster.WithExecutor(testExecutor))
+		})
+		It("generates an rsync command for each host with the requested options", func() {
+			testExecutor.ClusterOutput = &cluster.RemoteOutput{}
+			testCluster.SyncDirectory("/usr/local/ext/", func(host string) string { return "/usr/local/ext" }, cluster.ON_HOSTS, cluster.SyncOptions{
+				Delete:   true,
+				Checksum: true,
+				Excludes: []string{"*.tmp", ".git"},
+			})
+			Expect(testExecutor.ClusterCommands).To(HaveLen(1))
+			commands := testExecutor.ClusterCommands[0]
+			Expect(commands).To(HaveLen(2))
+			Expect(commands[0].Host).To(Equal("localhost"))
+			Expect(commands[0].Command.Args).To(Equal([]string{"rsync", "-a", "--stats", "--delete", "--checksum", "--exclude=*.tmp", "--exclude=.git",
+				"-e", "ssh -o StrictHostKeyChecking=no", "/usr/local/ext/", "/usr/local/ext"}))
+			Expect(commands[1].Host).To(Equal("remotehost1"))
+			Expect(commands[1].Command.Args).To(Equal([]string{"rsync", "-a", "--stats", "--delete", "--checksum", "--exclude=*.tmp", "--exclude=.git",
+				"-e", "ssh -o StrictHostKeyChecking=no", "/usr/local/ext/", "testUser@remotehost1:/usr/local/ext"}))
+			Expect(commands[1].Scope).To(Equal(cluster.ON_HOSTS | cluster.ON_LOCAL))
+		})
+		DescribeTable("bandwidth limits", func(opts cluster.SyncOptions, expectedFlag string) {
+			testExecutor.ClusterOutput = &cluster.RemoteOutput{}
+			testCluster.SyncDirectory("/usr/local/ext", func(host string) string { return "/usr/local/ext" }, cluster.ON_HOSTS, opts)
+			args := testExecutor.ClusterCommands[0][0].Command.Args
+			if expectedFlag == "" {
+				Expect(args).ToNot(ContainElement(HavePrefix("--bwlimit")))
+			} else {
+				Expect(args).To(ContainElement(expectedFlag))
+			}
+		},
+			Entry("no limit by default", cluster.SyncOptions{}, ""),
+			Entry("a per-transfer limit", cluster.SyncOptions{BandwidthLimit: 1000}, "--bwlimit=1000"),
+			Entry("an aggregate limit shared between hosts", cluster.SyncOptions{AggregateBandwidthLimit: 1000}, "--bwlimit=500"),
+			Entry("the lower of the two limits", cluster.SyncOptions{BandwidthLimit: 300, AggregateBandwidthLimit: 1000}, "--bwlimit=300"),
+			Entry("an aggregate limit shared between concurrent transfers", cluster.SyncOptions{AggregateBandwidthLimit: 1000, MaxConcurrentTransfers: 1}, "--bwlimit=1000"),
+		)
+		It("synchronizes at most MaxConcurrentTransfers hosts at once", func() {
+			localCommand := cluster.NewShellCommand(cluster.ON_HOSTS, -2, "localhost", []string{"rsync"})
+			localCommand.Stdout = newStats
+			remoteCommand := cluster.NewShellCommand(cluster.ON_HOSTS, -2, "remotehost1", []string{"rsync"})
+			remoteCommand.Error = errors.New("exit status 12")
+			testExecutor.ClusterOutputs = []*cluster.RemoteOutput{
+				cluster.NewRemoteOutput(cluster.ON_HOSTS, 0, []cluster.ShellCommand{localCommand}),
+				cluster.NewRemoteOutput(cluster.ON_HOSTS, 1, []cluster.ShellCommand{remoteCommand}),
+			}
+			output := testCluster.SyncDirectory("/usr/local/ext", func(host string) string { return "/usr/local/ext" }, cluster.ON_HOSTS, cluster.SyncOptions{MaxConcurrentTransfers: 1})
+			Expect(testExecutor.ClusterCommands).To(HaveLen(2))
+			Expect(testExecutor.ClusterCommands[0]).To(HaveLen(1))
+			Expect(testExecutor.ClusterCommands[1][0].Host).To(Equal("remotehost1"))
+			Expect(output.NumErrors).To(Equal(1))
+			Expect(output.Commands).To(HaveLen(2))
+			Expect(output.FailedCommands[0].Host).To(Equal("remotehost1"))
+			Expect(output.TransferStats).To(HaveKey("localhost"))
+		})
+		It("starts each remaining transfer as soon as a running one finishes", func() {
+			executor := &blockingExecutor{TestExecutor: testExecutor, release: map[string]chan struct{}{
+				"localhost": make(chan struct{}), "remotehost1": make(chan struct{}), "remotehost2": make(chan struct{}),
+			}}
+			testCluster = cluster.NewCluster([]cluster.SegConfig{
+				{DbID: 1, ContentID: -1, Role: "p", Port: 5432, Hostname: "localhost", DataDir: "/data/gpseg-1"},
+				{DbID: 2, ContentID: 0, Role: "p", Port: 20000, Hostname: "localhost", DataDir: "/data/gpseg0"},
+				{DbID: 3, ContentID: 1, Role: "p", Port: 20001, Hostname: "remotehost1", DataDir: "/data/gpseg1"},
+				{DbID: 4, ContentID: 2, Role: "p", Port: 20002, Hostname: "remotehost2", DataDir: "/data/gpseg2"},
+			}, cluster.WithExecutor(executor))
+			done := make(chan *cluster.RemoteOutput)
+			go func() {
+				done <- testCluster.SyncDirectory("/usr/local/ext", func(host string) string { return "/usr/local/ext" }, cluster.ON_HOSTS,
+					cluster.SyncOptions{AggregateBandwidthLimit: 1000, MaxConcurrentTransfers: 2})
+			}()
+
+			Eventually(executor.Started).Should(ConsistOf("localhost", "remotehost1"))
+			close(executor.release["remotehost1"])
+			Eventually(executor.Started).Should(ConsistOf("localhost", "remotehost1", "remotehost2"))
+			close(executor.release["localhost"])
+			close(executor.release["remotehost2"])
+
+			var output *cluster.RemoteOutput
+			Eventually(done).Should(Receive(&output))
+			Expect(output.Commands).To(HaveLen(3))
+			Expect(output.Commands[2].Host).To(Equal("remotehost2"))
+			Expect(output.Commands[2].Command.Args).To(ContainElement("--bwlimit=500"))
+		})
+		It("quotes ssh arguments containing spaces or quotes for rsync", func() {
+			testExecutor.ClusterOutput = &cluster.RemoteOutput{}
+			testCluster.SSHOptions.ExtraFlags = []string{"-o", "ProxyCommand=ssh -W %h:%p 'bastion'"}
+			testCluster.SyncDirectory("/usr/local/ext", func(host string) string { return "/usr/local/ext" }, cluster.ON_HOSTS, cluster.SyncOptions{})
+
+			args := testExecutor.ClusterCommands[0][0].Command.Args
+			Expect(args).To(ContainElement(`ssh -o StrictHostKeyChecking=no -o 'ProxyCommand=ssh -W %h:%p ''bastion'''`))
+		})
+		It("records transfer stats for each host that succeeded", func() {
+			localCommand := cluster.NewShellCommand(cluster.ON_HOSTS, -2, "localhost", []string{"rsync"})
+			localCommand.Stdout = newStats
+			remoteCommand := cluster.NewShellCommand(cluster.ON_HOSTS, -2, "remotehost1", []string{"rsync"})
+			remoteCommand.Error = errors.New("exit status 12")
+			testExecutor.ClusterOutput = cluster.NewRemoteOutput(cluster.ON_HOSTS, 1, []cluster.ShellCommand{localCommand, remoteCommand})
+
+			output := testCluster.SyncDirectory("/usr/local/ext", func(host string) string { return "/usr/local/ext" }, cluster.ON_HOSTS, cluster.SyncOptions{})
+			Expect(output.NumErrors).To(Equal(1))
+			Expect(output.TransferStats).To(Equal(map[string]cluster.TransferStats{
+				"localhost": {FilesTransferred: 3, TotalFileSize: 1234567, TransferredFileSize: 4096},
+			}))
+		})
+	})
+})
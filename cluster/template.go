@@ -0,0 +1,46 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to generating commands
+ * from text/template strings rather than from generator functions.
+ */
+
+import (
+	"bytes"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * GenerateTemplatedCommands expands commandTemplate against each segment in
+ * scope, as with a SegConfig generator, so a simple command such as
+ *
+ *   "pg_ctl -D {{.DataDir}} -o '-p {{.Port}}' restart"
+ *
+ * needs no generator function.  The expanded commands are run locally or
+ * through ssh as in GenerateSSHCommandList.  Templates are only supported for
+ * per-segment scopes, and an error is returned if the template cannot be
+ * parsed or refers to a field that SegConfig does not have.
+ */
+func (cluster *Cluster) GenerateTemplatedCommands(scope Scope, commandTemplate string) ([]ShellCommand, error) {
+	if scopeIsHosts(scope) {
+		return nil, errors.Errorf("Cannot generate templated commands for scope %s; templates are only supported with ON_SEGMENTS", scope)
+	}
+	tmpl, err := template.New("command").Parse(commandTemplate)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse command template %q", commandTemplate)
+	}
+
+	expanded := make(map[int]string)
+	for _, seg := range cluster.segmentsInScope(scope) {
+		var buffer bytes.Buffer
+		if err := tmpl.Execute(&buffer, seg); err != nil {
+			return nil, errors.Wrapf(err, "Failed to expand command template for segment with dbid %d", seg.DbID)
+		}
+		expanded[seg.ContentID] = buffer.String()
+	}
+	return cluster.GenerateContentSSHCommandList(scope, cluster.Target, func(content int) string {
+		return expanded[content]
+	}), nil
+}
@@ -0,0 +1,51 @@
+package cluster_test
+
+import (
+	"os/user"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/template tests", func() {
+	var testCluster *cluster.Cluster
+	BeforeEach(func() {
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "testUser", HomeDir: "testDir"}, nil }
+		testCluster = cluster.NewCluster([]cluster.SegConfig{
+			{DbID: 1, ContentID: -1, Role: "p", Port: 5432, Hostname: "localhost", DataDir: "/data/gpseg-1"},
+			{DbID: 2, ContentID: 0, Role: "p", Port: 20000, Hostname: "localhost", DataDir: "/data/gpseg0"},
+			{DbID: 3, ContentID: 1, Role: "p", Port: 20001, Hostname: "remotehost1", DataDir: "/data/gpseg1"},
+		})
+	})
+	It("expands the template for each segment", func() {
+		commands, err := testCluster.GenerateTemplatedCommands(cluster.ON_SEGMENTS, "pg_ctl -D {{.DataDir}} -o '-p {{.Port}}' restart")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(commands).To(Equal([]cluster.ShellCommand{
+			cluster.NewShellCommand(cluster.ON_SEGMENTS, 0, "", []string{"bash", "-c", "pg_ctl -D /data/gpseg0 -o '-p 20000' restart"}),
+			cluster.NewShellCommand(cluster.ON_SEGMENTS, 1, "", []string{"ssh", "-o", "StrictHostKeyChecking=no", "testUser@remotehost1", "pg_ctl -D /data/gpseg1 -o '-p 20001' restart"}),
+		}))
+	})
+	It("includes the coordinator if requested", func() {
+		commands, err := testCluster.GenerateTemplatedCommands(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, "echo {{.DbID}}")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(commands).To(HaveLen(3))
+		Expect(commands[0].CommandString).To(Equal("bash -c echo 1"))
+	})
+	It("returns an error for an invalid template", func() {
+		_, err := testCluster.GenerateTemplatedCommands(cluster.ON_SEGMENTS, "echo {{.DataDir")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(HavePrefix(`Failed to parse command template "echo {{.DataDir"`))
+	})
+	It("returns an error for an unknown field", func() {
+		_, err := testCluster.GenerateTemplatedCommands(cluster.ON_SEGMENTS, "echo {{.NoSuchField}}")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(HavePrefix("Failed to expand command template for segment with dbid 2"))
+	})
+	It("returns an error for a per-host scope", func() {
+		_, err := testCluster.GenerateTemplatedCommands(cluster.ON_HOSTS, "echo {{.Hostname}}")
+		Expect(err).To(MatchError("Cannot generate templated commands for scope ON_HOSTS|EXCLUDE_COORDINATOR|ON_REMOTE|EXCLUDE_MIRRORS; templates are only supported with ON_SEGMENTS"))
+	})
+})
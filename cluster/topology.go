@@ -0,0 +1,100 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to modifying a cluster's
+ * topology after it has been created, e.g. by a monitoring process that
+ * tracks failovers while other goroutines generate and execute commands.
+ */
+
+import (
+	"github.com/pkg/errors"
+)
+
+/*
+ * The functions below, and the accessor functions in cluster.go, are safe to
+ * call concurrently.  Each update copies Segments before modifying it and
+ * then rebuilds the lookup maps, so a *SegConfig or slice obtained before an
+ * update remains valid but reflects the topology at the time it was obtained.
+ *
+ * Reading the exported fields of a Cluster directly is not synchronized, so
+ * code that may run concurrently with an update should use SegmentsSnapshot
+ * or the accessor functions instead.
+ */
+
+// SegmentsSnapshot returns a copy of the cluster's current segments.
+func (cluster *Cluster) SegmentsSnapshot() []SegConfig {
+	cluster.mutex.RLock()
+	defer cluster.mutex.RUnlock()
+	return append([]SegConfig{}, cluster.Segments...)
+}
+
+/*
+ * AddSegment adds a segment to the cluster.  It returns an error if a segment
+ * with the same dbid already exists, or if the segment's content already has
+ * both a primary and a mirror.
+ */
+func (cluster *Cluster) AddSegment(segment SegConfig) error {
+	cluster.mutex.Lock()
+	defer cluster.mutex.Unlock()
+	if cluster.indexOfDbid(segment.DbID) != -1 {
+		return errors.Errorf("Cannot add segment with dbid %d; a segment with that dbid already exists", segment.DbID)
+	}
+	if len(cluster.ByContent[segment.ContentID]) >= 2 {
+		return errors.Errorf("Cannot add segment with dbid %d; content %d already has a primary and a mirror", segment.DbID, segment.ContentID)
+	}
+	segments := make([]SegConfig, len(cluster.Segments), len(cluster.Segments)+1)
+	copy(segments, cluster.Segments)
+	segments = append(segments, segment)
+	cluster.index(segments)
+	return nil
+}
+
+func (cluster *Cluster) RemoveSegment(dbid int) error {
+	cluster.mutex.Lock()
+	defer cluster.mutex.Unlock()
+	index := cluster.indexOfDbid(dbid)
+	if index == -1 {
+		return errors.Errorf("Cannot remove segment with dbid %d; no such segment exists", dbid)
+	}
+	segments := make([]SegConfig, 0, len(cluster.Segments)-1)
+	segments = append(segments, cluster.Segments[:index]...)
+	segments = append(segments, cluster.Segments[index+1:]...)
+	cluster.index(segments)
+	return nil
+}
+
+/*
+ * UpdateSegmentStatus sets the status ("u" or "d") of the segment with the
+ * given dbid.  If role is given, the segment's role is also updated, so that
+ * a failover can be recorded by updating both segments of a content.
+ */
+func (cluster *Cluster) UpdateSegmentStatus(dbid int, status string, role ...string) error {
+	if status != "u" && status != "d" {
+		return errors.Errorf("Invalid status %q for segment with dbid %d; status must be \"u\" or \"d\"", status, dbid)
+	}
+	if len(role) > 1 || (len(role) == 1 && role[0] != "p" && role[0] != "m") {
+		return errors.Errorf("Invalid role for segment with dbid %d; role must be \"p\" or \"m\"", dbid)
+	}
+	cluster.mutex.Lock()
+	defer cluster.mutex.Unlock()
+	index := cluster.indexOfDbid(dbid)
+	if index == -1 {
+		return errors.Errorf("Cannot update segment with dbid %d; no such segment exists", dbid)
+	}
+	segments := append([]SegConfig{}, cluster.Segments...)
+	segments[index].Status = status
+	if len(role) == 1 {
+		segments[index].Role = role[0]
+	}
+	cluster.index(segments)
+	return nil
+}
+
+func (cluster *Cluster) indexOfDbid(dbid int) int {
+	for i, segment := range cluster.Segments {
+		if segment.DbID == dbid {
+			return i
+		}
+	}
+	return -1
+}
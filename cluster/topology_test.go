@@ -0,0 +1,101 @@
+package cluster_test
+
+import (
+	"sync"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/topology tests", func() {
+	var testCluster *cluster.Cluster
+	coordinator := cluster.SegConfig{DbID: 1, ContentID: -1, Role: "p", Status: "u", Port: 5432, Hostname: "cdw"}
+	primary := cluster.SegConfig{DbID: 2, ContentID: 0, Role: "p", Status: "u", Port: 20000, Hostname: "sdw1"}
+	mirror := cluster.SegConfig{DbID: 3, ContentID: 0, Role: "m", Status: "u", Port: 21000, Hostname: "sdw2"}
+	BeforeEach(func() {
+		testCluster = cluster.NewCluster([]cluster.SegConfig{coordinator, primary})
+	})
+	Describe("AddSegment", func() {
+		It("adds a segment and updates the lookup structures", func() {
+			Expect(testCluster.AddSegment(mirror)).To(Succeed())
+			Expect(testCluster.Segments).To(HaveLen(3))
+			Expect(testCluster.Hostnames).To(Equal([]string{"cdw", "sdw1", "sdw2"}))
+			Expect(testCluster.GetDbidForContent(0, "m")).To(Equal(3))
+			Expect(testCluster.GetContentsForHost("sdw2")).To(Equal([]int{0}))
+		})
+		It("adds a new content", func() {
+			Expect(testCluster.AddSegment(cluster.SegConfig{DbID: 4, ContentID: 1, Role: "p", Port: 20001, Hostname: "sdw1"})).To(Succeed())
+			Expect(testCluster.ContentIDs).To(Equal([]int{-1, 0, 1}))
+			Expect(testCluster.GetPortsForHost("sdw1")).To(Equal([]int{20000, 20001}))
+		})
+		It("rejects a duplicate dbid", func() {
+			err := testCluster.AddSegment(primary)
+			Expect(err).To(MatchError("Cannot add segment with dbid 2; a segment with that dbid already exists"))
+		})
+		It("rejects a third segment for a content", func() {
+			Expect(testCluster.AddSegment(mirror)).To(Succeed())
+			err := testCluster.AddSegment(cluster.SegConfig{DbID: 4, ContentID: 0, Role: "m", Port: 22000, Hostname: "sdw3"})
+			Expect(err).To(MatchError("Cannot add segment with dbid 4; content 0 already has a primary and a mirror"))
+		})
+	})
+	Describe("RemoveSegment", func() {
+		It("removes a segment along with its content and host", func() {
+			Expect(testCluster.RemoveSegment(2)).To(Succeed())
+			Expect(testCluster.ContentIDs).To(Equal([]int{-1}))
+			Expect(testCluster.Hostnames).To(Equal([]string{"cdw"}))
+			Expect(testCluster.GetHostForContent(0)).To(Equal(""))
+		})
+		It("returns an error for a nonexistent segment", func() {
+			Expect(testCluster.RemoveSegment(9)).To(MatchError("Cannot remove segment with dbid 9; no such segment exists"))
+		})
+	})
+	Describe("UpdateSegmentStatus", func() {
+		It("updates the status without changing segments obtained earlier", func() {
+			segments := testCluster.SegmentsSnapshot()
+			Expect(testCluster.UpdateSegmentStatus(2, "d")).To(Succeed())
+			segConfig, err := testCluster.LookupSegment(0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(segConfig.Status).To(Equal("d"))
+			Expect(segments[1].Status).To(Equal("u"))
+		})
+		It("records a failover by updating roles", func() {
+			Expect(testCluster.AddSegment(mirror)).To(Succeed())
+			Expect(testCluster.UpdateSegmentStatus(2, "d", "m")).To(Succeed())
+			Expect(testCluster.UpdateSegmentStatus(3, "u", "p")).To(Succeed())
+			Expect(testCluster.GetDbidForContent(0)).To(Equal(3))
+			Expect(testCluster.GetDbidForContent(0, "m")).To(Equal(2))
+		})
+		It("returns an error for an invalid status or nonexistent segment", func() {
+			Expect(testCluster.UpdateSegmentStatus(2, "x")).To(MatchError(`Invalid status "x" for segment with dbid 2; status must be "u" or "d"`))
+			Expect(testCluster.UpdateSegmentStatus(2, "u", "x")).To(MatchError(`Invalid role for segment with dbid 2; role must be "p" or "m"`))
+			Expect(testCluster.UpdateSegmentStatus(9, "u")).To(MatchError("Cannot update segment with dbid 9; no such segment exists"))
+		})
+	})
+	It("allows updates while other goroutines generate commands", func() {
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				_ = testCluster.AddSegment(mirror)
+				_ = testCluster.UpdateSegmentStatus(2, "d")
+				_ = testCluster.RemoveSegment(3)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				testCluster.GenerateCommandList(cluster.ON_HOSTS, func(host string) []string {
+					return append([]string{"ls"}, testCluster.GetDirsForHost(host)...)
+				})
+				testCluster.GenerateCommandList(cluster.ON_SEGMENTS, func(content int) []string {
+					return []string{"echo", testCluster.GetHostForContent(content)}
+				})
+			}
+		}()
+		wg.Wait()
+		Expect(testCluster.Segments).To(HaveLen(2))
+	})
+})
@@ -0,0 +1,109 @@
+package cluster
+
+/*
+ * This file contains structs and functions related to watching gpsegconfig_dump
+ * for changes, so long-running processes can track the cluster's topology.
+ */
+
+import (
+	"path"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+)
+
+/*
+ * FTS may write gpsegconfig_dump in several steps or replace it with a rename,
+ * so events within this interval of one another are coalesced into a single
+ * re-parse of the file.
+ */
+const segConfigWatchSettleTime = 100 * time.Millisecond
+
+type SegConfigWatcher struct {
+	watcher   *fsnotify.Watcher
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+/*
+ * WatchSegmentConfigurationFile re-parses gpsegconfig_dump in the given
+ * coordinator data directory whenever it changes and passes the new
+ * configuration to callback.  The callback is invoked from a single goroutine,
+ * so calls never overlap.  If the file cannot be parsed, for instance because
+ * it was removed, a warning is logged and the callback is not invoked.
+ *
+ * The data directory rather than the file itself is watched, so that the file
+ * continues to be tracked if it is replaced.  Call Close to stop watching.
+ */
+func WatchSegmentConfigurationFile(coordinatorDataDir string, callback func(segConfigs []SegConfig)) (*SegConfigWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create file watcher")
+	}
+	if err := watcher.Add(coordinatorDataDir); err != nil {
+		_ = watcher.Close()
+		return nil, errors.Wrapf(err, "Failed to watch directory %s", coordinatorDataDir)
+	}
+	segConfigWatcher := &SegConfigWatcher{watcher: watcher, done: make(chan struct{})}
+	segConfigWatcher.wg.Add(1)
+	go segConfigWatcher.run(coordinatorDataDir, callback)
+	return segConfigWatcher, nil
+}
+
+func (segConfigWatcher *SegConfigWatcher) run(coordinatorDataDir string, callback func(segConfigs []SegConfig)) {
+	defer segConfigWatcher.wg.Done()
+	gpsegconfigDump := path.Join(coordinatorDataDir, "gpsegconfig_dump")
+	settle := time.NewTimer(segConfigWatchSettleTime)
+	settle.Stop()
+	for {
+		select {
+		case <-segConfigWatcher.done:
+			settle.Stop()
+			return
+		case event, ok := <-segConfigWatcher.watcher.Events:
+			if !ok {
+				return
+			}
+			if path.Clean(event.Name) == gpsegconfigDump && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+				// Before Go 1.23, a timer that fired while we were busy keeps its
+				// value in settle.C, so drain it or the reload runs too early.
+				if !settle.Stop() {
+					select {
+					case <-settle.C:
+					default:
+					}
+				}
+				settle.Reset(segConfigWatchSettleTime)
+			}
+		case err, ok := <-segConfigWatcher.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Warn("Error watching %s: %v", gpsegconfigDump, err)
+		case <-settle.C:
+			segConfigs, err := GetSegmentConfigurationFromFile(coordinatorDataDir)
+			if err != nil {
+				log.Warn("Could not reload segment configuration: %v", err)
+				continue
+			}
+			callback(segConfigs)
+		}
+	}
+}
+
+/*
+ * Close stops watching the file and waits for any callback in progress to
+ * return.  It is safe to call more than once.
+ */
+func (segConfigWatcher *SegConfigWatcher) Close() error {
+	var err error
+	segConfigWatcher.closeOnce.Do(func() {
+		close(segConfigWatcher.done)
+		err = segConfigWatcher.watcher.Close()
+		segConfigWatcher.wg.Wait()
+	})
+	return err
+}
@@ -0,0 +1,65 @@
+package cluster_test
+
+import (
+	"os"
+	"path"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/watch tests", func() {
+	var (
+		dataDir  string
+		updates  chan []cluster.SegConfig
+		watcher  *cluster.SegConfigWatcher
+		coordSeg = "1 -1 p p n u 7000 localhost localhost /data/qddir/demoDataDir-1\n"
+		seg0     = "2 0 p p n u 7002 sdw1 sdw1 /data/primary/gpseg0\n"
+	)
+	writeDump := func(contents string) {
+		// Write to a temporary file and rename it, as FTS does
+		tempFile := path.Join(dataDir, "gpsegconfig_dump.tmp")
+		Expect(os.WriteFile(tempFile, []byte(contents), 0600)).To(Succeed())
+		Expect(os.Rename(tempFile, path.Join(dataDir, "gpsegconfig_dump"))).To(Succeed())
+	}
+	BeforeEach(func() {
+		dataDir = GinkgoT().TempDir()
+		updates = make(chan []cluster.SegConfig, 10)
+		writeDump(coordSeg)
+		var err error
+		watcher, err = cluster.WatchSegmentConfigurationFile(dataDir, func(segConfigs []cluster.SegConfig) {
+			updates <- segConfigs
+		})
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		Expect(watcher.Close()).To(Succeed())
+	})
+	It("invokes the callback with the new configuration when the file is replaced", func() {
+		writeDump(coordSeg + seg0)
+
+		var segConfigs []cluster.SegConfig
+		Eventually(updates, "5s").Should(Receive(&segConfigs))
+		Expect(segConfigs).To(HaveLen(2))
+		Expect(segConfigs[1].Hostname).To(Equal("sdw1"))
+	})
+	It("ignores changes to other files", func() {
+		Expect(os.WriteFile(path.Join(dataDir, "postgresql.conf"), []byte("port=7000\n"), 0600)).To(Succeed())
+		Consistently(updates, "300ms").ShouldNot(Receive())
+	})
+	It("does not invoke the callback if the file cannot be parsed", func() {
+		writeDump("not a valid line\n")
+		Consistently(updates, "300ms").ShouldNot(Receive())
+		testhelper.ExpectRegexp(logfile, "Could not reload segment configuration")
+	})
+	It("can be closed more than once", func() {
+		Expect(watcher.Close()).To(Succeed())
+	})
+	It("returns an error if the directory does not exist", func() {
+		_, err := cluster.WatchSegmentConfigurationFile(path.Join(dataDir, "missing"), func([]cluster.SegConfig) {})
+		Expect(err).To(MatchError(ContainSubstring("Failed to watch directory")))
+	})
+})
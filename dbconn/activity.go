@@ -0,0 +1,108 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to inspecting the sessions
+ * connected to the server, as listed in pg_stat_activity.
+ */
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * A Backend is a session connected to the server.  State is one of "active",
+ * "idle", "idle in transaction", and so on, and Runtime is how long the current
+ * or most recent query has been running.
+ */
+type Backend struct {
+	PID             int            `db:"pid"`
+	User            string         `db:"usename"`
+	DBName          string         `db:"datname"`
+	ApplicationName string         `db:"application_name"`
+	State           string         `db:"state"`
+	Query           string         `db:"query"`
+	BackendStart    sql.NullTime   `db:"backend_start"`
+	QueryStart      sql.NullTime   `db:"query_start"`
+	ClientAddr      sql.NullString `db:"client_addr"`
+	Runtime         time.Duration  `db:"-"`
+}
+
+/*
+ * BackendFilter restricts the backends returned by ListBackends to those
+ * matching every non-zero field.  MinRuntime selects backends whose query has
+ * been running for at least that long, and ActiveOnly those running a query.
+ */
+type BackendFilter struct {
+	ApplicationName string
+	DBName          string
+	User            string
+	MinRuntime      time.Duration
+	ActiveOnly      bool
+}
+
+/*
+ * ListBackends returns the sessions connected to the server that match filter,
+ * other than the one the query runs on, ordered by PID.  GPDB 5 and earlier
+ * name some pg_stat_activity columns differently and do not report a state,
+ * so there the state is derived from the query, which is "<IDLE>" for an idle
+ * session.
+ */
+func (dbconn *DBConn) ListBackends(filter BackendFilter, whichConn ...int) ([]Backend, error) {
+	pidColumn, queryColumn := "pid", "query"
+	stateExpression := "COALESCE(state, '')"
+	if dbconn.Version.IsGPDB() && dbconn.Version.Before("6") {
+		pidColumn, queryColumn = "procpid", "current_query"
+		stateExpression = `CASE current_query
+		WHEN '<IDLE>' THEN 'idle'
+		WHEN '<IDLE> in transaction' THEN 'idle in transaction'
+		ELSE 'active' END`
+	}
+	conditions := []string{fmt.Sprintf("%s <> pg_catalog.pg_backend_pid()", pidColumn)}
+	if filter.ApplicationName != "" {
+		conditions = append(conditions, fmt.Sprintf("application_name = '%s'", EscapeString(filter.ApplicationName)))
+	}
+	if filter.DBName != "" {
+		conditions = append(conditions, fmt.Sprintf("datname = '%s'", EscapeString(filter.DBName)))
+	}
+	if filter.User != "" {
+		conditions = append(conditions, fmt.Sprintf("usename = '%s'", EscapeString(filter.User)))
+	}
+	if filter.ActiveOnly {
+		conditions = append(conditions, fmt.Sprintf("%s = 'active'", stateExpression))
+	}
+	if filter.MinRuntime > 0 {
+		conditions = append(conditions, fmt.Sprintf("query_start <= now() - interval '%d milliseconds'", filter.MinRuntime.Milliseconds()))
+	}
+	query := fmt.Sprintf(`
+	SELECT %s AS pid,
+		COALESCE(usename, '') AS usename,
+		COALESCE(datname, '') AS datname,
+		COALESCE(application_name, '') AS application_name,
+		%s AS state,
+		COALESCE(%s, '') AS query,
+		backend_start,
+		query_start,
+		COALESCE(EXTRACT(EPOCH FROM now() - query_start), 0) AS runtime_seconds,
+		client_addr::text AS client_addr
+	FROM pg_catalog.pg_stat_activity
+	WHERE %s
+	ORDER BY %s`, pidColumn, stateExpression, queryColumn, strings.Join(conditions, "\n\tAND "), pidColumn)
+	rows := make([]struct {
+		Backend
+		RuntimeSeconds float64 `db:"runtime_seconds"`
+	}, 0)
+	if err := dbconn.Select(&rows, query, whichConn...); err != nil {
+		return nil, errors.Wrap(err, "Failed to list backends")
+	}
+	backends := make([]Backend, len(rows))
+	for i, row := range rows {
+		backends[i] = row.Backend
+		backends[i].Runtime = time.Duration(row.RuntimeSeconds * float64(time.Second))
+	}
+	return backends, nil
+}
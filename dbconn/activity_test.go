@@ -0,0 +1,74 @@
+package dbconn_test
+
+import (
+	"errors"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/activity tests", func() {
+	backendColumns := []string{"pid", "usename", "datname", "application_name", "state", "query", "backend_start", "query_start", "runtime_seconds", "client_addr"}
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	Describe("DBConn.ListBackends", func() {
+		It("lists backends on GPDB 6 and later", func() {
+			testhelper.SetDBVersion(connection, "6.0.0")
+			rows := sqlmock.NewRows(backendColumns).
+				AddRow(100, "gpadmin", "testdb", "gpbackup", "active", "COPY foo TO STDOUT", start, start, 90.5, "127.0.0.1/32").
+				AddRow(101, "gpadmin", "testdb", "psql", "idle", "", start, nil, 0, nil)
+			mock.ExpectQuery(`SELECT pid AS pid,(.|\n)*COALESCE\(state, ''\) AS state,(.|\n)*WHERE pid <> pg_catalog.pg_backend_pid\(\)\s+ORDER BY pid`).WillReturnRows(rows)
+
+			backends, err := connection.ListBackends(dbconn.BackendFilter{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(backends).To(HaveLen(2))
+			Expect(backends[0].PID).To(Equal(100))
+			Expect(backends[0].ApplicationName).To(Equal("gpbackup"))
+			Expect(backends[0].State).To(Equal("active"))
+			Expect(backends[0].QueryStart.Time).To(Equal(start))
+			Expect(backends[0].Runtime).To(Equal(90500 * time.Millisecond))
+			Expect(backends[0].ClientAddr.String).To(Equal("127.0.0.1/32"))
+			Expect(backends[1].QueryStart.Valid).To(BeFalse())
+			Expect(backends[1].ClientAddr.Valid).To(BeFalse())
+		})
+		It("uses the older column names on GPDB 5", func() {
+			mock.ExpectQuery(`SELECT procpid AS pid,(.|\n)*WHEN '<IDLE>' THEN 'idle'(.|\n)*COALESCE\(current_query, ''\) AS query(.|\n)*WHERE procpid <> pg_catalog.pg_backend_pid\(\)`).WillReturnRows(sqlmock.NewRows(backendColumns))
+
+			backends, err := connection.ListBackends(dbconn.BackendFilter{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(backends).To(BeEmpty())
+		})
+		It("filters backends", func() {
+			mockConn := testhelper.NewMockDBConn(testhelper.MakeVersion(dbconn.GPDB, "7.0.0"), 1)
+			mockConn.ExpectSelect(`WHERE pid <> pg_catalog.pg_backend_pid()
+	AND application_name = 'gp''backup'
+	AND datname = 'testdb'
+	AND usename = 'gpadmin'
+	AND COALESCE(state, '') = 'active'
+	AND query_start <= now() - interval '1500 milliseconds'`, backendColumns)
+
+			_, err := mockConn.ListBackends(dbconn.BackendFilter{
+				ApplicationName: "gp'backup",
+				DBName:          "testdb",
+				User:            "gpadmin",
+				ActiveOnly:      true,
+				MinRuntime:      1500 * time.Millisecond,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			mockConn.ExpectationsWereMet()
+		})
+		It("returns an error if the query fails", func() {
+			mockConn := testhelper.NewMockDBConn(testhelper.MakeVersion(dbconn.GPDB, "6.0.0"), 1)
+			mockConn.ExpectSelectError("FROM pg_catalog.pg_stat_activity", errors.New("permission denied"))
+
+			_, err := mockConn.ListBackends(dbconn.BackendFilter{})
+			Expect(err).To(MatchError("Failed to list backends: permission denied"))
+			Expect(mockConn.Transcript()).To(HaveLen(1))
+		})
+	})
+})
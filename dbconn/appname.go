@@ -0,0 +1,55 @@
+package dbconn
+
+/*
+ * This file contains functions related to the application_name reported by
+ * each connection, which identifies the program that opened a session in
+ * pg_stat_activity and in the server log.
+ */
+
+import (
+	"os"
+	"path/filepath"
+	"runtime/debug"
+
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+)
+
+/*
+ * DefaultApplicationName returns the name of the running program, followed by
+ * the version of its main module if it was built from a tagged release, such
+ * as "gpbackup v1.30.5".
+ */
+func DefaultApplicationName() string {
+	name := filepath.Base(os.Args[0])
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		name += " " + info.Main.Version
+	}
+	return name
+}
+
+/*
+ * applicationName returns the application_name to send when connecting: the
+ * ApplicationName field if set, or else DefaultApplicationName, unless the
+ * user has chosen a name with $PGAPPNAME, which the driver uses instead.
+ */
+func (dbconn *DBConn) applicationName() string {
+	if dbconn.ApplicationName != "" {
+		return dbconn.ApplicationName
+	}
+	if operating.System.Getenv("PGAPPNAME") != "" {
+		return ""
+	}
+	return DefaultApplicationName()
+}
+
+/*
+ * SetApplicationName sets the application_name for every connection in the
+ * pool, and for any connections made later.
+ */
+func (dbconn *DBConn) SetApplicationName(name string) error {
+	dbconn.ApplicationName = name
+	if dbconn.ConnPool == nil {
+		return nil
+	}
+	return dbconn.SetGUC("application_name", name)
+}
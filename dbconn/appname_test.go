@@ -0,0 +1,94 @@
+package dbconn_test
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/appname tests", func() {
+	var env map[string]string
+	BeforeEach(func() {
+		env = map[string]string{}
+		operating.System.Getenv = func(key string) string { return env[key] }
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+	Describe("DefaultApplicationName", func() {
+		It("returns the name of the program", func() {
+			Expect(dbconn.DefaultApplicationName()).To(HavePrefix(filepath.Base(os.Args[0])))
+		})
+	})
+	Describe("DBConn.Connect", func() {
+		It("sends the default application name", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			driver := useRecordingDriver(connection)
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+
+			Expect(connection.Connect(1)).To(Succeed())
+			Expect(driver.ConnStrs[0]).To(ContainSubstring(" application_name='" + dbconn.DefaultApplicationName() + "'"))
+		})
+		It("sends the application name set on the DBConn to every connection", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			driver := useRecordingDriver(connection)
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+			env["PGAPPNAME"] = "from_env"
+			connection.ApplicationName = "gpbackup 1.30.0"
+
+			Expect(connection.Connect(2)).To(Succeed())
+			Expect(driver.ConnStrs).To(HaveLen(2))
+			for _, connStr := range driver.ConnStrs {
+				Expect(connStr).To(ContainSubstring(" application_name='gpbackup 1.30.0'"))
+			}
+		})
+		It("leaves the application name to the driver if $PGAPPNAME is set", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			driver := useRecordingDriver(connection)
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+			env["PGAPPNAME"] = "from_env"
+
+			Expect(connection.Connect(1)).To(Succeed())
+			Expect(driver.ConnStrs[0]).ToNot(ContainSubstring("application_name"))
+		})
+		It("lets startup parameters override the application name", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			driver := useRecordingDriver(connection)
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+			connection.ApplicationName = "gpbackup"
+			connection.StartupParameters = map[string]string{"application_name": "gpbackup_helper"}
+
+			Expect(connection.Connect(1)).To(Succeed())
+			Expect(driver.ConnStrs[0]).To(ContainSubstring(" application_name='gpbackup_helper'"))
+			Expect(driver.ConnStrs[0]).ToNot(ContainSubstring("application_name='gpbackup'"))
+		})
+	})
+	Describe("DBConn.SetApplicationName", func() {
+		It("sets the application name for later connections", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			driver := useRecordingDriver(connection)
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+
+			Expect(connection.SetApplicationName("gprestore")).To(Succeed())
+			Expect(connection.Connect(1)).To(Succeed())
+			Expect(driver.ConnStrs[0]).To(ContainSubstring(" application_name='gprestore'"))
+		})
+		It("sets the application name on every open connection", func() {
+			connection, mock = testhelper.CreateAndConnectMockDB(2)
+			for i := 0; i < 2; i++ {
+				mock.ExpectExec(regexp.QuoteMeta("SELECT pg_catalog.set_config('application_name', 'gprestore', false)")).WillReturnResult(testhelper.TestResult{Rows: 1})
+			}
+
+			Expect(connection.SetApplicationName("gprestore")).To(Succeed())
+			Expect(connection.ApplicationName).To(Equal("gprestore"))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,175 @@
+package dbconn
+
+/*
+ * This file contains types for scanning one-dimensional PostgreSQL arrays into
+ * Go slices and for passing Go slices as array parameters, for example:
+ *
+ *   var names StringArray
+ *   err := connection.Get(&names, "SELECT array_agg(relname) FROM pg_class")
+ *
+ *   rows, err := connection.QueryWithArgs("SELECT ... WHERE oid = ANY($1)", OidArray(oids))
+ *
+ * Array columns can likewise be scanned into struct fields of these types.
+ * NULL elements cannot be represented and cause an error when scanned.
+ */
+
+import (
+	"database/sql/driver"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// StringArray represents a text[] or other array whose elements are scanned as strings.
+type StringArray []string
+
+func (array *StringArray) Scan(src interface{}) error {
+	elements, err := parseArray(src)
+	if err != nil || elements == nil {
+		*array = nil
+		return err
+	}
+	*array = elements
+	return nil
+}
+
+func (array StringArray) Value() (driver.Value, error) {
+	if array == nil {
+		return nil, nil
+	}
+	quoted := make([]string, len(array))
+	for i, element := range array {
+		quoted[i] = quoteArrayElement(element)
+	}
+	return "{" + strings.Join(quoted, ",") + "}", nil
+}
+
+// Int64Array represents an int2[], int4[], or int8[] array.
+type Int64Array []int64
+
+func (array *Int64Array) Scan(src interface{}) error {
+	elements, err := parseArray(src)
+	if err != nil || elements == nil {
+		*array = nil
+		return err
+	}
+	result := make(Int64Array, len(elements))
+	for i, element := range elements {
+		result[i], err = strconv.ParseInt(element, 10, 64)
+		if err != nil {
+			*array = nil
+			return errors.Wrapf(err, "Failed to parse array element %d", i+1)
+		}
+	}
+	*array = result
+	return nil
+}
+
+func (array Int64Array) Value() (driver.Value, error) {
+	if array == nil {
+		return nil, nil
+	}
+	elements := make([]string, len(array))
+	for i, element := range array {
+		elements[i] = strconv.FormatInt(element, 10)
+	}
+	return "{" + strings.Join(elements, ",") + "}", nil
+}
+
+// OidArray represents an oid[] array, such as pg_proc.proargtypes cast to oid[].
+type OidArray []uint32
+
+func (array *OidArray) Scan(src interface{}) error {
+	elements, err := parseArray(src)
+	if err != nil || elements == nil {
+		*array = nil
+		return err
+	}
+	result := make(OidArray, len(elements))
+	for i, element := range elements {
+		oid, err := strconv.ParseUint(element, 10, 32)
+		if err != nil {
+			*array = nil
+			return errors.Wrapf(err, "Failed to parse array element %d", i+1)
+		}
+		result[i] = uint32(oid)
+	}
+	*array = result
+	return nil
+}
+
+func (array OidArray) Value() (driver.Value, error) {
+	if array == nil {
+		return nil, nil
+	}
+	elements := make([]string, len(array))
+	for i, element := range array {
+		elements[i] = strconv.FormatUint(uint64(element), 10)
+	}
+	return "{" + strings.Join(elements, ",") + "}", nil
+}
+
+func quoteArrayElement(element string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + replacer.Replace(element) + `"`
+}
+
+/*
+ * parseArray splits the text representation of a one-dimensional array, such
+ * as {a,"b c",d}, into its elements, removing any quoting.  It returns a nil
+ * slice for a NULL array.
+ */
+func parseArray(src interface{}) ([]string, error) {
+	var text string
+	switch src := src.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		text = string(src)
+	case string:
+		text = src
+	default:
+		return nil, errors.Errorf("Cannot scan %T into an array", src)
+	}
+	if len(text) < 2 || text[0] != '{' || text[len(text)-1] != '}' {
+		return nil, errors.Errorf("Invalid array %q", text)
+	}
+	text = text[1 : len(text)-1]
+	elements := make([]string, 0)
+	if text == "" {
+		return elements, nil
+	}
+	for i := 0; i <= len(text); i++ {
+		var element strings.Builder
+		quoted := false
+		if i < len(text) && text[i] == '"' {
+			quoted = true
+			for i++; i < len(text) && text[i] != '"'; i++ {
+				if text[i] == '\\' && i+1 < len(text) {
+					i++
+				}
+				element.WriteByte(text[i])
+			}
+			if i == len(text) {
+				return nil, errors.Errorf("Invalid array %q: unterminated quoted element", "{"+text+"}")
+			}
+			i++
+		} else {
+			for ; i < len(text) && text[i] != ','; i++ {
+				if text[i] == '{' {
+					return nil, errors.New("Multidimensional arrays are not supported")
+				}
+				element.WriteByte(text[i])
+			}
+		}
+		if i < len(text) && text[i] != ',' {
+			return nil, errors.Errorf("Invalid array %q: unexpected character after quoted element", "{"+text+"}")
+		}
+		if !quoted && strings.EqualFold(element.String(), "NULL") {
+			return nil, errors.Errorf("Array element %d is NULL", len(elements)+1)
+		}
+		elements = append(elements, element.String())
+	}
+	return elements, nil
+}
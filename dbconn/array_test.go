@@ -0,0 +1,98 @@
+package dbconn_test
+
+import (
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/array tests", func() {
+	Describe("StringArray", func() {
+		DescribeTable("scans an array", func(src interface{}, expected dbconn.StringArray) {
+			var array dbconn.StringArray
+			Expect(array.Scan(src)).To(Succeed())
+			Expect(array).To(Equal(expected))
+		},
+			Entry("with unquoted elements", "{a,b,c}", dbconn.StringArray{"a", "b", "c"}),
+			Entry("with quoted elements", []byte(`{"a b","c,d","e\"f","g\\h",""}`), dbconn.StringArray{"a b", "c,d", `e"f`, `g\h`, ""}),
+			Entry("with a quoted NULL", `{"NULL"}`, dbconn.StringArray{"NULL"}),
+			Entry("that is empty", "{}", dbconn.StringArray{}),
+			Entry("that is NULL", nil, dbconn.StringArray(nil)),
+		)
+		DescribeTable("returns an error for an invalid array", func(src interface{}, expectedErr string) {
+			var array dbconn.StringArray
+			Expect(array.Scan(src)).To(MatchError(expectedErr))
+		},
+			Entry("without braces", "a,b", `Invalid array "a,b"`),
+			Entry("with an unterminated quote", `{"a}`, `Invalid array "{\"a}": unterminated quoted element`),
+			Entry("with a NULL element", "{a,NULL}", "Array element 2 is NULL"),
+			Entry("with multiple dimensions", "{{a},{b}}", "Multidimensional arrays are not supported"),
+			Entry("of another type", 1, "Cannot scan int into an array"),
+		)
+		It("formats an array parameter", func() {
+			value, err := dbconn.StringArray{"a", "b c", `d"e`}.Value()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal(`{"a","b c","d\"e"}`))
+		})
+		It("formats a nil array as NULL", func() {
+			value, err := dbconn.StringArray(nil).Value()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(BeNil())
+		})
+		It("scans an array column", func() {
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(sqlmock.NewRows([]string{"names"}).AddRow("{pg_class,pg_proc}"))
+
+			var names dbconn.StringArray
+			err := connection.Get(&names, "SELECT array_agg(relname) AS names FROM pg_class")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(names).To(Equal(dbconn.StringArray{"pg_class", "pg_proc"}))
+		})
+		It("binds an array parameter", func() {
+			mock.ExpectQuery("SELECT (.*)").WithArgs(`{"public","pg_catalog"}`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+			var count int
+			err := connection.GetWithArgs(&count, "SELECT count(*) FROM pg_namespace WHERE nspname = ANY($1)", dbconn.StringArray{"public", "pg_catalog"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(count).To(Equal(2))
+		})
+	})
+	Describe("Int64Array", func() {
+		It("scans an array", func() {
+			var array dbconn.Int64Array
+			Expect(array.Scan("{1,-2,3}")).To(Succeed())
+			Expect(array).To(Equal(dbconn.Int64Array{1, -2, 3}))
+		})
+		It("returns an error for a non-integer element", func() {
+			var array dbconn.Int64Array
+			err := array.Scan("{1,a}")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("Failed to parse array element 2"))
+			Expect(array).To(BeNil())
+		})
+		It("formats an array parameter", func() {
+			value, err := dbconn.Int64Array{1, -2, 3}.Value()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal("{1,-2,3}"))
+		})
+	})
+	Describe("OidArray", func() {
+		It("scans an array", func() {
+			var array dbconn.OidArray
+			Expect(array.Scan([]byte("{16384,4294967295}"))).To(Succeed())
+			Expect(array).To(Equal(dbconn.OidArray{16384, 4294967295}))
+		})
+		It("returns an error for an out-of-range element", func() {
+			var array dbconn.OidArray
+			err := array.Scan("{-1}")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("Failed to parse array element 1"))
+		})
+		It("formats an array parameter", func() {
+			value, err := dbconn.OidArray{16384, 16385}.Value()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal("{16384,16385}"))
+		})
+	})
+})
@@ -0,0 +1,133 @@
+package dbconn
+
+/*
+ * This file contains functions related to inserting many rows at once.
+ */
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/pkg/errors"
+)
+
+// PostgreSQL allows at most this many bind parameters in one query.
+const maxBindParameters = 65535
+
+/*
+ * InsertBatch inserts rows into the given columns of table and returns the
+ * number of rows inserted.  As with CopyFrom, the table name may be
+ * schema-qualified, and it and the column names are quoted.
+ *
+ * If no transaction is in progress on the connection and the pgx driver is in
+ * use, the rows are loaded with a single COPY, which is atomic on its own.
+ * Otherwise they are inserted with multi-row INSERT statements of up to
+ * batchSize rows each (fewer if needed to stay within PostgreSQL's limit on
+ * bind parameters), in a new transaction if none is in progress, so that
+ * either all of the rows are inserted or none are.  If a transaction is
+ * already in progress, the INSERTs become part of it, and it is up to the
+ * caller to roll it back on error.
+ */
+func (dbconn *DBConn) InsertBatch(table string, columns []string, rows [][]interface{}, batchSize int, whichConn ...int) (int64, error) {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	if len(columns) == 0 {
+		return 0, errors.New("At least one column must be specified")
+	}
+	if batchSize < 1 {
+		return 0, errors.Errorf("Invalid batch size %d; must be at least 1", batchSize)
+	}
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			return 0, errors.Errorf("Row %d has %d values, but %d columns were specified", i+1, len(row), len(columns))
+		}
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	if dbconn.supportsCopy(connNum) {
+		return dbconn.CopyFrom(table, columns, CopyFromRows(rows), connNum)
+	}
+	if maxRows := maxBindParameters / len(columns); batchSize > maxRows {
+		batchSize = maxRows
+	}
+	insert := func(exec func(query string, args ...interface{}) (sql.Result, error)) (int64, error) {
+		var numRows int64
+		for start := 0; start < len(rows); start += batchSize {
+			end := start + batchSize
+			if end > len(rows) {
+				end = len(rows)
+			}
+			query, args, err := dbconn.batchInsertQuery(table, columns, rows[start:end], connNum)
+			if err != nil {
+				return 0, errors.Wrapf(err, "Failed to insert rows into %s", table)
+			}
+			if _, err := exec(query, args...); err != nil {
+				return 0, errors.Wrapf(err, "Failed to insert rows into %s", table)
+			}
+			numRows += int64(end - start)
+		}
+		return numRows, nil
+	}
+	if dbconn.Tx[connNum] != nil {
+		return insert(func(query string, args ...interface{}) (sql.Result, error) {
+			return dbconn.exec(context.Background(), dbconn.Tx[connNum], connNum, query, args...)
+		})
+	}
+	var numRows int64
+	err := dbconn.RunInTransaction(func(tx *Tx) error {
+		var err error
+		numRows, err = insert(tx.Exec)
+		return err
+	}, connNum)
+	if err != nil {
+		return 0, err
+	}
+	return numRows, nil
+}
+
+func (dbconn *DBConn) batchInsertQuery(table string, columns []string, rows [][]interface{}, connNum int) (string, []interface{}, error) {
+	conn, err := dbconn.pooledConn(connNum)
+	if err != nil {
+		return "", nil, err
+	}
+	quotedColumns := make([]string, len(columns))
+	for i, column := range columns {
+		quotedColumns[i] = QuoteIdentifier(column)
+	}
+	placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+	values := make([]string, len(rows))
+	args := make([]interface{}, 0, len(rows)*len(columns))
+	for i, row := range rows {
+		values[i] = placeholders
+		args = append(args, row...)
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", pgx.Identifier(strings.Split(table, ".")).Sanitize(),
+		strings.Join(quotedColumns, ", "), strings.Join(values, ", "))
+	return conn.Rebind(query), args, nil
+}
+
+// supportsCopy reports whether CopyFrom can be used on the given connection, without running a query.
+func (dbconn *DBConn) supportsCopy(connNum int) bool {
+	if dbconn.Tx[connNum] != nil {
+		return false
+	}
+	pooledConn, err := dbconn.pooledConn(connNum)
+	if err != nil {
+		return false
+	}
+	conn, err := pooledConn.Conn(context.Background())
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	isPgx := false
+	_ = conn.Raw(func(driverConn interface{}) error {
+		_, isPgx = driverConn.(*stdlib.Conn)
+		return nil
+	})
+	return isPgx
+}
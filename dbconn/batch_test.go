@@ -0,0 +1,84 @@
+package dbconn_test
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/batch tests", func() {
+	Describe("DBConn.InsertBatch", func() {
+		columns := []string{"id", "name"}
+		rows := [][]interface{}{{1, "a"}, {2, "b"}, {3, "c"}}
+
+		It("inserts rows in batches in a transaction", func() {
+			mock.ExpectBegin()
+			mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "public"."runs" ("id", "name") VALUES (?, ?), (?, ?)`)).
+				WithArgs(1, "a", 2, "b").WillReturnResult(testhelper.TestResult{Rows: 2})
+			mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "public"."runs" ("id", "name") VALUES (?, ?)`)).
+				WithArgs(3, "c").WillReturnResult(testhelper.TestResult{Rows: 1})
+			mock.ExpectCommit()
+
+			numRows, err := connection.InsertBatch("public.runs", columns, rows, 2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(numRows).To(Equal(int64(3)))
+			Expect(connection.Tx[0]).To(BeNil())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("inserts all rows in one statement if they fit in one batch", func() {
+			mock.ExpectBegin()
+			mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "runs" ("id", "name") VALUES (?, ?), (?, ?), (?, ?)`)).
+				WithArgs(1, "a", 2, "b", 3, "c").WillReturnResult(testhelper.TestResult{Rows: 3})
+			mock.ExpectCommit()
+
+			numRows, err := connection.InsertBatch("runs", columns, rows, 100)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(numRows).To(Equal(int64(3)))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("rolls back and returns an error if a batch fails", func() {
+			mock.ExpectBegin()
+			mock.ExpectExec("INSERT INTO").WillReturnResult(testhelper.TestResult{Rows: 2})
+			mock.ExpectExec("INSERT INTO").WillReturnError(errors.New("duplicate key value violates unique constraint"))
+			mock.ExpectRollback()
+
+			_, err := connection.InsertBatch("runs", columns, rows, 2)
+			Expect(err).To(MatchError("Failed to insert rows into runs: duplicate key value violates unique constraint"))
+			Expect(connection.Tx[0]).To(BeNil())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("inserts rows in a transaction that is already in progress", func() {
+			ExpectBegin(mock)
+			mock.ExpectExec("INSERT INTO").WithArgs(1, "a", 2, "b", 3, "c").WillReturnResult(testhelper.TestResult{Rows: 3})
+
+			connection.MustBegin()
+			numRows, err := connection.InsertBatch("runs", columns, rows, 3)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(numRows).To(Equal(int64(3)))
+			Expect(connection.Tx[0]).ToNot(BeNil())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("does nothing if there are no rows", func() {
+			numRows, err := connection.InsertBatch("runs", columns, nil, 2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(numRows).To(Equal(int64(0)))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns an error if a row has the wrong number of values", func() {
+			_, err := connection.InsertBatch("runs", columns, [][]interface{}{{1, "a"}, {2}}, 2)
+			Expect(err).To(MatchError("Row 2 has 1 values, but 2 columns were specified"))
+		})
+		It("returns an error for an invalid batch size", func() {
+			_, err := connection.InsertBatch("runs", columns, rows, 0)
+			Expect(err).To(MatchError("Invalid batch size 0; must be at least 1"))
+		})
+		It("returns an error if no columns are specified", func() {
+			_, err := connection.InsertBatch("runs", nil, rows, 2)
+			Expect(err).To(MatchError("At least one column must be specified"))
+		})
+	})
+})
@@ -0,0 +1,73 @@
+package dbconn
+
+/*
+ * This file contains functions related to canceling queries that are already
+ * running.
+ *
+ * There are two ways to cancel a query run through a DBConn:
+ *
+ * - Run it with one of the Context functions, such as QueryContext, and cancel
+ *   the context.  The driver asks the server to cancel the query and returns
+ *   context.Canceled, but it also discards that session, so the sql.DB opens a
+ *   new one for the next query and any session state (SET commands, temporary
+ *   tables, an open transaction) on that connection number is lost.
+ *
+ * - Record the session's backend PID with BackendPID before starting the query,
+ *   then call CancelBackend with that PID from another connection.  The query
+ *   fails with a "canceling statement due to user request" error but the
+ *   session survives, so this is the better choice for long-lived sessions.
+ *
+ * Neither affects the other connections in the pool.
+ */
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * BackendPID returns the process ID of the server backend for the given
+ * connection.  Call it before starting the query to be canceled, as the
+ * connection cannot run another query while that one is in progress.
+ */
+func (dbconn *DBConn) BackendPID(whichConn ...int) (int, error) {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	pid, err := SelectInt(dbconn, "SELECT pg_backend_pid()", connNum)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Failed to get backend PID of connection %d", connNum)
+	}
+	return pid, nil
+}
+
+/*
+ * CancelBackend asks the server to cancel the query currently running in the
+ * backend with the given PID, using the given connection to send the request;
+ * that connection must not be the one running the query.  It returns false if
+ * the server could not signal the backend, such as because it has exited.  If
+ * the backend is idle, there is nothing to cancel and the request is ignored.
+ */
+func (dbconn *DBConn) CancelBackend(pid int, whichConn ...int) (bool, error) {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	var canceled bool
+	err := dbconn.Get(&canceled, fmt.Sprintf("SELECT pg_cancel_backend(%d)", pid), connNum)
+	if err != nil {
+		return false, errors.Wrapf(err, "Failed to cancel query in backend %d", pid)
+	}
+	return canceled, nil
+}
+
+/*
+ * TerminateBackend asks the server to end the session of the backend with the
+ * given PID, rolling back any transaction in progress there.  As with
+ * CancelBackend, it returns false if the server could not signal the backend.
+ */
+func (dbconn *DBConn) TerminateBackend(pid int, whichConn ...int) (bool, error) {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	var terminated bool
+	err := dbconn.Get(&terminated, fmt.Sprintf("SELECT pg_terminate_backend(%d)", pid), connNum)
+	if err != nil {
+		return false, errors.Wrapf(err, "Failed to terminate backend %d", pid)
+	}
+	return terminated, nil
+}
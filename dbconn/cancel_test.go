@@ -0,0 +1,69 @@
+package dbconn_test
+
+import (
+	"errors"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/cancel tests", func() {
+	BeforeEach(func() {
+		connection, mock = testhelper.CreateAndConnectMockDB(2)
+	})
+	Describe("DBConn.BackendPID", func() {
+		It("returns the backend PID of the given connection", func() {
+			mock.ExpectQuery(`SELECT pg_backend_pid\(\)`).WillReturnRows(sqlmock.NewRows([]string{"pg_backend_pid"}).AddRow(4242))
+
+			pid, err := connection.BackendPID(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pid).To(Equal(4242))
+		})
+		It("returns an error if the query fails", func() {
+			mock.ExpectQuery(`SELECT pg_backend_pid\(\)`).WillReturnError(errors.New("connection reset"))
+
+			_, err := connection.BackendPID()
+			Expect(err).To(MatchError("Failed to get backend PID of connection 0: connection reset"))
+		})
+	})
+	Describe("DBConn.CancelBackend", func() {
+		It("cancels the query in the given backend", func() {
+			mock.ExpectQuery(`SELECT pg_cancel_backend\(4242\)`).WillReturnRows(sqlmock.NewRows([]string{"pg_cancel_backend"}).AddRow(true))
+
+			canceled, err := connection.CancelBackend(4242, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(canceled).To(BeTrue())
+		})
+		It("returns false if the backend could not be signaled", func() {
+			mock.ExpectQuery(`SELECT pg_cancel_backend\(4242\)`).WillReturnRows(sqlmock.NewRows([]string{"pg_cancel_backend"}).AddRow(false))
+
+			canceled, err := connection.CancelBackend(4242)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(canceled).To(BeFalse())
+		})
+		It("returns an error if the query fails", func() {
+			mock.ExpectQuery(`SELECT pg_cancel_backend\(4242\)`).WillReturnError(errors.New("permission denied"))
+
+			_, err := connection.CancelBackend(4242)
+			Expect(err).To(MatchError("Failed to cancel query in backend 4242: permission denied"))
+		})
+	})
+	Describe("DBConn.TerminateBackend", func() {
+		It("terminates the given backend", func() {
+			mock.ExpectQuery(`SELECT pg_terminate_backend\(4242\)`).WillReturnRows(sqlmock.NewRows([]string{"pg_terminate_backend"}).AddRow(true))
+
+			terminated, err := connection.TerminateBackend(4242, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(terminated).To(BeTrue())
+		})
+		It("returns an error if the query fails", func() {
+			mock.ExpectQuery(`SELECT pg_terminate_backend\(4242\)`).WillReturnError(errors.New("permission denied"))
+
+			_, err := connection.TerminateBackend(4242)
+			Expect(err).To(MatchError("Failed to terminate backend 4242: permission denied"))
+		})
+	})
+})
@@ -0,0 +1,46 @@
+package dbconn
+
+/*
+ * This file contains functions that report whether a database supports a given
+ * feature, based on both its type and its version, so that callers need not
+ * repeat checks like IsGPDB() && AtLeast("6") for each feature.  Apache
+ * Cloudberry is treated as Greenplum 7; see EffectiveGPDBMajor.  All of them
+ * return false for an Unknown database type.
+ */
+
+// SupportsResourceGroups reports whether resource groups are available, which they are from GPDB 5 onward.
+func (dbversion GPDBVersion) SupportsResourceGroups() bool {
+	return dbversion.AtLeastFeatureLevel(5)
+}
+
+/*
+ * SupportsFilespaces reports whether the database stores tablespace locations
+ * in filespaces (pg_filespace and pg_filespace_entry), as GPDB 5 and earlier
+ * did, and SupportsTablespaceLocationsTable whether it instead records them
+ * per segment in gp_tablespace_location, as GPDB 6 and later do.
+ */
+func (dbversion GPDBVersion) SupportsFilespaces() bool {
+	return dbversion.EffectiveGPDBMajor() != 0 && !dbversion.AtLeastFeatureLevel(6)
+}
+
+func (dbversion GPDBVersion) SupportsTablespaceLocationsTable() bool {
+	return dbversion.AtLeastFeatureLevel(6)
+}
+
+/*
+ * SupportsCoordinatorTerminology reports whether the database refers to its
+ * coordinator as such, rather than as the master, in its utilities, GUCs, and
+ * environment variables (e.g. COORDINATOR_DATA_DIRECTORY), as GPDB 7 and
+ * later do.
+ */
+func (dbversion GPDBVersion) SupportsCoordinatorTerminology() bool {
+	return dbversion.AtLeastFeatureLevel(7)
+}
+
+/*
+ * UsesGpRole reports whether utility mode is requested with the gp_role GUC,
+ * as in GPDB 7 and later, rather than gp_session_role.
+ */
+func (dbversion GPDBVersion) UsesGpRole() bool {
+	return dbversion.AtLeastFeatureLevel(7)
+}
@@ -0,0 +1,41 @@
+package dbconn_test
+
+import (
+	"github.com/blang/semver"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/capabilities tests", func() {
+	version := func(dbType dbconn.DBType, versionStr string) dbconn.GPDBVersion {
+		return dbconn.GPDBVersion{SemVer: semver.MustParse(versionStr), Type: dbType}
+	}
+	gpdb4 := version(dbconn.GPDB, "4.3.33")
+	gpdb5 := version(dbconn.GPDB, "5.29.0")
+	gpdb6 := version(dbconn.GPDB, "6.25.0")
+	gpdb7 := version(dbconn.GPDB, "7.1.0")
+	cbdb1 := version(dbconn.CBDB, "1.6.0")
+	unknown := version(dbconn.Unknown, "9.0.0")
+
+	DescribeTable("capabilities by database type and version", func(capability func(dbconn.GPDBVersion) bool, supported []dbconn.GPDBVersion, unsupported []dbconn.GPDBVersion) {
+		for _, v := range supported {
+			Expect(capability(v)).To(BeTrue(), "expected %s %s to be supported", v.Type, v.SemVer)
+		}
+		for _, v := range unsupported {
+			Expect(capability(v)).To(BeFalse(), "expected %s %s to be unsupported", v.Type, v.SemVer)
+		}
+	},
+		Entry("resource groups", dbconn.GPDBVersion.SupportsResourceGroups,
+			[]dbconn.GPDBVersion{gpdb5, gpdb6, gpdb7, cbdb1}, []dbconn.GPDBVersion{gpdb4, unknown}),
+		Entry("filespaces", dbconn.GPDBVersion.SupportsFilespaces,
+			[]dbconn.GPDBVersion{gpdb4, gpdb5}, []dbconn.GPDBVersion{gpdb6, gpdb7, cbdb1, unknown}),
+		Entry("the tablespace locations table", dbconn.GPDBVersion.SupportsTablespaceLocationsTable,
+			[]dbconn.GPDBVersion{gpdb6, gpdb7, cbdb1}, []dbconn.GPDBVersion{gpdb4, gpdb5, unknown}),
+		Entry("coordinator terminology", dbconn.GPDBVersion.SupportsCoordinatorTerminology,
+			[]dbconn.GPDBVersion{gpdb7, cbdb1}, []dbconn.GPDBVersion{gpdb4, gpdb5, gpdb6, unknown}),
+		Entry("gp_role", dbconn.GPDBVersion.UsesGpRole,
+			[]dbconn.GPDBVersion{gpdb7, cbdb1}, []dbconn.GPDBVersion{gpdb4, gpdb5, gpdb6, unknown}),
+	)
+})
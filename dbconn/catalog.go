@@ -0,0 +1,127 @@
+package dbconn
+
+/*
+ * This file contains structs and functions for basic questions about the
+ * objects in a database, such as which schemas exist or whether a given table
+ * does.  Names are passed and returned unquoted, exactly as stored in the
+ * catalog, and are compared as string literals rather than parsed as
+ * identifiers, so callers need not quote them or worry about case folding.
+ */
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * Kind is the relkind of the relation in pg_class: "r" for a table, "v" for a
+ * view, "m" for a materialized view, "S" for a sequence, "f" for a foreign
+ * table, or "p" for a partitioned table.
+ */
+type Relation struct {
+	Schema string `db:"schema"`
+	Name   string `db:"name"`
+	Kind   string `db:"kind"`
+}
+
+func (relation Relation) FQN() string {
+	return fmt.Sprintf("%s.%s", QuoteIdentifier(relation.Schema), QuoteIdentifier(relation.Name))
+}
+
+/*
+ * systemSchemaCondition selects the schemas that belong to the user rather
+ * than to the server: those holding the catalogs, TOAST and append-optimized
+ * auxiliary tables, and other sessions' temporary tables are excluded.
+ */
+const systemSchemaCondition = `n.nspname NOT IN ('pg_catalog', 'information_schema', 'gp_toolkit', 'pg_aoseg', 'pg_bitmapindex', 'pg_ext_aux')
+	AND n.nspname NOT LIKE 'pg_temp_%'
+	AND n.nspname NOT LIKE 'pg_toast%'`
+
+// relationKinds returns the relkinds ListRelations and RelationExists consider, which depend on the version.
+func (dbconn *DBConn) relationKinds() []string {
+	kinds := []string{"r", "v", "S"}
+	if dbconn.Version.AtLeastFeatureLevel(6) {
+		kinds = append(kinds, "m", "f")
+	}
+	if dbconn.Version.AtLeastFeatureLevel(7) {
+		kinds = append(kinds, "p")
+	}
+	return kinds
+}
+
+func (dbconn *DBConn) relationKindCondition() string {
+	kinds := dbconn.relationKinds()
+	for i, kind := range kinds {
+		kinds[i] = fmt.Sprintf("'%s'", kind)
+	}
+	return fmt.Sprintf("c.relkind IN (%s)", strings.Join(kinds, ", "))
+}
+
+// ListDatabases returns the names of the databases that accept connections, other than templates.
+func (dbconn *DBConn) ListDatabases(whichConn ...int) ([]string, error) {
+	query := `
+	SELECT datname
+	FROM pg_catalog.pg_database
+	WHERE datallowconn AND NOT datistemplate
+	ORDER BY datname`
+	databases, err := SelectStringSlice(dbconn, query, whichConn...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list databases")
+	}
+	return databases, nil
+}
+
+// ListSchemas returns the names of the user schemas in the current database.
+func (dbconn *DBConn) ListSchemas(whichConn ...int) ([]string, error) {
+	query := fmt.Sprintf(`
+	SELECT n.nspname
+	FROM pg_catalog.pg_namespace n
+	WHERE %s
+	ORDER BY n.nspname`, systemSchemaCondition)
+	schemas, err := SelectStringSlice(dbconn, query, whichConn...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list schemas")
+	}
+	return schemas, nil
+}
+
+/*
+ * ListRelations returns the tables, views, and sequences in the given schema,
+ * ordered by name.  Indexes, TOAST tables, and composite types are omitted.
+ */
+func (dbconn *DBConn) ListRelations(schema string, whichConn ...int) ([]Relation, error) {
+	query := fmt.Sprintf(`
+	SELECT n.nspname AS schema,
+		c.relname AS name,
+		c.relkind::text AS kind
+	FROM pg_catalog.pg_class c
+		JOIN pg_catalog.pg_namespace n ON c.relnamespace = n.oid
+	WHERE n.nspname = '%s'
+		AND %s
+	ORDER BY c.relname`, EscapeString(schema), dbconn.relationKindCondition())
+	relations := make([]Relation, 0)
+	if err := dbconn.Select(&relations, query, whichConn...); err != nil {
+		return nil, errors.Wrapf(err, "Failed to list relations in schema %s", schema)
+	}
+	return relations, nil
+}
+
+// RelationExists reports whether a table, view, or sequence with the given name exists in the given schema.
+func (dbconn *DBConn) RelationExists(schema string, name string, whichConn ...int) (bool, error) {
+	query := fmt.Sprintf(`
+	SELECT EXISTS (
+		SELECT 1
+		FROM pg_catalog.pg_class c
+			JOIN pg_catalog.pg_namespace n ON c.relnamespace = n.oid
+		WHERE n.nspname = '%s'
+			AND c.relname = '%s'
+			AND %s
+	)`, EscapeString(schema), EscapeString(name), dbconn.relationKindCondition())
+	var exists bool
+	if err := dbconn.Get(&exists, query, whichConn...); err != nil {
+		return false, errors.Wrapf(err, "Failed to check whether relation %s.%s exists", schema, name)
+	}
+	return exists, nil
+}
@@ -0,0 +1,109 @@
+package dbconn_test
+
+import (
+	"errors"
+	"regexp"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/catalog tests", func() {
+	Describe("Relation.FQN", func() {
+		It("quotes the schema and name", func() {
+			relation := dbconn.Relation{Schema: "My Schema", Name: `foo"bar`}
+			Expect(relation.FQN()).To(Equal(`"My Schema"."foo""bar"`))
+		})
+	})
+	Describe("DBConn.ListDatabases", func() {
+		It("lists databases that accept connections", func() {
+			mock.ExpectQuery(`FROM pg_catalog.pg_database\s+WHERE datallowconn AND NOT datistemplate`).WillReturnRows(sqlmock.NewRows([]string{"datname"}).AddRow("postgres").AddRow("testdb"))
+
+			databases, err := connection.ListDatabases()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(databases).To(Equal([]string{"postgres", "testdb"}))
+		})
+		It("returns an error if the query fails", func() {
+			mock.ExpectQuery("pg_database").WillReturnError(errors.New("connection reset"))
+
+			_, err := connection.ListDatabases()
+			Expect(err).To(MatchError("Failed to list databases: connection reset"))
+		})
+	})
+	Describe("DBConn.ListSchemas", func() {
+		It("lists user schemas", func() {
+			mock.ExpectQuery(`FROM pg_catalog.pg_namespace n\s+WHERE n.nspname NOT IN \('pg_catalog', 'information_schema'`).WillReturnRows(sqlmock.NewRows([]string{"nspname"}).AddRow("public").AddRow("sales"))
+
+			schemas, err := connection.ListSchemas()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(schemas).To(Equal([]string{"public", "sales"}))
+		})
+		It("returns an error if the query fails", func() {
+			mock.ExpectQuery("pg_namespace").WillReturnError(errors.New("connection reset"))
+
+			_, err := connection.ListSchemas()
+			Expect(err).To(MatchError("Failed to list schemas: connection reset"))
+		})
+	})
+	Describe("DBConn.ListRelations", func() {
+		It("lists the relations in a schema on GPDB 5", func() {
+			mock.ExpectQuery(regexp.QuoteMeta(`WHERE n.nspname = 'o''brien'
+		AND c.relkind IN ('r', 'v', 'S')`)).WillReturnRows(sqlmock.NewRows([]string{"schema", "name", "kind"}).AddRow("o'brien", "foo", "r").AddRow("o'brien", "foo_view", "v"))
+
+			relations, err := connection.ListRelations("o'brien")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(relations).To(Equal([]dbconn.Relation{
+				{Schema: "o'brien", Name: "foo", Kind: "r"},
+				{Schema: "o'brien", Name: "foo_view", Kind: "v"},
+			}))
+		})
+		It("includes materialized views and foreign tables on GPDB 6", func() {
+			testhelper.SetDBVersion(connection, "6.0.0")
+			mock.ExpectQuery(regexp.QuoteMeta(`c.relkind IN ('r', 'v', 'S', 'm', 'f')`)).WillReturnRows(sqlmock.NewRows([]string{"schema", "name", "kind"}))
+
+			relations, err := connection.ListRelations("public")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(relations).To(BeEmpty())
+		})
+		It("includes partitioned tables on GPDB 7", func() {
+			testhelper.SetDBVersion(connection, "7.0.0")
+			mock.ExpectQuery(regexp.QuoteMeta(`c.relkind IN ('r', 'v', 'S', 'm', 'f', 'p')`)).WillReturnRows(sqlmock.NewRows([]string{"schema", "name", "kind"}))
+
+			_, err := connection.ListRelations("public")
+			Expect(err).ToNot(HaveOccurred())
+		})
+		It("returns an error if the query fails", func() {
+			mock.ExpectQuery("pg_class").WillReturnError(errors.New("connection reset"))
+
+			_, err := connection.ListRelations("public")
+			Expect(err).To(MatchError("Failed to list relations in schema public: connection reset"))
+		})
+	})
+	Describe("DBConn.RelationExists", func() {
+		It("compares names as literals rather than identifiers", func() {
+			mock.ExpectQuery(regexp.QuoteMeta(`WHERE n.nspname = 'Sales'
+			AND c.relname = 'o''brien'`)).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+			exists, err := connection.RelationExists("Sales", "o'brien")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(exists).To(BeTrue())
+		})
+		It("returns false if the relation does not exist", func() {
+			mock.ExpectQuery("SELECT EXISTS").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+			exists, err := connection.RelationExists("public", "foo")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(exists).To(BeFalse())
+		})
+		It("returns an error if the query fails", func() {
+			mock.ExpectQuery("SELECT EXISTS").WillReturnError(errors.New("connection reset"))
+
+			_, err := connection.RelationExists("public", "foo")
+			Expect(err).To(MatchError("Failed to check whether relation public.foo exists: connection reset"))
+		})
+	})
+})
@@ -0,0 +1,146 @@
+package dbconn
+
+/*
+ * This file contains functions related to bulk loading and exporting data with
+ * the COPY protocol.
+ */
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/pkg/errors"
+)
+
+/*
+ * A CopyFromSource supplies rows to CopyFrom one at a time, so that callers can
+ * stream rows from a file or another query without holding them all in memory.
+ * Next advances to the next row and returns false when there are no more rows
+ * or an error occurs, Values returns the values of the current row in column
+ * order, and Err returns any error that stopped iteration.
+ */
+type CopyFromSource interface {
+	Next() bool
+	Values() ([]interface{}, error)
+	Err() error
+}
+
+// CopyFromRows returns a CopyFromSource for rows already held in memory.
+func CopyFromRows(rows [][]interface{}) CopyFromSource {
+	return pgx.CopyFromRows(rows)
+}
+
+/*
+ * withPgxConn runs fn with the pgx connection underlying the given connection
+ * number.  The COPY protocol is not available through database/sql, so this
+ * only works with the default driver, and not inside a transaction, as
+ * database/sql does not expose the connection a transaction is running on.
+ */
+func (dbconn *DBConn) withPgxConn(ctx context.Context, connNum int, fn func(conn *pgx.Conn) error) error {
+	if dbconn.Tx[connNum] != nil {
+		return errors.Errorf("Cannot use COPY on connection %d while a transaction is in progress", connNum)
+	}
+	pooledConn, err := dbconn.pooledConn(connNum)
+	if err != nil {
+		return err
+	}
+	conn, err := pooledConn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Raw(func(driverConn interface{}) error {
+		stdlibConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errors.Errorf("COPY requires the pgx driver, but connection %d uses %T", connNum, driverConn)
+		}
+		return fn(stdlibConn.Conn())
+	})
+}
+
+/*
+ * CopyFrom loads rows into the given columns of table with COPY FROM STDIN and
+ * returns the number of rows loaded.  The table name may be schema-qualified,
+ * and it and the column names are quoted, so they should be given exactly as
+ * they appear in the catalog.  If any row fails to load, none of them are.
+ */
+func (dbconn *DBConn) CopyFrom(table string, columns []string, rows CopyFromSource, whichConn ...int) (int64, error) {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	tableName := pgx.Identifier(strings.Split(table, "."))
+	quotedColumns := make([]string, len(columns))
+	for i, column := range columns {
+		quotedColumns[i] = QuoteIdentifier(column)
+	}
+	// The query text is only used to describe the COPY to any query hooks.
+	copyQuery := fmt.Sprintf("COPY %s (%s) FROM STDIN", tableName.Sanitize(), strings.Join(quotedColumns, ", "))
+	var numRows int64
+	err := dbconn.runQuery(context.Background(), copyQuery, nil, connNum, func(ctx context.Context) (int64, error) {
+		err := dbconn.withPgxConn(ctx, connNum, func(conn *pgx.Conn) error {
+			var err error
+			numRows, err = conn.CopyFrom(ctx, tableName, columns, rows)
+			return err
+		})
+		return numRows, err
+	})
+	if err != nil {
+		return 0, errors.Wrapf(err, "Failed to copy rows into %s", table)
+	}
+	return numRows, nil
+}
+
+type CopyFormat int
+
+const (
+	COPY_FORMAT_TEXT CopyFormat = iota
+	COPY_FORMAT_CSV
+	COPY_FORMAT_BINARY
+)
+
+func (format CopyFormat) String() string {
+	switch format {
+	case COPY_FORMAT_TEXT:
+		return "text"
+	case COPY_FORMAT_CSV:
+		return "csv"
+	case COPY_FORMAT_BINARY:
+		return "binary"
+	}
+	return fmt.Sprintf("CopyFormat(%d)", int(format))
+}
+
+/*
+ * CopyTo runs query with COPY TO STDOUT in the given format and writes the
+ * output to w as the server sends it, so that arbitrarily large results can
+ * be exported without holding them in memory.  It returns the number of rows
+ * copied.  The query must be a SELECT (or VALUES) statement; to export a whole
+ * table, use "SELECT * FROM table".  If writing to w fails, the driver
+ * closes that session to abandon the COPY, and the error is returned.
+ */
+func (dbconn *DBConn) CopyTo(query string, w io.Writer, format CopyFormat, whichConn ...int) (int64, error) {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	if format < COPY_FORMAT_TEXT || format > COPY_FORMAT_BINARY {
+		return 0, errors.Errorf("Invalid COPY format: %s", format)
+	}
+	// Use the pre-9.0 option syntax, which all supported versions accept.
+	copyQuery := fmt.Sprintf("COPY (%s) TO STDOUT", query)
+	if format != COPY_FORMAT_TEXT {
+		copyQuery += fmt.Sprintf(" WITH %s", strings.ToUpper(format.String()))
+	}
+	var numRows int64
+	err := dbconn.runQuery(context.Background(), copyQuery, nil, connNum, func(ctx context.Context) (int64, error) {
+		err := dbconn.withPgxConn(ctx, connNum, func(conn *pgx.Conn) error {
+			commandTag, err := conn.PgConn().CopyTo(ctx, w, copyQuery)
+			numRows = commandTag.RowsAffected()
+			return err
+		})
+		return numRows, err
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to copy query results")
+	}
+	return numRows, nil
+}
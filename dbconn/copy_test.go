@@ -0,0 +1,68 @@
+package dbconn_test
+
+import (
+	"io"
+
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/copy tests", func() {
+	BeforeEach(func() {
+		connection, mock = testhelper.CreateAndConnectMockDB(1)
+	})
+	Describe("CopyFromRows", func() {
+		It("returns each row in order", func() {
+			source := dbconn.CopyFromRows([][]interface{}{{1, "one"}, {2, "two"}})
+			values := make([][]interface{}, 0)
+			for source.Next() {
+				row, err := source.Values()
+				Expect(err).ToNot(HaveOccurred())
+				values = append(values, row)
+			}
+			Expect(source.Err()).ToNot(HaveOccurred())
+			Expect(values).To(Equal([][]interface{}{{1, "one"}, {2, "two"}}))
+		})
+	})
+	Describe("DBConn.CopyFrom", func() {
+		It("requires the pgx driver", func() {
+			_, err := connection.CopyFrom("public.foo", []string{"a", "b"}, dbconn.CopyFromRows(nil))
+			Expect(err).To(MatchError(HavePrefix("Failed to copy rows into public.foo: COPY requires the pgx driver")))
+		})
+		It("cannot be used inside a transaction", func() {
+			ExpectBegin(mock)
+			connection.MustBegin()
+
+			_, err := connection.CopyFrom("foo", []string{"a"}, dbconn.CopyFromRows(nil))
+			Expect(err).To(MatchError("Failed to copy rows into foo: Cannot use COPY on connection 0 while a transaction is in progress"))
+		})
+	})
+	Describe("CopyFormat", func() {
+		It("names each format", func() {
+			Expect(dbconn.COPY_FORMAT_TEXT.String()).To(Equal("text"))
+			Expect(dbconn.COPY_FORMAT_CSV.String()).To(Equal("csv"))
+			Expect(dbconn.COPY_FORMAT_BINARY.String()).To(Equal("binary"))
+			Expect(dbconn.CopyFormat(7).String()).To(Equal("CopyFormat(7)"))
+		})
+	})
+	Describe("DBConn.CopyTo", func() {
+		It("requires the pgx driver", func() {
+			_, err := connection.CopyTo("SELECT * FROM foo", io.Discard, dbconn.COPY_FORMAT_CSV)
+			Expect(err).To(MatchError(HavePrefix("Failed to copy query results: COPY requires the pgx driver")))
+		})
+		It("rejects an invalid format", func() {
+			_, err := connection.CopyTo("SELECT * FROM foo", io.Discard, dbconn.CopyFormat(7))
+			Expect(err).To(MatchError("Invalid COPY format: CopyFormat(7)"))
+		})
+		It("cannot be used inside a transaction", func() {
+			ExpectBegin(mock)
+			connection.MustBegin()
+
+			_, err := connection.CopyTo("SELECT * FROM foo", io.Discard, dbconn.COPY_FORMAT_TEXT)
+			Expect(err).To(MatchError("Failed to copy query results: Cannot use COPY on connection 0 while a transaction is in progress"))
+		})
+	})
+})
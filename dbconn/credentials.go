@@ -0,0 +1,59 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to obtaining passwords for
+ * database connections on demand.
+ */
+
+import (
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/*
+ * A CredentialProvider supplies a password for a DBConn when the server
+ * rejects a connection attempt for lack of a valid one, such as by prompting
+ * the user or fetching it from a secret store.  It is only consulted after
+ * such a failure, so connections that authenticate some other way (trust,
+ * Kerberos, ~/.pgpass, and so on) never invoke it.
+ */
+type CredentialProvider interface {
+	Password(dbconn *DBConn) (string, error)
+}
+
+// CredentialProviderFunc adapts an ordinary function to a CredentialProvider.
+type CredentialProviderFunc func(dbconn *DBConn) (string, error)
+
+func (f CredentialProviderFunc) Password(dbconn *DBConn) (string, error) {
+	return f(dbconn)
+}
+
+func isPasswordAuthenticationError(err error) bool {
+	return strings.Contains(err.Error(), "SQLSTATE 28P01") || strings.Contains(err.Error(), "password authentication failed")
+}
+
+/*
+ * connect opens a connection with the current secrets, if a SecretsProvider is
+ * set, and the current password.  If the server rejects the password and a
+ * CredentialProvider is set, it asks the provider for a new one, stores it in
+ * Password so that the rest of the pool can reuse it, and tries once more.
+ */
+func (dbconn *DBConn) connect(connStr string) (*sqlx.DB, error) {
+	secrets, err := dbconn.currentSecrets()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dbconn.Driver.Connect("pgx", connStr+secrets.connectionString(dbconn.Password))
+	if err == nil || dbconn.Credentials == nil || !isPasswordAuthenticationError(err) {
+		return conn, err
+	}
+	password, providerErr := dbconn.Credentials.Password(dbconn)
+	if providerErr != nil {
+		return nil, errors.Wrapf(providerErr, "Failed to obtain password for user %s", dbconn.User)
+	}
+	dbconn.Password = password
+	secrets.Password = ""
+	return dbconn.Driver.Connect("pgx", connStr+secrets.connectionString(dbconn.Password))
+}
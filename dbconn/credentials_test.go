@@ -0,0 +1,76 @@
+package dbconn_test
+
+import (
+	"errors"
+
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/credentials tests", func() {
+	var (
+		driver     *recordingDriver
+		authErr    error
+		numPrompts int
+		provider   dbconn.CredentialProviderFunc
+	)
+	BeforeEach(func() {
+		connection, mock = testhelper.CreateMockDBConn()
+		driver = useRecordingDriver(connection)
+		authErr = errors.New(`failed to connect to host=testhost user=testrole database=testdb: server error (FATAL: password authentication failed for user "testrole" (SQLSTATE 28P01))`)
+		numPrompts = 0
+		provider = func(conn *dbconn.DBConn) (string, error) {
+			numPrompts++
+			Expect(conn).To(BeIdenticalTo(connection))
+			return "secret", nil
+		}
+	})
+	It("does not consult the provider if the connection succeeds", func() {
+		testhelper.ExpectVersionQuery(mock, "7.0.0")
+		connection.Credentials = provider
+
+		Expect(connection.Connect(1)).To(Succeed())
+		Expect(numPrompts).To(Equal(0))
+		Expect(driver.ConnStrs[0]).ToNot(ContainSubstring("password"))
+	})
+	It("retries with the provided password after a password authentication failure", func() {
+		testhelper.ExpectVersionQuery(mock, "7.0.0")
+		driver.ErrsToReturn = []error{authErr}
+		connection.Credentials = provider
+
+		Expect(connection.Connect(2)).To(Succeed())
+		Expect(numPrompts).To(Equal(1))
+		Expect(connection.Password).To(Equal("secret"))
+		Expect(driver.ConnStrs).To(HaveLen(3))
+		Expect(driver.ConnStrs[0]).ToNot(ContainSubstring("password"))
+		Expect(driver.ConnStrs[1]).To(HaveSuffix(" password='secret'"))
+		Expect(driver.ConnStrs[2]).To(HaveSuffix(" password='secret'"))
+	})
+	It("returns the authentication error if no provider is set", func() {
+		driver.ErrsToReturn = []error{authErr}
+
+		err := connection.Connect(1)
+		Expect(err).To(MatchError(ContainSubstring("password authentication failed")))
+		Expect(driver.ConnStrs).To(HaveLen(1))
+	})
+	It("does not consult the provider for other errors", func() {
+		driver.ErrsToReturn = []error{errors.New("connection refused")}
+		connection.Credentials = provider
+
+		Expect(connection.Connect(1)).ToNot(Succeed())
+		Expect(numPrompts).To(Equal(0))
+	})
+	It("returns an error if the provider fails", func() {
+		driver.ErrsToReturn = []error{authErr}
+		connection.Credentials = dbconn.CredentialProviderFunc(func(conn *dbconn.DBConn) (string, error) {
+			return "", errors.New("secret not found")
+		})
+
+		err := connection.Connect(1)
+		Expect(err).To(MatchError(ContainSubstring("Failed to obtain password for user " + connection.User + ": secret not found")))
+		Expect(driver.ConnStrs).To(HaveLen(1))
+	})
+})
@@ -9,8 +9,12 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/cloudberrydb/gp-common-go-libs/gplog"
 	"github.com/cloudberrydb/gp-common-go-libs/operating"
@@ -25,6 +29,9 @@ import (
 	"github.com/pkg/errors"
 )
 
+// The logger for messages from this package, whose level can be set with gplog.SetLevelFor("dbconn", ...).
+var log = gplog.Component("dbconn")
+
 /*
  * While the sqlx.DB struct (and indirectly the sql.DB struct) maintains its own
  * connection pool, there is no guarantee of session-level consistency between
@@ -51,6 +58,63 @@ type DBConn struct {
 	Port     int
 	Tx       []*sqlx.Tx
 	Version  GPDBVersion
+	SSL      SSLOptions
+	Kerberos KerberosOptions
+	// If set, sent to the server when connecting; otherwise libpq's usual
+	// sources, such as $PGPASSWORD and ~/.pgpass, are used.
+	Password string
+	// If set, consulted for a new Password when the server rejects one; see
+	// CredentialProvider.
+	Credentials CredentialProvider
+	// If set, consulted for the password and SSL client key each time a
+	// connection is made; see SecretsProvider.
+	SecretsProvider SecretsProvider
+	// Sent to the server on every connection, along with any StartupParameters
+	// in the ConnectOptions, which take precedence; see ConnectOptions.
+	StartupParameters map[string]string
+	// Sent as application_name on every connection; if empty, $PGAPPNAME or
+	// else DefaultApplicationName is used.  See SetApplicationName.
+	ApplicationName string
+	// If set, sent as client_encoding on every connection; otherwise the
+	// server's default is used.  See SetClientEncoding.
+	ClientEncoding string
+	// See SetStatementTimeout.
+	StatementTimeout time.Duration
+	// If positive, the deadline for any query run without one of its own,
+	// enforced by the client rather than the server; see WithoutQueryTimeout.
+	// For Query and the like, it covers running the query but not reading
+	// the rows.
+	DefaultQueryTimeout time.Duration
+	// Called for every query; see QueryHook.
+	QueryHooks []QueryHook
+	// Called for each pooled connection as it is established; see OnConnect.
+	ConnectHooks []func(conn *ConnHandle) error
+	// If positive, any query that takes at least this long is logged at
+	// warning level with its duration.
+	SlowQueryThreshold time.Duration
+	// If set, a query that fails outside a transaction because its
+	// connection was broken is retried on a new connection; see
+	// IsBrokenConnectionError and ReconnectError.
+	ReconnectPolicy *RetryPolicy
+	// If set, RunInTransaction retries transactions that fail with a
+	// serialization failure or deadlock; see RunInTransaction.
+	TransactionRetryPolicy *RetryPolicy
+
+	// The connection string, pool limits, and GUCs set with SetGUC, for
+	// re-establishing a broken connection the same way.
+	connStr     string
+	poolOptions ConnectOptions
+	sessionGUCs map[string]string
+	// The counter from which NextConn allocates connection numbers.
+	nextConn uint64
+	// Held while a connection is established on demand; see EnsureConnected.
+	establishMutex sync.Mutex
+	// Held while sessionGUCs is read or written, and while a new connection
+	// gets those GUCs and is put in the pool; see restoreGUCs.
+	gucMutex sync.Mutex
+	// Held briefly while a pooled connection is read or replaced, as one may
+	// be established on demand by another goroutine; see pooled.
+	poolMutex sync.RWMutex
 }
 
 /*
@@ -126,17 +190,7 @@ func (dbconn *DBConn) MustBegin(whichConn ...int) {
 }
 
 func (dbconn *DBConn) Begin(whichConn ...int) error {
-	connNum := dbconn.ValidateConnNum(whichConn...)
-	if dbconn.Tx[connNum] != nil {
-		return errors.New("Cannot begin transaction; there is already a transaction in progress")
-	}
-	var err error
-	dbconn.Tx[connNum], err = dbconn.ConnPool[connNum].Beginx()
-	if err != nil {
-		return err
-	}
-	_, err = dbconn.Exec("SET TRANSACTION ISOLATION LEVEL SERIALIZABLE", connNum)
-	return err
+	return dbconn.BeginWithOptions(TxOptions{}, whichConn...)
 }
 
 func (dbconn *DBConn) Close() {
@@ -188,22 +242,83 @@ func (dbconn *DBConn) MustConnect(numConns int) {
 }
 
 func (dbconn *DBConn) Connect(numConns int, utilityMode ...bool) error {
+	if len(utilityMode) > 1 {
+		return errors.Errorf("The utility mode parameter accepts exactly one boolean value")
+	}
+	return dbconn.ConnectWithOptions(ConnectOptions{
+		NumConns:    numConns,
+		UtilityMode: len(utilityMode) == 1 && utilityMode[0],
+	})
+}
+
+/*
+ * ConnectOptions replaces the positional arguments accepted by Connect, so
+ * that new connection-time settings can be added without changing the
+ * signature of every caller.
+ *
+ * StartupParameters are sent to the server when each pooled connection is
+ * established, e.g. {"search_path": "myschema", "options": "-c work_mem=1GB"},
+ * so callers need not set PGOPTIONS in the process environment before calling
+ * Connect.  Use UtilityMode rather than setting gp_role or gp_session_role.
+ *
+ * The remaining options tune each of the NumConns underlying sql.DBs; see
+ * SetMaxOpenConns and related functions below.  ConnMaxLifetime and
+ * ConnMaxIdleTime allow a long-running process to replace stale connections.
+ *
+ * If Lazy is set, no connection is made until it is first used; see
+ * EnsureConnected.
+ */
+type ConnectOptions struct {
+	NumConns          int
+	UtilityMode       bool
+	Lazy              bool
+	StartupParameters map[string]string
+	MaxOpenConns      int
+	MaxIdleConns      int
+	ConnMaxLifetime   time.Duration
+	ConnMaxIdleTime   time.Duration
+}
+
+var startupParamRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+func startupParamString(params map[string]string) (string, error) {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		if !startupParamRegex.MatchString(name) {
+			return "", errors.Errorf("Invalid startup parameter name: %s", name)
+		}
+		if name == "gp_role" || name == "gp_session_role" {
+			return "", errors.Errorf("Cannot set %s as a startup parameter; use utility mode instead", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	paramStr := ""
+	for _, name := range names {
+		paramStr += fmt.Sprintf(" %s='%s'", name, EscapeConnectionParam(params[name]))
+	}
+	return paramStr, nil
+}
+
+func (dbconn *DBConn) ConnectWithOptions(opts ConnectOptions) error {
+	numConns := opts.NumConns
 	if numConns < 1 {
 		return errors.Errorf("Must specify a connection pool size that is a positive integer")
 	}
 	if dbconn.ConnPool != nil {
 		return errors.Errorf("The database connection must be closed before reusing the connection")
 	}
+	if opts.Lazy && opts.UtilityMode {
+		return errors.Errorf("Cannot defer connecting in utility mode")
+	}
 
 	dbname := EscapeConnectionParam(dbconn.DBName)
 	user := EscapeConnectionParam(dbconn.User)
-	krbsrvname := operating.System.Getenv("PGKRBSRVNAME")
-	if krbsrvname == "" {
-		krbsrvname = "postgres"
+	if err := dbconn.SSL.Validate(); err != nil {
+		return err
 	}
-	sslmode := operating.System.Getenv("PGSSLMODE")
-	if sslmode == "" {
-		sslmode = "prefer"
+	if err := dbconn.Kerberos.Validate(); err != nil {
+		return err
 	}
 	// This string takes in the literal user/database names. They do not need
 	// to be escaped or quoted.
@@ -212,20 +327,41 @@ func (dbconn *DBConn) Connect(numConns int, utilityMode ...bool) error {
 	// the same object again, then querying for the object in the same
 	// connection will generate a cache lookup failure. To disable pgx's
 	// automatic prepared statement cache we set statement_cache_capacity to 0.
-	connStr := fmt.Sprintf(`user='%s' dbname='%s' krbsrvname='%s' host=%s port=%d sslmode='%s' statement_cache_capacity=0`,
-		user, dbname, krbsrvname, dbconn.Host, dbconn.Port, sslmode)
+	// Kerberos parameters are only used if the server requests GSSAPI
+	// authentication; see kerberos.go.
+	connStr := fmt.Sprintf(`user='%s' dbname='%s'%s host=%s port=%d%s statement_cache_capacity=0`,
+		user, dbname, dbconn.Kerberos.connectionString(), dbconn.Host, dbconn.Port, dbconn.SSL.connectionString())
+	startupParams := make(map[string]string, len(dbconn.StartupParameters)+len(opts.StartupParameters))
+	if appName := dbconn.applicationName(); appName != "" {
+		startupParams["application_name"] = appName
+	}
+	if dbconn.ClientEncoding != "" {
+		startupParams["client_encoding"] = dbconn.ClientEncoding
+	}
+	if dbconn.StatementTimeout > 0 {
+		startupParams["statement_timeout"] = strconv.FormatInt(statementTimeoutMillis(dbconn.StatementTimeout), 10)
+	}
+	for name, value := range dbconn.StartupParameters {
+		startupParams[name] = value
+	}
+	for name, value := range opts.StartupParameters {
+		startupParams[name] = value
+	}
+	paramStr, err := startupParamString(startupParams)
+	if err != nil {
+		return err
+	}
+	connStr += paramStr
 
 	dbconn.ConnPool = make([]*sqlx.DB, numConns)
-	if len(utilityMode) > 1 {
-		return errors.Errorf("The utility mode parameter accepts exactly one boolean value")
-	} else if len(utilityMode) == 1 && utilityMode[0] {
+	if opts.UtilityMode {
 		// The utility mode GUC differs between GPDB 7 and later (gp_role)
 		// and GPDB 6 and earlier (gp_session_role), and we don't get the
 		// database version until after the connection is established, so
 		// we need to just try one first and see whether it works.
 		roleConnStr := connStr + " gp_role=utility"
 		sessionRoleConnStr := connStr + " gp_session_role=utility"
-		utilConn, err := dbconn.Driver.Connect("pgx", sessionRoleConnStr)
+		utilConn, err := dbconn.connect(sessionRoleConnStr)
 		if utilConn != nil {
 			utilConn.Close()
 		}
@@ -240,26 +376,103 @@ func (dbconn *DBConn) Connect(numConns int, utilityMode ...bool) error {
 		}
 	}
 
-	for i := 0; i < numConns; i++ {
-		conn, err := dbconn.Driver.Connect("pgx", connStr)
+	dbconn.connStr = connStr
+	dbconn.gucMutex.Lock()
+	dbconn.sessionGUCs = nil
+	dbconn.gucMutex.Unlock()
+	dbconn.nextConn = 0
+	for i := 0; i < numConns && !opts.Lazy; i++ {
+		conn, err := dbconn.connect(connStr)
 		err = dbconn.handleConnectionError(err)
 		if err != nil {
 			return err
 		}
-		conn.SetMaxOpenConns(1)
-		conn.SetMaxIdleConns(1)
 		dbconn.ConnPool[i] = conn
 	}
+	dbconn.SetMaxOpenConns(opts.MaxOpenConns)
+	dbconn.SetMaxIdleConns(opts.MaxIdleConns)
+	dbconn.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	dbconn.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
 	dbconn.Tx = make([]*sqlx.Tx, numConns)
 	dbconn.NumConns = numConns
+	if opts.Lazy {
+		dbconn.Version = GPDBVersion{}
+		return nil
+	}
 	version, err := InitializeVersion(dbconn)
 	if err != nil {
 		return errors.Wrap(err, "Failed to determine database version")
 	}
 	dbconn.Version = version
+	for i := 0; i < numConns; i++ {
+		if err := dbconn.runConnectHooks(i); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+/*
+ * Each sql.DB in ConnPool is limited to one open and one idle connection by
+ * default, so that each connection number corresponds to a single session.
+ * Raising the open connection limit allows the sql.DB to open additional
+ * sessions under load, so session-level state such as transactions and SET
+ * commands may then not carry over between queries on the same connection
+ * number.  A limit of 0 restores the default of one connection.  A negative
+ * idle limit keeps no idle connections, so that each query reconnects.
+ */
+func (dbconn *DBConn) SetMaxOpenConns(maxOpenConns int) {
+	if maxOpenConns == 0 {
+		maxOpenConns = 1
+	}
+	dbconn.configurePool(func(options *ConnectOptions) { options.MaxOpenConns = maxOpenConns },
+		func(conn *sqlx.DB) { conn.SetMaxOpenConns(maxOpenConns) })
+}
+
+func (dbconn *DBConn) SetMaxIdleConns(maxIdleConns int) {
+	if maxIdleConns == 0 {
+		maxIdleConns = 1
+	}
+	dbconn.configurePool(func(options *ConnectOptions) { options.MaxIdleConns = maxIdleConns },
+		func(conn *sqlx.DB) { conn.SetMaxIdleConns(maxIdleConns) })
+}
+
+// SetConnMaxLifetime closes connections once they are older than the given duration; 0 means no limit.
+func (dbconn *DBConn) SetConnMaxLifetime(maxLifetime time.Duration) {
+	dbconn.configurePool(func(options *ConnectOptions) { options.ConnMaxLifetime = maxLifetime },
+		func(conn *sqlx.DB) { conn.SetConnMaxLifetime(maxLifetime) })
+}
+
+// SetConnMaxIdleTime closes connections once they have been idle for the given duration; 0 means no limit.
+func (dbconn *DBConn) SetConnMaxIdleTime(maxIdleTime time.Duration) {
+	dbconn.configurePool(func(options *ConnectOptions) { options.ConnMaxIdleTime = maxIdleTime },
+		func(conn *sqlx.DB) { conn.SetConnMaxIdleTime(maxIdleTime) })
+}
+
+/*
+ * configurePool records a change to the pool limits and applies it to every
+ * established connection.  poolMutex is held throughout, so a connection being
+ * established at the same time gets the new limits when it is put in the pool.
+ */
+func (dbconn *DBConn) configurePool(configure func(options *ConnectOptions), apply func(conn *sqlx.DB)) {
+	dbconn.poolMutex.Lock()
+	defer dbconn.poolMutex.Unlock()
+	configure(&dbconn.poolOptions)
+	for _, conn := range dbconn.ConnPool {
+		if conn != nil {
+			apply(conn)
+		}
+	}
+}
+
+// limitConnLocked applies the pool limits to a new connection; poolMutex must be held.
+func (dbconn *DBConn) limitConnLocked(conn *sqlx.DB) {
+	conn.SetMaxOpenConns(dbconn.poolOptions.MaxOpenConns)
+	conn.SetMaxIdleConns(dbconn.poolOptions.MaxIdleConns)
+	conn.SetConnMaxLifetime(dbconn.poolOptions.ConnMaxLifetime)
+	conn.SetConnMaxIdleTime(dbconn.poolOptions.ConnMaxIdleTime)
+}
+
 func (dbconn *DBConn) MustConnectInUtilityMode(numConns int) {
 	err := dbconn.Connect(numConns, true)
 	gplog.FatalOnError(err)
@@ -277,6 +490,8 @@ func (dbconn *DBConn) handleConnectionError(err error) error {
 			} else if strings.Contains(err.Error(), "pq: database") {
 				return errors.Errorf(`Database "%s" does not exist on %s:%d, exiting`, dbconn.DBName, dbconn.Host, dbconn.Port)
 			}
+		} else if dbconn.ClientEncoding != "" && strings.Contains(err.Error(), `"client_encoding"`) {
+			return errors.Errorf(`Client encoding "%s" is not supported by %s:%d: %v`, dbconn.ClientEncoding, dbconn.Host, dbconn.Port, err)
 		} else if strings.Contains(err.Error(), "connection refused") {
 			return errors.Errorf(`could not connect to server: Connection refused
 	Is the server running on host "%s" and accepting
@@ -296,12 +511,139 @@ func (dbconn *DBConn) handleConnectionError(err error) error {
  * requiring that to be ensured at the call site.
  */
 
-func (dbconn *DBConn) Exec(query string, whichConn ...int) (sql.Result, error) {
-	connNum := dbconn.ValidateConnNum(whichConn...)
+/*
+ * sqlxQueryer is the set of functions common to sqlx.DB and sqlx.Tx, so that
+ * the wrappers below can run a query the same way whether or not there is a
+ * transaction in progress.
+ */
+type sqlxQueryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	GetContext(ctx context.Context, destination interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, destination interface{}, query string, args ...interface{}) error
+	QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error)
+}
+
+func (dbconn *DBConn) queryer(connNum int) sqlxQueryer {
 	if dbconn.Tx[connNum] != nil {
-		return dbconn.Tx[connNum].Exec(query)
+		return dbconn.Tx[connNum]
+	}
+	conn := dbconn.pooled(connNum)
+	if conn == nil {
+		return pendingConn{dbconn: dbconn, connNum: connNum}
+	}
+	return conn
+}
+
+func (dbconn *DBConn) exec(ctx context.Context, queryer sqlxQueryer, connNum int, query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := dbconn.withDefaultTimeout(ctx)
+	defer cancel()
+	var result sql.Result
+	err := dbconn.withReconnect(queryer, connNum, func(queryer sqlxQueryer) error {
+		return dbconn.runQuery(ctx, query, args, connNum, func(ctx context.Context) (int64, error) {
+			var err error
+			result, err = queryer.ExecContext(ctx, query, args...)
+			return resultRowsAffected(result), err
+		})
+	})
+	return result, err
+}
+
+func (dbconn *DBConn) get(ctx context.Context, queryer sqlxQueryer, connNum int, destination interface{}, query string, args ...interface{}) error {
+	ctx, cancel := dbconn.withDefaultTimeout(ctx)
+	defer cancel()
+	return dbconn.withReconnect(queryer, connNum, func(queryer sqlxQueryer) error {
+		return dbconn.runQuery(ctx, query, args, connNum, func(ctx context.Context) (int64, error) {
+			err := queryer.GetContext(ctx, destination, query, args...)
+			if err != nil {
+				return 0, err
+			}
+			return 1, nil
+		})
+	})
+}
+
+func (dbconn *DBConn) selectRows(ctx context.Context, queryer sqlxQueryer, connNum int, destination interface{}, query string, args ...interface{}) error {
+	ctx, cancel := dbconn.withDefaultTimeout(ctx)
+	defer cancel()
+	return dbconn.withReconnect(queryer, connNum, func(queryer sqlxQueryer) error {
+		return dbconn.runQuery(ctx, query, args, connNum, func(ctx context.Context) (int64, error) {
+			err := queryer.SelectContext(ctx, destination, query, args...)
+			return destinationRows(destination), err
+		})
+	})
+}
+
+/*
+ * timedRows are the rows of a query run by queryRows.  Closing them also
+ * releases the context they are read under.
+ */
+type timedRows struct {
+	*sqlx.Rows
+	cancel context.CancelFunc
+}
+
+func (rows *timedRows) Close() error {
+	err := rows.Rows.Close()
+	rows.cancel()
+	return err
+}
+
+/*
+ * queryRows runs a query whose rows are read after it returns.  The
+ * DefaultQueryTimeout bounds only running the query, not reading the rows,
+ * which may take as long as the caller needs, so it is enforced with a timer
+ * that is stopped once the query returns rather than with a deadline on the
+ * context the rows are read under.  A deadline set by the caller still covers
+ * both.
+ */
+func (dbconn *DBConn) queryRows(ctx context.Context, queryer sqlxQueryer, connNum int, query string, args ...interface{}) (*timedRows, error) {
+	ctx = context.WithValue(ctx, rowsPendingKey{}, true)
+	cancel := context.CancelFunc(func() {})
+	stopTimer := func() bool { return true }
+	timeout := dbconn.defaultTimeout(ctx)
+	if timeout > 0 {
+		ctx, cancel = context.WithCancel(ctx)
+		stopTimer = time.AfterFunc(timeout, cancel).Stop
+	}
+	var rows *sqlx.Rows
+	err := dbconn.withReconnect(queryer, connNum, func(queryer sqlxQueryer) error {
+		return dbconn.runQuery(ctx, query, args, connNum, func(ctx context.Context) (int64, error) {
+			var err error
+			rows, err = queryer.QueryxContext(ctx, query, args...)
+			return -1, err
+		})
+	})
+	if !stopTimer() {
+		// The timer fired, so the query was canceled, or the rows soon will be.
+		if err == nil {
+			_ = rows.Close()
+		}
+		err = errors.WithMessagef(context.DeadlineExceeded, "Query on connection %d did not complete within %v", connNum, timeout)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
 	}
-	return dbconn.ConnPool[connNum].Exec(query)
+	return &timedRows{Rows: rows, cancel: cancel}, nil
+}
+
+/*
+ * query is like queryRows, for callers that return the rows to their own
+ * callers as an *sqlx.Rows.  Such rows cannot release their context when
+ * closed, but no timer is left running, and the context is released along
+ * with the caller's.
+ */
+func (dbconn *DBConn) query(ctx context.Context, queryer sqlxQueryer, connNum int, query string, args ...interface{}) (*sqlx.Rows, error) {
+	rows, err := dbconn.queryRows(ctx, queryer, connNum, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return rows.Rows, nil
+}
+
+func (dbconn *DBConn) Exec(query string, whichConn ...int) (sql.Result, error) {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	return dbconn.exec(context.Background(), dbconn.queryer(connNum), connNum, query)
 }
 
 func (dbconn *DBConn) MustExec(query string, whichConn ...int) {
@@ -311,10 +653,7 @@ func (dbconn *DBConn) MustExec(query string, whichConn ...int) {
 
 func (dbconn *DBConn) ExecContext(queryContext context.Context, query string, whichConn ...int) (sql.Result, error) {
 	connNum := dbconn.ValidateConnNum(whichConn...)
-	if dbconn.Tx[connNum] != nil {
-		return dbconn.Tx[connNum].ExecContext(queryContext, query)
-	}
-	return dbconn.ConnPool[connNum].ExecContext(queryContext, query)
+	return dbconn.exec(queryContext, dbconn.queryer(connNum), connNum, query)
 }
 
 func (dbconn *DBConn) MustExecContext(queryContext context.Context, query string, whichConn ...int) {
@@ -322,65 +661,118 @@ func (dbconn *DBConn) MustExecContext(queryContext context.Context, query string
 	gplog.FatalOnError(err)
 }
 
-func (dbconn *DBConn) GetWithArgs(destination interface{}, query string, args ...interface{}) error {
-	if dbconn.Tx[0] != nil {
-		return dbconn.Tx[0].Get(destination, query, args...)
+/*
+ * ExecAffected runs the query and returns the number of rows it inserted,
+ * updated, or deleted, so that callers need not check the sql.Result
+ * separately.
+ */
+func (dbconn *DBConn) ExecAffected(query string, whichConn ...int) (int64, error) {
+	result, err := dbconn.Exec(query, whichConn...)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to get the number of rows affected")
 	}
-	return dbconn.ConnPool[0].Get(destination, query, args...)
+	return rowsAffected, nil
+}
+
+func (dbconn *DBConn) MustExecAffected(query string, whichConn ...int) int64 {
+	rowsAffected, err := dbconn.ExecAffected(query, whichConn...)
+	gplog.FatalOnError(err)
+	return rowsAffected
+}
+
+func (dbconn *DBConn) GetWithArgs(destination interface{}, query string, args ...interface{}) error {
+	return dbconn.get(context.Background(), dbconn.queryer(0), 0, destination, query, args...)
 }
 
 func (dbconn *DBConn) Get(destination interface{}, query string, whichConn ...int) error {
 	connNum := dbconn.ValidateConnNum(whichConn...)
-	if dbconn.Tx[connNum] != nil {
-		return dbconn.Tx[connNum].Get(destination, query)
-	}
-	return dbconn.ConnPool[connNum].Get(destination, query)
+	return dbconn.get(context.Background(), dbconn.queryer(connNum), connNum, destination, query)
 }
 
 func (dbconn *DBConn) SelectWithArgs(destination interface{}, query string, args ...interface{}) error {
-	if dbconn.Tx[0] != nil {
-		return dbconn.Tx[0].Select(destination, query, args...)
-	}
-	return dbconn.ConnPool[0].Select(destination, query, args...)
+	return dbconn.selectRows(context.Background(), dbconn.queryer(0), 0, destination, query, args...)
 }
 
 func (dbconn *DBConn) Select(destination interface{}, query string, whichConn ...int) error {
 	connNum := dbconn.ValidateConnNum(whichConn...)
-	if dbconn.Tx[connNum] != nil {
-		return dbconn.Tx[connNum].Select(destination, query)
-	}
-	return dbconn.ConnPool[connNum].Select(destination, query)
+	return dbconn.selectRows(context.Background(), dbconn.queryer(connNum), connNum, destination, query)
 }
 
 func (dbconn *DBConn) SelectContext(ctx context.Context, destination interface{}, query string, whichConn ...int) error {
 	connNum := dbconn.ValidateConnNum(whichConn...)
-	if dbconn.Tx[connNum] != nil {
-		return dbconn.Tx[connNum].SelectContext(ctx, destination, query)
-	}
-	return dbconn.ConnPool[connNum].SelectContext(ctx, destination, query)
+	return dbconn.selectRows(ctx, dbconn.queryer(connNum), connNum, destination, query)
 }
 
 func (dbconn *DBConn) QueryWithArgs(query string, args ...interface{}) (*sqlx.Rows, error) {
-	if dbconn.Tx[0] != nil {
-		return dbconn.Tx[0].Queryx(query, args...)
-	}
-	return dbconn.ConnPool[0].Queryx(query, args...)
+	return dbconn.query(context.Background(), dbconn.queryer(0), 0, query, args...)
 }
 
 func (dbconn *DBConn) Query(query string, whichConn ...int) (*sqlx.Rows, error) {
 	connNum := dbconn.ValidateConnNum(whichConn...)
-	if dbconn.Tx[connNum] != nil {
-		return dbconn.Tx[connNum].Queryx(query)
-	}
-	return dbconn.ConnPool[connNum].Queryx(query)
+	return dbconn.query(context.Background(), dbconn.queryer(connNum), connNum, query)
 }
 
 func (dbconn *DBConn) QueryContext(ctx context.Context, query string, whichConn ...int) (*sqlx.Rows, error) {
 	connNum := dbconn.ValidateConnNum(whichConn...)
-	if dbconn.Tx[connNum] != nil {
-		return dbconn.Tx[connNum].QueryxContext(ctx, query)
+	return dbconn.query(ctx, dbconn.queryer(connNum), connNum, query)
+}
+
+/*
+ * NamedExec and NamedSelect run a query containing named placeholders such as
+ * :schema, which are bound to the fields of arg with the matching "db" tags, or
+ * to the entries of arg if it is a map[string]interface{}.  As with the other
+ * wrappers, they run inside the connection's transaction if one is in progress.
+ */
+func (dbconn *DBConn) NamedExec(query string, arg interface{}, whichConn ...int) (sql.Result, error) {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	conn, err := dbconn.pooledConn(connNum)
+	if err != nil {
+		return nil, err
+	}
+	boundQuery, args, err := conn.BindNamed(query, arg)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to bind named parameters")
+	}
+	return dbconn.exec(context.Background(), dbconn.queryer(connNum), connNum, boundQuery, args...)
+}
+
+func (dbconn *DBConn) NamedSelect(destination interface{}, query string, arg interface{}, whichConn ...int) error {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	conn, err := dbconn.pooledConn(connNum)
+	if err != nil {
+		return err
+	}
+	boundQuery, args, err := conn.BindNamed(query, arg)
+	if err != nil {
+		return errors.Wrap(err, "Failed to bind named parameters")
 	}
-	return dbconn.ConnPool[connNum].QueryxContext(ctx, query)
+	return dbconn.selectRows(context.Background(), dbconn.queryer(connNum), connNum, destination, boundQuery, args...)
+}
+
+/*
+ * SelectT and GetT are typed versions of SelectWithArgs and GetWithArgs that
+ * return their results instead of scanning into a destination pointer.  They
+ * use sqlx for scanning, so struct fields are mapped by their "db" tags exactly
+ * as with the other wrapper functions, and they likewise run on connection 0,
+ * inside its transaction if one is in progress.
+ */
+func SelectT[T any](connection *DBConn, query string, args ...interface{}) ([]T, error) {
+	results := make([]T, 0)
+	err := connection.SelectWithArgs(&results, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func GetT[T any](connection *DBConn, query string, args ...interface{}) (T, error) {
+	var result T
+	err := connection.GetWithArgs(&result, query, args...)
+	return result, err
 }
 
 /*
@@ -410,6 +802,19 @@ func EscapeConnectionParam(param string) string {
 	return param
 }
 
+/*
+ * QuoteIdentifier double-quotes an identifier (doubling any embedded double
+ * quotes) so that it can be safely interpolated into a query, and EscapeString
+ * doubles any single quotes in a string so it can be placed in a '' literal.
+ */
+func QuoteIdentifier(ident string) string {
+	return `"` + strings.Replace(ident, `"`, `""`, -1) + `"`
+}
+
+func EscapeString(str string) string {
+	return strings.Replace(str, `'`, `''`, -1)
+}
+
 /*
  * This is a convenience function for Select() when we're selecting a single
  * string that may be NULL or not exist.  We can't use Get() because that
@@ -29,6 +29,26 @@ func ExpectBegin(mock sqlmock.Sqlmock) {
 	mock.ExpectExec("SET TRANSACTION(.*)").WillReturnResult(fakeResult)
 }
 
+/*
+ * recordingDriver wraps a TestDriver to record the connection strings passed
+ * to Connect, for tests of connection-time options.
+ */
+type recordingDriver struct {
+	*testhelper.TestDriver
+	ConnStrs []string
+}
+
+func (driver *recordingDriver) Connect(driverName string, dataSourceName string) (*sqlx.DB, error) {
+	driver.ConnStrs = append(driver.ConnStrs, dataSourceName)
+	return driver.TestDriver.Connect(driverName, dataSourceName)
+}
+
+func useRecordingDriver(connection *dbconn.DBConn) *recordingDriver {
+	driver := &recordingDriver{TestDriver: connection.Driver.(*testhelper.TestDriver)}
+	connection.Driver = driver
+	return driver
+}
+
 func TestDBConn(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "dbconn tests")
@@ -149,6 +169,56 @@ var _ = Describe("dbconn/dbconn tests", func() {
 			Expect(err.Error()).To(Equal(`Database "testdb" does not exist on testhost:5432, exiting`))
 		})
 	})
+	Describe("DBConn.ConnectWithOptions", func() {
+		It("applies startup parameters to every pooled connection", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			driver := useRecordingDriver(connection)
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+
+			err := connection.ConnectWithOptions(dbconn.ConnectOptions{
+				NumConns: 2,
+				StartupParameters: map[string]string{
+					"search_path": "my'schema",
+					"options":     "-c work_mem=1GB",
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(driver.ConnStrs).To(HaveLen(2))
+			for _, connStr := range driver.ConnStrs {
+				Expect(connStr).To(HaveSuffix(fmt.Sprintf(`statement_cache_capacity=0 application_name='%s' options='-c work_mem=1GB' search_path='my\'schema'`, dbconn.DefaultApplicationName())))
+			}
+		})
+		It("limits each pooled connection to one session by default", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+
+			err := connection.ConnectWithOptions(dbconn.ConnectOptions{NumConns: 1})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(connection.ConnPool[0].Stats().MaxOpenConnections).To(Equal(1))
+		})
+		It("applies connection pool limits to every pooled connection", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+
+			err := connection.ConnectWithOptions(dbconn.ConnectOptions{NumConns: 1, MaxOpenConns: 3, ConnMaxLifetime: time.Minute})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(connection.ConnPool[0].Stats().MaxOpenConnections).To(Equal(3))
+
+			connection.SetMaxOpenConns(0)
+			Expect(connection.ConnPool[0].Stats().MaxOpenConnections).To(Equal(1))
+		})
+		It("rejects an invalid startup parameter name", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			err := connection.ConnectWithOptions(dbconn.ConnectOptions{NumConns: 1, StartupParameters: map[string]string{"foo bar": "baz"}})
+			Expect(err).To(MatchError("Invalid startup parameter name: foo bar"))
+			Expect(connection.ConnPool).To(BeNil())
+		})
+		It("rejects setting the role as a startup parameter", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			err := connection.ConnectWithOptions(dbconn.ConnectOptions{NumConns: 1, StartupParameters: map[string]string{"gp_session_role": "utility"}})
+			Expect(err).To(MatchError("Cannot set gp_session_role as a startup parameter; use utility mode instead"))
+		})
+	})
 	Describe("DBConn.Close", func() {
 		BeforeEach(func() {
 			connection, mock = testhelper.CreateMockDBConn()
@@ -238,6 +308,40 @@ var _ = Describe("dbconn/dbconn tests", func() {
 			Expect(rowsReturned).To(Equal(int64(1)))
 		})
 	})
+	Describe("DBConn.ExecAffected", func() {
+		It("returns the number of rows affected", func() {
+			mock.ExpectExec("UPDATE (.*)").WillReturnResult(testhelper.TestResult{Rows: 3})
+
+			rowsAffected, err := connection.ExecAffected("UPDATE pg_tables SET tablename = 'table'")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rowsAffected).To(Equal(int64(3)))
+		})
+		It("returns an error if the query fails", func() {
+			mock.ExpectExec("UPDATE (.*)").WillReturnError(fmt.Errorf("relation does not exist"))
+
+			_, err := connection.ExecAffected("UPDATE pg_tables SET tablename = 'table'")
+			Expect(err).To(MatchError("relation does not exist"))
+		})
+		It("returns an error if the number of rows affected is unavailable", func() {
+			mock.ExpectExec("UPDATE (.*)").WillReturnResult(sqlmock.NewErrorResult(fmt.Errorf("not supported")))
+
+			_, err := connection.ExecAffected("UPDATE pg_tables SET tablename = 'table'")
+			Expect(err).To(MatchError("Failed to get the number of rows affected: not supported"))
+		})
+	})
+	Describe("DBConn.MustExecAffected", func() {
+		It("returns the number of rows affected", func() {
+			mock.ExpectExec("DELETE (.*)").WillReturnResult(testhelper.TestResult{Rows: 2})
+
+			Expect(connection.MustExecAffected("DELETE FROM pg_tables")).To(Equal(int64(2)))
+		})
+		It("panics if the query fails", func() {
+			mock.ExpectExec("DELETE (.*)").WillReturnError(fmt.Errorf("permission denied"))
+
+			defer testhelper.ShouldPanicWithMessage("permission denied")
+			connection.MustExecAffected("DELETE FROM pg_tables")
+		})
+	})
 	Describe("DBConn.Get", func() {
 		It("executes a GET outside of a transaction", func() {
 			two_col_single_row := sqlmock.NewRows([]string{"schemaname", "tablename"}).
@@ -360,6 +464,84 @@ var _ = Describe("dbconn/dbconn tests", func() {
 			Expect(testSlice[1].Tablename).To(Equal("table2"))
 		})
 	})
+	Describe("DBConn.NamedExec", func() {
+		type table struct {
+			Schema string `db:"schemaname"`
+			Name   string `db:"tablename"`
+		}
+		It("binds the fields of a struct to named parameters", func() {
+			mock.ExpectExec(`DELETE FROM pg_tables WHERE schemaname = \? AND tablename = \?`).WithArgs("schema1", "table1").WillReturnResult(testhelper.TestResult{Rows: 1})
+
+			res, err := connection.NamedExec("DELETE FROM pg_tables WHERE schemaname = :schemaname AND tablename = :tablename", table{Schema: "schema1", Name: "table1"})
+			Expect(err).ToNot(HaveOccurred())
+			rowsAffected, _ := res.RowsAffected()
+			Expect(rowsAffected).To(Equal(int64(1)))
+		})
+		It("binds the entries of a map to named parameters in a transaction", func() {
+			ExpectBegin(mock)
+			mock.ExpectExec(`DELETE FROM pg_tables WHERE tablename = \?`).WithArgs("table1").WillReturnResult(testhelper.TestResult{Rows: 1})
+			mock.ExpectCommit()
+
+			connection.MustBegin()
+			_, err := connection.NamedExec("DELETE FROM pg_tables WHERE tablename = :name", map[string]interface{}{"name": "table1"})
+			connection.MustCommit()
+			Expect(err).ToNot(HaveOccurred())
+		})
+		It("returns an error if a named parameter has no value", func() {
+			_, err := connection.NamedExec("DELETE FROM pg_tables WHERE tablename = :name", map[string]interface{}{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("Failed to bind named parameters: "))
+		})
+	})
+	Describe("DBConn.NamedSelect", func() {
+		type table struct {
+			Schema string `db:"schemaname"`
+			Name   string `db:"tablename"`
+		}
+		It("binds named parameters and scans the results", func() {
+			rows := sqlmock.NewRows([]string{"schemaname", "tablename"}).AddRow("schema1", "table1")
+			mock.ExpectQuery(`SELECT schemaname, tablename FROM pg_tables WHERE schemaname = \?`).WithArgs("schema1").WillReturnRows(rows)
+
+			results := make([]table, 0)
+			err := connection.NamedSelect(&results, "SELECT schemaname, tablename FROM pg_tables WHERE schemaname = :schemaname", table{Schema: "schema1"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(Equal([]table{{Schema: "schema1", Name: "table1"}}))
+		})
+	})
+	Describe("SelectT", func() {
+		type table struct {
+			Schema string `db:"schemaname"`
+			Name   string `db:"tablename"`
+		}
+		It("returns typed results using struct tags", func() {
+			two_col_rows := sqlmock.NewRows([]string{"schemaname", "tablename"}).
+				AddRow("schema1", "table1").
+				AddRow("schema2", "table2")
+			mock.ExpectQuery("SELECT (.*)").WithArgs("schema%").WillReturnRows(two_col_rows)
+
+			results, err := dbconn.SelectT[table](connection, "SELECT schemaname, tablename FROM two_columns WHERE schemaname LIKE $1", "schema%")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(Equal([]table{{"schema1", "table1"}, {"schema2", "table2"}}))
+		})
+		It("returns an empty slice if there are no rows", func() {
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(sqlmock.NewRows([]string{"schemaname", "tablename"}))
+
+			results, err := dbconn.SelectT[table](connection, "SELECT schemaname, tablename FROM two_columns")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(BeEmpty())
+			Expect(results).ToNot(BeNil())
+		})
+		It("returns a single typed value with GetT", func() {
+			mock.ExpectQuery("SELECT (.*)").WithArgs("table1").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+			result, err := dbconn.GetT[int](connection, "SELECT count(*) FROM pg_class WHERE relname = $1", "table1")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(3))
+		})
+	})
 	Describe("DBConn.SelectContext", func() {
 		It("executes a SELECT outside of a transaction", func() {
 			two_col_rows := sqlmock.NewRows([]string{"schemaname", "tablename"}).
@@ -0,0 +1,89 @@
+package dbconn
+
+/*
+ * This file contains functions related to the character encodings used by
+ * the server and by each connection.  Tools that write data out and read it
+ * back, such as dump and restore utilities, should set ClientEncoding or call
+ * CheckServerEncoding so that a mismatch is an error rather than silently
+ * converted or corrupted data.
+ *
+ * Note that the driver returns text in whatever client encoding is in effect,
+ * so with a ClientEncoding other than UTF8, strings read from the database
+ * hold bytes in that encoding rather than UTF-8.
+ */
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// encodingAliases maps alternate spellings the server accepts to the encoding names it reports.
+var encodingAliases = map[string]string{
+	"UNICODE":   "UTF8",
+	"ISO88591":  "LATIN1",
+	"ISO88592":  "LATIN2",
+	"ISO885915": "LATIN9",
+	"WIN":       "WIN1251",
+	"ALT":       "WIN866",
+}
+
+/*
+ * NormalizeEncoding returns an encoding name in a form that can be compared
+ * with another, ignoring case, dashes, and underscores as the server does, so
+ * that for example "utf-8" and "UTF8" are treated as the same encoding.
+ */
+func NormalizeEncoding(encoding string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(encoding))
+	if alias, ok := encodingAliases[normalized]; ok {
+		return alias
+	}
+	return normalized
+}
+
+// GetServerEncoding returns the encoding of the database, which cannot be changed after it is created.
+func (dbconn *DBConn) GetServerEncoding(whichConn ...int) (string, error) {
+	return dbconn.GetGUC("server_encoding", whichConn...)
+}
+
+func (dbconn *DBConn) GetClientEncoding(whichConn ...int) (string, error) {
+	return dbconn.GetGUC("client_encoding", whichConn...)
+}
+
+/*
+ * SetClientEncoding sets the client encoding on every connection in the pool,
+ * and stores it in ClientEncoding for any connections made later.  It returns
+ * an error if the server does not recognize the encoding or cannot convert
+ * between it and the server encoding.
+ */
+func (dbconn *DBConn) SetClientEncoding(encoding string) error {
+	if dbconn.ConnPool != nil {
+		if err := dbconn.SetGUC("client_encoding", encoding); err != nil {
+			return errors.Wrapf(err, "Failed to set client encoding to %s", encoding)
+		}
+	}
+	dbconn.ClientEncoding = encoding
+	return nil
+}
+
+/*
+ * CheckServerEncoding returns an error unless the server encoding is one of the
+ * given encodings, compared as by NormalizeEncoding.
+ */
+func (dbconn *DBConn) CheckServerEncoding(allowed ...string) error {
+	serverEncoding, err := dbconn.GetServerEncoding()
+	if err != nil {
+		return err
+	}
+	for _, encoding := range allowed {
+		if NormalizeEncoding(encoding) == NormalizeEncoding(serverEncoding) {
+			return nil
+		}
+	}
+	return errors.Errorf("Server encoding %s is not one of the supported encodings: %s", serverEncoding, strings.Join(allowed, ", "))
+}
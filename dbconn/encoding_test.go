@@ -0,0 +1,73 @@
+package dbconn_test
+
+import (
+	"errors"
+	"regexp"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/encoding tests", func() {
+	expectServerEncoding := func(encoding string) {
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_catalog.current_setting('server_encoding')")).WillReturnRows(sqlmock.NewRows([]string{"current_setting"}).AddRow(encoding))
+	}
+	DescribeTable("NormalizeEncoding", func(encoding string, expected string) {
+		Expect(dbconn.NormalizeEncoding(encoding)).To(Equal(expected))
+	},
+		Entry("canonical name", "UTF8", "UTF8"),
+		Entry("lowercase with a dash", "utf-8", "UTF8"),
+		Entry("alias", "unicode", "UTF8"),
+		Entry("ISO name", "ISO_8859_15", "LATIN9"),
+		Entry("underscore", "sql_ascii", "SQLASCII"),
+	)
+	Describe("DBConn.Connect", func() {
+		It("sends the client encoding as a startup parameter", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			driver := useRecordingDriver(connection)
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+			connection.ClientEncoding = "LATIN1"
+
+			Expect(connection.Connect(1)).To(Succeed())
+			Expect(driver.ConnStrs[0]).To(ContainSubstring(" client_encoding='LATIN1'"))
+		})
+		It("returns an error if the server rejects the client encoding", func() {
+			connection, mock = testhelper.CreateMockDBConn(errors.New(`FATAL: invalid value for parameter "client_encoding": "EBCDIC" (SQLSTATE 22023)`))
+			connection.ClientEncoding = "EBCDIC"
+
+			err := connection.Connect(1)
+			Expect(err).To(MatchError(`Client encoding "EBCDIC" is not supported by testhost:5432: FATAL: invalid value for parameter "client_encoding": "EBCDIC" (SQLSTATE 22023)`))
+		})
+	})
+	Describe("DBConn.SetClientEncoding", func() {
+		It("sets the client encoding on every connection", func() {
+			mock.ExpectExec(regexp.QuoteMeta("SELECT pg_catalog.set_config('client_encoding', 'LATIN1', false)")).WillReturnResult(testhelper.TestResult{Rows: 1})
+
+			Expect(connection.SetClientEncoding("LATIN1")).To(Succeed())
+			Expect(connection.ClientEncoding).To(Equal("LATIN1"))
+		})
+		It("returns an error if the encoding cannot be used", func() {
+			mock.ExpectExec("set_config").WillReturnError(errors.New(`conversion between EUC_JP and LATIN1 is not supported`))
+
+			err := connection.SetClientEncoding("EUC_JP")
+			Expect(err).To(MatchError("Failed to set client encoding to EUC_JP: Failed to set client_encoding on connection 0: conversion between EUC_JP and LATIN1 is not supported"))
+			Expect(connection.ClientEncoding).To(BeEmpty())
+		})
+	})
+	Describe("DBConn.CheckServerEncoding", func() {
+		It("succeeds if the server encoding is allowed", func() {
+			expectServerEncoding("UTF8")
+
+			Expect(connection.CheckServerEncoding("SQL_ASCII", "utf-8")).To(Succeed())
+		})
+		It("returns an error if the server encoding is not allowed", func() {
+			expectServerEncoding("LATIN1")
+
+			Expect(connection.CheckServerEncoding("UTF8")).To(MatchError("Server encoding LATIN1 is not one of the supported encodings: UTF8"))
+		})
+	})
+})
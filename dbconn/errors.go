@@ -0,0 +1,136 @@
+package dbconn
+
+/*
+ * This file contains functions for classifying the errors returned by queries,
+ * so that callers can decide how to handle an error without matching its
+ * message.  Errors are classified by their SQLSTATE code where the driver
+ * provides one, and otherwise by the messages recognized by
+ * IsBrokenConnectionError and IsTransientConnectionError.
+ */
+
+import (
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+type ErrorCategory int
+
+const (
+	ERROR_OTHER ErrorCategory = iota
+	ERROR_CONNECTION
+	ERROR_PERMISSION
+	ERROR_MISSING_OBJECT
+	ERROR_SERIALIZATION_FAILURE
+	ERROR_DEADLOCK
+	ERROR_DISK_FULL
+	ERROR_INSUFFICIENT_RESOURCES
+	ERROR_CANCELED
+)
+
+func (category ErrorCategory) String() string {
+	switch category {
+	case ERROR_CONNECTION:
+		return "connection failure"
+	case ERROR_PERMISSION:
+		return "permission denied"
+	case ERROR_MISSING_OBJECT:
+		return "missing object"
+	case ERROR_SERIALIZATION_FAILURE:
+		return "serialization failure"
+	case ERROR_DEADLOCK:
+		return "deadlock"
+	case ERROR_DISK_FULL:
+		return "disk full"
+	case ERROR_INSUFFICIENT_RESOURCES:
+		return "insufficient resources"
+	case ERROR_CANCELED:
+		return "canceled"
+	default:
+		return "other"
+	}
+}
+
+var errorCodeCategories = map[string]ErrorCategory{
+	"28000": ERROR_PERMISSION,            // invalid_authorization_specification
+	"28P01": ERROR_PERMISSION,            // invalid_password
+	"42501": ERROR_PERMISSION,            // insufficient_privilege
+	"3D000": ERROR_MISSING_OBJECT,        // invalid_catalog_name
+	"3F000": ERROR_MISSING_OBJECT,        // invalid_schema_name
+	"42P01": ERROR_MISSING_OBJECT,        // undefined_table
+	"42703": ERROR_MISSING_OBJECT,        // undefined_column
+	"42704": ERROR_MISSING_OBJECT,        // undefined_object
+	"42883": ERROR_MISSING_OBJECT,        // undefined_function
+	"40001": ERROR_SERIALIZATION_FAILURE, // serialization_failure
+	"40P01": ERROR_DEADLOCK,              // deadlock_detected
+	"53100": ERROR_DISK_FULL,             // disk_full
+	"57014": ERROR_CANCELED,              // query_canceled
+	"57P01": ERROR_CONNECTION,            // admin_shutdown
+	"57P02": ERROR_CONNECTION,            // crash_shutdown
+	"57P03": ERROR_CONNECTION,            // cannot_connect_now
+}
+
+// Other codes in these classes are categorized by class.
+var errorClassCategories = map[string]ErrorCategory{
+	"08": ERROR_CONNECTION,             // connection_exception
+	"53": ERROR_INSUFFICIENT_RESOURCES, // insufficient_resources
+}
+
+var sqlStateRegex = regexp.MustCompile(`\(SQLSTATE ([0-9A-Z]{5})\)`)
+
+/*
+ * ErrorCode returns the SQLSTATE code of a database error, such as "42P01" for
+ * a missing table, or "" if err is not a database error or does not carry a
+ * code.  It recognizes errors from the pgx and lib/pq drivers, including when
+ * wrapped, and the "(SQLSTATE XXXXX)" suffix with which pgx formats them.
+ */
+func ErrorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		return stateErr.SQLState()
+	}
+	if matches := sqlStateRegex.FindStringSubmatch(err.Error()); matches != nil {
+		return matches[1]
+	}
+	return ""
+}
+
+// ClassifyError returns the category of a database error, or ERROR_OTHER if it does not belong to any.
+func ClassifyError(err error) ErrorCategory {
+	if err == nil {
+		return ERROR_OTHER
+	}
+	if code := ErrorCode(err); code != "" {
+		if category, ok := errorCodeCategories[code]; ok {
+			return category
+		}
+		if category, ok := errorClassCategories[code[:2]]; ok {
+			return category
+		}
+		return ERROR_OTHER
+	}
+	if IsBrokenConnectionError(err) || IsTransientConnectionError(err) {
+		return ERROR_CONNECTION
+	}
+	return ERROR_OTHER
+}
+
+/*
+ * IsRetryable reports whether a query that failed with err may succeed if run
+ * again: it failed because of a serialization failure or deadlock, which
+ * requires retrying the whole transaction, or a connection failure, or because
+ * the server had too many connections at the time.  Whether it is safe to
+ * retry a statement that changes data is up to the caller.
+ */
+func IsRetryable(err error) bool {
+	switch ClassifyError(err) {
+	case ERROR_SERIALIZATION_FAILURE, ERROR_DEADLOCK, ERROR_CONNECTION:
+		return true
+	case ERROR_INSUFFICIENT_RESOURCES:
+		return ErrorCode(err) == "53300" // too_many_connections
+	}
+	return false
+}
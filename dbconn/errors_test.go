@@ -0,0 +1,64 @@
+package dbconn_test
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/jackc/pgconn"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/errors tests", func() {
+	Describe("ErrorCode", func() {
+		It("returns the code of a driver error", func() {
+			err := &pgconn.PgError{Code: "42P01", Message: `relation "foo" does not exist`}
+			Expect(dbconn.ErrorCode(err)).To(Equal("42P01"))
+		})
+		It("returns the code of a wrapped driver error", func() {
+			err := fmt.Errorf("Failed to list relations: %w", &pgconn.PgError{Code: "42501"})
+			Expect(dbconn.ErrorCode(err)).To(Equal("42501"))
+		})
+		It("returns the code from the message of an error formatted by the driver", func() {
+			err := errors.New(`ERROR: could not serialize access due to concurrent update (SQLSTATE 40001)`)
+			Expect(dbconn.ErrorCode(err)).To(Equal("40001"))
+		})
+		It("returns an empty code for other errors", func() {
+			Expect(dbconn.ErrorCode(errors.New("something went wrong"))).To(BeEmpty())
+			Expect(dbconn.ErrorCode(nil)).To(BeEmpty())
+		})
+	})
+	DescribeTable("ClassifyError", func(err error, expected dbconn.ErrorCategory) {
+		Expect(dbconn.ClassifyError(err)).To(Equal(expected))
+	},
+		Entry("nil", nil, dbconn.ERROR_OTHER),
+		Entry("insufficient privilege", &pgconn.PgError{Code: "42501"}, dbconn.ERROR_PERMISSION),
+		Entry("undefined table", &pgconn.PgError{Code: "42P01"}, dbconn.ERROR_MISSING_OBJECT),
+		Entry("serialization failure", &pgconn.PgError{Code: "40001"}, dbconn.ERROR_SERIALIZATION_FAILURE),
+		Entry("deadlock", &pgconn.PgError{Code: "40P01"}, dbconn.ERROR_DEADLOCK),
+		Entry("disk full", &pgconn.PgError{Code: "53100"}, dbconn.ERROR_DISK_FULL),
+		Entry("out of memory", &pgconn.PgError{Code: "53200"}, dbconn.ERROR_INSUFFICIENT_RESOURCES),
+		Entry("query canceled", &pgconn.PgError{Code: "57014"}, dbconn.ERROR_CANCELED),
+		Entry("connection failure code", &pgconn.PgError{Code: "08006"}, dbconn.ERROR_CONNECTION),
+		Entry("connection failure message", errors.New("read: connection reset by peer"), dbconn.ERROR_CONNECTION),
+		Entry("syntax error", &pgconn.PgError{Code: "42601"}, dbconn.ERROR_OTHER),
+		Entry("error without a code", errors.New("something went wrong"), dbconn.ERROR_OTHER),
+	)
+	DescribeTable("IsRetryable", func(err error, expected bool) {
+		Expect(dbconn.IsRetryable(err)).To(Equal(expected))
+	},
+		Entry("serialization failure", &pgconn.PgError{Code: "40001"}, true),
+		Entry("deadlock", &pgconn.PgError{Code: "40P01"}, true),
+		Entry("broken connection", errors.New("server closed the connection unexpectedly"), true),
+		Entry("too many connections", &pgconn.PgError{Code: "53300"}, true),
+		Entry("disk full", &pgconn.PgError{Code: "53100"}, false),
+		Entry("permission denied", &pgconn.PgError{Code: "42501"}, false),
+		Entry("nil", nil, false),
+	)
+	It("describes each category", func() {
+		Expect(dbconn.ERROR_SERIALIZATION_FAILURE.String()).To(Equal("serialization failure"))
+		Expect(dbconn.ERROR_OTHER.String()).To(Equal("other"))
+	})
+})
@@ -0,0 +1,129 @@
+package dbconn
+
+/*
+ * This file contains a QueryHook that logs the plans of slow queries, for
+ * diagnosing performance problems at a customer site without having to
+ * reproduce the workload.
+ */
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Set on the context of a query run by ExplainLogger itself, so that it does not explain its own queries.
+type explainingKey struct{}
+
+// Set on the context of a query whose rows are still being read when AfterQuery is called, as with Query.
+type rowsPendingKey struct{}
+
+const explainSavepoint = "gp_explain_logger"
+
+/*
+ * An ExplainLogger is a QueryHook that runs EXPLAIN on each query through
+ * DBConn that succeeds but takes at least Threshold, and logs the plan at
+ * verbose level.  Only SELECT, INSERT, UPDATE, DELETE, VALUES, and WITH
+ * queries can be explained.  If Analyze is true, SELECT and VALUES queries are
+ * explained with EXPLAIN ANALYZE, which runs them a second time, so it should
+ * only be enabled if they have no side effects that would be wrong to repeat;
+ * other queries are never analyzed.
+ *
+ * Queries whose rows are still being read, such as from Query, are not
+ * explained, as their connection is busy.  In a transaction, the query is
+ * explained within a savepoint, so that an EXPLAIN that fails does not abort
+ * the transaction.  A query that cannot be explained is logged at verbose
+ * level and otherwise ignored.
+ */
+type ExplainLogger struct {
+	DBConn    *DBConn
+	Threshold time.Duration
+	Analyze   bool
+}
+
+func (logger *ExplainLogger) BeforeQuery(ctx context.Context, event *QueryEvent) context.Context {
+	return ctx
+}
+
+func (logger *ExplainLogger) AfterQuery(ctx context.Context, event *QueryEvent) {
+	if event.Err != nil || event.Duration < logger.Threshold || ctx.Value(explainingKey{}) != nil || ctx.Value(rowsPendingKey{}) != nil {
+		return
+	}
+	keyword := firstKeyword(event.Query)
+	switch keyword {
+	case "SELECT", "INSERT", "UPDATE", "DELETE", "VALUES", "WITH":
+	default:
+		return
+	}
+	explain := "EXPLAIN "
+	if logger.Analyze && (keyword == "SELECT" || keyword == "VALUES") {
+		explain = "EXPLAIN ANALYZE "
+	}
+	plan, err := logger.explain(explain+event.Query, event)
+	if err != nil {
+		log.Verbose("Could not explain query on connection %d: %s: %v", event.ConnNum, event.Query, err)
+		return
+	}
+	log.Verbose("Plan of query on connection %d, which took %v: %s\n%s", event.ConnNum, event.Duration.Round(time.Millisecond), event.Query, strings.Join(plan, "\n"))
+}
+
+func (logger *ExplainLogger) explain(query string, event *QueryEvent) ([]string, error) {
+	dbconn, connNum := logger.DBConn, event.ConnNum
+	ctx := context.WithValue(context.Background(), explainingKey{}, true)
+	queryer := dbconn.queryer(connNum)
+	inTransaction := dbconn.Tx[connNum] != nil
+	if inTransaction {
+		if _, err := dbconn.exec(ctx, queryer, connNum, "SAVEPOINT "+explainSavepoint); err != nil {
+			return nil, err
+		}
+	}
+	plan := make([]string, 0)
+	err := dbconn.selectRows(ctx, queryer, connNum, &plan, query, event.Args...)
+	if inTransaction {
+		if err != nil {
+			_, _ = dbconn.exec(ctx, queryer, connNum, "ROLLBACK TO SAVEPOINT "+explainSavepoint)
+		}
+		_, _ = dbconn.exec(ctx, queryer, connNum, "RELEASE SAVEPOINT "+explainSavepoint)
+	}
+	return plan, err
+}
+
+// firstKeyword returns the first word of query in upper case, skipping leading whitespace, comments, and parentheses.
+func firstKeyword(query string) string {
+	for {
+		query = strings.TrimLeft(query, " \t\r\n(")
+		if strings.HasPrefix(query, "--") {
+			if end := strings.Index(query, "\n"); end != -1 {
+				query = query[end+1:]
+				continue
+			}
+			return ""
+		}
+		if strings.HasPrefix(query, "/*") {
+			if end := strings.Index(query, "*/"); end != -1 {
+				query = query[end+2:]
+				continue
+			}
+			return ""
+		}
+		break
+	}
+	end := strings.IndexFunc(query, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	})
+	if end == -1 {
+		end = len(query)
+	}
+	return strings.ToUpper(query[:end])
+}
+
+/*
+ * EnableQueryDebugging logs every subsequent query with its duration, as
+ * VerboseQueryLogger does, and the plan of any that takes at least threshold,
+ * as ExplainLogger does.  Like AddQueryHook, it should not be called while
+ * queries are running.
+ */
+func (dbconn *DBConn) EnableQueryDebugging(threshold time.Duration, analyze bool) {
+	dbconn.AddQueryHook(VerboseQueryLogger{})
+	dbconn.AddQueryHook(&ExplainLogger{DBConn: dbconn, Threshold: threshold, Analyze: analyze})
+}
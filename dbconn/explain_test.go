@@ -0,0 +1,106 @@
+package dbconn_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/explain tests", func() {
+	var logfile *gbytes.Buffer
+	planRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"QUERY PLAN"}).
+			AddRow("Gather Motion 3:1  (slice1; segments: 3)").
+			AddRow("  ->  Seq Scan on foo")
+	}
+
+	BeforeEach(func() {
+		_, _, logfile = testhelper.SetupTestLogger()
+	})
+
+	Describe("ExplainLogger", func() {
+		It("logs the plan of a query that takes at least the threshold", func() {
+			connection.AddQueryHook(&dbconn.ExplainLogger{DBConn: connection})
+			mock.ExpectExec("DELETE FROM foo").WillReturnResult(sqlmock.NewResult(0, 2))
+			mock.ExpectQuery(regexp.QuoteMeta("EXPLAIN DELETE FROM foo")).WillReturnRows(planRows())
+
+			_, err := connection.Exec("DELETE FROM foo")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+			Expect(logfile).To(gbytes.Say(`Plan of query on connection 0, which took .*: DELETE FROM foo\n.*Gather Motion 3:1  \(slice1; segments: 3\)\n  ->  Seq Scan on foo`))
+		})
+		It("does not explain queries faster than the threshold", func() {
+			connection.AddQueryHook(&dbconn.ExplainLogger{DBConn: connection, Threshold: time.Hour})
+			mock.ExpectExec("DELETE FROM foo").WillReturnResult(sqlmock.NewResult(0, 2))
+
+			_, err := connection.Exec("DELETE FROM foo")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("analyzes SELECT queries but not others if Analyze is set", func() {
+			connection.AddQueryHook(&dbconn.ExplainLogger{DBConn: connection, Analyze: true})
+			mock.ExpectQuery("SELECT i FROM foo").WillReturnRows(sqlmock.NewRows([]string{"i"}).AddRow(1))
+			mock.ExpectQuery(regexp.QuoteMeta("EXPLAIN ANALYZE /* comment */ SELECT i FROM foo")).WillReturnRows(planRows())
+			mock.ExpectExec("INSERT INTO foo").WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectQuery(regexp.QuoteMeta("EXPLAIN INSERT INTO foo")).WillReturnRows(planRows())
+
+			var i int
+			Expect(connection.Get(&i, "/* comment */ SELECT i FROM foo")).To(Succeed())
+			_, err := connection.Exec("INSERT INTO foo VALUES (1)")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("does not explain failed queries, other statements, or queries whose rows are being read", func() {
+			connection.AddQueryHook(&dbconn.ExplainLogger{DBConn: connection})
+			mock.ExpectExec("DELETE FROM foo").WillReturnError(errors.New("permission denied"))
+			mock.ExpectExec("VACUUM foo").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery("SELECT i FROM foo").WillReturnRows(sqlmock.NewRows([]string{"i"}).AddRow(1))
+
+			_, _ = connection.Exec("DELETE FROM foo")
+			_, _ = connection.Exec("VACUUM foo")
+			rows, err := connection.Query("SELECT i FROM foo")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rows.Close()).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("explains a query in a transaction within a savepoint", func() {
+			connection.AddQueryHook(&dbconn.ExplainLogger{DBConn: connection})
+			mock.ExpectBegin()
+			mock.ExpectExec("UPDATE foo").WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec("SAVEPOINT gp_explain_logger").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery("EXPLAIN UPDATE foo").WillReturnError(errors.New("cannot explain"))
+			mock.ExpectExec("ROLLBACK TO SAVEPOINT gp_explain_logger").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("RELEASE SAVEPOINT gp_explain_logger").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectCommit()
+
+			tx, err := connection.BeginTx(context.Background(), nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = tx.Exec("UPDATE foo SET i = 2")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tx.Commit()).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+			Expect(logfile).To(gbytes.Say("Could not explain query on connection 0: UPDATE foo SET i = 2: cannot explain"))
+		})
+	})
+	Describe("DBConn.EnableQueryDebugging", func() {
+		It("logs every query's duration and the plans of slow queries", func() {
+			connection.EnableQueryDebugging(0, false)
+			mock.ExpectExec("DELETE FROM foo").WillReturnResult(sqlmock.NewResult(0, 2))
+			mock.ExpectQuery("EXPLAIN DELETE FROM foo").WillReturnRows(planRows())
+
+			_, err := connection.Exec("DELETE FROM foo")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(logfile).To(gbytes.Say("Query on connection 0 took .*: DELETE FROM foo"))
+			Expect(logfile).To(gbytes.Say("Plan of query on connection 0"))
+		})
+	})
+})
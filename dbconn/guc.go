@@ -0,0 +1,298 @@
+package dbconn
+
+/*
+ * This file contains functions related to managing session-level
+ * configuration parameters (GUCs) across the connection pool.
+ */
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/pkg/errors"
+)
+
+/*
+ * SetGUC sets the given configuration parameter for the rest of the session
+ * on every connection in the pool, as SET would, so that later queries see
+ * the same setting whichever connection they run on.  set_config is used
+ * rather than SET so that the value need not be quoted by the caller.  The
+ * setting is also re-applied to any connection that is re-established after
+ * breaking; see DBConn.ReconnectPolicy.
+ */
+func (dbconn *DBConn) SetGUC(name string, value string) error {
+	/*
+	 * The setting is recorded first, so that a connection established while it
+	 * is being applied either gets it on establishment or is already in the
+	 * pool by the time the loop below checks.
+	 */
+	restore := dbconn.rememberGUC(name, value)
+	for connNum := 0; connNum < dbconn.NumConns; connNum++ {
+		// A connection not yet established gets the setting when it is.
+		if dbconn.pooled(connNum) == nil {
+			continue
+		}
+		if err := dbconn.setGUC(name, value, connNum); err != nil {
+			restore()
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * rememberGUC records a setting to be applied to connections established
+ * later, and returns a function that puts back the previous setting, for
+ * when the new one turns out to be invalid.
+ */
+func (dbconn *DBConn) rememberGUC(name string, value string) func() {
+	dbconn.gucMutex.Lock()
+	defer dbconn.gucMutex.Unlock()
+	if dbconn.ConnPool == nil {
+		return func() {}
+	}
+	if dbconn.sessionGUCs == nil {
+		dbconn.sessionGUCs = make(map[string]string)
+	}
+	oldValue, hadValue := dbconn.sessionGUCs[name]
+	dbconn.sessionGUCs[name] = value
+	return func() {
+		dbconn.gucMutex.Lock()
+		defer dbconn.gucMutex.Unlock()
+		if hadValue {
+			dbconn.sessionGUCs[name] = oldValue
+		} else {
+			delete(dbconn.sessionGUCs, name)
+		}
+	}
+}
+
+func setConfigQuery(name string, value string) string {
+	return fmt.Sprintf("SELECT pg_catalog.set_config('%s', '%s', false)", EscapeString(name), EscapeString(value))
+}
+
+func (dbconn *DBConn) setGUC(name string, value string, connNum int) error {
+	_, err := dbconn.Exec(setConfigQuery(name, value), connNum)
+	return errors.Wrapf(err, "Failed to set %s on connection %d", name, connNum)
+}
+
+// GetGUC returns the current value of the given configuration parameter on the given connection.
+func (dbconn *DBConn) GetGUC(name string, whichConn ...int) (string, error) {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	query := fmt.Sprintf("SELECT pg_catalog.current_setting('%s')", EscapeString(name))
+	value, err := SelectString(dbconn, query, connNum)
+	return value, errors.Wrapf(err, "Failed to get %s on connection %d", name, connNum)
+}
+
+/*
+ * WithGUCs sets each of the given configuration parameters on every connection
+ * in the pool, runs fn, and then restores each connection's previous values,
+ * even if fn returns an error or panics.  If a parameter cannot be set, the
+ * ones already set are restored and fn is not run.  An error from fn takes
+ * precedence over an error restoring the previous values.
+ */
+func (dbconn *DBConn) WithGUCs(gucs map[string]string, fn func() error) (err error) {
+	type previousValue struct {
+		name    string
+		value   string
+		connNum int
+	}
+	previous := make([]previousValue, 0, len(gucs)*dbconn.NumConns)
+	defer func() {
+		// Undo the changes in the reverse of the order they were made.
+		for i := len(previous) - 1; i >= 0; i-- {
+			restoreErr := dbconn.setGUC(previous[i].name, previous[i].value, previous[i].connNum)
+			if err == nil {
+				err = restoreErr
+			}
+		}
+	}()
+	names := make([]string, 0, len(gucs))
+	for name := range gucs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := gucs[name]
+		for connNum := 0; connNum < dbconn.NumConns; connNum++ {
+			oldValue, err := dbconn.GetGUC(name, connNum)
+			if err != nil {
+				return err
+			}
+			if err = dbconn.setGUC(name, value, connNum); err != nil {
+				return err
+			}
+			previous = append(previous, previousValue{name: name, value: oldValue, connNum: connNum})
+		}
+	}
+	return fn()
+}
+
+/*
+ * renamedGUCs maps the names of configuration parameters renamed in GPDB 7 to
+ * their new names, so that callers can use either name with any version.
+ */
+var renamedGUCs = map[string]string{
+	"gp_session_role":                      "gp_role",
+	"optimizer_enable_master_only_queries": "optimizer_enable_coordinator_only_queries",
+}
+
+// gucName returns the name the connected database uses for the given configuration parameter.
+func (dbconn *DBConn) gucName(name string) string {
+	if dbconn.Version.AtLeastFeatureLevel(7) {
+		if newName, ok := renamedGUCs[name]; ok {
+			return newName
+		}
+		return name
+	}
+	for oldName, newName := range renamedGUCs {
+		// gp_role also exists before GPDB 7, so it is not renamed back.
+		if name == newName && newName != "gp_role" {
+			return oldName
+		}
+	}
+	return name
+}
+
+/*
+ * ShowAll returns the current value of every configuration parameter on the
+ * given connection, as SHOW ALL reports them.  Values are formatted as SHOW
+ * formats them, with units, such as "128MB" or "1min".
+ */
+func (dbconn *DBConn) ShowAll(whichConn ...int) (map[string]string, error) {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	rows, err := dbconn.queryRows(context.Background(), dbconn.queryer(connNum), connNum, "SHOW ALL")
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to get settings on connection %d", connNum)
+	}
+	defer rows.Close()
+	settings := make(map[string]string)
+	for rows.Next() {
+		columns, err := rows.SliceScan()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to get settings on connection %d", connNum)
+		}
+		if len(columns) < 2 {
+			return nil, errors.Errorf("Failed to get settings on connection %d: expected at least 2 columns, got %d", connNum, len(columns))
+		}
+		settings[showColumnString(columns[0])] = showColumnString(columns[1])
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrapf(err, "Failed to get settings on connection %d", connNum)
+	}
+	return settings, nil
+}
+
+func showColumnString(column interface{}) string {
+	switch column := column.(type) {
+	case []byte:
+		return string(column)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(column)
+	}
+}
+
+/*
+ * Show is like GetGUC, but accepts the name a configuration parameter has in
+ * any version, such as gp_session_role or gp_role, and uses the name that the
+ * connected database recognizes.
+ */
+func (dbconn *DBConn) Show(name string, whichConn ...int) (string, error) {
+	return dbconn.GetGUC(dbconn.gucName(name), whichConn...)
+}
+
+func (dbconn *DBConn) MustShow(name string, whichConn ...int) string {
+	value, err := dbconn.Show(name, whichConn...)
+	gplog.FatalOnError(err)
+	return value
+}
+
+/*
+ * ShowBytes and ShowDuration return the value of a memory or time parameter,
+ * such as work_mem or statement_timeout, converted from the units SHOW uses.
+ * A value without units, such as the -1 or 0 some parameters use to mean
+ * "disabled", is returned as that many bytes or milliseconds.
+ */
+func (dbconn *DBConn) ShowBytes(name string, whichConn ...int) (int64, error) {
+	value, err := dbconn.Show(name, whichConn...)
+	if err != nil {
+		return 0, err
+	}
+	return ParseGUCBytes(value)
+}
+
+func (dbconn *DBConn) ShowDuration(name string, whichConn ...int) (time.Duration, error) {
+	value, err := dbconn.Show(name, whichConn...)
+	if err != nil {
+		return 0, err
+	}
+	return ParseGUCDuration(value)
+}
+
+var gucByteUnits = map[string]int64{
+	"":   1,
+	"B":  1,
+	"kB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+	"TB": 1 << 40,
+}
+
+var gucTimeUnits = map[string]time.Duration{
+	"":    time.Millisecond,
+	"us":  time.Microsecond,
+	"ms":  time.Millisecond,
+	"s":   time.Second,
+	"min": time.Minute,
+	"h":   time.Hour,
+	"d":   24 * time.Hour,
+}
+
+// splitGUCValue splits a value such as "128MB" into its number and its unit.
+func splitGUCValue(value string) (int64, string, error) {
+	value = strings.TrimSpace(value)
+	end := strings.IndexFunc(value, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '-'
+	})
+	if end == -1 {
+		end = len(value)
+	}
+	number, err := strconv.ParseInt(value[:end], 10, 64)
+	if err != nil {
+		return 0, "", errors.Errorf("Invalid setting %q: expected a number followed by an optional unit", value)
+	}
+	return number, strings.TrimSpace(value[end:]), nil
+}
+
+// ParseGUCBytes converts a memory setting as formatted by SHOW, such as "128MB", to a number of bytes.
+func ParseGUCBytes(value string) (int64, error) {
+	number, unit, err := splitGUCValue(value)
+	if err != nil {
+		return 0, err
+	}
+	multiplier, ok := gucByteUnits[unit]
+	if !ok {
+		return 0, errors.Errorf("Invalid setting %q: unknown memory unit %q", value, unit)
+	}
+	return number * multiplier, nil
+}
+
+// ParseGUCDuration converts a time setting as formatted by SHOW, such as "1min", to a time.Duration.
+func ParseGUCDuration(value string) (time.Duration, error) {
+	number, unit, err := splitGUCValue(value)
+	if err != nil {
+		return 0, err
+	}
+	multiplier, ok := gucTimeUnits[unit]
+	if !ok {
+		return 0, errors.Errorf("Invalid setting %q: unknown time unit %q", value, unit)
+	}
+	return time.Duration(number) * multiplier, nil
+}
@@ -0,0 +1,181 @@
+package dbconn_test
+
+import (
+	"errors"
+	"regexp"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/guc tests", func() {
+	fakeResult := testhelper.TestResult{Rows: 1}
+	expectSet := func(name string, value string) *sqlmock.ExpectedExec {
+		return mock.ExpectExec(regexp.QuoteMeta("SELECT pg_catalog.set_config('" + name + "', '" + value + "', false)"))
+	}
+	expectGet := func(name string) *sqlmock.ExpectedQuery {
+		return mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_catalog.current_setting('" + name + "')"))
+	}
+	settingRow := func(value string) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"current_setting"}).AddRow(value)
+	}
+	BeforeEach(func() {
+		connection, mock = testhelper.CreateAndConnectMockDB(2)
+	})
+	Describe("DBConn.SetGUC", func() {
+		It("sets the parameter on every connection", func() {
+			expectSet("search_path", "my''schema, public").WillReturnResult(fakeResult)
+			expectSet("search_path", "my''schema, public").WillReturnResult(fakeResult)
+
+			Expect(connection.SetGUC("search_path", "my'schema, public")).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns an error if the parameter cannot be set", func() {
+			expectSet("work_mem", "lots").WillReturnError(errors.New(`invalid value for parameter "work_mem"`))
+
+			Expect(connection.SetGUC("work_mem", "lots")).To(MatchError(`Failed to set work_mem on connection 0: invalid value for parameter "work_mem"`))
+		})
+	})
+	Describe("DBConn.GetGUC", func() {
+		It("returns the current value on the given connection", func() {
+			expectGet("work_mem").WillReturnRows(settingRow("64MB"))
+
+			value, err := connection.GetGUC("work_mem", 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal("64MB"))
+		})
+		It("returns an error for an unknown parameter", func() {
+			expectGet("no_such_guc").WillReturnError(errors.New(`unrecognized configuration parameter "no_such_guc"`))
+
+			_, err := connection.GetGUC("no_such_guc")
+			Expect(err).To(MatchError(`Failed to get no_such_guc on connection 0: unrecognized configuration parameter "no_such_guc"`))
+		})
+	})
+	Describe("DBConn.WithGUCs", func() {
+		It("sets the parameters, runs the function, and restores the previous values", func() {
+			expectGet("work_mem").WillReturnRows(settingRow("32MB"))
+			expectSet("work_mem", "1GB").WillReturnResult(fakeResult)
+			expectGet("work_mem").WillReturnRows(settingRow("64MB"))
+			expectSet("work_mem", "1GB").WillReturnResult(fakeResult)
+			mock.ExpectExec("ANALYZE").WillReturnResult(fakeResult)
+			expectSet("work_mem", "64MB").WillReturnResult(fakeResult)
+			expectSet("work_mem", "32MB").WillReturnResult(fakeResult)
+
+			err := connection.WithGUCs(map[string]string{"work_mem": "1GB"}, func() error {
+				_, err := connection.Exec("ANALYZE")
+				return err
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("restores the previous values and returns the error if the function fails", func() {
+			connection, mock = testhelper.CreateAndConnectMockDB(1)
+			expectGet("work_mem").WillReturnRows(settingRow("32MB"))
+			expectSet("work_mem", "1GB").WillReturnResult(fakeResult)
+			expectSet("work_mem", "32MB").WillReturnResult(fakeResult)
+			fnErr := errors.New("analyze failed")
+
+			Expect(connection.WithGUCs(map[string]string{"work_mem": "1GB"}, func() error { return fnErr })).To(Equal(fnErr))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("restores the parameters already set and does not run the function if one cannot be set", func() {
+			connection, mock = testhelper.CreateAndConnectMockDB(1)
+			expectGet("search_path").WillReturnRows(settingRow("public"))
+			expectSet("search_path", "myschema").WillReturnResult(fakeResult)
+			expectGet("work_mem").WillReturnRows(settingRow("32MB"))
+			expectSet("work_mem", "lots").WillReturnError(errors.New("invalid value"))
+			expectSet("search_path", "public").WillReturnResult(fakeResult)
+
+			ran := false
+			err := connection.WithGUCs(map[string]string{"work_mem": "lots", "search_path": "myschema"}, func() error {
+				ran = true
+				return nil
+			})
+			Expect(err).To(MatchError("Failed to set work_mem on connection 0: invalid value"))
+			Expect(ran).To(BeFalse())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+	Describe("DBConn.ShowAll", func() {
+		It("returns every setting", func() {
+			rows := sqlmock.NewRows([]string{"name", "setting", "description"}).
+				AddRow("work_mem", "32MB", "Sets the maximum memory to be used for query workspaces.").
+				AddRow("statement_timeout", "0", "Sets the maximum allowed duration of any statement.")
+			mock.ExpectQuery("SHOW ALL").WillReturnRows(rows)
+
+			settings, err := connection.ShowAll(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(settings).To(Equal(map[string]string{"work_mem": "32MB", "statement_timeout": "0"}))
+		})
+		It("returns an error if the query fails", func() {
+			mock.ExpectQuery("SHOW ALL").WillReturnError(errors.New("connection reset"))
+
+			_, err := connection.ShowAll()
+			Expect(err).To(MatchError("Failed to get settings on connection 0: connection reset"))
+		})
+	})
+	Describe("DBConn.Show", func() {
+		It("uses the old name of a renamed parameter before GPDB 7", func() {
+			expectGet("optimizer_enable_master_only_queries").WillReturnRows(settingRow("off"))
+
+			Expect(connection.Show("optimizer_enable_coordinator_only_queries")).To(Equal("off"))
+		})
+		It("uses the new name of a renamed parameter on GPDB 7", func() {
+			testhelper.SetDBVersion(connection, "7.0.0")
+			expectGet("gp_role").WillReturnRows(settingRow("utility"))
+
+			Expect(connection.Show("gp_session_role")).To(Equal("utility"))
+		})
+		It("does not rename gp_role before GPDB 7", func() {
+			expectGet("gp_role").WillReturnRows(settingRow("dispatch"))
+
+			Expect(connection.Show("gp_role")).To(Equal("dispatch"))
+		})
+		It("panics in MustShow if the parameter cannot be retrieved", func() {
+			expectGet("no_such_guc").WillReturnError(errors.New(`unrecognized configuration parameter "no_such_guc"`))
+
+			defer testhelper.ShouldPanicWithMessage(`Failed to get no_such_guc on connection 0: unrecognized configuration parameter "no_such_guc"`)
+			connection.MustShow("no_such_guc")
+		})
+	})
+	Describe("DBConn.ShowBytes and DBConn.ShowDuration", func() {
+		It("converts a memory setting to bytes", func() {
+			expectGet("work_mem").WillReturnRows(settingRow("32MB"))
+
+			Expect(connection.ShowBytes("work_mem")).To(Equal(int64(32 << 20)))
+		})
+		It("converts a time setting to a duration", func() {
+			expectGet("statement_timeout").WillReturnRows(settingRow("5min"))
+
+			Expect(connection.ShowDuration("statement_timeout")).To(Equal(5 * time.Minute))
+		})
+	})
+	DescribeTable("ParseGUCBytes", func(value string, expected int64) {
+		Expect(dbconn.ParseGUCBytes(value)).To(Equal(expected))
+	},
+		Entry("kilobytes", "8kB", int64(8192)),
+		Entry("gigabytes", "2GB", int64(2<<30)),
+		Entry("no unit", "-1", int64(-1)),
+	)
+	DescribeTable("ParseGUCDuration", func(value string, expected time.Duration) {
+		Expect(dbconn.ParseGUCDuration(value)).To(Equal(expected))
+	},
+		Entry("milliseconds", "500ms", 500*time.Millisecond),
+		Entry("seconds", "30s", 30*time.Second),
+		Entry("days", "1d", 24*time.Hour),
+		Entry("no unit", "0", time.Duration(0)),
+	)
+	It("rejects settings that cannot be converted", func() {
+		_, err := dbconn.ParseGUCBytes("lots")
+		Expect(err).To(MatchError(`Invalid setting "lots": expected a number followed by an optional unit`))
+		_, err = dbconn.ParseGUCBytes("5min")
+		Expect(err).To(MatchError(`Invalid setting "5min": unknown memory unit "min"`))
+		_, err = dbconn.ParseGUCDuration("5MB")
+		Expect(err).To(MatchError(`Invalid setting "5MB": unknown time unit "MB"`))
+	})
+})
@@ -0,0 +1,107 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to addressing a single
+ * pooled connection through a handle instead of a connection number.
+ */
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/*
+ * A ConnHandle refers to a single connection in a DBConn's pool.  Unlike the
+ * DBConn wrapper functions, which take an optional trailing connection number
+ * and (for the *WithArgs variants) only support connection 0, every ConnHandle
+ * function accepts query arguments and always runs on the same connection, so
+ * a worker goroutine can be handed one handle instead of an integer.
+ *
+ * As with the DBConn wrapper functions, queries run as part of the connection's
+ * transaction if one is in progress, and go through the same query hooks,
+ * default timeout, and ReconnectPolicy.
+ */
+type ConnHandle struct {
+	dbconn  *DBConn
+	connNum int
+}
+
+/*
+ * Conn returns a handle to the given pooled connection.  As with
+ * ValidateConnNum, an invalid connection number is considered programmer error
+ * and causes a Fatal error.
+ */
+func (dbconn *DBConn) Conn(connNum int) *ConnHandle {
+	return &ConnHandle{dbconn: dbconn, connNum: dbconn.ValidateConnNum(connNum)}
+}
+
+func (handle *ConnHandle) ConnNum() int {
+	return handle.connNum
+}
+
+func (handle *ConnHandle) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return handle.dbconn.exec(context.Background(), handle.dbconn.queryer(handle.connNum), handle.connNum, query, args...)
+}
+
+func (handle *ConnHandle) Get(destination interface{}, query string, args ...interface{}) error {
+	return handle.dbconn.get(context.Background(), handle.dbconn.queryer(handle.connNum), handle.connNum, destination, query, args...)
+}
+
+func (handle *ConnHandle) Select(destination interface{}, query string, args ...interface{}) error {
+	return handle.dbconn.selectRows(context.Background(), handle.dbconn.queryer(handle.connNum), handle.connNum, destination, query, args...)
+}
+
+func (handle *ConnHandle) Query(query string, args ...interface{}) (*sqlx.Rows, error) {
+	return handle.dbconn.query(context.Background(), handle.dbconn.queryer(handle.connNum), handle.connNum, query, args...)
+}
+
+/*
+ * The *Context variants are like the functions above, but run the query under
+ * the given context, which bounds it along with any DefaultQueryTimeout.
+ */
+func (handle *ConnHandle) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return handle.dbconn.exec(ctx, handle.dbconn.queryer(handle.connNum), handle.connNum, query, args...)
+}
+
+func (handle *ConnHandle) GetContext(ctx context.Context, destination interface{}, query string, args ...interface{}) error {
+	return handle.dbconn.get(ctx, handle.dbconn.queryer(handle.connNum), handle.connNum, destination, query, args...)
+}
+
+func (handle *ConnHandle) SelectContext(ctx context.Context, destination interface{}, query string, args ...interface{}) error {
+	return handle.dbconn.selectRows(ctx, handle.dbconn.queryer(handle.connNum), handle.connNum, destination, query, args...)
+}
+
+func (handle *ConnHandle) QueryContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	return handle.dbconn.query(ctx, handle.dbconn.queryer(handle.connNum), handle.connNum, query, args...)
+}
+
+func (handle *ConnHandle) Begin() error {
+	return handle.dbconn.Begin(handle.connNum)
+}
+
+func (handle *ConnHandle) Commit() error {
+	return handle.dbconn.Commit(handle.connNum)
+}
+
+func (handle *ConnHandle) Rollback() error {
+	return handle.dbconn.Rollback(handle.connNum)
+}
+
+/*
+ * Handles returns a handle for each connection in the pool, in order.  It
+ * returns an error rather than an empty slice if the DBConn is not connected,
+ * as that almost always indicates a missing call to Connect.
+ */
+func (dbconn *DBConn) Handles() ([]*ConnHandle, error) {
+	if dbconn.NumConns == 0 {
+		return nil, errors.New("Cannot get connection handles; the database connection is not open")
+	}
+	handles := make([]*ConnHandle, dbconn.NumConns)
+	for i := range handles {
+		handles[i] = &ConnHandle{dbconn: dbconn, connNum: i}
+	}
+	return handles, nil
+}
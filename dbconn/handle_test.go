@@ -0,0 +1,86 @@
+package dbconn_test
+
+import (
+	"context"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/handle tests", func() {
+	Describe("DBConn.ConnectWithOptions", func() {
+		It("connects with the given pool size", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			testhelper.ExpectVersionQuery(mock, "6.0.0")
+			err := connection.ConnectWithOptions(dbconn.ConnectOptions{NumConns: 2})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(connection.NumConns).To(Equal(2))
+		})
+		It("rejects an invalid pool size", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			err := connection.ConnectWithOptions(dbconn.ConnectOptions{})
+			Expect(err).To(MatchError("Must specify a connection pool size that is a positive integer"))
+		})
+		It("rejects more than one utility mode argument to Connect", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			err := connection.Connect(1, true, true)
+			Expect(err).To(MatchError("The utility mode parameter accepts exactly one boolean value"))
+		})
+	})
+	Describe("DBConn.Conn", func() {
+		It("runs queries with arguments on the given connection", func() {
+			connection, mock = testhelper.CreateAndConnectMockDB(2)
+			mock.ExpectQuery("SELECT (.*)").WithArgs("public").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("foo"))
+
+			handle := connection.Conn(1)
+			var names []string
+			err := handle.Select(&names, "SELECT name FROM tables WHERE schema = $1", "public")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(names).To(Equal([]string{"foo"}))
+			Expect(handle.ConnNum()).To(Equal(1))
+		})
+		It("runs queries in the connection's transaction", func() {
+			ExpectBegin(mock)
+			mock.ExpectExec("INSERT (.*)").WithArgs(1).WillReturnResult(testhelper.TestResult{Rows: 1})
+			mock.ExpectCommit()
+
+			handle := connection.Conn(0)
+			Expect(handle.Begin()).To(Succeed())
+			_, err := handle.Exec("INSERT INTO foo VALUES ($1)", 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(handle.Commit()).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("runs queries under the given context", func() {
+			handle := connection.Conn(0)
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			var i int
+			err := handle.GetContext(ctx, &i, "SELECT 1")
+			Expect(err).To(MatchError(context.Canceled))
+		})
+		It("panics on an invalid connection number", func() {
+			defer testhelper.ShouldPanicWithMessage("Invalid connection number: 3")
+			connection.Conn(3)
+		})
+	})
+	Describe("DBConn.Handles", func() {
+		It("returns one handle per connection", func() {
+			connection, mock = testhelper.CreateAndConnectMockDB(3)
+			handles, err := connection.Handles()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(handles).To(HaveLen(3))
+			Expect(handles[2].ConnNum()).To(Equal(2))
+		})
+		It("returns an error if the connection is not open", func() {
+			connection.Close()
+			_, err := connection.Handles()
+			Expect(err).To(MatchError("Cannot get connection handles; the database connection is not open"))
+		})
+	})
+})
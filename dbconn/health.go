@@ -0,0 +1,122 @@
+package dbconn
+
+/*
+ * This file contains structs and functions for summarizing the health of a
+ * cluster as recorded by the fault tolerance service (FTS) in
+ * gp_segment_configuration: which segments it has marked down, which
+ * primaries are not in sync with their mirrors, and which segments are not
+ * acting in their preferred role because of a failover.
+ *
+ * Modes differ by version.  In GPDB 5 a primary whose mirror is being
+ * resynchronized has mode "r" and one whose mirror is down has mode "c", for
+ * change tracking; from GPDB 6 onward both are reported as "n", not in sync.
+ * A synchronized pair has mode "s" on every version.
+ */
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A SegmentHealth is the FTS status of one coordinator or segment instance.
+type SegmentHealth struct {
+	DbID          int    `db:"dbid"`
+	ContentID     int    `db:"content"`
+	Role          string `db:"role"`
+	PreferredRole string `db:"preferred_role"`
+	Mode          string `db:"mode"`
+	Status        string `db:"status"`
+	Hostname      string `db:"hostname"`
+	Port          int    `db:"port"`
+	Mirrored      bool   `db:"mirrored"`
+}
+
+func (segment SegmentHealth) IsDown() bool {
+	return segment.Status == "d"
+}
+
+// IsInSync reports whether a mirrored primary is synchronized with its mirror; it is always true for an unmirrored one.
+func (segment SegmentHealth) IsInSync() bool {
+	return !segment.Mirrored || segment.Mode == "s"
+}
+
+func (segment SegmentHealth) IsRoleMismatched() bool {
+	return segment.Role != segment.PreferredRole
+}
+
+/*
+ * ClusterHealth summarizes the problems with a cluster.  Segments holds every
+ * instance, ordered by content ID with primaries first, and the other slices
+ * hold the instances that are down, the mirrored primaries whose mirrors are
+ * not in sync, and the instances not in their preferred role.  The coordinator
+ * and standby, with content ID -1, are included.
+ */
+type ClusterHealth struct {
+	Segments       []SegmentHealth
+	Down           []SegmentHealth
+	NotInSync      []SegmentHealth
+	RoleMismatches []SegmentHealth
+}
+
+func (health ClusterHealth) IsHealthy() bool {
+	return len(health.Down) == 0 && len(health.NotInSync) == 0 && len(health.RoleMismatches) == 0
+}
+
+func (health ClusterHealth) String() string {
+	if health.IsHealthy() {
+		return fmt.Sprintf("All %d segments are up, in sync, and in their preferred roles", len(health.Segments))
+	}
+	problems := make([]string, 0)
+	if len(health.Down) > 0 {
+		problems = append(problems, fmt.Sprintf("%d down", len(health.Down)))
+	}
+	if len(health.NotInSync) > 0 {
+		problems = append(problems, fmt.Sprintf("%d not in sync", len(health.NotInSync)))
+	}
+	if len(health.RoleMismatches) > 0 {
+		problems = append(problems, fmt.Sprintf("%d not in their preferred role", len(health.RoleMismatches)))
+	}
+	return fmt.Sprintf("Of %d segments, %s", len(health.Segments), strings.Join(problems, ", "))
+}
+
+/*
+ * GetClusterHealth reads gp_segment_configuration and summarizes the health of
+ * the cluster.  It returns an error for a database that is not Greenplum or
+ * Cloudberry, which has no segments.
+ */
+func (dbconn *DBConn) GetClusterHealth(whichConn ...int) (ClusterHealth, error) {
+	if dbconn.Version.EffectiveGPDBMajor() == 0 {
+		return ClusterHealth{}, errors.Errorf("Cannot check the health of segments of %s", dbconn.Version.Type)
+	}
+	query := `
+	SELECT s.dbid,
+		s.content,
+		s.role::text AS role,
+		s.preferred_role::text AS preferred_role,
+		s.mode::text AS mode,
+		s.status::text AS status,
+		s.hostname,
+		s.port,
+		EXISTS (SELECT 1 FROM pg_catalog.gp_segment_configuration m WHERE m.content = s.content AND m.dbid <> s.dbid) AS mirrored
+	FROM pg_catalog.gp_segment_configuration s
+	ORDER BY s.content, s.role DESC`
+	segments := make([]SegmentHealth, 0)
+	if err := dbconn.Select(&segments, query, whichConn...); err != nil {
+		return ClusterHealth{}, errors.Wrap(err, "Failed to get cluster health")
+	}
+	health := ClusterHealth{Segments: segments}
+	for _, segment := range segments {
+		if segment.IsDown() {
+			health.Down = append(health.Down, segment)
+		}
+		if segment.ContentID >= 0 && segment.Role == "p" && !segment.IsInSync() {
+			health.NotInSync = append(health.NotInSync, segment)
+		}
+		if segment.IsRoleMismatched() {
+			health.RoleMismatches = append(health.RoleMismatches, segment)
+		}
+	}
+	return health, nil
+}
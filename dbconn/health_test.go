@@ -0,0 +1,78 @@
+package dbconn_test
+
+import (
+	"errors"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/health tests", func() {
+	healthColumns := []string{"dbid", "content", "role", "preferred_role", "mode", "status", "hostname", "port", "mirrored"}
+
+	Describe("DBConn.GetClusterHealth", func() {
+		It("reports a healthy cluster", func() {
+			rows := sqlmock.NewRows(healthColumns).
+				AddRow(1, -1, "p", "p", "s", "u", "cdw", 5432, true).
+				AddRow(6, -1, "m", "m", "s", "u", "scdw", 5432, true).
+				AddRow(2, 0, "p", "p", "s", "u", "sdw1", 6000, true).
+				AddRow(4, 0, "m", "m", "s", "u", "sdw2", 7000, true).
+				AddRow(3, 1, "p", "p", "n", "u", "sdw2", 6000, false)
+			mock.ExpectQuery("FROM pg_catalog.gp_segment_configuration s").WillReturnRows(rows)
+
+			health, err := connection.GetClusterHealth()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(health.Segments).To(HaveLen(5))
+			Expect(health.IsHealthy()).To(BeTrue())
+			Expect(health.String()).To(Equal("All 5 segments are up, in sync, and in their preferred roles"))
+		})
+		It("reports segments that are down, not in sync, or not in their preferred role", func() {
+			testhelper.SetDBVersion(connection, "6.0.0")
+			rows := sqlmock.NewRows(healthColumns).
+				AddRow(1, -1, "p", "p", "n", "u", "cdw", 5432, false).
+				AddRow(2, 0, "p", "m", "n", "u", "sdw2", 7000, true).
+				AddRow(4, 0, "m", "p", "n", "d", "sdw1", 6000, true).
+				AddRow(3, 1, "p", "p", "s", "u", "sdw2", 6000, true).
+				AddRow(5, 1, "m", "m", "s", "u", "sdw1", 7000, true)
+			mock.ExpectQuery("gp_segment_configuration").WillReturnRows(rows)
+
+			health, err := connection.GetClusterHealth()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(health.IsHealthy()).To(BeFalse())
+			Expect(health.Down).To(Equal([]dbconn.SegmentHealth{
+				{DbID: 4, ContentID: 0, Role: "m", PreferredRole: "p", Mode: "n", Status: "d", Hostname: "sdw1", Port: 6000, Mirrored: true},
+			}))
+			Expect(health.NotInSync).To(HaveLen(1))
+			Expect(health.NotInSync[0].DbID).To(Equal(2))
+			Expect(health.RoleMismatches).To(HaveLen(2))
+			Expect(health.String()).To(Equal("Of 5 segments, 1 down, 1 not in sync, 2 not in their preferred role"))
+		})
+		It("treats a GPDB 5 primary in change tracking as not in sync", func() {
+			rows := sqlmock.NewRows(healthColumns).
+				AddRow(2, 0, "p", "p", "c", "u", "sdw1", 6000, true).
+				AddRow(4, 0, "m", "m", "s", "d", "sdw2", 7000, true)
+			mock.ExpectQuery("gp_segment_configuration").WillReturnRows(rows)
+
+			health, err := connection.GetClusterHealth()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(health.NotInSync).To(HaveLen(1))
+			Expect(health.Down).To(HaveLen(1))
+		})
+		It("returns an error for a database without segments", func() {
+			connection.Version = dbconn.GPDBVersion{Type: dbconn.Unknown}
+
+			_, err := connection.GetClusterHealth()
+			Expect(err).To(MatchError("Cannot check the health of segments of Unknown Database"))
+		})
+		It("returns an error if the query fails", func() {
+			mock.ExpectQuery("gp_segment_configuration").WillReturnError(errors.New("connection reset"))
+
+			_, err := connection.GetClusterHealth()
+			Expect(err).To(MatchError("Failed to get cluster health: connection reset"))
+		})
+	})
+})
@@ -0,0 +1,133 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to observing the queries
+ * run through a DBConn, such as for logging, auditing, tracing, or metrics.
+ */
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"time"
+)
+
+/*
+ * A QueryEvent describes one query run through a DBConn.  Args holds any
+ * bind arguments, and ConnNum the connection the query ran on.  Duration and
+ * Err are set once the query finishes; for functions that return rows to the
+ * caller, such as Query, the query finishes when the first rows are ready,
+ * not when the caller has read them all.  RowsAffected is the number of rows
+ * changed by an Exec or scanned by a Get or Select, or -1 if unknown.
+ */
+type QueryEvent struct {
+	Query        string
+	Args         []interface{}
+	ConnNum      int
+	Start        time.Time
+	Duration     time.Duration
+	RowsAffected int64
+	Err          error
+}
+
+/*
+ * A QueryHook is called before and after every query run through a DBConn or
+ * a Tx started from it, in the goroutine running the query.  BeforeQuery
+ * returns the context in which to run the query and call AfterQuery, so that
+ * a hook can pass state such as a tracing span from one call to the other.
+ * For functions that do not take a context, the hooks are passed
+ * context.Background().  Hooks run in the order they were added, and must be
+ * safe for concurrent use if queries run on several connections at once.
+ */
+type QueryHook interface {
+	BeforeQuery(ctx context.Context, event *QueryEvent) context.Context
+	AfterQuery(ctx context.Context, event *QueryEvent)
+}
+
+/*
+ * QueryHookFuncs adapts a pair of functions to a QueryHook, for hooks that only
+ * need to act before or after each query; either function may be nil.
+ */
+type QueryHookFuncs struct {
+	Before func(ctx context.Context, event *QueryEvent) context.Context
+	After  func(ctx context.Context, event *QueryEvent)
+}
+
+func (hook QueryHookFuncs) BeforeQuery(ctx context.Context, event *QueryEvent) context.Context {
+	if hook.Before == nil {
+		return ctx
+	}
+	return hook.Before(ctx, event)
+}
+
+func (hook QueryHookFuncs) AfterQuery(ctx context.Context, event *QueryEvent) {
+	if hook.After != nil {
+		hook.After(ctx, event)
+	}
+}
+
+// VerboseQueryLogger is a QueryHook that logs each query, its duration, and any error at verbose level.
+type VerboseQueryLogger struct{}
+
+func (logger VerboseQueryLogger) BeforeQuery(ctx context.Context, event *QueryEvent) context.Context {
+	return ctx
+}
+
+func (logger VerboseQueryLogger) AfterQuery(ctx context.Context, event *QueryEvent) {
+	if event.Err != nil {
+		log.Verbose("Query on connection %d failed after %v: %s: %v", event.ConnNum, event.Duration, event.Query, event.Err)
+	} else {
+		log.Verbose("Query on connection %d took %v: %s", event.ConnNum, event.Duration, event.Query)
+	}
+}
+
+// AddQueryHook adds a hook to be called for every subsequent query; it should not be called while queries are running.
+func (dbconn *DBConn) AddQueryHook(hook QueryHook) {
+	dbconn.QueryHooks = append(dbconn.QueryHooks, hook)
+}
+
+/*
+ * runQuery runs a query through the hooks.  The run function is passed the
+ * context returned by the hooks and returns the number of rows affected, or
+ * -1 if that is unknown.
+ */
+func (dbconn *DBConn) runQuery(ctx context.Context, query string, args []interface{}, connNum int, run func(ctx context.Context) (int64, error)) error {
+	if len(dbconn.QueryHooks) == 0 && dbconn.SlowQueryThreshold <= 0 {
+		_, err := run(ctx)
+		return err
+	}
+	event := &QueryEvent{Query: query, Args: args, ConnNum: connNum, RowsAffected: -1}
+	for _, hook := range dbconn.QueryHooks {
+		ctx = hook.BeforeQuery(ctx, event)
+	}
+	event.Start = time.Now()
+	event.RowsAffected, event.Err = run(ctx)
+	event.Duration = time.Since(event.Start)
+	if dbconn.SlowQueryThreshold > 0 && event.Duration >= dbconn.SlowQueryThreshold {
+		log.Warn("Slow query on connection %d took %v: %s", connNum, event.Duration.Round(time.Millisecond), query)
+	}
+	for _, hook := range dbconn.QueryHooks {
+		hook.AfterQuery(ctx, event)
+	}
+	return event.Err
+}
+
+func resultRowsAffected(result sql.Result) int64 {
+	if result == nil {
+		return -1
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return -1
+	}
+	return rowsAffected
+}
+
+// destinationRows returns the number of rows Select scanned into the given slice pointer.
+func destinationRows(destination interface{}) int64 {
+	value := reflect.ValueOf(destination)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Slice {
+		return -1
+	}
+	return int64(value.Elem().Len())
+}
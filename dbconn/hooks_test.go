@@ -0,0 +1,179 @@
+package dbconn_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type contextKey string
+
+/*
+ * recordingHook records each event it sees, and checks that the context it
+ * returns from BeforeQuery is the one passed to AfterQuery.
+ */
+type recordingHook struct {
+	events     []dbconn.QueryEvent
+	contextErr error
+}
+
+func (hook *recordingHook) BeforeQuery(ctx context.Context, event *dbconn.QueryEvent) context.Context {
+	return context.WithValue(ctx, contextKey("query"), event.Query)
+}
+
+func (hook *recordingHook) AfterQuery(ctx context.Context, event *dbconn.QueryEvent) {
+	if ctx.Value(contextKey("query")) != event.Query {
+		hook.contextErr = errors.New("AfterQuery was not passed the context returned by BeforeQuery")
+	}
+	hook.events = append(hook.events, *event)
+}
+
+var _ = Describe("dbconn/hooks tests", func() {
+	var hook *recordingHook
+	BeforeEach(func() {
+		connection, mock = testhelper.CreateAndConnectMockDB(2)
+		hook = &recordingHook{}
+		connection.AddQueryHook(hook)
+	})
+	AfterEach(func() {
+		Expect(hook.contextErr).ToNot(HaveOccurred())
+	})
+	It("reports the rows affected by an Exec", func() {
+		mock.ExpectExec("DELETE FROM foo").WillReturnResult(testhelper.TestResult{Rows: 3})
+
+		connection.MustExec("DELETE FROM foo", 1)
+		Expect(hook.events).To(HaveLen(1))
+		Expect(hook.events[0].Query).To(Equal("DELETE FROM foo"))
+		Expect(hook.events[0].ConnNum).To(Equal(1))
+		Expect(hook.events[0].RowsAffected).To(Equal(int64(3)))
+		Expect(hook.events[0].Err).ToNot(HaveOccurred())
+		Expect(hook.events[0].Start).ToNot(BeZero())
+	})
+	It("reports the arguments and rows scanned by a Select", func() {
+		mock.ExpectQuery("SELECT relname").WithArgs("public").WillReturnRows(sqlmock.NewRows([]string{"relname"}).AddRow("foo").AddRow("bar"))
+
+		names := make([]string, 0)
+		Expect(connection.SelectWithArgs(&names, "SELECT relname FROM pg_class WHERE nspname = $1", "public")).To(Succeed())
+		Expect(hook.events[0].Args).To(Equal([]interface{}{"public"}))
+		Expect(hook.events[0].RowsAffected).To(Equal(int64(2)))
+	})
+	It("reports queries run through a ConnHandle", func() {
+		mock.ExpectExec("DELETE FROM foo").WithArgs(1).WillReturnResult(testhelper.TestResult{Rows: 1})
+		mock.ExpectQuery("SELECT relname").WillReturnRows(sqlmock.NewRows([]string{"relname"}).AddRow("foo"))
+
+		handle := connection.Conn(1)
+		_, err := handle.Exec("DELETE FROM foo WHERE id = $1", 1)
+		Expect(err).ToNot(HaveOccurred())
+		var name string
+		Expect(handle.Get(&name, "SELECT relname FROM pg_class")).To(Succeed())
+		Expect(hook.events).To(HaveLen(2))
+		Expect(hook.events[0].Query).To(Equal("DELETE FROM foo WHERE id = $1"))
+		Expect(hook.events[0].ConnNum).To(Equal(1))
+		Expect(hook.events[0].RowsAffected).To(Equal(int64(1)))
+		Expect(hook.events[1].Query).To(Equal("SELECT relname FROM pg_class"))
+	})
+	It("reports errors", func() {
+		mock.ExpectQuery("SELECT 1").WillReturnError(errors.New("connection reset"))
+
+		var result int
+		Expect(connection.Get(&result, "SELECT 1")).ToNot(Succeed())
+		Expect(hook.events[0].Err).To(MatchError("connection reset"))
+	})
+	It("does not know how many rows a Query will return", func() {
+		mock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow(1))
+
+		rows, err := connection.Query("SELECT 1")
+		Expect(err).ToNot(HaveOccurred())
+		rows.Close()
+		Expect(hook.events[0].RowsAffected).To(Equal(int64(-1)))
+	})
+	It("passes the caller's context to the hooks", func() {
+		mock.ExpectExec("SELECT 1").WillReturnResult(testhelper.TestResult{})
+		var traceID interface{}
+		connection.AddQueryHook(dbconn.QueryHookFuncs{Before: func(ctx context.Context, event *dbconn.QueryEvent) context.Context {
+			traceID = ctx.Value(contextKey("trace"))
+			return ctx
+		}})
+
+		_, err := connection.ExecContext(context.WithValue(context.Background(), contextKey("trace"), "abc123"), "SELECT 1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(traceID).To(Equal("abc123"))
+	})
+	It("reports queries run in a Tx", func() {
+		mock.ExpectBegin()
+		mock.ExpectExec(`SAVEPOINT "sp"`).WillReturnResult(testhelper.TestResult{})
+		mock.ExpectCommit()
+
+		Expect(connection.RunInTransaction(func(tx *dbconn.Tx) error { return tx.Savepoint("sp") }, 1)).To(Succeed())
+		Expect(hook.events).To(HaveLen(1))
+		Expect(hook.events[0].Query).To(Equal(`SAVEPOINT "sp"`))
+		Expect(hook.events[0].ConnNum).To(Equal(1))
+	})
+	It("calls each hook in order", func() {
+		mock.ExpectExec("SELECT 1").WillReturnResult(testhelper.TestResult{})
+		order := make([]string, 0)
+		for _, name := range []string{"first", "second"} {
+			name := name
+			connection.AddQueryHook(dbconn.QueryHookFuncs{
+				Before: func(ctx context.Context, event *dbconn.QueryEvent) context.Context {
+					order = append(order, "before "+name)
+					return ctx
+				},
+				After: func(ctx context.Context, event *dbconn.QueryEvent) { order = append(order, "after "+name) },
+			})
+		}
+
+		connection.MustExec("SELECT 1")
+		Expect(order).To(Equal([]string{"before first", "before second", "after first", "after second"}))
+	})
+	Describe("VerboseQueryLogger", func() {
+		It("logs each query and any error", func() {
+			_, _, logfile := testhelper.SetupTestLogger()
+			connection.AddQueryHook(dbconn.VerboseQueryLogger{})
+			mock.ExpectExec("SELECT 1").WillReturnResult(testhelper.TestResult{})
+			mock.ExpectExec("SELECT 2").WillReturnError(errors.New("syntax error"))
+
+			connection.MustExec("SELECT 1")
+			_, _ = connection.Exec("SELECT 2")
+			Expect(logfile).To(gbytes.Say(`Query on connection 0 took .*: SELECT 1`))
+			Expect(logfile).To(gbytes.Say(`Query on connection 0 failed after .*: SELECT 2: syntax error`))
+		})
+	})
+	Describe("SlowQueryThreshold", func() {
+		var logfile *gbytes.Buffer
+		BeforeEach(func() {
+			_, _, logfile = testhelper.SetupTestLogger()
+		})
+		It("logs queries that take at least the threshold", func() {
+			connection.SlowQueryThreshold = time.Nanosecond
+			mock.ExpectExec("SELECT pg_sleep").WillDelayFor(10 * time.Millisecond).WillReturnResult(testhelper.TestResult{})
+
+			connection.MustExec("SELECT pg_sleep(10)", 1)
+			Expect(logfile).To(gbytes.Say(`\[WARNING\]:-Slow query on connection 1 took .*: SELECT pg_sleep\(10\)`))
+			Expect(hook.events).To(HaveLen(1))
+		})
+		It("does not log faster queries", func() {
+			connection.SlowQueryThreshold = time.Hour
+			mock.ExpectExec("SELECT 1").WillReturnResult(testhelper.TestResult{})
+
+			connection.MustExec("SELECT 1")
+			Expect(logfile).ToNot(gbytes.Say("Slow query"))
+		})
+		It("logs slow queries even without any hooks", func() {
+			connection, mock = testhelper.CreateAndConnectMockDB(1)
+			connection.SlowQueryThreshold = time.Nanosecond
+			mock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow(1))
+
+			Expect(dbconn.MustSelectInt(connection, "SELECT 1")).To(Equal(1))
+			Expect(logfile).To(gbytes.Say("Slow query on connection 0 took .*: SELECT 1"))
+		})
+	})
+})
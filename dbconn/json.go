@@ -0,0 +1,79 @@
+package dbconn
+
+/*
+ * This file contains functions and types for reading and writing json and
+ * jsonb values as Go values.
+ */
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * SelectJSON runs a query returning a single json or jsonb value and
+ * unmarshals it into destination, as json.Unmarshal would.  To read several
+ * rows at once, aggregate them in the query, such as with json_agg, and pass a
+ * pointer to a slice.  A NULL value leaves destination unchanged, as a JSON
+ * null would.
+ */
+func (dbconn *DBConn) SelectJSON(destination interface{}, query string, whichConn ...int) error {
+	var value sql.NullString
+	if err := dbconn.Get(&value, query, whichConn...); err != nil {
+		return err
+	}
+	if !value.Valid {
+		return nil
+	}
+	return errors.Wrap(json.Unmarshal([]byte(value.String), destination), "Failed to unmarshal JSON")
+}
+
+/*
+ * JSON wraps a Go value so that it is marshaled to JSON when passed as a query
+ * argument, and unmarshaled from JSON when a json or jsonb column is scanned
+ * into it, such as in a struct field:
+ *
+ *   type Run struct {
+ *       ID      int                      `db:"id"`
+ *       Options dbconn.JSON[RunOptions] `db:"options"`
+ *   }
+ *
+ *   _, err := connection.NamedExec("INSERT INTO runs VALUES (:id, :options)", run)
+ *
+ * A NULL value is scanned as the zero value of T.
+ */
+type JSON[T any] struct {
+	Data T
+}
+
+func (value *JSON[T]) Scan(src interface{}) error {
+	var data T
+	var text []byte
+	switch src := src.(type) {
+	case nil:
+		value.Data = data
+		return nil
+	case []byte:
+		text = src
+	case string:
+		text = []byte(src)
+	default:
+		return errors.Errorf("Cannot scan %T into JSON", src)
+	}
+	if err := json.Unmarshal(text, &data); err != nil {
+		return errors.Wrap(err, "Failed to unmarshal JSON")
+	}
+	value.Data = data
+	return nil
+}
+
+func (value JSON[T]) Value() (driver.Value, error) {
+	text, err := json.Marshal(value.Data)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to marshal JSON")
+	}
+	return string(text), nil
+}
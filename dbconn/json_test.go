@@ -0,0 +1,98 @@
+package dbconn_test
+
+import (
+	"errors"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/json tests", func() {
+	type runOptions struct {
+		Jobs   int      `json:"jobs"`
+		Tables []string `json:"tables"`
+	}
+
+	Describe("DBConn.SelectJSON", func() {
+		It("unmarshals a JSON value into a struct", func() {
+			mock.ExpectQuery("SELECT options FROM runs").WillReturnRows(sqlmock.NewRows([]string{"options"}).AddRow(`{"jobs": 4, "tables": ["public.foo"]}`))
+
+			var options runOptions
+			err := connection.SelectJSON(&options, "SELECT options FROM runs WHERE id = 1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(options).To(Equal(runOptions{Jobs: 4, Tables: []string{"public.foo"}}))
+		})
+		It("unmarshals an aggregated JSON value into a slice", func() {
+			mock.ExpectQuery("SELECT json_agg").WillReturnRows(sqlmock.NewRows([]string{"json_agg"}).AddRow([]byte(`[{"jobs": 1}, {"jobs": 2}]`)))
+
+			var options []runOptions
+			err := connection.SelectJSON(&options, "SELECT json_agg(options) FROM runs")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(options).To(Equal([]runOptions{{Jobs: 1}, {Jobs: 2}}))
+		})
+		It("leaves the destination unchanged for a NULL value", func() {
+			mock.ExpectQuery("SELECT options FROM runs").WillReturnRows(sqlmock.NewRows([]string{"options"}).AddRow(nil))
+
+			options := runOptions{Jobs: 1}
+			err := connection.SelectJSON(&options, "SELECT options FROM runs WHERE id = 1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(options).To(Equal(runOptions{Jobs: 1}))
+		})
+		It("returns an error for invalid JSON", func() {
+			mock.ExpectQuery("SELECT options FROM runs").WillReturnRows(sqlmock.NewRows([]string{"options"}).AddRow(`{"jobs": "four"}`))
+
+			var options runOptions
+			err := connection.SelectJSON(&options, "SELECT options FROM runs WHERE id = 1")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("Failed to unmarshal JSON: "))
+		})
+		It("returns an error if the query fails", func() {
+			mock.ExpectQuery("SELECT options FROM runs").WillReturnError(errors.New("relation \"runs\" does not exist"))
+
+			var options runOptions
+			err := connection.SelectJSON(&options, "SELECT options FROM runs WHERE id = 1")
+			Expect(err).To(MatchError("relation \"runs\" does not exist"))
+		})
+	})
+	Describe("JSON", func() {
+		It("scans a JSON column into a struct field", func() {
+			type run struct {
+				ID      int                     `db:"id"`
+				Options dbconn.JSON[runOptions] `db:"options"`
+			}
+			mock.ExpectQuery("SELECT id, options FROM runs").WillReturnRows(sqlmock.NewRows([]string{"id", "options"}).AddRow(1, `{"jobs": 2}`).AddRow(2, nil))
+
+			runs := make([]run, 0)
+			err := connection.Select(&runs, "SELECT id, options FROM runs")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(runs).To(Equal([]run{{ID: 1, Options: dbconn.JSON[runOptions]{Data: runOptions{Jobs: 2}}}, {ID: 2}}))
+		})
+		It("returns an error when scanning invalid JSON", func() {
+			var value dbconn.JSON[runOptions]
+			err := value.Scan("not json")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("Failed to unmarshal JSON: "))
+		})
+		It("returns an error when scanning a value of another type", func() {
+			var value dbconn.JSON[runOptions]
+			Expect(value.Scan(1)).To(MatchError("Cannot scan int into JSON"))
+		})
+		It("marshals a query argument", func() {
+			mock.ExpectExec("INSERT INTO runs").WithArgs(1, `{"jobs":4,"tables":["public.foo"]}`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+			_, err := connection.NamedExec("INSERT INTO runs VALUES (:id, :options)", map[string]interface{}{
+				"id":      1,
+				"options": dbconn.JSON[runOptions]{Data: runOptions{Jobs: 4, Tables: []string{"public.foo"}}},
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+		It("returns an error for a value that cannot be marshaled", func() {
+			_, err := dbconn.JSON[chan int]{Data: make(chan int)}.Value()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("Failed to marshal JSON: "))
+		})
+	})
+})
@@ -0,0 +1,90 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to Kerberos (GSSAPI)
+ * authentication for database connections.
+ *
+ * The pgx driver authenticates with GSSAPI when the server requests it, using
+ * the krbsrvname and krbspn parameters built here, but only if a GSSAPI
+ * implementation has been registered first.  Programs that connect to
+ * Kerberized clusters must register one at startup, for example:
+ *
+ *   import "github.com/otan/gopgkrb5"
+ *
+ *   pgconn.RegisterGSSProvider(func() (pgconn.GSS, error) { return gopgkrb5.NewGSS() })
+ *
+ * This library does not do so itself to avoid pulling a Kerberos dependency
+ * into every utility.  The driver also does not support GSSAPI transport
+ * encryption, so a gssencmode of "require" is rejected; use SSLOptions to
+ * encrypt Kerberized connections instead.
+ */
+
+import (
+	"fmt"
+
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+/*
+ * KerberosOptions holds the libpq Kerberos connection parameters.  SrvName
+ * defaults to $PGKRBSRVNAME, or "postgres" if that is not set, and GSSEncMode
+ * defaults to $PGGSSENCMODE, or "prefer" if that is not set.  Spn, the full
+ * service principal name, is only passed to the server if set.
+ */
+type KerberosOptions struct {
+	SrvName    string
+	Spn        string
+	GSSEncMode string
+}
+
+var validGSSEncModes = map[string]bool{
+	"disable": true,
+	"prefer":  true,
+	"require": true,
+}
+
+func (opts KerberosOptions) srvName() string {
+	if opts.SrvName != "" {
+		return opts.SrvName
+	}
+	if srvName := operating.System.Getenv("PGKRBSRVNAME"); srvName != "" {
+		return srvName
+	}
+	return "postgres"
+}
+
+func (opts KerberosOptions) gssEncMode() string {
+	if opts.GSSEncMode != "" {
+		return opts.GSSEncMode
+	}
+	if mode := operating.System.Getenv("PGGSSENCMODE"); mode != "" {
+		return mode
+	}
+	return "prefer"
+}
+
+/*
+ * Validate checks that the GSSAPI encryption mode is one libpq recognizes and
+ * that it does not require encryption the driver cannot provide.  As with
+ * libpq connecting to a server without GSSAPI encryption, "prefer" falls back
+ * to an unencrypted (or SSL-encrypted) connection.
+ */
+func (opts KerberosOptions) Validate() error {
+	mode := opts.gssEncMode()
+	if !validGSSEncModes[mode] {
+		return errors.Errorf("Invalid GSSAPI encryption mode %q; must be one of disable, prefer, or require", mode)
+	}
+	if mode == "require" {
+		return errors.New("GSSAPI encryption is not supported by the database driver; set gssencmode to prefer or disable and use SSL to encrypt the connection")
+	}
+	return nil
+}
+
+func (opts KerberosOptions) connectionString() string {
+	connStr := fmt.Sprintf(" krbsrvname='%s'", EscapeConnectionParam(opts.srvName()))
+	if opts.Spn != "" {
+		connStr += fmt.Sprintf(" krbspn='%s'", EscapeConnectionParam(opts.Spn))
+	}
+	return connStr
+}
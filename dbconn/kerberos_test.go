@@ -0,0 +1,72 @@
+package dbconn_test
+
+import (
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/kerberos tests", func() {
+	var env map[string]string
+	BeforeEach(func() {
+		env = map[string]string{}
+		operating.System.Getenv = func(key string) string { return env[key] }
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+	Describe("KerberosOptions.Validate", func() {
+		It("accepts valid options", func() {
+			Expect(dbconn.KerberosOptions{}.Validate()).To(Succeed())
+			Expect(dbconn.KerberosOptions{GSSEncMode: "disable"}.Validate()).To(Succeed())
+		})
+		It("rejects an invalid mode", func() {
+			Expect(dbconn.KerberosOptions{GSSEncMode: "on"}.Validate()).To(MatchError(`Invalid GSSAPI encryption mode "on"; must be one of disable, prefer, or require`))
+		})
+		It("rejects requiring GSSAPI encryption from the environment", func() {
+			env["PGGSSENCMODE"] = "require"
+			Expect(dbconn.KerberosOptions{}.Validate()).To(MatchError(ContainSubstring("GSSAPI encryption is not supported")))
+		})
+	})
+	Describe("DBConn.Connect", func() {
+		It("uses the default service name", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			driver := useRecordingDriver(connection)
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+
+			Expect(connection.Connect(1)).To(Succeed())
+			Expect(driver.ConnStrs[0]).To(ContainSubstring(" dbname='testdb' krbsrvname='postgres' host="))
+			Expect(driver.ConnStrs[0]).ToNot(ContainSubstring("krbspn"))
+		})
+		It("uses the service name from the environment", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			driver := useRecordingDriver(connection)
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+			env["PGKRBSRVNAME"] = "gpdb"
+
+			Expect(connection.Connect(1)).To(Succeed())
+			Expect(driver.ConnStrs[0]).To(ContainSubstring(" krbsrvname='gpdb' "))
+		})
+		It("passes the Kerberos options in the connection string", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			driver := useRecordingDriver(connection)
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+			env["PGKRBSRVNAME"] = "gpdb"
+			connection.Kerberos = dbconn.KerberosOptions{SrvName: "cbdb", Spn: "cbdb/mdw.example.com@EXAMPLE.COM"}
+
+			Expect(connection.Connect(1)).To(Succeed())
+			Expect(driver.ConnStrs[0]).To(ContainSubstring(" krbsrvname='cbdb' krbspn='cbdb/mdw.example.com@EXAMPLE.COM' host="))
+		})
+		It("does not connect if the Kerberos options are invalid", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			driver := useRecordingDriver(connection)
+			connection.Kerberos = dbconn.KerberosOptions{GSSEncMode: "require"}
+
+			Expect(connection.Connect(1)).ToNot(Succeed())
+			Expect(driver.ConnStrs).To(BeEmpty())
+		})
+	})
+})
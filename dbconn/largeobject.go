@@ -0,0 +1,127 @@
+package dbconn
+
+/*
+ * This file contains functions for storing binary data, such as manifests or
+ * plugin payloads, in large objects.  The data is streamed between the client
+ * and the server in chunks through the server-side descriptor functions
+ * lo_open, loread, and lowrite, which are available on every supported
+ * version, rather than read from or written to files on the server as
+ * lo_import and lo_export do.  Not every version of Greenplum supports large
+ * objects; where they are unsupported, the server's error is returned.
+ *
+ * Large object descriptors are only valid within a transaction, so each
+ * function runs in the connection's transaction if one is in progress, and
+ * otherwise in a new one, which is committed if it succeeds.
+ */
+
+import (
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// The modes for lo_open, from libpq-fs.h.
+const (
+	largeObjectRead  = 0x40000
+	largeObjectWrite = 0x20000
+)
+
+// The amount of data read or written by each call to loread or lowrite.
+const largeObjectChunkSize = 256 * 1024
+
+/*
+ * inLargeObjectTransaction runs fn in the connection's transaction if one is
+ * in progress, and otherwise in a new one.  The new transaction is never
+ * retried under TransactionRetryPolicy, as fn consumes a reader or writes to
+ * a writer that cannot be rewound, so a retry would store a truncated object
+ * or write the data twice.
+ */
+func (dbconn *DBConn) inLargeObjectTransaction(connNum int, fn func(tx *Tx) error) error {
+	if sqlxTx := dbconn.Tx[connNum]; sqlxTx != nil {
+		return fn(&Tx{tx: sqlxTx, dbconn: dbconn, connNum: connNum})
+	}
+	return dbconn.runInTransaction(fn, connNum)
+}
+
+func openLargeObject(tx *Tx, oid uint32, mode int) (int, error) {
+	var fd int
+	err := tx.Get(&fd, "SELECT pg_catalog.lo_open($1, $2)", oid, mode)
+	return fd, err
+}
+
+func closeLargeObject(tx *Tx, fd int) error {
+	_, err := tx.Exec("SELECT pg_catalog.lo_close($1)", fd)
+	return err
+}
+
+/*
+ * ImportLargeObject creates a large object holding everything read from r and
+ * returns its OID.  If reading from r or writing to the database fails, the
+ * large object is not created, unless a transaction was already in progress,
+ * in which case it is up to the caller to roll it back.
+ */
+func (dbconn *DBConn) ImportLargeObject(r io.Reader, whichConn ...int) (uint32, error) {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	var oid uint32
+	err := dbconn.inLargeObjectTransaction(connNum, func(tx *Tx) error {
+		if err := tx.Get(&oid, "SELECT pg_catalog.lo_create(0)"); err != nil {
+			return err
+		}
+		fd, err := openLargeObject(tx, oid, largeObjectWrite)
+		if err != nil {
+			return err
+		}
+		buffer := make([]byte, largeObjectChunkSize)
+		for {
+			n, readErr := io.ReadFull(r, buffer)
+			if n > 0 {
+				if _, err := tx.Exec("SELECT pg_catalog.lowrite($1, $2)", fd, buffer[:n]); err != nil {
+					return err
+				}
+			}
+			if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+				break
+			} else if readErr != nil {
+				return readErr
+			}
+		}
+		return closeLargeObject(tx, fd)
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to import large object")
+	}
+	return oid, nil
+}
+
+// ExportLargeObject writes the contents of the large object with the given OID to w.
+func (dbconn *DBConn) ExportLargeObject(oid uint32, w io.Writer, whichConn ...int) error {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	err := dbconn.inLargeObjectTransaction(connNum, func(tx *Tx) error {
+		fd, err := openLargeObject(tx, oid, largeObjectRead)
+		if err != nil {
+			return err
+		}
+		for {
+			var chunk []byte
+			if err := tx.Get(&chunk, "SELECT pg_catalog.loread($1, $2)", fd, largeObjectChunkSize); err != nil {
+				return err
+			}
+			if _, err := w.Write(chunk); err != nil {
+				return err
+			}
+			if len(chunk) < largeObjectChunkSize {
+				break
+			}
+		}
+		return closeLargeObject(tx, fd)
+	})
+	return errors.Wrapf(err, "Failed to export large object %d", oid)
+}
+
+// UnlinkLargeObject deletes the large object with the given OID.
+func (dbconn *DBConn) UnlinkLargeObject(oid uint32, whichConn ...int) error {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	_, err := dbconn.exec(context.Background(), dbconn.queryer(connNum), connNum, "SELECT pg_catalog.lo_unlink($1)", oid)
+	return errors.Wrapf(err, "Failed to unlink large object %d", oid)
+}
@@ -0,0 +1,113 @@
+package dbconn_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/largeobject tests", func() {
+	const chunkSize = 256 * 1024
+	payload := bytes.Repeat([]byte("0123456789abcdef"), (chunkSize+1024)/16)
+
+	Describe("DBConn.ImportLargeObject", func() {
+		It("creates a large object and writes the data in chunks in a new transaction", func() {
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_catalog.lo_create(0)")).WillReturnRows(sqlmock.NewRows([]string{"lo_create"}).AddRow(16384))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_catalog.lo_open($1, $2)")).WithArgs(16384, 0x20000).WillReturnRows(sqlmock.NewRows([]string{"lo_open"}).AddRow(0))
+			mock.ExpectExec(regexp.QuoteMeta("SELECT pg_catalog.lowrite($1, $2)")).WithArgs(0, payload[:chunkSize]).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(regexp.QuoteMeta("SELECT pg_catalog.lowrite($1, $2)")).WithArgs(0, payload[chunkSize:]).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(regexp.QuoteMeta("SELECT pg_catalog.lo_close($1)")).WithArgs(0).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			oid, err := connection.ImportLargeObject(bytes.NewReader(payload))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(oid).To(Equal(uint32(16384)))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("rolls back if writing fails", func() {
+			mock.ExpectBegin()
+			mock.ExpectQuery("lo_create").WillReturnRows(sqlmock.NewRows([]string{"lo_create"}).AddRow(16384))
+			mock.ExpectQuery("lo_open").WillReturnRows(sqlmock.NewRows([]string{"lo_open"}).AddRow(0))
+			mock.ExpectExec("lowrite").WillReturnError(errors.New("disk full"))
+			mock.ExpectRollback()
+
+			_, err := connection.ImportLargeObject(bytes.NewReader([]byte("manifest")))
+			Expect(err).To(MatchError("Failed to import large object: disk full"))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("does not retry the transaction under a TransactionRetryPolicy", func() {
+			connection.TransactionRetryPolicy = &dbconn.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+			mock.ExpectBegin()
+			mock.ExpectQuery("lo_create").WillReturnRows(sqlmock.NewRows([]string{"lo_create"}).AddRow(16384))
+			mock.ExpectQuery("lo_open").WillReturnRows(sqlmock.NewRows([]string{"lo_open"}).AddRow(0))
+			mock.ExpectExec("lowrite").WillReturnError(errors.New("ERROR: could not serialize access due to concurrent update (SQLSTATE 40001)"))
+			mock.ExpectRollback()
+
+			_, err := connection.ImportLargeObject(bytes.NewReader([]byte("manifest")))
+			Expect(err).To(MatchError(ContainSubstring("could not serialize access")))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("runs in the transaction in progress without committing it", func() {
+			mock.ExpectBegin()
+			mock.ExpectQuery("lo_create").WillReturnRows(sqlmock.NewRows([]string{"lo_create"}).AddRow(16384))
+			mock.ExpectQuery("lo_open").WillReturnRows(sqlmock.NewRows([]string{"lo_open"}).AddRow(0))
+			mock.ExpectExec("lo_close").WillReturnResult(sqlmock.NewResult(0, 1))
+
+			tx, err := connection.BeginTx(context.Background(), nil)
+			Expect(err).ToNot(HaveOccurred())
+			oid, err := connection.ImportLargeObject(bytes.NewReader(nil))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(oid).To(Equal(uint32(16384)))
+			Expect(connection.Tx[0]).ToNot(BeNil())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+
+			mock.ExpectCommit()
+			Expect(tx.Commit()).To(Succeed())
+		})
+	})
+	Describe("DBConn.ExportLargeObject", func() {
+		It("reads the large object in chunks and writes it to the writer", func() {
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_catalog.lo_open($1, $2)")).WithArgs(16384, 0x40000).WillReturnRows(sqlmock.NewRows([]string{"lo_open"}).AddRow(0))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_catalog.loread($1, $2)")).WithArgs(0, chunkSize).WillReturnRows(sqlmock.NewRows([]string{"loread"}).AddRow(payload[:chunkSize]))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_catalog.loread($1, $2)")).WithArgs(0, chunkSize).WillReturnRows(sqlmock.NewRows([]string{"loread"}).AddRow(payload[chunkSize:]))
+			mock.ExpectExec("lo_close").WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			var buffer bytes.Buffer
+			Expect(connection.ExportLargeObject(16384, &buffer)).To(Succeed())
+			Expect(buffer.Bytes()).To(Equal(payload))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns an error if the large object does not exist", func() {
+			mock.ExpectBegin()
+			mock.ExpectQuery("lo_open").WillReturnError(errors.New("large object 16384 does not exist"))
+			mock.ExpectRollback()
+
+			err := connection.ExportLargeObject(16384, &bytes.Buffer{})
+			Expect(err).To(MatchError("Failed to export large object 16384: large object 16384 does not exist"))
+		})
+	})
+	Describe("DBConn.UnlinkLargeObject", func() {
+		It("deletes the large object", func() {
+			mock.ExpectExec(regexp.QuoteMeta("SELECT pg_catalog.lo_unlink($1)")).WithArgs(16384).WillReturnResult(sqlmock.NewResult(0, 1))
+
+			Expect(connection.UnlinkLargeObject(16384)).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns an error if the deletion fails", func() {
+			mock.ExpectExec("lo_unlink").WillReturnError(errors.New("permission denied"))
+
+			Expect(connection.UnlinkLargeObject(16384)).To(MatchError("Failed to unlink large object 16384: permission denied"))
+		})
+	})
+})
@@ -0,0 +1,153 @@
+package dbconn
+
+/*
+ * This file contains functions for establishing pooled connections on demand,
+ * for a DBConn connected with ConnectOptions.Lazy, so that a program that may
+ * never need the database, such as in a dry run, does not fail for lack of a
+ * server or valid credentials.
+ *
+ * Each connection in the pool is established the first time it is used, with
+ * the same startup parameters, pool limits, and GUCs set with SetGUC as if it
+ * had been made by Connect, and the database version is determined when the
+ * first one is.  Until then, Version is the zero value, so code that chooses
+ * its queries by version before running any should call EnsureConnected
+ * first.
+ */
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/*
+ * A pendingConn stands in for a connection that has not been established yet,
+ * and establishes it when a query is run on it.
+ */
+type pendingConn struct {
+	dbconn  *DBConn
+	connNum int
+}
+
+func (pending pendingConn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	conn, err := pending.dbconn.pooledConn(pending.connNum)
+	if err != nil {
+		return nil, err
+	}
+	return conn.ExecContext(ctx, query, args...)
+}
+
+func (pending pendingConn) GetContext(ctx context.Context, destination interface{}, query string, args ...interface{}) error {
+	conn, err := pending.dbconn.pooledConn(pending.connNum)
+	if err != nil {
+		return err
+	}
+	return conn.GetContext(ctx, destination, query, args...)
+}
+
+func (pending pendingConn) SelectContext(ctx context.Context, destination interface{}, query string, args ...interface{}) error {
+	conn, err := pending.dbconn.pooledConn(pending.connNum)
+	if err != nil {
+		return err
+	}
+	return conn.SelectContext(ctx, destination, query, args...)
+}
+
+func (pending pendingConn) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	conn, err := pending.dbconn.pooledConn(pending.connNum)
+	if err != nil {
+		return nil, err
+	}
+	return conn.QueryxContext(ctx, query, args...)
+}
+
+// pooledConn returns the given pooled connection, establishing it first if necessary.
+func (dbconn *DBConn) pooledConn(connNum int) (*sqlx.DB, error) {
+	if dbconn.pooled(connNum) == nil {
+		if err := dbconn.establishIfUnset(connNum); err != nil {
+			return nil, err
+		}
+	}
+	return dbconn.pooled(connNum), nil
+}
+
+/*
+ * pooled and setPooled read and replace the given pooled connection.  The
+ * connection may be established by another goroutine at any time, so code
+ * that may run while a DBConn connected with Lazy is in use should go through
+ * these rather than indexing ConnPool directly.
+ */
+func (dbconn *DBConn) pooled(connNum int) *sqlx.DB {
+	dbconn.poolMutex.RLock()
+	defer dbconn.poolMutex.RUnlock()
+	return dbconn.ConnPool[connNum]
+}
+
+func (dbconn *DBConn) setPooled(connNum int, conn *sqlx.DB) {
+	dbconn.poolMutex.Lock()
+	defer dbconn.poolMutex.Unlock()
+	if conn != nil {
+		// The limits may have changed since the connection was made.
+		dbconn.limitConnLocked(conn)
+	}
+	dbconn.ConnPool[connNum] = conn
+}
+
+/*
+ * establishIfUnset establishes the given pooled connection unless another
+ * goroutine did so while this one waited for establishMutex, so that
+ * goroutines using a lazy connection at the same time only establish it once.
+ */
+func (dbconn *DBConn) establishIfUnset(connNum int) error {
+	dbconn.establishMutex.Lock()
+	defer dbconn.establishMutex.Unlock()
+	if dbconn.pooled(connNum) != nil {
+		return nil
+	}
+	return dbconn.establishLocked(connNum)
+}
+
+// establish makes the given pooled connection as reconnect does, replacing any existing one.
+func (dbconn *DBConn) establish(connNum int) error {
+	dbconn.establishMutex.Lock()
+	defer dbconn.establishMutex.Unlock()
+	return dbconn.establishLocked(connNum)
+}
+
+func (dbconn *DBConn) establishLocked(connNum int) error {
+	if err := dbconn.reconnect(connNum); err != nil {
+		return err
+	}
+	log.Debug("Established connection %d to %s:%d", connNum, dbconn.Host, dbconn.Port)
+	return nil
+}
+
+func (dbconn *DBConn) MustEnsureConnected(whichConn ...int) {
+	err := dbconn.EnsureConnected(whichConn...)
+	gplog.FatalOnError(err)
+}
+
+/*
+ * EnsureConnected establishes the given connection, or connection 0 if none is
+ * given, if it has not been already, so that a lazily connected program can
+ * check the connection and learn the database version before going on.  It
+ * does nothing for a connection that is already established.
+ */
+func (dbconn *DBConn) EnsureConnected(whichConn ...int) error {
+	if dbconn.ConnPool == nil {
+		return errors.New("Cannot establish a connection before connecting")
+	}
+	_, err := dbconn.pooledConn(dbconn.ValidateConnNum(whichConn...))
+	return err
+}
+
+// IsConnected reports whether the given connection has been established.
+func (dbconn *DBConn) IsConnected(whichConn ...int) bool {
+	if dbconn.ConnPool == nil {
+		return false
+	}
+	return dbconn.pooled(dbconn.ValidateConnNum(whichConn...)) != nil
+}
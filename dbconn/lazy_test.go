@@ -0,0 +1,178 @@
+package dbconn_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("dbconn/lazy tests", func() {
+	var driver *recordingDriver
+
+	BeforeEach(func() {
+		connection, mock = testhelper.CreateMockDBConn()
+		driver = useRecordingDriver(connection)
+	})
+
+	Describe("DBConn.ConnectWithOptions with Lazy", func() {
+		It("does not connect until a connection is used", func() {
+			Expect(connection.ConnectWithOptions(dbconn.ConnectOptions{NumConns: 2, Lazy: true})).To(Succeed())
+			Expect(driver.ConnStrs).To(BeEmpty())
+			Expect(connection.NumConns).To(Equal(2))
+			Expect(connection.IsConnected(0)).To(BeFalse())
+			Expect(connection.Version).To(Equal(dbconn.GPDBVersion{}))
+		})
+		It("succeeds even if the server cannot be reached", func() {
+			connection.Driver.(*recordingDriver).ErrToReturn = errors.New("connection refused")
+
+			Expect(connection.ConnectWithOptions(dbconn.ConnectOptions{NumConns: 1, Lazy: true})).To(Succeed())
+			_, err := connection.Exec("SELECT 1")
+			Expect(err).To(MatchError(ContainSubstring("could not connect to server: Connection refused")))
+			Expect(connection.IsConnected()).To(BeFalse())
+		})
+		It("establishes each connection and determines the version on first use", func() {
+			Expect(connection.ConnectWithOptions(dbconn.ConnectOptions{NumConns: 2, Lazy: true})).To(Succeed())
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+			mock.ExpectExec("DELETE FROM foo").WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec("DELETE FROM bar").WillReturnResult(sqlmock.NewResult(0, 1))
+
+			_, err := connection.Exec("DELETE FROM foo", 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(connection.IsConnected(1)).To(BeTrue())
+			Expect(connection.IsConnected(0)).To(BeFalse())
+			Expect(connection.Version.AtLeast("7")).To(BeTrue())
+
+			_, err = connection.Exec("DELETE FROM bar", 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(driver.ConnStrs).To(HaveLen(2))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("applies settings made before a connection is established", func() {
+			Expect(connection.ConnectWithOptions(dbconn.ConnectOptions{NumConns: 1, Lazy: true})).To(Succeed())
+			Expect(connection.SetGUC("search_path", "sales")).To(Succeed())
+			mock.ExpectExec(regexp.QuoteMeta("SELECT pg_catalog.set_config('search_path', 'sales', false)")).WillReturnResult(sqlmock.NewResult(0, 0))
+			testhelper.ExpectVersionQuery(mock, "6.0.0")
+			mock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"i"}).AddRow(1))
+
+			var i int
+			Expect(connection.Get(&i, "SELECT 1")).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("does not apply a setting to later connections if it could not be set", func() {
+			Expect(connection.ConnectWithOptions(dbconn.ConnectOptions{NumConns: 2, Lazy: true})).To(Succeed())
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+			Expect(connection.EnsureConnected(0)).To(Succeed())
+			mock.ExpectExec(regexp.QuoteMeta("SELECT pg_catalog.set_config('work_mem', 'lots', false)")).WillReturnError(errors.New(`invalid value for parameter "work_mem"`))
+			Expect(connection.SetGUC("work_mem", "lots")).To(HaveOccurred())
+
+			Expect(connection.EnsureConnected(1)).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("establishes the connection to begin a transaction", func() {
+			Expect(connection.ConnectWithOptions(dbconn.ConnectOptions{NumConns: 1, Lazy: true})).To(Succeed())
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+			mock.ExpectBegin()
+
+			_, err := connection.BeginTx(context.Background(), nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("rejects utility mode", func() {
+			err := connection.ConnectWithOptions(dbconn.ConnectOptions{NumConns: 1, Lazy: true, UtilityMode: true})
+			Expect(err).To(MatchError("Cannot defer connecting in utility mode"))
+		})
+	})
+	Describe("DBConn.EnsureConnected", func() {
+		It("establishes the connection if it has not been", func() {
+			Expect(connection.ConnectWithOptions(dbconn.ConnectOptions{NumConns: 1, Lazy: true})).To(Succeed())
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+
+			Expect(connection.EnsureConnected()).To(Succeed())
+			Expect(connection.EnsureConnected()).To(Succeed())
+			Expect(driver.ConnStrs).To(HaveLen(1))
+			Expect(connection.Version.Is("7.0.0")).To(BeTrue())
+		})
+		It("establishes the connection once when called from several goroutines at once", func() {
+			Expect(connection.ConnectWithOptions(dbconn.ConnectOptions{NumConns: 1, Lazy: true})).To(Succeed())
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+
+			var wg sync.WaitGroup
+			errs := make(chan error, 5)
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					errs <- connection.EnsureConnected()
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(driver.ConnStrs).To(HaveLen(1))
+		})
+		It("can be called while the pool is being configured from another goroutine", func() {
+			Expect(connection.ConnectWithOptions(dbconn.ConnectOptions{NumConns: 2, Lazy: true})).To(Succeed())
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+
+			done := make(chan error)
+			go func() {
+				done <- connection.EnsureConnected(1)
+			}()
+			for i := 0; i < 10; i++ {
+				connection.SetMaxIdleConns(2)
+			}
+			Expect(<-done).To(Succeed())
+			Expect(connection.IsConnected(1)).To(BeTrue())
+		})
+		It("does nothing for a connection made by Connect", func() {
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+			Expect(connection.Connect(1)).To(Succeed())
+
+			Expect(connection.EnsureConnected()).To(Succeed())
+			Expect(driver.ConnStrs).To(HaveLen(1))
+		})
+		It("returns an error before connecting", func() {
+			Expect(connection.EnsureConnected()).To(MatchError("Cannot establish a connection before connecting"))
+		})
+		It("logs establishing the connection at the debug level set for dbconn", func() {
+			stdout, _, _ := testhelper.SetupTestLogger()
+			gplog.SetLevelFor("dbconn", gplog.LOGDEBUG)
+			defer gplog.ClearLevelFor("dbconn")
+			Expect(connection.ConnectWithOptions(dbconn.ConnectOptions{NumConns: 1, Lazy: true})).To(Succeed())
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+
+			Expect(connection.EnsureConnected()).To(Succeed())
+			Expect(stdout).To(gbytes.Say(`\[DEBUG\]:-Established connection 0 to \S+\n`))
+		})
+		It("does not log establishing the connection at the default verbosity", func() {
+			stdout, _, logfile := testhelper.SetupTestLogger()
+			Expect(connection.ConnectWithOptions(dbconn.ConnectOptions{NumConns: 1, Lazy: true})).To(Succeed())
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+
+			Expect(connection.EnsureConnected()).To(Succeed())
+			Expect(stdout).ToNot(gbytes.Say("Established connection"))
+			Expect(logfile).To(gbytes.Say("Established connection 0"))
+		})
+	})
+	Describe("DBConn.Ping", func() {
+		It("establishes connections that have not been", func() {
+			Expect(connection.ConnectWithOptions(dbconn.ConnectOptions{NumConns: 1, Lazy: true})).To(Succeed())
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+
+			Expect(connection.Ping(context.Background())).To(Succeed())
+			Expect(connection.IsConnected()).To(BeTrue())
+		})
+	})
+})
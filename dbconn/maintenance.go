@@ -0,0 +1,128 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to running ANALYZE and
+ * VACUUM over a set of tables in parallel across the connection pool.
+ */
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+type MaintenanceOperation int
+
+const (
+	ANALYZE MaintenanceOperation = iota
+	VACUUM
+	VACUUM_ANALYZE
+)
+
+func (op MaintenanceOperation) String() string {
+	switch op {
+	case VACUUM:
+		return "VACUUM"
+	case VACUUM_ANALYZE:
+		return "VACUUM ANALYZE"
+	default:
+		return "ANALYZE"
+	}
+}
+
+/*
+ * Size is used only to prioritize tables, and may be left at 0 if the caller
+ * does not care about ordering or populated with GetTableSizes.
+ */
+type MaintenanceTable struct {
+	Schema string
+	Name   string
+	Size   int64
+}
+
+func (table MaintenanceTable) FQN() string {
+	return fmt.Sprintf("%s.%s", QuoteIdentifier(table.Schema), QuoteIdentifier(table.Name))
+}
+
+type MaintenanceResult struct {
+	Table    MaintenanceTable
+	ConnNum  int
+	Duration time.Duration
+	Error    error
+}
+
+/*
+ * A MaintenanceProgressFunc is called once per table as each table finishes,
+ * with the number of tables completed so far and the total number of tables.
+ * Calls are serialized, so the function does not need to be concurrency-safe.
+ */
+type MaintenanceProgressFunc func(completed int, total int, result MaintenanceResult)
+
+/*
+ * GetTableSizes populates the Size field of each table with its on-disk size
+ * as reported by pg_relation_size, for use in prioritizing larger tables.
+ */
+func GetTableSizes(connection *DBConn, tables []MaintenanceTable) error {
+	for i := range tables {
+		query := fmt.Sprintf("SELECT pg_catalog.pg_relation_size('%s'::regclass) AS size", EscapeString(tables[i].FQN()))
+		err := connection.Get(&tables[i].Size, query)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * RunMaintenance runs the given operation over each table, using one worker
+ * per pooled connection so that at most NumConns tables are processed at once.
+ * Tables are dispatched in order of descending Size, so that the largest
+ * tables start first and do not become stragglers at the end of the run.
+ *
+ * A failure on one table does not stop the others; each table's outcome is
+ * reported in its MaintenanceResult, and results are returned in dispatch
+ * order.  VACUUM cannot run inside a transaction block, so this function
+ * should not be called while a transaction is in progress on any connection.
+ */
+func RunMaintenance(connection *DBConn, op MaintenanceOperation, tables []MaintenanceTable, progress MaintenanceProgressFunc) []MaintenanceResult {
+	ordered := make([]MaintenanceTable, len(tables))
+	copy(ordered, tables)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Size > ordered[j].Size
+	})
+
+	results := make([]MaintenanceResult, len(ordered))
+	tableIndices := make(chan int, len(ordered))
+	for i := range ordered {
+		tableIndices <- i
+	}
+	close(tableIndices)
+
+	var progressMutex sync.Mutex
+	completed := 0
+	var wg sync.WaitGroup
+	for connNum := 0; connNum < connection.NumConns; connNum++ {
+		wg.Add(1)
+		go func(whichConn int) {
+			defer wg.Done()
+			for index := range tableIndices {
+				table := ordered[index]
+				log.Verbose("Running %s on %s", op, table.FQN())
+				start := time.Now()
+				_, err := connection.Exec(fmt.Sprintf("%s %s", op, table.FQN()), whichConn)
+				result := MaintenanceResult{Table: table, ConnNum: whichConn, Duration: time.Since(start), Error: err}
+				results[index] = result
+
+				progressMutex.Lock()
+				completed++
+				if progress != nil {
+					progress(completed, len(ordered), result)
+				}
+				progressMutex.Unlock()
+			}
+		}(connNum)
+	}
+	wg.Wait()
+	return results
+}
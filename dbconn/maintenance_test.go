@@ -0,0 +1,60 @@
+package dbconn_test
+
+import (
+	"fmt"
+	"regexp"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/maintenance tests", func() {
+	Describe("MaintenanceTable.FQN", func() {
+		It("quotes the schema and table names", func() {
+			table := dbconn.MaintenanceTable{Schema: "public", Name: `my"table`}
+			Expect(table.FQN()).To(Equal(`"public"."my""table"`))
+		})
+	})
+	Describe("GetTableSizes", func() {
+		It("populates the size of each table", func() {
+			tables := []dbconn.MaintenanceTable{{Schema: "public", Name: "foo"}, {Schema: "public", Name: "bar"}}
+			mock.ExpectQuery(regexp.QuoteMeta(`pg_relation_size('"public"."foo"'::regclass)`)).WillReturnRows(sqlmock.NewRows([]string{"size"}).AddRow(100))
+			mock.ExpectQuery(regexp.QuoteMeta(`pg_relation_size('"public"."bar"'::regclass)`)).WillReturnRows(sqlmock.NewRows([]string{"size"}).AddRow(200))
+
+			err := dbconn.GetTableSizes(connection, tables)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tables[0].Size).To(Equal(int64(100)))
+			Expect(tables[1].Size).To(Equal(int64(200)))
+		})
+	})
+	Describe("RunMaintenance", func() {
+		It("runs the operation on the largest tables first and reports progress", func() {
+			tables := []dbconn.MaintenanceTable{{Schema: "public", Name: "small", Size: 1}, {Schema: "public", Name: "large", Size: 100}}
+			mock.ExpectExec(regexp.QuoteMeta(`VACUUM ANALYZE "public"."large"`)).WillReturnResult(testhelper.TestResult{Rows: 0})
+			mock.ExpectExec(regexp.QuoteMeta(`VACUUM ANALYZE "public"."small"`)).WillReturnResult(testhelper.TestResult{Rows: 0})
+
+			progress := make([]string, 0)
+			results := dbconn.RunMaintenance(connection, dbconn.VACUUM_ANALYZE, tables, func(completed int, total int, result dbconn.MaintenanceResult) {
+				progress = append(progress, fmt.Sprintf("%d/%d %s", completed, total, result.Table.Name))
+			})
+			Expect(results).To(HaveLen(2))
+			Expect(results[0].Table.Name).To(Equal("large"))
+			Expect(results[0].Error).ToNot(HaveOccurred())
+			Expect(results[1].Table.Name).To(Equal("small"))
+			Expect(progress).To(Equal([]string{"1/2 large", "2/2 small"}))
+		})
+		It("continues past failing tables and reports each error", func() {
+			tables := []dbconn.MaintenanceTable{{Schema: "public", Name: "foo"}, {Schema: "public", Name: "bar"}}
+			mock.ExpectExec(regexp.QuoteMeta(`ANALYZE "public"."foo"`)).WillReturnError(fmt.Errorf("relation does not exist"))
+			mock.ExpectExec(regexp.QuoteMeta(`ANALYZE "public"."bar"`)).WillReturnResult(testhelper.TestResult{Rows: 0})
+
+			results := dbconn.RunMaintenance(connection, dbconn.ANALYZE, tables, nil)
+			Expect(results[0].Error).To(MatchError("relation does not exist"))
+			Expect(results[1].Error).ToNot(HaveOccurred())
+		})
+	})
+})
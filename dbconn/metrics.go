@@ -0,0 +1,114 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to exporting metrics about
+ * the queries and connections of a DBConn.
+ *
+ * As with tracing, metrics are created through small interfaces rather than a
+ * particular metrics library.  The Counter and Histogram interfaces are
+ * satisfied by prometheus.Counter and prometheus.Histogram, so a Prometheus
+ * registrar takes only a few lines, for example:
+ *
+ *   type promRegistrar struct{ factory promauto.Factory }
+ *
+ *   func (r promRegistrar) NewCounter(name, help string) dbconn.Counter {
+ *       return r.factory.NewCounter(prometheus.CounterOpts{Name: name, Help: help})
+ *   }
+ *
+ *   func (r promRegistrar) NewHistogram(name, help string) dbconn.Histogram {
+ *       return r.factory.NewHistogram(prometheus.HistogramOpts{Name: name, Help: help})
+ *   }
+ *
+ *   func (r promRegistrar) NewGaugeFunc(name, help string, fn func() float64) {
+ *       r.factory.NewGaugeFunc(prometheus.GaugeOpts{Name: name, Help: help}, fn)
+ *   }
+ *
+ * and then call connection.EnableMetrics(promRegistrar{promauto.With(registry)}).
+ */
+
+import (
+	"context"
+)
+
+type Counter interface {
+	Inc()
+}
+
+type Histogram interface {
+	Observe(value float64)
+}
+
+/*
+ * A MetricsRegistrar creates and registers metrics with the given names and
+ * help text.  NewGaugeFunc registers a gauge whose value is read by calling fn
+ * whenever the metrics are collected.
+ */
+type MetricsRegistrar interface {
+	NewCounter(name string, help string) Counter
+	NewHistogram(name string, help string) Histogram
+	NewGaugeFunc(name string, help string, fn func() float64)
+}
+
+/*
+ * MetricsHook is a QueryHook that counts queries and failed queries and
+ * records the duration of each query in seconds.
+ */
+type MetricsHook struct {
+	Queries       Counter
+	Errors        Counter
+	QueryDuration Histogram
+}
+
+func (hook *MetricsHook) BeforeQuery(ctx context.Context, event *QueryEvent) context.Context {
+	return ctx
+}
+
+func (hook *MetricsHook) AfterQuery(ctx context.Context, event *QueryEvent) {
+	hook.Queries.Inc()
+	if event.Err != nil {
+		hook.Errors.Inc()
+	}
+	hook.QueryDuration.Observe(event.Duration.Seconds())
+}
+
+/*
+ * EnableMetrics registers the following metrics with the given registrar and
+ * updates them for each subsequent query:
+ *
+ *   dbconn_queries_total             Queries run
+ *   dbconn_query_errors_total        Queries that returned an error
+ *   dbconn_query_duration_seconds    Duration of each query
+ *   dbconn_open_connections          Open connections across the pool
+ *   dbconn_in_use_connections        Connections currently running a query
+ *
+ * Registrars typically reject duplicate names, so to export metrics for more
+ * than one DBConn, give each registrar its own prefix or constant labels.
+ */
+func (dbconn *DBConn) EnableMetrics(registrar MetricsRegistrar) {
+	dbconn.AddQueryHook(&MetricsHook{
+		Queries:       registrar.NewCounter("dbconn_queries_total", "Total number of queries run."),
+		Errors:        registrar.NewCounter("dbconn_query_errors_total", "Total number of queries that returned an error."),
+		QueryDuration: registrar.NewHistogram("dbconn_query_duration_seconds", "Duration of each query in seconds."),
+	})
+	registrar.NewGaugeFunc("dbconn_open_connections", "Number of open database connections.", func() float64 {
+		open, _ := dbconn.connectionStats()
+		return float64(open)
+	})
+	registrar.NewGaugeFunc("dbconn_in_use_connections", "Number of database connections currently running a query.", func() float64 {
+		_, inUse := dbconn.connectionStats()
+		return float64(inUse)
+	})
+}
+
+func (dbconn *DBConn) connectionStats() (open int, inUse int) {
+	for connNum := range dbconn.ConnPool {
+		conn := dbconn.pooled(connNum)
+		if conn == nil {
+			continue
+		}
+		stats := conn.Stats()
+		open += stats.OpenConnections
+		inUse += stats.InUse
+	}
+	return open, inUse
+}
@@ -0,0 +1,73 @@
+package dbconn_test
+
+import (
+	"errors"
+
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type testCounter struct{ value int }
+
+func (counter *testCounter) Inc() { counter.value++ }
+
+type testHistogram struct{ values []float64 }
+
+func (histogram *testHistogram) Observe(value float64) {
+	histogram.values = append(histogram.values, value)
+}
+
+type testRegistrar struct {
+	counters   map[string]*testCounter
+	histograms map[string]*testHistogram
+	gauges     map[string]func() float64
+}
+
+func (registrar *testRegistrar) NewCounter(name string, help string) dbconn.Counter {
+	registrar.counters[name] = &testCounter{}
+	return registrar.counters[name]
+}
+
+func (registrar *testRegistrar) NewHistogram(name string, help string) dbconn.Histogram {
+	registrar.histograms[name] = &testHistogram{}
+	return registrar.histograms[name]
+}
+
+func (registrar *testRegistrar) NewGaugeFunc(name string, help string, fn func() float64) {
+	registrar.gauges[name] = fn
+}
+
+var _ = Describe("dbconn/metrics tests", func() {
+	var registrar *testRegistrar
+	BeforeEach(func() {
+		connection, mock = testhelper.CreateAndConnectMockDB(2)
+		registrar = &testRegistrar{counters: map[string]*testCounter{}, histograms: map[string]*testHistogram{}, gauges: map[string]func() float64{}}
+		connection.EnableMetrics(registrar)
+	})
+	It("registers each metric", func() {
+		Expect(registrar.counters).To(HaveKey("dbconn_queries_total"))
+		Expect(registrar.counters).To(HaveKey("dbconn_query_errors_total"))
+		Expect(registrar.histograms).To(HaveKey("dbconn_query_duration_seconds"))
+		Expect(registrar.gauges).To(HaveKey("dbconn_open_connections"))
+		Expect(registrar.gauges).To(HaveKey("dbconn_in_use_connections"))
+	})
+	It("counts queries and errors and records their durations", func() {
+		mock.ExpectExec("SELECT 1").WillReturnResult(testhelper.TestResult{})
+		mock.ExpectExec("SELECT 2").WillReturnError(errors.New("syntax error"))
+
+		connection.MustExec("SELECT 1")
+		_, _ = connection.Exec("SELECT 2", 1)
+		Expect(registrar.counters["dbconn_queries_total"].value).To(Equal(2))
+		Expect(registrar.counters["dbconn_query_errors_total"].value).To(Equal(1))
+		Expect(registrar.histograms["dbconn_query_duration_seconds"].values).To(HaveLen(2))
+	})
+	It("reports connection counts across the pool", func() {
+		Expect(registrar.gauges["dbconn_open_connections"]()).To(BeNumerically(">=", 0))
+		Expect(registrar.gauges["dbconn_in_use_connections"]()).To(Equal(float64(0)))
+		connection.Close()
+		Expect(registrar.gauges["dbconn_open_connections"]()).To(Equal(float64(0)))
+	})
+})
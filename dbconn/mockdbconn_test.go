@@ -0,0 +1,55 @@
+package dbconn_test
+
+import (
+	"database/sql/driver"
+	"errors"
+
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("testhelper/mockdbconn tests", func() {
+	var mockConn *testhelper.MockDBConn
+	BeforeEach(func() {
+		mockConn = testhelper.NewMockDBConn(testhelper.MakeVersion(dbconn.CBDB, "1.6.0", "14.4"), 2)
+	})
+	AfterEach(func() {
+		mockConn.ExpectationsWereMet()
+	})
+
+	It("reports the version it was created with without querying it", func() {
+		Expect(mockConn.Version.IsCBDB()).To(BeTrue())
+		Expect(mockConn.Version.Is("1.6.0")).To(BeTrue())
+		Expect(mockConn.Version.PGSemVer.String()).To(Equal("14.4.0"))
+		Expect(mockConn.NumConns).To(Equal(2))
+		Expect(mockConn.Transcript()).To(BeEmpty())
+	})
+	It("records the queries run on any connection in order", func() {
+		mockConn.ExpectExec("SET search_path TO sales", 0)
+		mockConn.ExpectSelect("SELECT 1", []string{"a"}, []driver.Value{1})
+		mockConn.ExpectExecError("DROP TABLE foo", errors.New("permission denied"))
+
+		mockConn.MustExec("SET search_path TO sales", 1)
+		var a int
+		Expect(mockConn.Get(&a, "SELECT 1")).To(Succeed())
+		_, err := mockConn.Exec("DROP TABLE foo")
+		Expect(err).To(MatchError("permission denied"))
+		Expect(mockConn.Transcript()).To(Equal([]string{"SET search_path TO sales", "SELECT 1", "DROP TABLE foo"}))
+
+		mockConn.ResetTranscript()
+		Expect(mockConn.Transcript()).To(BeEmpty())
+	})
+	It("matches queries literally rather than as regular expressions", func() {
+		query := "SELECT count(*) FROM pg_class WHERE relname LIKE 'gp_%' AND relkind = ANY($1)"
+		mockConn.ExpectSelect(query, []string{"count"}, []driver.Value{3})
+		mockConn.ExpectSelectError("SELECT (a)", errors.New("syntax error"))
+
+		var count int
+		Expect(mockConn.Get(&count, query)).To(Succeed())
+		Expect(count).To(Equal(3))
+		Expect(mockConn.Get(&count, "SELECT (a)")).To(MatchError("syntax error"))
+	})
+})
@@ -0,0 +1,89 @@
+package dbconn
+
+/*
+ * This file contains functions for determining what kind of node a connection
+ * is connected to, so that a tool can refuse to make changes on a standby or
+ * on a segment reached in utility mode.
+ */
+
+import (
+	"github.com/pkg/errors"
+)
+
+type NodeType int
+
+const (
+	NODE_COORDINATOR NodeType = iota
+	NODE_STANDBY
+	NODE_SEGMENT
+)
+
+func (nodeType NodeType) String() string {
+	switch nodeType {
+	case NODE_STANDBY:
+		return "standby coordinator"
+	case NODE_SEGMENT:
+		return "segment"
+	default:
+		return "coordinator"
+	}
+}
+
+/*
+ * IsInRecovery reports whether the node is in recovery, as a hot standby is.
+ * GPDB 5 does not allow connections to a node in recovery, so there it always
+ * returns false.
+ */
+func (dbconn *DBConn) IsInRecovery(whichConn ...int) (bool, error) {
+	if dbconn.Version.EffectiveGPDBMajor() != 0 && !dbconn.Version.AtLeastFeatureLevel(6) {
+		return false, nil
+	}
+	var inRecovery bool
+	if err := dbconn.Get(&inRecovery, "SELECT pg_catalog.pg_is_in_recovery()", whichConn...); err != nil {
+		return false, errors.Wrap(err, "Failed to determine whether the node is in recovery")
+	}
+	return inRecovery, nil
+}
+
+/*
+ * GetNodeType reports whether the given connection is to the coordinator, to
+ * a standby coordinator in recovery, or to a segment, which can only be
+ * connected to directly in utility mode.  A database that is not Greenplum or
+ * Cloudberry has no segments, so it is treated as a coordinator.
+ */
+func (dbconn *DBConn) GetNodeType(whichConn ...int) (NodeType, error) {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	if dbconn.Version.EffectiveGPDBMajor() != 0 {
+		contentID, err := SelectInt(dbconn, "SELECT pg_catalog.current_setting('gp_contentid')::int", connNum)
+		if err != nil {
+			return NODE_COORDINATOR, errors.Wrap(err, "Failed to determine the content ID of the node")
+		}
+		if contentID >= 0 {
+			return NODE_SEGMENT, nil
+		}
+	}
+	inRecovery, err := dbconn.IsInRecovery(connNum)
+	if err != nil {
+		return NODE_COORDINATOR, err
+	}
+	if inRecovery {
+		return NODE_STANDBY, nil
+	}
+	return NODE_COORDINATOR, nil
+}
+
+/*
+ * RequireCoordinator returns an error unless the given connection is to a
+ * coordinator that is not in recovery, for tools to call before making
+ * changes.
+ */
+func (dbconn *DBConn) RequireCoordinator(whichConn ...int) error {
+	nodeType, err := dbconn.GetNodeType(whichConn...)
+	if err != nil {
+		return err
+	}
+	if nodeType != NODE_COORDINATOR {
+		return errors.Errorf("Connected to a %s, but this operation must be run on the coordinator", nodeType)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package dbconn_test
+
+import (
+	"errors"
+	"regexp"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/node tests", func() {
+	expectContentID := func(contentID int) {
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_catalog.current_setting('gp_contentid')::int")).WillReturnRows(sqlmock.NewRows([]string{"current_setting"}).AddRow(contentID))
+	}
+	expectInRecovery := func(inRecovery bool) {
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_catalog.pg_is_in_recovery()")).WillReturnRows(sqlmock.NewRows([]string{"pg_is_in_recovery"}).AddRow(inRecovery))
+	}
+	BeforeEach(func() {
+		testhelper.SetDBVersion(connection, "7.0.0")
+	})
+	Describe("DBConn.IsInRecovery", func() {
+		It("reports whether the node is in recovery", func() {
+			expectInRecovery(true)
+
+			Expect(connection.IsInRecovery()).To(BeTrue())
+		})
+		It("returns false without querying on GPDB 5", func() {
+			testhelper.SetDBVersion(connection, "5.1.0")
+
+			Expect(connection.IsInRecovery()).To(BeFalse())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns an error if the query fails", func() {
+			mock.ExpectQuery("pg_is_in_recovery").WillReturnError(errors.New("connection reset"))
+
+			_, err := connection.IsInRecovery()
+			Expect(err).To(MatchError("Failed to determine whether the node is in recovery: connection reset"))
+		})
+	})
+	Describe("DBConn.GetNodeType", func() {
+		It("identifies a coordinator", func() {
+			expectContentID(-1)
+			expectInRecovery(false)
+
+			Expect(connection.GetNodeType()).To(Equal(dbconn.NODE_COORDINATOR))
+		})
+		It("identifies a standby coordinator", func() {
+			expectContentID(-1)
+			expectInRecovery(true)
+
+			Expect(connection.GetNodeType()).To(Equal(dbconn.NODE_STANDBY))
+		})
+		It("identifies a segment", func() {
+			expectContentID(3)
+
+			Expect(connection.GetNodeType()).To(Equal(dbconn.NODE_SEGMENT))
+		})
+		It("treats a database without segments as a coordinator", func() {
+			connection.Version = dbconn.GPDBVersion{Type: dbconn.Unknown}
+			expectInRecovery(false)
+
+			Expect(connection.GetNodeType()).To(Equal(dbconn.NODE_COORDINATOR))
+		})
+		It("returns an error if the content ID cannot be determined", func() {
+			mock.ExpectQuery("gp_contentid").WillReturnError(errors.New("connection reset"))
+
+			_, err := connection.GetNodeType()
+			Expect(err).To(MatchError("Failed to determine the content ID of the node: connection reset"))
+		})
+	})
+	Describe("DBConn.RequireCoordinator", func() {
+		It("succeeds on a coordinator", func() {
+			expectContentID(-1)
+			expectInRecovery(false)
+
+			Expect(connection.RequireCoordinator()).To(Succeed())
+		})
+		It("returns an error on a segment", func() {
+			expectContentID(0)
+
+			Expect(connection.RequireCoordinator()).To(MatchError("Connected to a segment, but this operation must be run on the coordinator"))
+		})
+		It("returns an error on a standby", func() {
+			expectContentID(-1)
+			expectInRecovery(true)
+
+			Expect(connection.RequireCoordinator()).To(MatchError("Connected to a standby coordinator, but this operation must be run on the coordinator"))
+		})
+	})
+})
@@ -0,0 +1,170 @@
+package dbconn
+
+/*
+ * This file contains functions for converting between the sql.Null* types and
+ * pointers, where nil represents NULL, and for selecting values that may be
+ * NULL without losing that information.
+ *
+ * The sql.Null* types can be scanned into directly, while pointers are often
+ * more convenient in structs that are marshaled to JSON or compared in tests;
+ * sqlx scans NULL into a *string or *int struct field as nil, so either may
+ * be used for a nullable column.
+ */
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func NullStringToPtr(value sql.NullString) *string {
+	if !value.Valid {
+		return nil
+	}
+	return &value.String
+}
+
+func PtrToNullString(value *string) sql.NullString {
+	if value == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *value, Valid: true}
+}
+
+func NullInt64ToPtr(value sql.NullInt64) *int64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Int64
+}
+
+func PtrToNullInt64(value *int64) sql.NullInt64 {
+	if value == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: *value, Valid: true}
+}
+
+func NullInt32ToPtr(value sql.NullInt32) *int32 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Int32
+}
+
+func PtrToNullInt32(value *int32) sql.NullInt32 {
+	if value == nil {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{Int32: *value, Valid: true}
+}
+
+func NullFloat64ToPtr(value sql.NullFloat64) *float64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Float64
+}
+
+func PtrToNullFloat64(value *float64) sql.NullFloat64 {
+	if value == nil {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: *value, Valid: true}
+}
+
+func NullBoolToPtr(value sql.NullBool) *bool {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Bool
+}
+
+func PtrToNullBool(value *bool) sql.NullBool {
+	if value == nil {
+		return sql.NullBool{}
+	}
+	return sql.NullBool{Bool: *value, Valid: true}
+}
+
+func NullTimeToPtr(value sql.NullTime) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Time
+}
+
+func PtrToNullTime(value *time.Time) sql.NullTime {
+	if value == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *value, Valid: true}
+}
+
+/*
+ * ValueOrZero returns the value a pointer refers to, or the zero value of its
+ * type if the pointer is nil, along with whether it was nil, so that callers
+ * can use a default for NULL without conflating it with a genuine zero value.
+ */
+func ValueOrZero[T any](value *T) (result T, wasNull bool) {
+	if value == nil {
+		return result, true
+	}
+	return *value, false
+}
+
+/*
+ * SelectNullableString and SelectNullableInt are like SelectString and
+ * SelectInt, but return nil rather than the zero value if the query returns
+ * NULL or no rows, so that those cases can be told apart from an empty string
+ * or zero.
+ */
+func SelectNullableString(connection *DBConn, query string, whichConn ...int) (*string, error) {
+	var result sql.NullString
+	err := selectNullable(connection, &result, query, whichConn...)
+	if err != nil {
+		return nil, err
+	}
+	return NullStringToPtr(result), nil
+}
+
+func SelectNullableInt(connection *DBConn, query string, whichConn ...int) (*int, error) {
+	var result sql.NullInt64
+	err := selectNullable(connection, &result, query, whichConn...)
+	if err != nil || !result.Valid {
+		return nil, err
+	}
+	value := int(result.Int64)
+	return &value, nil
+}
+
+// selectNullable scans at most one row of a single column into destination, leaving it unchanged if there are no rows.
+func selectNullable(connection *DBConn, destination interface{}, query string, whichConn ...int) error {
+	connNum := connection.ValidateConnNum(whichConn...)
+	rows, err := connection.Query(query, connNum)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if cols, _ := rows.Rows.Columns(); len(cols) > 1 {
+		return errors.Errorf("Too many columns returned from query: got %d columns, expected 1 column", len(cols))
+	}
+	numRows := 0
+	for rows.Rows.Next() {
+		numRows++
+		if numRows > 1 {
+			continue
+		}
+		if err = rows.Rows.Scan(destination); err != nil {
+			return err
+		}
+	}
+	if err = rows.Rows.Err(); err != nil {
+		return err
+	}
+	if numRows > 1 {
+		return errors.Errorf("Too many rows returned from query: got %d rows, expected 1 row", numRows)
+	}
+	return nil
+}
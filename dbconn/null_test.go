@@ -0,0 +1,143 @@
+package dbconn_test
+
+import (
+	"database/sql"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/null tests", func() {
+	Describe("conversions between sql.Null types and pointers", func() {
+		It("converts a NULL value to nil and back", func() {
+			Expect(dbconn.NullStringToPtr(sql.NullString{})).To(BeNil())
+			Expect(dbconn.NullInt64ToPtr(sql.NullInt64{})).To(BeNil())
+			Expect(dbconn.NullInt32ToPtr(sql.NullInt32{})).To(BeNil())
+			Expect(dbconn.NullFloat64ToPtr(sql.NullFloat64{})).To(BeNil())
+			Expect(dbconn.NullBoolToPtr(sql.NullBool{})).To(BeNil())
+			Expect(dbconn.NullTimeToPtr(sql.NullTime{})).To(BeNil())
+
+			Expect(dbconn.PtrToNullString(nil)).To(Equal(sql.NullString{}))
+			Expect(dbconn.PtrToNullInt64(nil)).To(Equal(sql.NullInt64{}))
+			Expect(dbconn.PtrToNullInt32(nil)).To(Equal(sql.NullInt32{}))
+			Expect(dbconn.PtrToNullFloat64(nil)).To(Equal(sql.NullFloat64{}))
+			Expect(dbconn.PtrToNullBool(nil)).To(Equal(sql.NullBool{}))
+			Expect(dbconn.PtrToNullTime(nil)).To(Equal(sql.NullTime{}))
+		})
+		It("converts a zero value to a pointer to the zero value and back", func() {
+			str := dbconn.NullStringToPtr(sql.NullString{Valid: true})
+			Expect(str).ToNot(BeNil())
+			Expect(*str).To(Equal(""))
+			Expect(dbconn.PtrToNullString(str)).To(Equal(sql.NullString{Valid: true}))
+
+			num := dbconn.NullInt64ToPtr(sql.NullInt64{Valid: true})
+			Expect(num).ToNot(BeNil())
+			Expect(*num).To(Equal(int64(0)))
+			Expect(dbconn.PtrToNullInt64(num)).To(Equal(sql.NullInt64{Valid: true}))
+
+			flag := dbconn.NullBoolToPtr(sql.NullBool{Valid: true})
+			Expect(flag).ToNot(BeNil())
+			Expect(*flag).To(BeFalse())
+			Expect(dbconn.PtrToNullBool(flag)).To(Equal(sql.NullBool{Valid: true}))
+		})
+		It("converts a non-NULL value to a pointer and back", func() {
+			now := time.Now()
+			Expect(*dbconn.NullStringToPtr(sql.NullString{String: "a", Valid: true})).To(Equal("a"))
+			Expect(*dbconn.NullInt32ToPtr(sql.NullInt32{Int32: 3, Valid: true})).To(Equal(int32(3)))
+			Expect(*dbconn.NullFloat64ToPtr(sql.NullFloat64{Float64: 1.5, Valid: true})).To(Equal(1.5))
+			Expect(*dbconn.NullTimeToPtr(sql.NullTime{Time: now, Valid: true})).To(Equal(now))
+
+			num := int32(3)
+			Expect(dbconn.PtrToNullInt32(&num)).To(Equal(sql.NullInt32{Int32: 3, Valid: true}))
+			value := 1.5
+			Expect(dbconn.PtrToNullFloat64(&value)).To(Equal(sql.NullFloat64{Float64: 1.5, Valid: true}))
+			Expect(dbconn.PtrToNullTime(&now)).To(Equal(sql.NullTime{Time: now, Valid: true}))
+		})
+	})
+	Describe("ValueOrZero", func() {
+		It("returns the zero value and true for nil", func() {
+			value, wasNull := dbconn.ValueOrZero[int](nil)
+			Expect(value).To(Equal(0))
+			Expect(wasNull).To(BeTrue())
+		})
+		It("returns the value and false otherwise", func() {
+			str := ""
+			value, wasNull := dbconn.ValueOrZero(&str)
+			Expect(value).To(Equal(""))
+			Expect(wasNull).To(BeFalse())
+		})
+	})
+	Describe("SelectNullableString", func() {
+		It("returns a pointer to the value", func() {
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow(""))
+
+			result, err := dbconn.SelectNullableString(connection, "SELECT ''")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).ToNot(BeNil())
+			Expect(*result).To(Equal(""))
+		})
+		It("returns nil for a NULL value", func() {
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow(nil))
+
+			result, err := dbconn.SelectNullableString(connection, "SELECT NULL")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(BeNil())
+		})
+		It("returns nil if there are no rows", func() {
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(sqlmock.NewRows([]string{"name"}))
+
+			result, err := dbconn.SelectNullableString(connection, "SELECT name FROM foo")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(BeNil())
+		})
+		It("returns an error if there are multiple rows", func() {
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("a").AddRow("b"))
+
+			_, err := dbconn.SelectNullableString(connection, "SELECT name FROM foo")
+			Expect(err).To(MatchError("Too many rows returned from query: got 2 rows, expected 1 row"))
+		})
+		It("returns an error if there are multiple columns", func() {
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(sqlmock.NewRows([]string{"schema", "name"}).AddRow("a", "b"))
+
+			_, err := dbconn.SelectNullableString(connection, "SELECT schema, name FROM foo")
+			Expect(err).To(MatchError("Too many columns returned from query: got 2 columns, expected 1 column"))
+		})
+	})
+	Describe("SelectNullableInt", func() {
+		It("returns a pointer to the value", func() {
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+			result, err := dbconn.SelectNullableInt(connection, "SELECT 0")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).ToNot(BeNil())
+			Expect(*result).To(Equal(0))
+		})
+		It("returns nil for a NULL value", func() {
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(nil))
+
+			result, err := dbconn.SelectNullableInt(connection, "SELECT NULL::int")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(BeNil())
+		})
+	})
+	It("scans NULL into pointer struct fields as nil", func() {
+		type column struct {
+			Name    string  `db:"attname"`
+			Default *string `db:"adsrc"`
+			Length  *int    `db:"atttypmod"`
+		}
+		mock.ExpectQuery("SELECT (.*)").WillReturnRows(sqlmock.NewRows([]string{"attname", "adsrc", "atttypmod"}).AddRow("a", nil, nil).AddRow("b", "0", 4))
+
+		columns := make([]column, 0)
+		err := connection.Select(&columns, "SELECT attname, adsrc, atttypmod FROM columns")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(columns[0].Default).To(BeNil())
+		Expect(columns[0].Length).To(BeNil())
+		Expect(*columns[1].Default).To(Equal("0"))
+		Expect(*columns[1].Length).To(Equal(4))
+	})
+})
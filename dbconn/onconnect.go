@@ -0,0 +1,64 @@
+package dbconn
+
+/*
+ * This file contains functions for initializing each pooled connection as it
+ * is established, such as to set GUCs, load extensions, or set a role, so that
+ * every connection in the pool has the same session state.
+ */
+
+import (
+	"github.com/pkg/errors"
+)
+
+/*
+ * OnConnect adds a hook to be called with each pooled connection when Connect
+ * establishes it, and again whenever it is re-established, whether on demand
+ * after ConnectOptions.Lazy, under a ReconnectPolicy, or by ValidateConnPool.
+ * Hooks run in the order they were added, after the database version is
+ * known and any GUCs set with SetGUC have been restored.  If a hook returns an
+ * error, Connect fails, or the connection is discarded, to be established
+ * again when next used.
+ *
+ * As with SetGUC, session state set by a hook only applies to the one
+ * session per connection number that DBConn maintains by default; sessions
+ * that database/sql opens beyond that, under a higher MaxOpenConns or after
+ * ConnMaxLifetime, do not run the hooks.  Add hooks before calling Connect.
+ */
+func (dbconn *DBConn) OnConnect(hook func(conn *ConnHandle) error) {
+	dbconn.ConnectHooks = append(dbconn.ConnectHooks, hook)
+}
+
+func (dbconn *DBConn) runConnectHooks(connNum int) error {
+	handle := &ConnHandle{dbconn: dbconn, connNum: connNum}
+	for _, hook := range dbconn.ConnectHooks {
+		if err := hook(handle); err != nil {
+			return errors.Wrapf(err, "Failed to initialize connection %d", connNum)
+		}
+	}
+	return nil
+}
+
+/*
+ * initializeConn determines the database version if it is not yet known and
+ * runs the connect hooks on a newly established connection.  If either fails,
+ * the connection is closed and removed from the pool.
+ */
+func (dbconn *DBConn) initializeConn(connNum int) error {
+	var err error
+	if dbconn.Version.VersionString == "" {
+		var version GPDBVersion
+		if version, err = InitializeVersion(dbconn); err != nil {
+			err = errors.Wrap(err, "Failed to determine database version")
+		} else {
+			dbconn.Version = version
+		}
+	}
+	if err == nil {
+		err = dbconn.runConnectHooks(connNum)
+	}
+	if err != nil {
+		_ = dbconn.pooled(connNum).Close()
+		dbconn.setPooled(connNum, nil)
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package dbconn_test
+
+import (
+	"errors"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+	"github.com/jmoiron/sqlx"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/onconnect tests", func() {
+	var initialized []int
+	setRole := func(conn *dbconn.ConnHandle) error {
+		initialized = append(initialized, conn.ConnNum())
+		_, err := conn.Exec("SET ROLE loader")
+		return err
+	}
+
+	BeforeEach(func() {
+		initialized = nil
+		connection, mock = testhelper.CreateMockDBConn()
+	})
+
+	Describe("DBConn.OnConnect", func() {
+		It("runs the hooks on each connection made by Connect, once the version is known", func() {
+			var versions []string
+			connection.OnConnect(setRole)
+			connection.OnConnect(func(conn *dbconn.ConnHandle) error {
+				versions = append(versions, connection.Version.VersionString)
+				return nil
+			})
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+			mock.ExpectExec("SET ROLE loader").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("SET ROLE loader").WillReturnResult(sqlmock.NewResult(0, 0))
+
+			Expect(connection.Connect(2)).To(Succeed())
+			Expect(initialized).To(Equal([]int{0, 1}))
+			Expect(versions).To(HaveLen(2))
+			Expect(versions[0]).To(ContainSubstring("7.0.0"))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("fails Connect if a hook fails", func() {
+			connection.OnConnect(setRole)
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+			mock.ExpectExec("SET ROLE loader").WillReturnError(errors.New(`role "loader" does not exist`))
+
+			err := connection.Connect(1)
+			Expect(err).To(MatchError(`Failed to initialize connection 0: role "loader" does not exist`))
+		})
+		It("runs the hooks when a connection is established lazily, and retries after a failure", func() {
+			connection.OnConnect(setRole)
+			Expect(connection.ConnectWithOptions(dbconn.ConnectOptions{NumConns: 1, Lazy: true})).To(Succeed())
+			Expect(initialized).To(BeEmpty())
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+			mock.ExpectExec("SET ROLE loader").WillReturnError(errors.New("permission denied"))
+
+			_, err := connection.Exec("DELETE FROM foo")
+			Expect(err).To(MatchError("Failed to initialize connection 0: permission denied"))
+			Expect(connection.IsConnected()).To(BeFalse())
+
+			connection.Driver.(*testhelper.TestDriver).DB, mock = testhelper.CreateMockDB()
+			mock.ExpectExec("SET ROLE loader").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("DELETE FROM foo").WillReturnResult(sqlmock.NewResult(0, 1))
+
+			_, err = connection.Exec("DELETE FROM foo")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(initialized).To(Equal([]int{0, 0}))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("runs the hooks again when a broken connection is re-established", func() {
+			originalDB := connection.Driver.(*testhelper.TestDriver).DB
+			newDB, newMock := testhelper.CreateMockDB()
+			connection.Driver = &sequenceDriver{DBs: []*sqlx.DB{originalDB, newDB}}
+			connection.ReconnectPolicy = &dbconn.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}
+			connection.OnConnect(setRole)
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+			mock.ExpectExec("SET ROLE loader").WillReturnResult(sqlmock.NewResult(0, 0))
+			Expect(connection.Connect(1)).To(Succeed())
+
+			mock.ExpectExec("DELETE FROM foo").WillReturnError(errors.New("server closed the connection unexpectedly"))
+			newMock.ExpectExec("SET ROLE loader").WillReturnResult(sqlmock.NewResult(0, 0))
+			newMock.ExpectExec("DELETE FROM foo").WillReturnResult(sqlmock.NewResult(0, 1))
+
+			_, err := connection.Exec("DELETE FROM foo")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(initialized).To(Equal([]int{0, 0}))
+			Expect(newMock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,164 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to running independent
+ * queries in parallel across the connection pool.
+ */
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/*
+ * A ParallelResultHandler is called once for each query that runs
+ * successfully, with the index of the query in the list passed to
+ * SelectParallel and its rows, which SelectParallel closes after the handler
+ * returns.  Calls are serialized, so the handler does not need to be
+ * concurrency-safe.
+ */
+type ParallelResultHandler func(index int, rows *sqlx.Rows) error
+
+/*
+ * ParallelQueryError collects the errors from every query that failed in a
+ * call to SelectParallel, keyed by the index of the query.
+ */
+type ParallelQueryError struct {
+	NumQueries int
+	Errors     map[int]error
+}
+
+func (e *ParallelQueryError) Error() string {
+	indices := make([]int, 0, len(e.Errors))
+	for index := range e.Errors {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+	messages := make([]string, len(indices))
+	for i, index := range indices {
+		messages[i] = fmt.Sprintf("query %d: %v", index, e.Errors[index])
+	}
+	return fmt.Sprintf("%d of %d queries failed: %s", len(e.Errors), e.NumQueries, strings.Join(messages, "; "))
+}
+
+/*
+ * SelectParallel runs each query on the first free connection in the pool,
+ * using one worker per pooled connection so that at most NumConns queries run
+ * at once, and passes each query's rows to resultHandler.  A failure in one
+ * query, or an error returned by the handler for it, does not stop the others;
+ * if any fail, a *ParallelQueryError describing all of the failures is
+ * returned.  As the queries may run on any connection, they should not depend
+ * on session state, and this function should not be called while a
+ * transaction is in progress on any connection.
+ */
+func (dbconn *DBConn) SelectParallel(queries []string, resultHandler ParallelResultHandler) error {
+	queryIndices := make(chan int, len(queries))
+	for i := range queries {
+		queryIndices <- i
+	}
+	close(queryIndices)
+
+	var mutex sync.Mutex
+	errs := make(map[int]error)
+	var wg sync.WaitGroup
+	for connNum := 0; connNum < dbconn.NumConns; connNum++ {
+		wg.Add(1)
+		go func(whichConn int) {
+			defer wg.Done()
+			for index := range queryIndices {
+				rows, err := dbconn.queryRows(context.Background(), dbconn.queryer(whichConn), whichConn, queries[index])
+				mutex.Lock()
+				if err == nil {
+					err = resultHandler(index, rows.Rows)
+					if closeErr := rows.Close(); err == nil {
+						err = closeErr
+					}
+				}
+				if err != nil {
+					errs[index] = err
+				}
+				mutex.Unlock()
+			}
+		}(connNum)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return &ParallelQueryError{NumQueries: len(queries), Errors: errs}
+	}
+	return nil
+}
+
+/*
+ * ForEachConnError collects the errors returned by fn in a call to
+ * ForEachConn, keyed by connection number.
+ */
+type ForEachConnError struct {
+	NumConns int
+	Errors   map[int]error
+}
+
+func (e *ForEachConnError) Error() string {
+	connNums := make([]int, 0, len(e.Errors))
+	for connNum := range e.Errors {
+		connNums = append(connNums, connNum)
+	}
+	sort.Ints(connNums)
+	messages := make([]string, len(connNums))
+	for i, connNum := range connNums {
+		messages[i] = fmt.Sprintf("connection %d: %v", connNum, e.Errors[connNum])
+	}
+	return fmt.Sprintf("%d of %d connections failed: %s", len(e.Errors), e.NumConns, strings.Join(messages, "; "))
+}
+
+/*
+ * ForEachConn calls fn once for each connection in the pool, each in its own
+ * goroutine, and waits for them all to return, so that each worker has a
+ * connection to itself.  An error from one call does not stop the others; if
+ * any fail, a *ForEachConnError describing all of the failures is returned.
+ */
+func (dbconn *DBConn) ForEachConn(fn func(connNum int) error) error {
+	var mutex sync.Mutex
+	errs := make(map[int]error)
+	var wg sync.WaitGroup
+	for connNum := 0; connNum < dbconn.NumConns; connNum++ {
+		wg.Add(1)
+		go func(whichConn int) {
+			defer wg.Done()
+			if err := fn(whichConn); err != nil {
+				mutex.Lock()
+				errs[whichConn] = err
+				mutex.Unlock()
+			}
+		}(connNum)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return &ForEachConnError{NumConns: dbconn.NumConns, Errors: errs}
+	}
+	return nil
+}
+
+/*
+ * NextConn returns connection numbers in turn, starting from 0 and wrapping
+ * around after the last connection in the pool, so that workers started one
+ * at a time can each claim a connection without coordinating among
+ * themselves.  It is safe for concurrent use.  The first NumConns calls after
+ * connecting return distinct connections; with more workers than that, some
+ * will share a connection and must not use it at the same time.  Calling it
+ * before connecting is considered programmer error and causes a Fatal error.
+ */
+func (dbconn *DBConn) NextConn() int {
+	if dbconn.NumConns < 1 {
+		gplog.Fatal(errors.Errorf("Cannot allocate a connection before connecting"), "")
+	}
+	next := atomic.AddUint64(&dbconn.nextConn, 1) - 1
+	return int(next % uint64(dbconn.NumConns))
+}
@@ -0,0 +1,127 @@
+package dbconn_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+	"github.com/jmoiron/sqlx"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/parallel tests", func() {
+	var results map[int][]string
+	collect := func(index int, rows *sqlx.Rows) error {
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				return err
+			}
+			results[index] = append(results[index], name)
+		}
+		return rows.Err()
+	}
+	BeforeEach(func() {
+		connection, mock = testhelper.CreateAndConnectMockDB(2)
+		mock.MatchExpectationsInOrder(false)
+		results = make(map[int][]string)
+	})
+	Describe("DBConn.SelectParallel", func() {
+		It("runs every query and passes each result to the handler", func() {
+			mock.ExpectQuery("SELECT nspname").WillReturnRows(sqlmock.NewRows([]string{"nspname"}).AddRow("public").AddRow("myschema"))
+			mock.ExpectQuery("SELECT relname").WillReturnRows(sqlmock.NewRows([]string{"relname"}).AddRow("foo"))
+			mock.ExpectQuery("SELECT proname").WillReturnRows(sqlmock.NewRows([]string{"proname"}))
+
+			err := connection.SelectParallel([]string{
+				"SELECT nspname FROM pg_namespace",
+				"SELECT relname FROM pg_class",
+				"SELECT proname FROM pg_proc",
+			}, collect)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(Equal(map[int][]string{0: {"public", "myschema"}, 1: {"foo"}}))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("continues past failing queries and reports every error", func() {
+			mock.ExpectQuery("SELECT nspname").WillReturnError(errors.New("permission denied"))
+			mock.ExpectQuery("SELECT relname").WillReturnRows(sqlmock.NewRows([]string{"relname"}).AddRow("foo"))
+			mock.ExpectQuery("SELECT proname").WillReturnRows(sqlmock.NewRows([]string{"proname"}).AddRow("bar"))
+
+			err := connection.SelectParallel([]string{
+				"SELECT nspname FROM pg_namespace",
+				"SELECT relname FROM pg_class",
+				"SELECT proname FROM pg_proc",
+			}, func(index int, rows *sqlx.Rows) error {
+				if index == 2 {
+					return errors.New("unexpected function")
+				}
+				return collect(index, rows)
+			})
+			Expect(err).To(MatchError("2 of 3 queries failed: query 0: permission denied; query 2: unexpected function"))
+			var parallelErr *dbconn.ParallelQueryError
+			Expect(errors.As(err, &parallelErr)).To(BeTrue())
+			Expect(parallelErr.Errors).To(HaveLen(2))
+			Expect(results).To(Equal(map[int][]string{1: {"foo"}}))
+		})
+		It("does nothing if there are no queries", func() {
+			Expect(connection.SelectParallel(nil, collect)).To(Succeed())
+		})
+	})
+	Describe("DBConn.ForEachConn", func() {
+		It("calls the function once for each connection", func() {
+			var mutex sync.Mutex
+			connNums := make([]int, 0)
+			err := connection.ForEachConn(func(connNum int) error {
+				mutex.Lock()
+				defer mutex.Unlock()
+				connNums = append(connNums, connNum)
+				return nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(connNums).To(ConsistOf(0, 1))
+		})
+		It("reports the error from every connection that failed", func() {
+			err := connection.ForEachConn(func(connNum int) error {
+				return fmt.Errorf("worker %d failed", connNum)
+			})
+			Expect(err).To(MatchError("2 of 2 connections failed: connection 0: worker 0 failed; connection 1: worker 1 failed"))
+			var forEachErr *dbconn.ForEachConnError
+			Expect(errors.As(err, &forEachErr)).To(BeTrue())
+			Expect(forEachErr.Errors).To(HaveKey(1))
+		})
+		It("succeeds if every call succeeds", func() {
+			Expect(connection.ForEachConn(func(connNum int) error { return nil })).To(Succeed())
+		})
+	})
+	Describe("DBConn.NextConn", func() {
+		It("allocates connections in turn", func() {
+			connNums := make([]int, 5)
+			for i := range connNums {
+				connNums[i] = connection.NextConn()
+			}
+			Expect(connNums).To(Equal([]int{0, 1, 0, 1, 0}))
+		})
+		It("gives concurrent callers distinct connections", func() {
+			var wg sync.WaitGroup
+			connNums := make([]int, 2)
+			for i := range connNums {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					connNums[i] = connection.NextConn()
+				}(i)
+			}
+			wg.Wait()
+			Expect(connNums).To(ConsistOf(0, 1))
+		})
+		It("panics before connecting", func() {
+			unconnected := dbconn.NewDBConn("testdb", "testrole", "testhost", 5432)
+			defer testhelper.ShouldPanicWithMessage("Cannot allocate a connection before connecting")
+			unconnected.NextConn()
+		})
+	})
+})
@@ -0,0 +1,57 @@
+package dbconn
+
+/*
+ * This file contains functions related to checking that pooled connections
+ * are still usable, such as before a long-idle program starts work.
+ */
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * Ping checks that every connection in the pool can reach the server, and
+ * returns an error naming the first one that cannot.  Connections with a
+ * transaction in progress are skipped, as they cannot be checked without
+ * disturbing the transaction.
+ */
+func (dbconn *DBConn) Ping(ctx context.Context) error {
+	for connNum := range dbconn.ConnPool {
+		if dbconn.Tx[connNum] != nil {
+			continue
+		}
+		conn, err := dbconn.pooledConn(connNum)
+		if err != nil {
+			return errors.Wrapf(err, "Connection %d failed to respond", connNum)
+		}
+		if err := conn.PingContext(ctx); err != nil {
+			return errors.Wrapf(err, "Connection %d failed to respond", connNum)
+		}
+	}
+	return nil
+}
+
+/*
+ * ValidateConnPool pings every connection in the pool, as Ping does, and
+ * re-establishes any that fail, as under a ReconnectPolicy, so that a program
+ * that has been idle can make sure its connections work before starting a
+ * sequence of queries.  It returns an error if a connection cannot be
+ * re-established, after trying the rest of the pool.
+ */
+func (dbconn *DBConn) ValidateConnPool() error {
+	var firstErr error
+	for connNum := range dbconn.ConnPool {
+		if dbconn.Tx[connNum] != nil {
+			continue
+		}
+		if conn := dbconn.pooled(connNum); conn != nil && conn.PingContext(context.Background()) == nil {
+			continue
+		}
+		if err := dbconn.establish(connNum); err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, "Failed to re-establish connection %d", connNum)
+		}
+	}
+	return firstErr
+}
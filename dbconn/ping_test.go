@@ -0,0 +1,102 @@
+package dbconn_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+	"github.com/jmoiron/sqlx"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func createPingMockDB() (*sqlx.DB, sqlmock.Sqlmock) {
+	db, pingMock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	Expect(err).ToNot(HaveOccurred())
+	return sqlx.NewDb(db, "sqlmock"), pingMock
+}
+
+var _ = Describe("dbconn/ping tests", func() {
+	var (
+		dbs      []*sqlx.DB
+		mocks    []sqlmock.Sqlmock
+		driver   *sequenceDriver
+		pingConn *dbconn.DBConn
+	)
+	BeforeEach(func() {
+		dbs, mocks = make([]*sqlx.DB, 3), make([]sqlmock.Sqlmock, 3)
+		for i := range dbs {
+			dbs[i], mocks[i] = createPingMockDB()
+		}
+		driver = &sequenceDriver{DBs: dbs}
+		pingConn = dbconn.NewDBConn("testdb", "testrole", "testhost", 5432)
+		pingConn.Driver = driver
+		testhelper.ExpectVersionQuery(mocks[0], "7.0.0")
+		pingConn.MustConnect(2)
+	})
+	AfterEach(func() {
+		pingConn.Close()
+	})
+
+	Describe("DBConn.Ping", func() {
+		It("pings every connection", func() {
+			mocks[0].ExpectPing()
+			mocks[1].ExpectPing()
+
+			Expect(pingConn.Ping(context.Background())).To(Succeed())
+			Expect(mocks[0].ExpectationsWereMet()).To(Succeed())
+			Expect(mocks[1].ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns an error for a connection that does not respond", func() {
+			mocks[0].ExpectPing()
+			mocks[1].ExpectPing().WillReturnError(errors.New("connection reset by peer"))
+
+			err := pingConn.Ping(context.Background())
+			Expect(err).To(MatchError("Connection 1 failed to respond: connection reset by peer"))
+		})
+		It("skips connections with a transaction in progress", func() {
+			ExpectBegin(mocks[0])
+			mocks[1].ExpectPing()
+
+			pingConn.MustBegin(0)
+			Expect(pingConn.Ping(context.Background())).To(Succeed())
+			Expect(mocks[1].ExpectationsWereMet()).To(Succeed())
+		})
+	})
+	Describe("DBConn.ValidateConnPool", func() {
+		It("leaves responsive connections alone", func() {
+			mocks[0].ExpectPing()
+			mocks[1].ExpectPing()
+
+			Expect(pingConn.ValidateConnPool()).To(Succeed())
+			Expect(pingConn.ConnPool).To(Equal([]*sqlx.DB{dbs[0], dbs[1]}))
+		})
+		It("re-establishes connections that do not respond, restoring their GUCs", func() {
+			for _, pingMock := range mocks[:2] {
+				pingMock.ExpectExec(regexp.QuoteMeta("SELECT pg_catalog.set_config('search_path', 'public', false)")).WillReturnResult(testhelper.TestResult{Rows: 1})
+			}
+			Expect(pingConn.SetGUC("search_path", "public")).To(Succeed())
+			mocks[0].ExpectPing().WillReturnError(errors.New("server closed the connection unexpectedly"))
+			mocks[1].ExpectPing()
+			mocks[2].ExpectExec(regexp.QuoteMeta("SELECT pg_catalog.set_config('search_path', 'public', false)")).WillReturnResult(testhelper.TestResult{Rows: 1})
+
+			Expect(pingConn.ValidateConnPool()).To(Succeed())
+			Expect(pingConn.ConnPool).To(Equal([]*sqlx.DB{dbs[2], dbs[1]}))
+			Expect(mocks[2].ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns an error if a connection cannot be re-established", func() {
+			driver.Errs = []error{nil, nil, errors.New("connection refused")}
+			mocks[0].ExpectPing().WillReturnError(errors.New("server closed the connection unexpectedly"))
+			mocks[1].ExpectPing()
+
+			err := pingConn.ValidateConnPool()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("Failed to re-establish connection 0: could not connect to server: Connection refused"))
+			Expect(mocks[1].ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})
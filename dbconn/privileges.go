@@ -0,0 +1,215 @@
+package dbconn
+
+/*
+ * This file contains structs and functions for retrieving the owners and
+ * access privileges of schemas, relations, and functions, such as to verify
+ * that a restore granted the same privileges as the original database.
+ */
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var privilegeNames = map[byte]string{
+	'r': "SELECT",
+	'w': "UPDATE",
+	'a': "INSERT",
+	'd': "DELETE",
+	'D': "TRUNCATE",
+	'x': "REFERENCES",
+	't': "TRIGGER",
+	'X': "EXECUTE",
+	'U': "USAGE",
+	'C': "CREATE",
+	'c': "CONNECT",
+	'T': "TEMPORARY",
+}
+
+/*
+ * An ACLItem is one entry of an access control list, as stored in aclitem form
+ * such as "gpadmin=arwdDxt/gpadmin".  An empty Grantee means PUBLIC.
+ * Privileges holds the privilege letters granted, and GrantOptions those of
+ * them the grantee may in turn grant to others.
+ */
+type ACLItem struct {
+	Grantee      string
+	Grantor      string
+	Privileges   string
+	GrantOptions string
+}
+
+// PrivilegeNames returns the names of the privileges granted, such as "SELECT", in the order they appear.
+func (item ACLItem) PrivilegeNames() []string {
+	names := make([]string, 0, len(item.Privileges))
+	for i := 0; i < len(item.Privileges); i++ {
+		if name, ok := privilegeNames[item.Privileges[i]]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+/*
+ * ParseACLItem parses the text form of an aclitem, in which role names that
+ * are not simple lowercase identifiers are double-quoted.
+ */
+func ParseACLItem(text string) (ACLItem, error) {
+	item := ACLItem{}
+	grantee, rest, ok := parseACLRoleName(text)
+	if !ok || !strings.HasPrefix(rest, "=") {
+		return ACLItem{}, errors.Errorf("Invalid ACL item %q", text)
+	}
+	item.Grantee = grantee
+	privileges, grantor, found := strings.Cut(rest[1:], "/")
+	if !found {
+		return ACLItem{}, errors.Errorf("Invalid ACL item %q", text)
+	}
+	for i := 0; i < len(privileges); i++ {
+		if privileges[i] == '*' {
+			if i == 0 {
+				return ACLItem{}, errors.Errorf("Invalid ACL item %q", text)
+			}
+			item.GrantOptions += string(privileges[i-1])
+			continue
+		}
+		if _, ok := privilegeNames[privileges[i]]; !ok {
+			return ACLItem{}, errors.Errorf("Invalid ACL item %q: unknown privilege %q", text, privileges[i])
+		}
+		item.Privileges += string(privileges[i])
+	}
+	item.Grantor, rest, ok = parseACLRoleName(grantor)
+	if !ok || rest != "" {
+		return ACLItem{}, errors.Errorf("Invalid ACL item %q", text)
+	}
+	return item, nil
+}
+
+// parseACLRoleName returns the role name at the start of text, unquoting it if necessary, and the remainder of text.
+func parseACLRoleName(text string) (string, string, bool) {
+	if !strings.HasPrefix(text, `"`) {
+		end := strings.IndexAny(text, "=/")
+		if end == -1 {
+			end = len(text)
+		}
+		return text[:end], text[end:], true
+	}
+	var name strings.Builder
+	for i := 1; i < len(text); i++ {
+		if text[i] == '"' {
+			if i+1 < len(text) && text[i+1] == '"' {
+				name.WriteByte('"')
+				i++
+				continue
+			}
+			return name.String(), text[i+1:], true
+		}
+		name.WriteByte(text[i])
+	}
+	return "", "", false
+}
+
+/*
+ * ObjectPrivileges holds the owner and access control list of a database
+ * object.  ACL is nil if the object has never had its privileges changed and
+ * so has the default privileges for its type, which is not the same as an
+ * empty list, meaning that all privileges have been revoked.  For a function,
+ * Name includes its argument types, as in "myfunc(integer, text)".
+ */
+type ObjectPrivileges struct {
+	Schema string
+	Name   string
+	Owner  string
+	ACL    []ACLItem
+}
+
+type privilegesRow struct {
+	Schema string      `db:"schema"`
+	Name   string      `db:"name"`
+	Owner  string      `db:"owner"`
+	ACL    StringArray `db:"acl"`
+}
+
+func (dbconn *DBConn) selectPrivileges(query string, whichConn ...int) ([]ObjectPrivileges, error) {
+	rows := make([]privilegesRow, 0)
+	if err := dbconn.Select(&rows, query, whichConn...); err != nil {
+		return nil, err
+	}
+	results := make([]ObjectPrivileges, len(rows))
+	for i, row := range rows {
+		results[i] = ObjectPrivileges{Schema: row.Schema, Name: row.Name, Owner: row.Owner}
+		if row.ACL == nil {
+			continue
+		}
+		results[i].ACL = make([]ACLItem, len(row.ACL))
+		for j, text := range row.ACL {
+			item, err := ParseACLItem(text)
+			if err != nil {
+				return nil, err
+			}
+			results[i].ACL[j] = item
+		}
+	}
+	return results, nil
+}
+
+// GetSchemaPrivileges returns the owner and privileges of each user schema, ordered by name.
+func (dbconn *DBConn) GetSchemaPrivileges(whichConn ...int) ([]ObjectPrivileges, error) {
+	query := fmt.Sprintf(`
+	SELECT n.nspname AS schema,
+		n.nspname AS name,
+		pg_catalog.pg_get_userbyid(n.nspowner) AS owner,
+		n.nspacl::text[] AS acl
+	FROM pg_catalog.pg_namespace n
+	WHERE %s
+	ORDER BY n.nspname`, systemSchemaCondition)
+	results, err := dbconn.selectPrivileges(query, whichConn...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get schema privileges")
+	}
+	return results, nil
+}
+
+// GetRelationPrivileges returns the owner and privileges of each relation in the given schema that ListRelations would return.
+func (dbconn *DBConn) GetRelationPrivileges(schema string, whichConn ...int) ([]ObjectPrivileges, error) {
+	query := fmt.Sprintf(`
+	SELECT n.nspname AS schema,
+		c.relname AS name,
+		pg_catalog.pg_get_userbyid(c.relowner) AS owner,
+		c.relacl::text[] AS acl
+	FROM pg_catalog.pg_class c
+		JOIN pg_catalog.pg_namespace n ON c.relnamespace = n.oid
+	WHERE n.nspname = '%s'
+		AND %s
+	ORDER BY c.relname`, EscapeString(schema), dbconn.relationKindCondition())
+	results, err := dbconn.selectPrivileges(query, whichConn...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to get privileges of relations in schema %s", schema)
+	}
+	return results, nil
+}
+
+/*
+ * GetFunctionPrivileges returns the owner and privileges of each function in
+ * the given schema, including aggregates and, from GPDB 7 onward, procedures.
+ * The argument list in each name gives only the argument types, without names
+ * or defaults, so that it is the same on every version.
+ */
+func (dbconn *DBConn) GetFunctionPrivileges(schema string, whichConn ...int) ([]ObjectPrivileges, error) {
+	query := fmt.Sprintf(`
+	SELECT n.nspname AS schema,
+		p.proname || '(' || pg_catalog.oidvectortypes(p.proargtypes) || ')' AS name,
+		pg_catalog.pg_get_userbyid(p.proowner) AS owner,
+		p.proacl::text[] AS acl
+	FROM pg_catalog.pg_proc p
+		JOIN pg_catalog.pg_namespace n ON p.pronamespace = n.oid
+	WHERE n.nspname = '%s'
+	ORDER BY name`, EscapeString(schema))
+	results, err := dbconn.selectPrivileges(query, whichConn...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to get privileges of functions in schema %s", schema)
+	}
+	return results, nil
+}
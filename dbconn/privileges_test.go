@@ -0,0 +1,113 @@
+package dbconn_test
+
+import (
+	"errors"
+	"regexp"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/privileges tests", func() {
+	privilegeColumns := []string{"schema", "name", "owner", "acl"}
+
+	Describe("ParseACLItem", func() {
+		DescribeTable("parses valid ACL items", func(text string, expected dbconn.ACLItem) {
+			item, err := dbconn.ParseACLItem(text)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(item).To(Equal(expected))
+		},
+			Entry("owner", "gpadmin=arwdDxt/gpadmin", dbconn.ACLItem{Grantee: "gpadmin", Grantor: "gpadmin", Privileges: "arwdDxt"}),
+			Entry("PUBLIC", "=r/gpadmin", dbconn.ACLItem{Grantee: "", Grantor: "gpadmin", Privileges: "r"}),
+			Entry("grant options", "testrole=r*w/gpadmin", dbconn.ACLItem{Grantee: "testrole", Grantor: "gpadmin", Privileges: "rw", GrantOptions: "r"}),
+			Entry("quoted names", `"Test ""Role"""=U/"Admin"`, dbconn.ACLItem{Grantee: `Test "Role"`, Grantor: "Admin", Privileges: "U"}),
+		)
+		DescribeTable("rejects invalid ACL items", func(text string, expected string) {
+			_, err := dbconn.ParseACLItem(text)
+			Expect(err).To(MatchError(expected))
+		},
+			Entry("no privileges", "gpadmin", `Invalid ACL item "gpadmin"`),
+			Entry("no grantor", "gpadmin=r", `Invalid ACL item "gpadmin=r"`),
+			Entry("unterminated quote", `"gpadmin=r/gpadmin`, `Invalid ACL item "\"gpadmin=r/gpadmin"`),
+			Entry("unknown privilege", "gpadmin=rq/gpadmin", `Invalid ACL item "gpadmin=rq/gpadmin": unknown privilege 'q'`),
+			Entry("leading grant option", "gpadmin=*r/gpadmin", `Invalid ACL item "gpadmin=*r/gpadmin"`),
+		)
+	})
+	Describe("ACLItem.PrivilegeNames", func() {
+		It("returns the names of the privileges", func() {
+			item := dbconn.ACLItem{Privileges: "arwX"}
+			Expect(item.PrivilegeNames()).To(Equal([]string{"INSERT", "SELECT", "UPDATE", "EXECUTE"}))
+		})
+	})
+	Describe("DBConn.GetSchemaPrivileges", func() {
+		It("returns the owner and ACL of each schema", func() {
+			rows := sqlmock.NewRows(privilegeColumns).
+				AddRow("public", "public", "gpadmin", "{gpadmin=UC/gpadmin,=UC/gpadmin}").
+				AddRow("sales", "sales", "testrole", nil)
+			mock.ExpectQuery(`n.nspacl::text\[\] AS acl\s+FROM pg_catalog.pg_namespace n`).WillReturnRows(rows)
+
+			privileges, err := connection.GetSchemaPrivileges()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(privileges).To(Equal([]dbconn.ObjectPrivileges{
+				{Schema: "public", Name: "public", Owner: "gpadmin", ACL: []dbconn.ACLItem{
+					{Grantee: "gpadmin", Grantor: "gpadmin", Privileges: "UC"},
+					{Grantee: "", Grantor: "gpadmin", Privileges: "UC"},
+				}},
+				{Schema: "sales", Name: "sales", Owner: "testrole"},
+			}))
+		})
+		It("distinguishes an empty ACL from default privileges", func() {
+			mock.ExpectQuery("pg_namespace").WillReturnRows(sqlmock.NewRows(privilegeColumns).AddRow("public", "public", "gpadmin", "{}"))
+
+			privileges, err := connection.GetSchemaPrivileges()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(privileges[0].ACL).ToNot(BeNil())
+			Expect(privileges[0].ACL).To(BeEmpty())
+		})
+		It("returns an error if an ACL item cannot be parsed", func() {
+			mock.ExpectQuery("pg_namespace").WillReturnRows(sqlmock.NewRows(privilegeColumns).AddRow("public", "public", "gpadmin", "{gpadmin}"))
+
+			_, err := connection.GetSchemaPrivileges()
+			Expect(err).To(MatchError(`Failed to get schema privileges: Invalid ACL item "gpadmin"`))
+		})
+	})
+	Describe("DBConn.GetRelationPrivileges", func() {
+		It("returns the owner and ACL of each relation in the schema", func() {
+			testhelper.SetDBVersion(connection, "7.0.0")
+			mock.ExpectQuery(regexp.QuoteMeta(`WHERE n.nspname = 'o''brien'
+		AND c.relkind IN ('r', 'v', 'S', 'm', 'f', 'p')`)).WillReturnRows(sqlmock.NewRows(privilegeColumns).AddRow("o'brien", "foo", "gpadmin", "{gpadmin=arwdDxt/gpadmin,testrole=r*/gpadmin}"))
+
+			privileges, err := connection.GetRelationPrivileges("o'brien")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(privileges).To(HaveLen(1))
+			Expect(privileges[0].ACL[1]).To(Equal(dbconn.ACLItem{Grantee: "testrole", Grantor: "gpadmin", Privileges: "r", GrantOptions: "r"}))
+		})
+		It("returns an error if the query fails", func() {
+			mock.ExpectQuery("pg_class").WillReturnError(errors.New("connection reset"))
+
+			_, err := connection.GetRelationPrivileges("public")
+			Expect(err).To(MatchError("Failed to get privileges of relations in schema public: connection reset"))
+		})
+	})
+	Describe("DBConn.GetFunctionPrivileges", func() {
+		It("returns the owner and ACL of each function in the schema", func() {
+			mock.ExpectQuery(regexp.QuoteMeta("p.proname || '(' || pg_catalog.oidvectortypes(p.proargtypes) || ')' AS name")).WillReturnRows(sqlmock.NewRows(privilegeColumns).AddRow("public", "add(integer, integer)", "gpadmin", "{=X/gpadmin}"))
+
+			privileges, err := connection.GetFunctionPrivileges("public")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(privileges).To(Equal([]dbconn.ObjectPrivileges{
+				{Schema: "public", Name: "add(integer, integer)", Owner: "gpadmin", ACL: []dbconn.ACLItem{{Grantor: "gpadmin", Privileges: "X"}}},
+			}))
+		})
+		It("returns an error if the query fails", func() {
+			mock.ExpectQuery("pg_proc").WillReturnError(errors.New("connection reset"))
+
+			_, err := connection.GetFunctionPrivileges("public")
+			Expect(err).To(MatchError("Failed to get privileges of functions in schema public: connection reset"))
+		})
+	})
+})
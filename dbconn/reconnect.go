@@ -0,0 +1,158 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to re-establishing pooled
+ * connections that break while the DBConn is in use, such as when the server
+ * restarts or a firewall drops an idle session.
+ */
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/*
+ * These errors indicate that the session a query ran on has ended, so the
+ * query may succeed on a new connection.
+ */
+var brokenConnectionErrors = []string{
+	"connection reset by peer",
+	"server closed the connection unexpectedly",
+	"broken pipe",
+	"unexpected EOF",
+	"conn closed",
+	"terminating connection due to administrator command",
+	"SQLSTATE 57P01",
+	"SQLSTATE 08006",
+}
+
+func IsBrokenConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	message := strings.ToLower(err.Error())
+	for _, brokenErr := range brokenConnectionErrors {
+		if strings.Contains(message, strings.ToLower(brokenErr)) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * A ReconnectError is returned when a query fails because its connection was
+ * broken and the connection could not be re-established, or the query still
+ * failed on each new connection, within the DBConn's ReconnectPolicy.  Err is
+ * the error from the final attempt.
+ */
+type ReconnectError struct {
+	ConnNum  int
+	Attempts int
+	Err      error
+}
+
+func (err *ReconnectError) Error() string {
+	return fmt.Sprintf("Connection %d is broken and could not be re-established after %d attempts: %v", err.ConnNum, err.Attempts, err.Err)
+}
+
+func (err *ReconnectError) Unwrap() error {
+	return err.Err
+}
+
+/*
+ * withReconnect calls run with queryer and, if the query fails because its
+ * connection was broken and a ReconnectPolicy is set, re-establishes the
+ * connection and calls run again with the new one, backing off between
+ * attempts as the policy specifies.  Queries in a transaction are never
+ * retried, as the transaction ended with the session.
+ *
+ * A query that changes data may have been committed before its connection
+ * broke, so with a ReconnectPolicy set such queries should be idempotent or
+ * run in a transaction.
+ */
+func (dbconn *DBConn) withReconnect(queryer sqlxQueryer, connNum int, run func(queryer sqlxQueryer) error) error {
+	err := run(queryer)
+	if dbconn.ReconnectPolicy == nil || !IsBrokenConnectionError(err) {
+		return err
+	}
+	if _, inTransaction := queryer.(*sqlx.Tx); inTransaction {
+		return err
+	}
+	policy := dbconn.ReconnectPolicy.withDefaults()
+	if policy.MaxAttempts < 2 {
+		return err
+	}
+	backoff := policy.InitialBackoff
+	for attempt := 1; attempt < policy.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(backoff)
+			backoff = policy.nextBackoff(backoff)
+		}
+		log.Warn("Connection %d to %s:%d was broken, reconnecting (attempt %d of %d): %v", connNum, dbconn.Host, dbconn.Port, attempt, policy.MaxAttempts-1, err)
+		if err = dbconn.reconnect(connNum); err != nil {
+			continue
+		}
+		err = run(dbconn.pooled(connNum))
+		if !IsBrokenConnectionError(err) {
+			return err
+		}
+	}
+	return &ReconnectError{ConnNum: connNum, Attempts: policy.MaxAttempts - 1, Err: err}
+}
+
+/*
+ * reconnect replaces the given pooled connection with a new one made the same
+ * way, with the same pool limits and the GUCs set with SetGUC, and runs the
+ * connect hooks on it; see OnConnect.
+ */
+func (dbconn *DBConn) reconnect(connNum int) error {
+	conn, err := dbconn.connect(dbconn.connStr)
+	if err != nil {
+		return dbconn.handleConnectionError(err)
+	}
+	dbconn.poolMutex.RLock()
+	dbconn.limitConnLocked(conn)
+	dbconn.poolMutex.RUnlock()
+	if err = dbconn.restoreGUCs(connNum, conn); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	return dbconn.initializeConn(connNum)
+}
+
+/*
+ * restoreGUCs applies the GUCs set with SetGUC to a new connection and puts it
+ * in the pool.  gucMutex is held until the connection is in the pool, so a
+ * concurrent SetGUC either records its setting before this reads them or
+ * finds the connection already established and applies it there.
+ */
+func (dbconn *DBConn) restoreGUCs(connNum int, conn *sqlx.DB) error {
+	dbconn.gucMutex.Lock()
+	defer dbconn.gucMutex.Unlock()
+	names := make([]string, 0, len(dbconn.sessionGUCs))
+	for name := range dbconn.sessionGUCs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// Run these directly, rather than through exec, so a failure is not itself retried.
+		if _, err := conn.ExecContext(context.Background(), setConfigQuery(name, dbconn.sessionGUCs[name])); err != nil {
+			return errors.Wrapf(err, "Failed to restore %s on connection %d", name, connNum)
+		}
+	}
+	if oldConn := dbconn.pooled(connNum); oldConn != nil {
+		_ = oldConn.Close()
+	}
+	dbconn.setPooled(connNum, conn)
+	return nil
+}
@@ -0,0 +1,152 @@
+package dbconn_test
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+	"github.com/jmoiron/sqlx"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+/*
+ * sequenceDriver returns a different mock database from each call to Connect,
+ * so that a re-established connection can be told apart from the original.
+ */
+type sequenceDriver struct {
+	DBs   []*sqlx.DB
+	Errs  []error
+	calls int
+}
+
+func (driver *sequenceDriver) Connect(driverName string, dataSourceName string) (*sqlx.DB, error) {
+	call := driver.calls
+	driver.calls++
+	if call < len(driver.Errs) && driver.Errs[call] != nil {
+		return nil, driver.Errs[call]
+	}
+	return driver.DBs[call], nil
+}
+
+var _ = Describe("dbconn/reconnect tests", func() {
+	brokenErr := errors.New("server closed the connection unexpectedly")
+	var (
+		originalDB *sqlx.DB
+		newDB      *sqlx.DB
+		newMock    sqlmock.Sqlmock
+		seqDriver  *sequenceDriver
+	)
+	BeforeEach(func() {
+		connection, mock = testhelper.CreateMockDBConn()
+		originalDB = connection.Driver.(*testhelper.TestDriver).DB
+		newDB, newMock = testhelper.CreateMockDB()
+		seqDriver = &sequenceDriver{DBs: []*sqlx.DB{originalDB, newDB, nil}}
+		connection.Driver = seqDriver
+		testhelper.ExpectVersionQuery(mock, "7.0.0")
+		connection.MustConnect(1)
+		connection.ReconnectPolicy = &dbconn.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	})
+
+	Describe("IsBrokenConnectionError", func() {
+		DescribeTable("identifies broken connections", func(err error, expected bool) {
+			Expect(dbconn.IsBrokenConnectionError(err)).To(Equal(expected))
+		},
+			Entry("nil", nil, false),
+			Entry("bad connection", fmt.Errorf("query failed: %w", driver.ErrBadConn), true),
+			Entry("connection reset", errors.New("read tcp 10.0.0.1:5432: read: connection reset by peer"), true),
+			Entry("server closed", brokenErr, true),
+			Entry("administrator shutdown", errors.New("FATAL: terminating connection due to administrator command (SQLSTATE 57P01)"), true),
+			Entry("syntax error", errors.New(`ERROR: syntax error at or near "SELEC" (SQLSTATE 42601)`), false),
+		)
+	})
+	Describe("queries on a broken connection", func() {
+		It("re-establishes the connection and retries the query", func() {
+			mock.ExpectQuery("SELECT 1").WillReturnError(brokenErr)
+			newMock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+
+			var result int
+			err := connection.Get(&result, "SELECT 1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(1))
+			Expect(connection.ConnPool[0]).To(BeIdenticalTo(newDB))
+			Expect(newDB.Stats().MaxOpenConnections).To(Equal(1))
+			Expect(newMock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("re-applies GUCs set with SetGUC and SetStatementTimeout", func() {
+			mock.ExpectExec(regexp.QuoteMeta("SELECT pg_catalog.set_config('search_path', 'public', false)")).WillReturnResult(testhelper.TestResult{Rows: 1})
+			mock.ExpectExec("SET statement_timeout = 5000").WillReturnResult(testhelper.TestResult{Rows: 0})
+			Expect(connection.SetGUC("search_path", "public")).To(Succeed())
+			Expect(connection.SetStatementTimeout(5 * time.Second)).To(Succeed())
+
+			mock.ExpectExec("DELETE FROM foo").WillReturnError(brokenErr)
+			newMock.ExpectExec(regexp.QuoteMeta("SELECT pg_catalog.set_config('search_path', 'public', false)")).WillReturnResult(testhelper.TestResult{Rows: 1})
+			newMock.ExpectExec(regexp.QuoteMeta("SELECT pg_catalog.set_config('statement_timeout', '5000', false)")).WillReturnResult(testhelper.TestResult{Rows: 1})
+			newMock.ExpectExec("DELETE FROM foo").WillReturnResult(testhelper.TestResult{Rows: 2})
+
+			rowsAffected, err := connection.ExecAffected("DELETE FROM foo")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rowsAffected).To(Equal(int64(2)))
+			Expect(newMock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns a ReconnectError if the connection cannot be re-established", func() {
+			seqDriver.Errs = []error{nil, errors.New("connection refused"), errors.New("connection refused")}
+			mock.ExpectQuery("SELECT 1").WillReturnError(brokenErr)
+
+			var result int
+			err := connection.Get(&result, "SELECT 1")
+			var reconnectErr *dbconn.ReconnectError
+			Expect(errors.As(err, &reconnectErr)).To(BeTrue())
+			Expect(reconnectErr.ConnNum).To(Equal(0))
+			Expect(reconnectErr.Attempts).To(Equal(2))
+			Expect(err.Error()).To(HavePrefix("Connection 0 is broken and could not be re-established after 2 attempts: could not connect to server: Connection refused"))
+			Expect(connection.ConnPool[0]).To(BeIdenticalTo(originalDB))
+		})
+		It("returns a ReconnectError if the query fails on each new connection", func() {
+			thirdDB, thirdMock := testhelper.CreateMockDB()
+			seqDriver.DBs[2] = thirdDB
+			mock.ExpectQuery("SELECT 1").WillReturnError(brokenErr)
+			newMock.ExpectQuery("SELECT 1").WillReturnError(brokenErr)
+			thirdMock.ExpectQuery("SELECT 1").WillReturnError(brokenErr)
+
+			var result int
+			err := connection.Get(&result, "SELECT 1")
+			Expect(err).To(MatchError(&dbconn.ReconnectError{ConnNum: 0, Attempts: 2, Err: brokenErr}))
+			Expect(errors.Is(err, brokenErr)).To(BeTrue())
+		})
+		It("does not retry a query in a transaction", func() {
+			ExpectBegin(mock)
+			mock.ExpectQuery("SELECT 1").WillReturnError(brokenErr)
+
+			connection.MustBegin()
+			var result int
+			err := connection.Get(&result, "SELECT 1")
+			Expect(err).To(Equal(brokenErr))
+			Expect(connection.ConnPool[0]).To(BeIdenticalTo(originalDB))
+		})
+		It("does not retry a query that fails for another reason", func() {
+			queryErr := errors.New(`relation "foo" does not exist`)
+			mock.ExpectQuery("SELECT 1").WillReturnError(queryErr)
+
+			var result int
+			err := connection.Get(&result, "SELECT 1")
+			Expect(err).To(Equal(queryErr))
+			Expect(connection.ConnPool[0]).To(BeIdenticalTo(originalDB))
+		})
+		It("does not retry without a ReconnectPolicy", func() {
+			connection.ReconnectPolicy = nil
+			mock.ExpectQuery("SELECT 1").WillReturnError(brokenErr)
+
+			var result int
+			err := connection.Get(&result, "SELECT 1")
+			Expect(err).To(Equal(brokenErr))
+			Expect(connection.ConnPool[0]).To(BeIdenticalTo(originalDB))
+		})
+	})
+})
@@ -0,0 +1,87 @@
+package dbconn
+
+/*
+ * This file contains functions for a process-wide registry of connections by
+ * name, for utilities that work with several databases at once, such as
+ * template1, the target database, and a segment in utility mode, so that each
+ * function can look up the connection it needs rather than having every
+ * connection passed down to it.
+ *
+ *   dbconn.Register("metadata", metadataConn)
+ *   ...
+ *   conn := dbconn.MustGet("metadata")
+ *
+ * The registry is safe for concurrent use, but the DBConns in it are not made
+ * any safer by being registered.
+ */
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/pkg/errors"
+)
+
+var (
+	registryMutex sync.RWMutex
+	registry      = map[string]*DBConn{}
+)
+
+// Register adds a connection to the registry under the given name, replacing any connection already registered under it.
+func Register(name string, connection *DBConn) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	registry[name] = connection
+}
+
+// Unregister removes the connection with the given name from the registry, without closing it.
+func Unregister(name string) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	delete(registry, name)
+}
+
+func Get(name string) (*DBConn, error) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	connection, ok := registry[name]
+	if !ok {
+		return nil, errors.Errorf("No connection is registered as %q", name)
+	}
+	return connection, nil
+}
+
+/*
+ * MustGet is like Get, but a missing connection is considered programmer
+ * error and causes a Fatal error.
+ */
+func MustGet(name string) *DBConn {
+	connection, err := Get(name)
+	gplog.FatalOnError(err)
+	return connection
+}
+
+func RegisteredNames() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*
+ * CloseAll closes every registered connection and empties the registry, such
+ * as when a utility exits.
+ */
+func CloseAll() {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	for name, connection := range registry {
+		connection.Close()
+		delete(registry, name)
+	}
+}
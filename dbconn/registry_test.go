@@ -0,0 +1,72 @@
+package dbconn_test
+
+import (
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/registry tests", func() {
+	AfterEach(func() {
+		for _, name := range dbconn.RegisteredNames() {
+			dbconn.Unregister(name)
+		}
+	})
+	Describe("Register and Get", func() {
+		It("returns the connection registered under a name", func() {
+			dbconn.Register("metadata", connection)
+
+			registered, err := dbconn.Get("metadata")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(registered).To(BeIdenticalTo(connection))
+			Expect(dbconn.MustGet("metadata")).To(BeIdenticalTo(connection))
+		})
+		It("replaces a connection registered under the same name", func() {
+			other := dbconn.NewDBConn("template1", "testrole", "testhost", 5432)
+			dbconn.Register("metadata", connection)
+			dbconn.Register("metadata", other)
+
+			Expect(dbconn.MustGet("metadata")).To(BeIdenticalTo(other))
+		})
+		It("returns an error for a name that is not registered", func() {
+			_, err := dbconn.Get("target")
+			Expect(err).To(MatchError(`No connection is registered as "target"`))
+		})
+		It("panics in MustGet for a name that is not registered", func() {
+			defer testhelper.ShouldPanicWithMessage(`No connection is registered as "target"`)
+			dbconn.MustGet("target")
+		})
+	})
+	Describe("Unregister", func() {
+		It("removes the connection without closing it", func() {
+			dbconn.Register("metadata", connection)
+			dbconn.Unregister("metadata")
+
+			_, err := dbconn.Get("metadata")
+			Expect(err).To(HaveOccurred())
+			Expect(connection.ConnPool).ToNot(BeNil())
+		})
+	})
+	Describe("RegisteredNames", func() {
+		It("returns the registered names in order", func() {
+			dbconn.Register("target", connection)
+			dbconn.Register("metadata", connection)
+
+			Expect(dbconn.RegisteredNames()).To(Equal([]string{"metadata", "target"}))
+		})
+	})
+	Describe("CloseAll", func() {
+		It("closes every registered connection and empties the registry", func() {
+			other, _ := testhelper.CreateAndConnectMockDB(1)
+			dbconn.Register("metadata", connection)
+			dbconn.Register("template1", other)
+
+			dbconn.CloseAll()
+			Expect(connection.ConnPool).To(BeNil())
+			Expect(other.ConnPool).To(BeNil())
+			Expect(dbconn.RegisteredNames()).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,130 @@
+package dbconn
+
+/*
+ * This file contains structs and functions for checking the state of
+ * replication to mirror segments and to the standby coordinator.
+ *
+ * From GPDB 6 onward, mirrors are kept up to date by streaming replication,
+ * and gp_stat_replication reports the pg_stat_replication view of the
+ * coordinator and of every primary segment, tagged with gp_segment_id.  GPDB 5
+ * replicates only the coordinator that way, and mirrors segments through file
+ * replication, so there only the standby coordinator has a replication status;
+ * GetMirrorSyncStatus works on every version.
+ */
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * A ReplicationStatus describes one replication connection from a primary,
+ * which is the coordinator if ContentID is -1, to its mirror or standby.
+ * State is the state of the WAL sender, such as "streaming" or "catchup", and
+ * SyncState whether the mirror is "sync" or "async".  The locations are WAL
+ * positions, and ReplayLagBytes is the amount of WAL sent to the mirror but
+ * not yet replayed there, or NULL if the database cannot compute it.
+ */
+type ReplicationStatus struct {
+	ContentID       int            `db:"gp_segment_id"`
+	ApplicationName string         `db:"application_name"`
+	ClientAddr      sql.NullString `db:"client_addr"`
+	State           string         `db:"state"`
+	SyncState       string         `db:"sync_state"`
+	SentLocation    string         `db:"sent_location"`
+	WriteLocation   string         `db:"write_location"`
+	FlushLocation   string         `db:"flush_location"`
+	ReplayLocation  string         `db:"replay_location"`
+	ReplayLagBytes  sql.NullInt64  `db:"replay_lag_bytes"`
+}
+
+func (dbconn *DBConn) replicationStatusQuery() string {
+	locationColumns := []string{"sent_location", "write_location", "flush_location", "replay_location"}
+	lagExpression := "pg_catalog.pg_xlog_location_diff(sent_location, replay_location)::bigint"
+	if dbconn.Version.AtLeastFeatureLevel(7) || (dbconn.Version.EffectiveGPDBMajor() == 0 && dbconn.Version.PGAtLeast("10")) {
+		locationColumns = []string{"sent_lsn", "write_lsn", "flush_lsn", "replay_lsn"}
+		lagExpression = "pg_catalog.pg_wal_lsn_diff(sent_lsn, replay_lsn)::bigint"
+	}
+	contentExpression, view := "gp_segment_id", "pg_catalog.gp_stat_replication"
+	if !dbconn.Version.AtLeastFeatureLevel(6) {
+		contentExpression, view = "-1", "pg_catalog.pg_stat_replication"
+		if dbconn.Version.EffectiveGPDBMajor() != 0 {
+			lagExpression = "NULL::bigint"
+		}
+	}
+	return fmt.Sprintf(`
+	SELECT %s AS gp_segment_id,
+		COALESCE(application_name, '') AS application_name,
+		client_addr::text AS client_addr,
+		COALESCE(state, '') AS state,
+		COALESCE(sync_state, '') AS sync_state,
+		COALESCE(%s::text, '') AS sent_location,
+		COALESCE(%s::text, '') AS write_location,
+		COALESCE(%s::text, '') AS flush_location,
+		COALESCE(%s::text, '') AS replay_location,
+		%s AS replay_lag_bytes
+	FROM %s
+	ORDER BY 1`, contentExpression, locationColumns[0], locationColumns[1], locationColumns[2], locationColumns[3], lagExpression, view)
+}
+
+/*
+ * GetReplicationStatus returns the status of every replication connection
+ * from the coordinator and, from GPDB 6 onward, from each primary segment,
+ * ordered by content ID.  A primary whose mirror is not connected has no
+ * entry.
+ */
+func (dbconn *DBConn) GetReplicationStatus(whichConn ...int) ([]ReplicationStatus, error) {
+	statuses := make([]ReplicationStatus, 0)
+	if err := dbconn.Select(&statuses, dbconn.replicationStatusQuery(), whichConn...); err != nil {
+		return nil, errors.Wrap(err, "Failed to get replication status")
+	}
+	return statuses, nil
+}
+
+/*
+ * GetStandbyStatus returns the replication status of the standby coordinator,
+ * or nil if no standby is connected.
+ */
+func (dbconn *DBConn) GetStandbyStatus(whichConn ...int) (*ReplicationStatus, error) {
+	statuses, err := dbconn.GetReplicationStatus(whichConn...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range statuses {
+		if statuses[i].ContentID == -1 {
+			return &statuses[i], nil
+		}
+	}
+	return nil, nil
+}
+
+/*
+ * A MirrorSyncStatus reports whether the mirror of a segment is in sync with
+ * its primary, according to the mode of the primary in
+ * gp_segment_configuration, which is "s" when they are synchronized.
+ */
+type MirrorSyncStatus struct {
+	ContentID int    `db:"content"`
+	Mode      string `db:"mode"`
+	InSync    bool   `db:"in_sync"`
+}
+
+// GetMirrorSyncStatus returns the sync status of each mirrored segment, ordered by content ID.
+func (dbconn *DBConn) GetMirrorSyncStatus(whichConn ...int) ([]MirrorSyncStatus, error) {
+	query := `
+	SELECT p.content,
+		p.mode::text AS mode,
+		p.mode = 's' AS in_sync
+	FROM pg_catalog.gp_segment_configuration p
+	WHERE p.role = 'p'
+		AND p.content >= 0
+		AND EXISTS (SELECT 1 FROM pg_catalog.gp_segment_configuration m WHERE m.content = p.content AND m.role = 'm')
+	ORDER BY p.content`
+	statuses := make([]MirrorSyncStatus, 0)
+	if err := dbconn.Select(&statuses, query, whichConn...); err != nil {
+		return nil, errors.Wrap(err, "Failed to get mirror sync status")
+	}
+	return statuses, nil
+}
@@ -0,0 +1,112 @@
+package dbconn_test
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/replication tests", func() {
+	replicationColumns := []string{"gp_segment_id", "application_name", "client_addr", "state", "sync_state", "sent_location", "write_location", "flush_location", "replay_location", "replay_lag_bytes"}
+
+	Describe("DBConn.GetReplicationStatus", func() {
+		It("queries gp_stat_replication with LSN columns on GPDB 7", func() {
+			testhelper.SetDBVersion(connection, "7.0.0")
+			rows := sqlmock.NewRows(replicationColumns).
+				AddRow(-1, "gp_walreceiver", "10.0.0.2/32", "streaming", "sync", "0/5000060", "0/5000060", "0/5000060", "0/5000060", 0).
+				AddRow(0, "gp_walreceiver", "10.0.0.3/32", "catchup", "async", "0/6000000", "0/5800000", "0/5800000", "0/5000000", 16777216)
+			mock.ExpectQuery(regexp.QuoteMeta("pg_catalog.pg_wal_lsn_diff(sent_lsn, replay_lsn)::bigint AS replay_lag_bytes\n\tFROM pg_catalog.gp_stat_replication")).WillReturnRows(rows)
+
+			statuses, err := connection.GetReplicationStatus()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(statuses).To(HaveLen(2))
+			Expect(statuses[1]).To(Equal(dbconn.ReplicationStatus{
+				ContentID:       0,
+				ApplicationName: "gp_walreceiver",
+				ClientAddr:      sql.NullString{String: "10.0.0.3/32", Valid: true},
+				State:           "catchup",
+				SyncState:       "async",
+				SentLocation:    "0/6000000",
+				WriteLocation:   "0/5800000",
+				FlushLocation:   "0/5800000",
+				ReplayLocation:  "0/5000000",
+				ReplayLagBytes:  sql.NullInt64{Int64: 16777216, Valid: true},
+			}))
+		})
+		It("queries gp_stat_replication with location columns on GPDB 6", func() {
+			testhelper.SetDBVersion(connection, "6.0.0")
+			mock.ExpectQuery(regexp.QuoteMeta("pg_catalog.pg_xlog_location_diff(sent_location, replay_location)::bigint AS replay_lag_bytes\n\tFROM pg_catalog.gp_stat_replication")).WillReturnRows(sqlmock.NewRows(replicationColumns))
+
+			statuses, err := connection.GetReplicationStatus()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(statuses).To(BeEmpty())
+		})
+		It("queries only the coordinator's replication on GPDB 5", func() {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT -1 AS gp_segment_id")).WillReturnRows(sqlmock.NewRows(replicationColumns).
+				AddRow(-1, "walreceiver", nil, "streaming", "sync", "0/C000000", "0/C000000", "0/C000000", "0/C000000", nil))
+
+			statuses, err := connection.GetReplicationStatus()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(statuses).To(HaveLen(1))
+			Expect(statuses[0].ClientAddr.Valid).To(BeFalse())
+			Expect(statuses[0].ReplayLagBytes.Valid).To(BeFalse())
+		})
+		It("returns an error if the query fails", func() {
+			mock.ExpectQuery("pg_stat_replication").WillReturnError(errors.New("permission denied"))
+
+			_, err := connection.GetReplicationStatus()
+			Expect(err).To(MatchError("Failed to get replication status: permission denied"))
+		})
+	})
+	Describe("DBConn.GetStandbyStatus", func() {
+		It("returns the status of the standby coordinator", func() {
+			testhelper.SetDBVersion(connection, "7.0.0")
+			mock.ExpectQuery("gp_stat_replication").WillReturnRows(sqlmock.NewRows(replicationColumns).
+				AddRow(-1, "gp_walreceiver", "10.0.0.2/32", "streaming", "sync", "0/1", "0/1", "0/1", "0/1", 0).
+				AddRow(0, "gp_walreceiver", "10.0.0.3/32", "streaming", "sync", "0/1", "0/1", "0/1", "0/1", 0))
+
+			status, err := connection.GetStandbyStatus()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(status).ToNot(BeNil())
+			Expect(status.ContentID).To(Equal(-1))
+			Expect(status.SyncState).To(Equal("sync"))
+		})
+		It("returns nil if no standby is connected", func() {
+			testhelper.SetDBVersion(connection, "7.0.0")
+			mock.ExpectQuery("gp_stat_replication").WillReturnRows(sqlmock.NewRows(replicationColumns).
+				AddRow(0, "gp_walreceiver", "10.0.0.3/32", "streaming", "sync", "0/1", "0/1", "0/1", "0/1", 0))
+
+			status, err := connection.GetStandbyStatus()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(status).To(BeNil())
+		})
+	})
+	Describe("DBConn.GetMirrorSyncStatus", func() {
+		It("returns the sync status of each mirrored segment", func() {
+			rows := sqlmock.NewRows([]string{"content", "mode", "in_sync"}).
+				AddRow(0, "s", true).
+				AddRow(1, "n", false)
+			mock.ExpectQuery("FROM pg_catalog.gp_segment_configuration p").WillReturnRows(rows)
+
+			statuses, err := connection.GetMirrorSyncStatus()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(statuses).To(Equal([]dbconn.MirrorSyncStatus{
+				{ContentID: 0, Mode: "s", InSync: true},
+				{ContentID: 1, Mode: "n", InSync: false},
+			}))
+		})
+		It("returns an error if the query fails", func() {
+			mock.ExpectQuery("gp_segment_configuration").WillReturnError(errors.New("connection reset"))
+
+			_, err := connection.GetMirrorSyncStatus()
+			Expect(err).To(MatchError("Failed to get mirror sync status: connection reset"))
+		})
+	})
+})
@@ -0,0 +1,131 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to retrying database
+ * connections that fail for transient reasons.
+ */
+
+import (
+	"strings"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/pkg/errors"
+)
+
+/*
+ * RetryPolicy controls how ConnectWithRetry retries failed connections.  The
+ * first retry waits InitialBackoff, and each subsequent wait is Multiplier
+ * times the previous one, up to MaxBackoff.  MaxAttempts counts the initial
+ * attempt, so a MaxAttempts of 1 disables retries.  Zero values are replaced
+ * with the corresponding values from DefaultRetryPolicy.
+ */
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		Multiplier:     2,
+	}
+}
+
+func (policy RetryPolicy) withDefaults() RetryPolicy {
+	defaults := DefaultRetryPolicy()
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = defaults.MaxAttempts
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = defaults.InitialBackoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = defaults.MaxBackoff
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = defaults.Multiplier
+	}
+	return policy
+}
+
+func (policy RetryPolicy) nextBackoff(backoff time.Duration) time.Duration {
+	backoff = time.Duration(float64(backoff) * policy.Multiplier)
+	if backoff > policy.MaxBackoff {
+		backoff = policy.MaxBackoff
+	}
+	return backoff
+}
+
+/*
+ * These errors indicate that the server is temporarily unable to accept the
+ * connection, such as while the coordinator is restarting or when all of its
+ * connection slots are in use, so the same connection may succeed later.
+ */
+var transientConnectionErrors = []string{
+	"connection refused",
+	"the database system is starting up",
+	"the database system is shutting down",
+	"the database system is in recovery mode",
+	"sorry, too many clients already",
+	"remaining connection slots are reserved",
+	"SQLSTATE 57P03",
+	"SQLSTATE 53300",
+}
+
+func IsTransientConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	message := strings.ToLower(err.Error())
+	for _, transientErr := range transientConnectionErrors {
+		if strings.Contains(message, strings.ToLower(transientErr)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (dbconn *DBConn) MustConnectWithRetry(numConns int, policy RetryPolicy) {
+	err := dbconn.ConnectWithRetry(numConns, policy)
+	gplog.FatalOnError(err)
+}
+
+/*
+ * ConnectWithRetry behaves like Connect, but retries with exponential backoff
+ * if the connection fails for a transient reason; see
+ * IsTransientConnectionError.  Any other error is returned immediately, and
+ * the error from the final attempt is returned once MaxAttempts is reached.
+ */
+func (dbconn *DBConn) ConnectWithRetry(numConns int, policy RetryPolicy) error {
+	return dbconn.ConnectWithOptionsAndRetry(ConnectOptions{NumConns: numConns}, policy)
+}
+
+func (dbconn *DBConn) ConnectWithOptionsAndRetry(opts ConnectOptions, policy RetryPolicy) error {
+	if dbconn.ConnPool != nil {
+		return errors.Errorf("The database connection must be closed before reusing the connection")
+	}
+	policy = policy.withDefaults()
+	backoff := policy.InitialBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = dbconn.ConnectWithOptions(opts)
+		if err == nil || !IsTransientConnectionError(err) || attempt >= policy.MaxAttempts {
+			break
+		}
+		// Discard any connections made before the failure, so the next
+		// attempt starts with an empty pool.
+		dbconn.Close()
+		log.Verbose("Connection attempt %d of %d to %s:%d failed, retrying in %v: %v", attempt, policy.MaxAttempts, dbconn.Host, dbconn.Port, backoff, err)
+		time.Sleep(backoff)
+		backoff = policy.nextBackoff(backoff)
+	}
+	if err != nil {
+		dbconn.Close()
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package dbconn_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/retry tests", func() {
+	var (
+		driver *recordingDriver
+		policy dbconn.RetryPolicy
+	)
+	BeforeEach(func() {
+		connection, mock = testhelper.CreateMockDBConn()
+		driver = useRecordingDriver(connection)
+		policy = dbconn.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+	})
+	Describe("IsTransientConnectionError", func() {
+		DescribeTable("classifies connection errors", func(message string, expected bool) {
+			Expect(dbconn.IsTransientConnectionError(errors.New(message))).To(Equal(expected))
+		},
+			Entry("connection refused", "dial tcp 127.0.0.1:5432: connect: connection refused", true),
+			Entry("refused, as reworded by Connect", "could not connect to server: Connection refused", true),
+			Entry("starting up", "FATAL: the database system is starting up (SQLSTATE 57P03)", true),
+			Entry("too many clients", "FATAL: sorry, too many clients already (SQLSTATE 53300)", true),
+			Entry("reserved slots", "FATAL: remaining connection slots are reserved for non-replication superuser connections", true),
+			Entry("bad password", `FATAL: password authentication failed for user "gpadmin" (SQLSTATE 28P01)`, false),
+			Entry("missing database", `Database "nodb" does not exist on localhost:5432, exiting`, false),
+		)
+		It("does not treat nil as transient", func() {
+			Expect(dbconn.IsTransientConnectionError(nil)).To(BeFalse())
+		})
+	})
+	Describe("DBConn.ConnectWithRetry", func() {
+		It("retries transient failures until the connection succeeds", func() {
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+			driver.ErrsToReturn = []error{
+				errors.New("FATAL: the database system is starting up (SQLSTATE 57P03)"),
+				errors.New("FATAL: sorry, too many clients already (SQLSTATE 53300)"),
+			}
+
+			Expect(connection.ConnectWithRetry(1, policy)).To(Succeed())
+			Expect(driver.ConnStrs).To(HaveLen(3))
+			Expect(connection.NumConns).To(Equal(1))
+		})
+		It("returns the last error once all attempts fail", func() {
+			driver.ErrToReturn = errors.New("FATAL: the database system is starting up (SQLSTATE 57P03)")
+
+			err := connection.ConnectWithRetry(1, policy)
+			Expect(err).To(MatchError(ContainSubstring("the database system is starting up")))
+			Expect(driver.ConnStrs).To(HaveLen(3))
+			Expect(connection.ConnPool).To(BeNil())
+		})
+		It("does not retry other errors", func() {
+			driver.ErrToReturn = errors.New(`FATAL: password authentication failed for user "gpadmin" (SQLSTATE 28P01)`)
+
+			Expect(connection.ConnectWithRetry(1, policy)).ToNot(Succeed())
+			Expect(driver.ConnStrs).To(HaveLen(1))
+		})
+		It("does not retry if MaxAttempts is 1", func() {
+			driver.ErrToReturn = errors.New("connection refused")
+			policy.MaxAttempts = 1
+
+			Expect(connection.ConnectWithRetry(1, policy)).ToNot(Succeed())
+			Expect(driver.ConnStrs).To(HaveLen(1))
+		})
+		It("waits longer between each attempt", func() {
+			driver.ErrToReturn = errors.New("connection refused")
+			policy = dbconn.RetryPolicy{MaxAttempts: 3, InitialBackoff: 20 * time.Millisecond, Multiplier: 2}
+
+			start := time.Now()
+			Expect(connection.ConnectWithRetry(1, policy)).ToNot(Succeed())
+			Expect(time.Since(start)).To(BeNumerically(">=", 60*time.Millisecond))
+		})
+		It("fails if the connection is already open", func() {
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+			connection.MustConnect(1)
+
+			Expect(connection.ConnectWithRetry(1, policy)).To(MatchError("The database connection must be closed before reusing the connection"))
+			Expect(connection.ConnPool).ToNot(BeNil())
+		})
+	})
+})
@@ -0,0 +1,31 @@
+package dbconn
+
+/*
+ * This file contains functions related to running queries as another role.
+ */
+
+import (
+	"github.com/pkg/errors"
+)
+
+/*
+ * AsRole runs fn with the given connection's current role set to role, as with
+ * SET ROLE, so that objects fn creates are owned by that role and its
+ * privileges are checked instead of the session user's.  The role is reset
+ * afterward, even if fn returns an error or panics.  An error from fn takes
+ * precedence over an error resetting the role.  fn should run its queries on
+ * the same connection number, as other connections are not affected.
+ */
+func (dbconn *DBConn) AsRole(role string, fn func() error, whichConn ...int) (err error) {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	if _, err = dbconn.Exec("SET ROLE "+QuoteIdentifier(role), connNum); err != nil {
+		return errors.Wrapf(err, "Failed to set role to %s on connection %d", role, connNum)
+	}
+	defer func() {
+		_, resetErr := dbconn.Exec("RESET ROLE", connNum)
+		if err == nil && resetErr != nil {
+			err = errors.Wrapf(resetErr, "Failed to reset role on connection %d", connNum)
+		}
+	}()
+	return fn()
+}
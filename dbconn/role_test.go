@@ -0,0 +1,64 @@
+package dbconn_test
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/role tests", func() {
+	Describe("DBConn.AsRole", func() {
+		fakeResult := testhelper.TestResult{Rows: 0}
+
+		It("sets the role, runs the function, and resets the role", func() {
+			mock.ExpectExec(regexp.QuoteMeta(`SET ROLE "table""owner"`)).WillReturnResult(fakeResult)
+			mock.ExpectExec("ALTER TABLE foo").WillReturnResult(fakeResult)
+			mock.ExpectExec("RESET ROLE").WillReturnResult(fakeResult)
+
+			err := connection.AsRole(`table"owner`, func() error {
+				_, err := connection.Exec("ALTER TABLE foo OWNER TO CURRENT_USER")
+				return err
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("resets the role and returns the error if the function fails", func() {
+			mock.ExpectExec("SET ROLE").WillReturnResult(fakeResult)
+			mock.ExpectExec("RESET ROLE").WillReturnResult(fakeResult)
+			fnErr := errors.New("permission denied")
+
+			err := connection.AsRole("owner", func() error { return fnErr })
+			Expect(err).To(Equal(fnErr))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("resets the role if the function panics", func() {
+			mock.ExpectExec("SET ROLE").WillReturnResult(fakeResult)
+			mock.ExpectExec("RESET ROLE").WillReturnResult(fakeResult)
+
+			Expect(func() {
+				_ = connection.AsRole("owner", func() error { panic("boom") })
+			}).To(PanicWith("boom"))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("does not run the function if the role cannot be set", func() {
+			mock.ExpectExec("SET ROLE").WillReturnError(errors.New(`role "owner" does not exist`))
+
+			err := connection.AsRole("owner", func() error {
+				Fail("function should not be called")
+				return nil
+			})
+			Expect(err).To(MatchError(`Failed to set role to owner on connection 0: role "owner" does not exist`))
+		})
+		It("returns an error if the role cannot be reset", func() {
+			mock.ExpectExec("SET ROLE").WillReturnResult(fakeResult)
+			mock.ExpectExec("RESET ROLE").WillReturnError(errors.New("connection reset by peer"))
+
+			err := connection.AsRole("owner", func() error { return nil })
+			Expect(err).To(MatchError("Failed to reset role on connection 0: connection reset by peer"))
+		})
+	})
+})
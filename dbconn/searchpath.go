@@ -0,0 +1,114 @@
+package dbconn
+
+/*
+ * This file contains functions related to managing the search_path on every
+ * connection in the pool.
+ */
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * GetSearchPath returns the schemas in the given connection's search_path, in
+ * order, with any quoting removed.  The special entry "$user", for the schema
+ * named after the current user, is returned as is.
+ */
+func (dbconn *DBConn) GetSearchPath(whichConn ...int) ([]string, error) {
+	value, err := dbconn.GetGUC("search_path", whichConn...)
+	if err != nil {
+		return nil, err
+	}
+	return parseSearchPath(value)
+}
+
+/*
+ * SetSearchPath sets the search_path on every connection in the pool to the
+ * given schemas, in order, quoting each name so that it is used exactly as
+ * given; pass "$user" for the schema named after the current user.  As with
+ * SetGUC, the setting lasts for the rest of the session.
+ */
+func (dbconn *DBConn) SetSearchPath(schemas ...string) error {
+	return dbconn.SetGUC("search_path", formatSearchPath(schemas))
+}
+
+/*
+ * AppendToSearchPath adds schema to the end of the search_path on every
+ * connection in the pool, taking the current search_path from connection 0.
+ * It does nothing if the schema is already in the search_path.
+ */
+func (dbconn *DBConn) AppendToSearchPath(schema string) error {
+	schemas, err := dbconn.GetSearchPath(0)
+	if err != nil {
+		return err
+	}
+	for _, existing := range schemas {
+		if existing == schema {
+			return nil
+		}
+	}
+	return dbconn.SetSearchPath(append(schemas, schema)...)
+}
+
+/*
+ * WithSearchPath sets the search_path on every connection in the pool to the
+ * given schemas, runs fn, and then restores each connection's previous
+ * search_path, as WithGUCs does.
+ */
+func (dbconn *DBConn) WithSearchPath(schemas []string, fn func() error) error {
+	return dbconn.WithGUCs(map[string]string{"search_path": formatSearchPath(schemas)}, fn)
+}
+
+func formatSearchPath(schemas []string) string {
+	quoted := make([]string, len(schemas))
+	for i, schema := range schemas {
+		quoted[i] = QuoteIdentifier(schema)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// parseSearchPath splits a search_path setting into schema names as the server would resolve them.
+func parseSearchPath(value string) ([]string, error) {
+	schemas := make([]string, 0)
+	if strings.TrimSpace(value) == "" {
+		return schemas, nil
+	}
+	for i := 0; i <= len(value); i++ {
+		for i < len(value) && value[i] == ' ' {
+			i++
+		}
+		var schema strings.Builder
+		if i < len(value) && value[i] == '"' {
+			for i++; ; i++ {
+				if i == len(value) {
+					return nil, errors.Errorf("Invalid search_path %q: unterminated quoted identifier", value)
+				}
+				if value[i] == '"' {
+					if i+1 < len(value) && value[i+1] == '"' {
+						i++
+					} else {
+						break
+					}
+				}
+				schema.WriteByte(value[i])
+			}
+			i++
+			for i < len(value) && value[i] == ' ' {
+				i++
+			}
+			if i < len(value) && value[i] != ',' {
+				return nil, errors.Errorf("Invalid search_path %q: unexpected character after quoted identifier", value)
+			}
+			schemas = append(schemas, schema.String())
+		} else {
+			// The server folds unquoted names to lower case, as in queries.
+			for ; i < len(value) && value[i] != ','; i++ {
+				schema.WriteByte(value[i])
+			}
+			schemas = append(schemas, strings.ToLower(strings.TrimRight(schema.String(), " ")))
+		}
+	}
+	return schemas, nil
+}
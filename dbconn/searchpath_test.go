@@ -0,0 +1,83 @@
+package dbconn_test
+
+import (
+	"regexp"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/searchpath tests", func() {
+	expectGetSearchPath := func(mock sqlmock.Sqlmock, value string) {
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_catalog.current_setting('search_path')")).
+			WillReturnRows(sqlmock.NewRows([]string{"current_setting"}).AddRow(value))
+	}
+	expectSetSearchPath := func(mock sqlmock.Sqlmock, value string) {
+		mock.ExpectExec(regexp.QuoteMeta("SELECT pg_catalog.set_config('search_path', '" + value + "', false)")).
+			WillReturnResult(testhelper.TestResult{Rows: 1})
+	}
+
+	Describe("DBConn.GetSearchPath", func() {
+		DescribeTable("parses the search_path", func(value string, expected []string) {
+			expectGetSearchPath(mock, value)
+
+			schemas, err := connection.GetSearchPath()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(schemas).To(Equal(expected))
+		},
+			Entry("the default", `"$user", public`, []string{"$user", "public"}),
+			Entry("quoted names with commas, spaces, and quotes", `"my schema", "a,b", "say ""hi"""`, []string{"my schema", "a,b", `say "hi"`}),
+			Entry("unquoted names, which are folded to lower case", "Public,pg_catalog", []string{"public", "pg_catalog"}),
+			Entry("an empty search_path", "", []string{}),
+		)
+		It("returns an error for an unterminated quoted name", func() {
+			expectGetSearchPath(mock, `"public`)
+
+			_, err := connection.GetSearchPath()
+			Expect(err).To(MatchError(`Invalid search_path "\"public": unterminated quoted identifier`))
+		})
+	})
+	Describe("DBConn.SetSearchPath", func() {
+		It("quotes each schema and sets the search_path on every connection", func() {
+			connection, mock = testhelper.CreateAndConnectMockDB(2)
+			expectSetSearchPath(mock, `"$user", "My''Schema", "public"`)
+			expectSetSearchPath(mock, `"$user", "My''Schema", "public"`)
+
+			Expect(connection.SetSearchPath("$user", "My'Schema", "public")).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+	Describe("DBConn.AppendToSearchPath", func() {
+		It("adds a schema to the end of the search_path", func() {
+			expectGetSearchPath(mock, `"$user", public`)
+			expectSetSearchPath(mock, `"$user", "public", "gp_toolkit"`)
+
+			Expect(connection.AppendToSearchPath("gp_toolkit")).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("does nothing if the schema is already in the search_path", func() {
+			expectGetSearchPath(mock, `"$user", public, gp_toolkit`)
+
+			Expect(connection.AppendToSearchPath("gp_toolkit")).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+	Describe("DBConn.WithSearchPath", func() {
+		It("sets the search_path while running the function and then restores it", func() {
+			expectGetSearchPath(mock, `"$user", public`)
+			expectSetSearchPath(mock, `"pg_catalog"`)
+			mock.ExpectQuery("SELECT relname FROM pg_class").WillReturnRows(sqlmock.NewRows([]string{"relname"}))
+			expectSetSearchPath(mock, `"$user", public`)
+
+			err := connection.WithSearchPath([]string{"pg_catalog"}, func() error {
+				var relnames []string
+				return connection.Select(&relnames, "SELECT relname FROM pg_class")
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})
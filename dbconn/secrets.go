@@ -0,0 +1,150 @@
+package dbconn
+
+/*
+ * This file contains structs and functions for obtaining passwords and TLS
+ * client keys from a secret store each time a connection is made, so that a
+ * long-lived program picks up rotated credentials without restarting.
+ *
+ * Environment variables and files are supported here; providers for other
+ * stores, such as Vault or a cloud KMS, can be written by callers by
+ * implementing SecretsProvider.
+ */
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+/*
+ * Secrets holds the credentials to use for one connection attempt.  Each
+ * field that is set takes precedence over the corresponding DBConn.Password
+ * or SSLOptions field.  SSLCert and SSLKey are paths to PEM files, as in
+ * SSLOptions, and SSLKeyPassword decrypts an encrypted SSLKey.
+ */
+type Secrets struct {
+	Password       string
+	SSLCert        string
+	SSLKey         string
+	SSLKeyPassword string
+}
+
+/*
+ * A SecretsProvider supplies the credentials for a DBConn each time a pooled
+ * connection is established, whether by Connect, on demand, or when a broken
+ * connection is re-established, so that it can return the current credentials
+ * after they are rotated.  It is called before each attempt, so providers
+ * that are slow to query should cache their secrets.  If the server rejects
+ * the password, the DBConn's CredentialProvider, if any, is still consulted.
+ */
+type SecretsProvider interface {
+	Secrets(dbconn *DBConn) (Secrets, error)
+}
+
+// SecretsProviderFunc adapts an ordinary function to a SecretsProvider.
+type SecretsProviderFunc func(dbconn *DBConn) (Secrets, error)
+
+func (f SecretsProviderFunc) Secrets(dbconn *DBConn) (Secrets, error) {
+	return f(dbconn)
+}
+
+/*
+ * EnvSecretsProvider reads each secret from the environment variable with the
+ * given name, if any, each time it is consulted.
+ */
+type EnvSecretsProvider struct {
+	PasswordVar       string
+	SSLCertVar        string
+	SSLKeyVar         string
+	SSLKeyPasswordVar string
+}
+
+func (provider EnvSecretsProvider) Secrets(dbconn *DBConn) (Secrets, error) {
+	getenv := func(name string) string {
+		if name == "" {
+			return ""
+		}
+		return operating.System.Getenv(name)
+	}
+	return Secrets{
+		Password:       getenv(provider.PasswordVar),
+		SSLCert:        getenv(provider.SSLCertVar),
+		SSLKey:         getenv(provider.SSLKeyVar),
+		SSLKeyPassword: getenv(provider.SSLKeyPasswordVar),
+	}, nil
+}
+
+/*
+ * FileSecretsProvider reads the password and SSL key password from the given
+ * files, if any, each time it is consulted, as when they are mounted from a
+ * secret store that replaces them on rotation.  A trailing newline is
+ * ignored.  SSLCert and SSLKey are passed through as paths, as the files they
+ * name are read anew on each connection anyway.
+ */
+type FileSecretsProvider struct {
+	PasswordFile       string
+	SSLCert            string
+	SSLKey             string
+	SSLKeyPasswordFile string
+}
+
+func (provider FileSecretsProvider) Secrets(dbconn *DBConn) (Secrets, error) {
+	readSecret := func(path string) (string, error) {
+		if path == "" {
+			return "", nil
+		}
+		contents, err := operating.System.ReadFile(path)
+		if err != nil {
+			return "", errors.Wrapf(err, "Cannot read secret from %s", path)
+		}
+		return strings.TrimRight(string(contents), "\r\n"), nil
+	}
+	password, err := readSecret(provider.PasswordFile)
+	if err != nil {
+		return Secrets{}, err
+	}
+	keyPassword, err := readSecret(provider.SSLKeyPasswordFile)
+	if err != nil {
+		return Secrets{}, err
+	}
+	return Secrets{Password: password, SSLCert: provider.SSLCert, SSLKey: provider.SSLKey, SSLKeyPassword: keyPassword}, nil
+}
+
+// currentSecrets returns the secrets from the SecretsProvider, if one is set.
+func (dbconn *DBConn) currentSecrets() (Secrets, error) {
+	if dbconn.SecretsProvider == nil {
+		return Secrets{}, nil
+	}
+	secrets, err := dbconn.SecretsProvider.Secrets(dbconn)
+	if err != nil {
+		return Secrets{}, errors.Wrapf(err, "Failed to obtain secrets for user %s", dbconn.User)
+	}
+	return secrets, nil
+}
+
+/*
+ * connectionString returns the connection parameters for the secrets, which
+ * are appended to the rest of the connection string so that they override
+ * any set there.  The password comes last, falling back to defaultPassword.
+ */
+func (secrets Secrets) connectionString(defaultPassword string) string {
+	connStr := ""
+	for _, param := range []struct {
+		name  string
+		value string
+	}{{"sslcert", secrets.SSLCert}, {"sslkey", secrets.SSLKey}, {"sslpassword", secrets.SSLKeyPassword}} {
+		if param.value != "" {
+			connStr += fmt.Sprintf(" %s='%s'", param.name, EscapeConnectionParam(param.value))
+		}
+	}
+	password := secrets.Password
+	if password == "" {
+		password = defaultPassword
+	}
+	if password != "" {
+		connStr += fmt.Sprintf(" password='%s'", EscapeConnectionParam(password))
+	}
+	return connStr
+}
@@ -0,0 +1,120 @@
+package dbconn_test
+
+import (
+	"errors"
+	"os"
+
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/secrets tests", func() {
+	var (
+		driver  *recordingDriver
+		secrets dbconn.Secrets
+		calls   int
+	)
+	BeforeEach(func() {
+		connection, mock = testhelper.CreateMockDBConn()
+		driver = useRecordingDriver(connection)
+		secrets = dbconn.Secrets{Password: "first"}
+		calls = 0
+		connection.SecretsProvider = dbconn.SecretsProviderFunc(func(conn *dbconn.DBConn) (dbconn.Secrets, error) {
+			calls++
+			Expect(conn).To(BeIdenticalTo(connection))
+			return secrets, nil
+		})
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("DBConn.SecretsProvider", func() {
+		It("is consulted for each connection made by Connect", func() {
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+			connection.Password = "ignored"
+
+			Expect(connection.Connect(2)).To(Succeed())
+			Expect(calls).To(Equal(2))
+			Expect(driver.ConnStrs).To(HaveLen(2))
+			Expect(driver.ConnStrs[0]).To(HaveSuffix(" password='first'"))
+			Expect(driver.ConnStrs[0]).ToNot(ContainSubstring("ignored"))
+		})
+		It("overrides the SSL client certificate and key", func() {
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+			secrets = dbconn.Secrets{SSLCert: "/new/client.crt", SSLKey: "/new/client.key", SSLKeyPassword: "it's"}
+
+			Expect(connection.Connect(1)).To(Succeed())
+			Expect(driver.ConnStrs[0]).To(HaveSuffix(` sslcert='/new/client.crt' sslkey='/new/client.key' sslpassword='it\'s'`))
+		})
+		It("falls back to DBConn.Password if it supplies no password", func() {
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+			connection.Password = "fallback"
+			secrets = dbconn.Secrets{}
+
+			Expect(connection.Connect(1)).To(Succeed())
+			Expect(driver.ConnStrs[0]).To(HaveSuffix(" password='fallback'"))
+		})
+		It("supplies rotated secrets to connections established later", func() {
+			Expect(connection.ConnectWithOptions(dbconn.ConnectOptions{NumConns: 2, Lazy: true})).To(Succeed())
+			Expect(calls).To(Equal(0))
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+			Expect(connection.EnsureConnected(0)).To(Succeed())
+
+			secrets = dbconn.Secrets{Password: "second"}
+			Expect(connection.EnsureConnected(1)).To(Succeed())
+			Expect(calls).To(Equal(2))
+			Expect(driver.ConnStrs[0]).To(HaveSuffix(" password='first'"))
+			Expect(driver.ConnStrs[1]).To(HaveSuffix(" password='second'"))
+		})
+		It("returns an error without connecting if it fails", func() {
+			connection.SecretsProvider = dbconn.SecretsProviderFunc(func(conn *dbconn.DBConn) (dbconn.Secrets, error) {
+				return dbconn.Secrets{}, errors.New("permission denied")
+			})
+
+			err := connection.Connect(1)
+			Expect(err).To(MatchError(ContainSubstring("Failed to obtain secrets for user " + connection.User + ": permission denied")))
+			Expect(driver.ConnStrs).To(BeEmpty())
+		})
+	})
+	Describe("EnvSecretsProvider", func() {
+		It("reads the secrets from the named environment variables", func() {
+			env := map[string]string{"PGPASSWORD_ROTATED": "hunter2", "CLIENT_KEY": "/keys/client.key"}
+			operating.System.Getenv = func(key string) string { return env[key] }
+			provider := dbconn.EnvSecretsProvider{PasswordVar: "PGPASSWORD_ROTATED", SSLKeyVar: "CLIENT_KEY"}
+
+			result, err := provider.Secrets(connection)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(dbconn.Secrets{Password: "hunter2", SSLKey: "/keys/client.key"}))
+
+			env["PGPASSWORD_ROTATED"] = "hunter3"
+			result, _ = provider.Secrets(connection)
+			Expect(result.Password).To(Equal("hunter3"))
+		})
+	})
+	Describe("FileSecretsProvider", func() {
+		It("reads the passwords from the files without their trailing newlines", func() {
+			operating.System.ReadFile = func(filename string) ([]byte, error) {
+				return []byte(filename + "-contents\n"), nil
+			}
+			provider := dbconn.FileSecretsProvider{PasswordFile: "/run/secrets/password", SSLCert: "/keys/client.crt", SSLKeyPasswordFile: "/run/secrets/keypass"}
+
+			result, err := provider.Secrets(connection)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(dbconn.Secrets{Password: "/run/secrets/password-contents", SSLCert: "/keys/client.crt", SSLKeyPassword: "/run/secrets/keypass-contents"}))
+		})
+		It("returns an error if a file cannot be read", func() {
+			operating.System.ReadFile = func(filename string) ([]byte, error) {
+				return nil, os.ErrNotExist
+			}
+			provider := dbconn.FileSecretsProvider{PasswordFile: "/run/secrets/password"}
+
+			_, err := provider.Secrets(connection)
+			Expect(err).To(MatchError("Cannot read secret from /run/secrets/password: file does not exist"))
+		})
+	})
+})
@@ -0,0 +1,84 @@
+package dbconn
+
+/*
+ * This file contains functions for running an expression on every segment
+ * through the database itself, by selecting it from gp_dist_random('gp_id'),
+ * which has one row on each segment.  This is simpler than connecting to each
+ * segment over SSH or in utility mode when all that is needed is the result of
+ * a function or setting on each one, such as its data directory.
+ *
+ * The expression must be evaluated on the segments for this to work, so it
+ * should call a volatile or stable function or refer to the row; an immutable
+ * expression may be evaluated once by the coordinator and the same value
+ * returned for every segment.
+ */
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * SegmentQuery returns a query that selects the given select-list expressions
+ * on every segment, preceded by the segment's content ID in a gp_segment_id
+ * column and ordered by it.  If includeCoordinator is true, the expressions
+ * are also evaluated on the coordinator, with a gp_segment_id of -1.
+ */
+func SegmentQuery(expressions string, includeCoordinator bool) string {
+	query := fmt.Sprintf("SELECT gp_segment_id, %s FROM gp_dist_random('gp_id')", expressions)
+	if includeCoordinator {
+		query = fmt.Sprintf("SELECT -1 AS gp_segment_id, %s UNION ALL %s", expressions, query)
+	}
+	return query + " ORDER BY gp_segment_id"
+}
+
+func (dbconn *DBConn) checkSegmentDispatch() error {
+	if dbconn.Version.EffectiveGPDBMajor() == 0 {
+		return errors.Errorf("Cannot run queries on segments of %s", dbconn.Version.Type)
+	}
+	return nil
+}
+
+/*
+ * SelectOnSegments runs SegmentQuery with the given expressions and scans the
+ * results into destination, as Select does, so destination should be a slice
+ * of structs with a field tagged `db:"gp_segment_id"` for the content ID.
+ */
+func (dbconn *DBConn) SelectOnSegments(destination interface{}, expressions string, includeCoordinator bool, whichConn ...int) error {
+	if err := dbconn.checkSegmentDispatch(); err != nil {
+		return err
+	}
+	err := dbconn.Select(destination, SegmentQuery(expressions, includeCoordinator), whichConn...)
+	return errors.Wrap(err, "Failed to run query on segments")
+}
+
+/*
+ * SelectStringOnSegments evaluates a single expression on every segment and
+ * returns its value on each, keyed by content ID; a NULL value is returned as
+ * an empty string.
+ */
+func (dbconn *DBConn) SelectStringOnSegments(expression string, includeCoordinator bool, whichConn ...int) (map[int]string, error) {
+	if err := dbconn.checkSegmentDispatch(); err != nil {
+		return nil, err
+	}
+	rows, err := dbconn.Query(SegmentQuery(expression, includeCoordinator), whichConn...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to run query on segments")
+	}
+	defer rows.Close()
+	results := make(map[int]string)
+	for rows.Next() {
+		var contentID int
+		var value sql.NullString
+		if err := rows.Scan(&contentID, &value); err != nil {
+			return nil, errors.Wrap(err, "Failed to run query on segments")
+		}
+		results[contentID] = value.String
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "Failed to run query on segments")
+	}
+	return results, nil
+}
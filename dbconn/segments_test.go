@@ -0,0 +1,74 @@
+package dbconn_test
+
+import (
+	"errors"
+	"regexp"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/segments tests", func() {
+	Describe("SegmentQuery", func() {
+		It("selects the expressions on every segment", func() {
+			Expect(dbconn.SegmentQuery("pg_catalog.current_setting('port') AS port", false)).To(Equal(
+				"SELECT gp_segment_id, pg_catalog.current_setting('port') AS port FROM gp_dist_random('gp_id') ORDER BY gp_segment_id"))
+		})
+		It("also selects the expressions on the coordinator", func() {
+			Expect(dbconn.SegmentQuery("pg_catalog.now()", true)).To(Equal(
+				"SELECT -1 AS gp_segment_id, pg_catalog.now() UNION ALL SELECT gp_segment_id, pg_catalog.now() FROM gp_dist_random('gp_id') ORDER BY gp_segment_id"))
+		})
+	})
+	Describe("DBConn.SelectOnSegments", func() {
+		It("scans the results tagged with each segment's content ID", func() {
+			rows := sqlmock.NewRows([]string{"gp_segment_id", "port"}).AddRow(0, 6000).AddRow(1, 6001)
+			mock.ExpectQuery(regexp.QuoteMeta(dbconn.SegmentQuery("pg_catalog.current_setting('port')::int AS port", false))).WillReturnRows(rows)
+
+			results := make([]struct {
+				ContentID int `db:"gp_segment_id"`
+				Port      int
+			}, 0)
+			err := connection.SelectOnSegments(&results, "pg_catalog.current_setting('port')::int AS port", false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(HaveLen(2))
+			Expect(results[1].ContentID).To(Equal(1))
+			Expect(results[1].Port).To(Equal(6001))
+		})
+		It("returns an error if the query fails", func() {
+			mock.ExpectQuery("gp_dist_random").WillReturnError(errors.New("permission denied"))
+
+			var results []struct{}
+			err := connection.SelectOnSegments(&results, "1", false)
+			Expect(err).To(MatchError("Failed to run query on segments: permission denied"))
+		})
+		It("returns an error for a database without segments", func() {
+			connection.Version = dbconn.GPDBVersion{Type: dbconn.Unknown}
+
+			var results []struct{}
+			err := connection.SelectOnSegments(&results, "1", false)
+			Expect(err).To(MatchError("Cannot run queries on segments of Unknown Database"))
+		})
+	})
+	Describe("DBConn.SelectStringOnSegments", func() {
+		It("returns each segment's value by content ID", func() {
+			rows := sqlmock.NewRows([]string{"gp_segment_id", "current_setting"}).
+				AddRow(-1, "/data/coordinator/gpseg-1").
+				AddRow(0, "/data/primary/gpseg0").
+				AddRow(1, nil)
+			mock.ExpectQuery(regexp.QuoteMeta(dbconn.SegmentQuery("pg_catalog.current_setting('data_directory')", true))).WillReturnRows(rows)
+
+			results, err := connection.SelectStringOnSegments("pg_catalog.current_setting('data_directory')", true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(Equal(map[int]string{-1: "/data/coordinator/gpseg-1", 0: "/data/primary/gpseg0", 1: ""}))
+		})
+		It("returns an error if the query fails", func() {
+			mock.ExpectQuery("gp_dist_random").WillReturnError(errors.New("permission denied"))
+
+			_, err := connection.SelectStringOnSegments("1", false)
+			Expect(err).To(MatchError("Failed to run query on segments: permission denied"))
+		})
+	})
+})
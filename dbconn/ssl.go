@@ -0,0 +1,88 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to configuring SSL/TLS for
+ * database connections.
+ */
+
+import (
+	"fmt"
+
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+/*
+ * SSLOptions holds the libpq SSL connection parameters.  Mode defaults to
+ * $PGSSLMODE, or "prefer" if that is not set.  The certificate and key paths
+ * are only passed to the server if set, and are checked before connecting so
+ * that a typo produces a clear error rather than a failed TLS handshake.
+ */
+type SSLOptions struct {
+	Mode     string
+	RootCert string
+	Cert     string
+	Key      string
+}
+
+var validSSLModes = map[string]bool{
+	"disable":     true,
+	"allow":       true,
+	"prefer":      true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+func (opts SSLOptions) mode() string {
+	if opts.Mode != "" {
+		return opts.Mode
+	}
+	if mode := operating.System.Getenv("PGSSLMODE"); mode != "" {
+		return mode
+	}
+	return "prefer"
+}
+
+/*
+ * Validate checks that the SSL mode is one libpq recognizes, that a client
+ * certificate and key are given together, and that each file exists.
+ */
+func (opts SSLOptions) Validate() error {
+	mode := opts.mode()
+	if !validSSLModes[mode] {
+		return errors.Errorf("Invalid SSL mode %q; must be one of disable, allow, prefer, require, verify-ca, or verify-full", mode)
+	}
+	if (opts.Cert == "") != (opts.Key == "") {
+		return errors.New("An SSL client certificate and key must be provided together")
+	}
+	for _, file := range []struct {
+		name string
+		path string
+	}{{"root certificate", opts.RootCert}, {"client certificate", opts.Cert}, {"client key", opts.Key}} {
+		if file.path == "" {
+			continue
+		}
+		info, err := operating.System.Stat(file.path)
+		if err != nil {
+			return errors.Errorf("Cannot read SSL %s %s: %v", file.name, file.path, err)
+		}
+		if info.IsDir() {
+			return errors.Errorf("SSL %s %s is a directory", file.name, file.path)
+		}
+	}
+	return nil
+}
+
+func (opts SSLOptions) connectionString() string {
+	connStr := fmt.Sprintf(" sslmode='%s'", EscapeConnectionParam(opts.mode()))
+	for _, param := range []struct {
+		name  string
+		value string
+	}{{"sslrootcert", opts.RootCert}, {"sslcert", opts.Cert}, {"sslkey", opts.Key}} {
+		if param.value != "" {
+			connStr += fmt.Sprintf(" %s='%s'", param.name, EscapeConnectionParam(param.value))
+		}
+	}
+	return connStr
+}
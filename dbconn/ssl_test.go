@@ -0,0 +1,73 @@
+package dbconn_test
+
+import (
+	"os"
+	"path"
+
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/ssl tests", func() {
+	var certDir, rootCert, clientCert, clientKey string
+	BeforeEach(func() {
+		certDir = GinkgoT().TempDir()
+		rootCert = path.Join(certDir, "root.crt")
+		clientCert = path.Join(certDir, "client.crt")
+		clientKey = path.Join(certDir, "client.key")
+		for _, file := range []string{rootCert, clientCert, clientKey} {
+			Expect(os.WriteFile(file, []byte("test"), 0600)).To(Succeed())
+		}
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+	Describe("SSLOptions.Validate", func() {
+		It("accepts valid options", func() {
+			Expect(dbconn.SSLOptions{}.Validate()).To(Succeed())
+			Expect(dbconn.SSLOptions{Mode: "verify-full", RootCert: rootCert, Cert: clientCert, Key: clientKey}.Validate()).To(Succeed())
+		})
+		It("rejects an invalid mode", func() {
+			Expect(dbconn.SSLOptions{Mode: "required"}.Validate()).To(MatchError(`Invalid SSL mode "required"; must be one of disable, allow, prefer, require, verify-ca, or verify-full`))
+		})
+		It("rejects an invalid mode from the environment", func() {
+			operating.System.Getenv = func(key string) string { return "on" }
+			Expect(dbconn.SSLOptions{}.Validate()).To(HaveOccurred())
+		})
+		It("rejects a certificate without a key", func() {
+			Expect(dbconn.SSLOptions{Cert: clientCert}.Validate()).To(MatchError("An SSL client certificate and key must be provided together"))
+		})
+		It("rejects a missing file", func() {
+			err := dbconn.SSLOptions{RootCert: path.Join(certDir, "missing.crt")}.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("Cannot read SSL root certificate " + path.Join(certDir, "missing.crt")))
+		})
+		It("rejects a directory", func() {
+			Expect(dbconn.SSLOptions{RootCert: certDir}.Validate()).To(MatchError("SSL root certificate " + certDir + " is a directory"))
+		})
+	})
+	Describe("DBConn.Connect", func() {
+		It("passes SSL options in the connection string", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			driver := useRecordingDriver(connection)
+			testhelper.ExpectVersionQuery(mock, "7.0.0")
+			connection.SSL = dbconn.SSLOptions{Mode: "verify-ca", RootCert: rootCert, Cert: clientCert, Key: clientKey}
+
+			Expect(connection.Connect(1)).To(Succeed())
+			Expect(driver.ConnStrs[0]).To(ContainSubstring(" sslmode='verify-ca' sslrootcert='" + rootCert + "' sslcert='" + clientCert + "' sslkey='" + clientKey + "' statement_cache_capacity=0"))
+		})
+		It("does not connect if the SSL options are invalid", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			driver := useRecordingDriver(connection)
+			connection.SSL = dbconn.SSLOptions{Mode: "bogus"}
+
+			Expect(connection.Connect(1)).ToNot(Succeed())
+			Expect(driver.ConnStrs).To(BeEmpty())
+			Expect(connection.ConnPool).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,40 @@
+package dbconn
+
+/*
+ * This file contains functions related to processing query results one row at
+ * a time, rather than reading them all into memory first.
+ */
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/*
+ * SelectStream runs query and calls rowHandler for each row of the result as
+ * it is received, so that large results, such as catalog queries over every
+ * relation in a database, can be processed without holding them all in
+ * memory.  rowHandler reads the current row with rows.Scan or rows.StructScan
+ * and should not call rows.Next or rows.Close.  If rowHandler returns an
+ * error, no more rows are read and that error is returned.
+ *
+ * The connection is busy until all rows have been read, so rowHandler must not
+ * run queries on the same connection number; with the default pool limits
+ * doing so blocks forever.  Use another connection number for any queries
+ * needed while processing the rows.
+ */
+func (dbconn *DBConn) SelectStream(query string, rowHandler func(rows *sqlx.Rows) error, whichConn ...int) error {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	rows, err := dbconn.queryRows(context.Background(), dbconn.queryer(connNum), connNum, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err = rowHandler(rows.Rows); err != nil {
+			return err
+		}
+	}
+	return errors.Wrap(rows.Err(), "Failed to read query results")
+}
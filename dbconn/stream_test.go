@@ -0,0 +1,76 @@
+package dbconn_test
+
+import (
+	"errors"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/stream tests", func() {
+	Describe("DBConn.SelectStream", func() {
+		type table struct {
+			Schema string `db:"schemaname"`
+			Name   string `db:"tablename"`
+		}
+		var tableRows *sqlmock.Rows
+		BeforeEach(func() {
+			tableRows = sqlmock.NewRows([]string{"schemaname", "tablename"}).
+				AddRow("schema1", "table1").
+				AddRow("schema2", "table2").
+				AddRow("schema3", "table3")
+		})
+
+		It("calls the handler for each row", func() {
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(tableRows)
+
+			var results []table
+			err := connection.SelectStream("SELECT schemaname, tablename FROM pg_tables", func(rows *sqlx.Rows) error {
+				var row table
+				if err := rows.StructScan(&row); err != nil {
+					return err
+				}
+				results = append(results, row)
+				return nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(Equal([]table{{"schema1", "table1"}, {"schema2", "table2"}, {"schema3", "table3"}}))
+		})
+		It("stops reading rows and returns the error if the handler fails", func() {
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(tableRows)
+			handlerErr := errors.New("handler failed")
+
+			numCalls := 0
+			err := connection.SelectStream("SELECT schemaname, tablename FROM pg_tables", func(rows *sqlx.Rows) error {
+				numCalls++
+				return handlerErr
+			})
+			Expect(err).To(Equal(handlerErr))
+			Expect(numCalls).To(Equal(1))
+		})
+		It("returns an error if the query fails", func() {
+			mock.ExpectQuery("SELECT (.*)").WillReturnError(errors.New("permission denied"))
+
+			err := connection.SelectStream("SELECT schemaname, tablename FROM pg_tables", func(rows *sqlx.Rows) error {
+				Fail("handler should not be called")
+				return nil
+			})
+			Expect(err).To(MatchError("permission denied"))
+		})
+		It("returns an error if reading the rows fails", func() {
+			tableRows.RowError(1, errors.New("connection reset"))
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(tableRows)
+
+			numCalls := 0
+			err := connection.SelectStream("SELECT schemaname, tablename FROM pg_tables", func(rows *sqlx.Rows) error {
+				numCalls++
+				return nil
+			})
+			Expect(err).To(MatchError("Failed to read query results: connection reset"))
+			Expect(numCalls).To(Equal(1))
+		})
+	})
+})
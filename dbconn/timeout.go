@@ -0,0 +1,95 @@
+package dbconn
+
+/*
+ * This file contains functions related to bounding how long queries may run.
+ */
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/pkg/errors"
+)
+
+// statement_timeout is in milliseconds, so round up to avoid turning a short timeout into no timeout.
+func statementTimeoutMillis(timeout time.Duration) int64 {
+	millis := timeout.Milliseconds()
+	if timeout > 0 && millis == 0 {
+		millis = 1
+	}
+	return millis
+}
+
+/*
+ * SetStatementTimeout sets statement_timeout on every connection in the pool,
+ * so that the server cancels any statement that runs longer than the given
+ * duration; 0 disables the timeout.  The timeout is also stored in the DBConn
+ * and sent as a startup parameter whenever the DBConn connects, so it is
+ * re-applied when the DBConn is closed and connected again.  If called before
+ * connecting, it only stores the timeout.
+ *
+ * Sessions that the underlying sql.DBs open on their own, such as to replace
+ * a dropped connection, use the startup parameters from the original Connect,
+ * so set the timeout before connecting where possible.  Connections that the
+ * DBConn re-establishes itself, under its ReconnectPolicy, do get the timeout.
+ *
+ * If a connection is in a transaction, the SET is part of that transaction and
+ * is undone if the transaction is rolled back.
+ */
+func (dbconn *DBConn) SetStatementTimeout(timeout time.Duration) error {
+	if timeout < 0 {
+		return errors.Errorf("Statement timeout must not be negative: %v", timeout)
+	}
+	dbconn.StatementTimeout = timeout
+	query := fmt.Sprintf("SET statement_timeout = %d", statementTimeoutMillis(timeout))
+	for connNum := 0; connNum < dbconn.NumConns; connNum++ {
+		if _, err := dbconn.Exec(query, connNum); err != nil {
+			return errors.Wrapf(err, "Failed to set statement timeout on connection %d", connNum)
+		}
+	}
+	dbconn.rememberGUC("statement_timeout", strconv.FormatInt(statementTimeoutMillis(timeout), 10))
+	return nil
+}
+
+func (dbconn *DBConn) MustSetStatementTimeout(timeout time.Duration) {
+	err := dbconn.SetStatementTimeout(timeout)
+	gplog.FatalOnError(err)
+}
+
+type noQueryTimeoutKey struct{}
+
+/*
+ * WithoutQueryTimeout returns a context that exempts queries run with it, such
+ * as through QueryContext, from the DBConn's DefaultQueryTimeout, for the few
+ * queries that are expected to run longer than the rest.
+ */
+func WithoutQueryTimeout(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noQueryTimeoutKey{}, true)
+}
+
+/*
+ * withDefaultTimeout applies DefaultQueryTimeout to ctx unless the caller has
+ * set a deadline of their own, which takes precedence whether it is shorter or
+ * longer, or exempted the query with WithoutQueryTimeout.
+ */
+func (dbconn *DBConn) withDefaultTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := dbconn.defaultTimeout(ctx)
+	if timeout == 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// defaultTimeout returns the timeout withDefaultTimeout applies to ctx, or 0 if it applies none.
+func (dbconn *DBConn) defaultTimeout(ctx context.Context) time.Duration {
+	if dbconn.DefaultQueryTimeout <= 0 || ctx.Value(noQueryTimeoutKey{}) != nil {
+		return 0
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return 0
+	}
+	return dbconn.DefaultQueryTimeout
+}
@@ -0,0 +1,153 @@
+package dbconn_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/timeout tests", func() {
+	var driver *recordingDriver
+	BeforeEach(func() {
+		connection, mock = testhelper.CreateMockDBConn()
+		driver = useRecordingDriver(connection)
+		testhelper.ExpectVersionQuery(mock, "7.0.0")
+	})
+	Describe("DBConn.SetStatementTimeout", func() {
+		It("sets the timeout on every connection", func() {
+			connection.MustConnect(2)
+			mock.ExpectExec("SET statement_timeout = 90000").WillReturnResult(testhelper.TestResult{})
+			mock.ExpectExec("SET statement_timeout = 90000").WillReturnResult(testhelper.TestResult{})
+
+			Expect(connection.SetStatementTimeout(90 * time.Second)).To(Succeed())
+			Expect(connection.StatementTimeout).To(Equal(90 * time.Second))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("rounds sub-millisecond timeouts up rather than disabling the timeout", func() {
+			connection.MustConnect(1)
+			mock.ExpectExec("SET statement_timeout = 1").WillReturnResult(testhelper.TestResult{})
+
+			Expect(connection.SetStatementTimeout(time.Microsecond)).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("disables the timeout with 0", func() {
+			connection.MustConnect(1)
+			mock.ExpectExec("SET statement_timeout = 0").WillReturnResult(testhelper.TestResult{})
+
+			Expect(connection.SetStatementTimeout(0)).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns an error if the timeout cannot be set", func() {
+			connection.MustConnect(1)
+			mock.ExpectExec("SET statement_timeout = 1000").WillReturnError(errors.New("permission denied"))
+
+			Expect(connection.SetStatementTimeout(time.Second)).To(MatchError("Failed to set statement timeout on connection 0: permission denied"))
+		})
+		It("rejects a negative timeout", func() {
+			Expect(connection.SetStatementTimeout(-time.Second)).To(MatchError("Statement timeout must not be negative: -1s"))
+		})
+		It("applies the timeout when connecting", func() {
+			Expect(connection.SetStatementTimeout(5 * time.Second)).To(Succeed())
+			connection.MustConnect(1)
+
+			Expect(driver.ConnStrs[0]).To(ContainSubstring(" statement_timeout='5000'"))
+		})
+		It("does not send a timeout by default", func() {
+			connection.MustConnect(1)
+
+			Expect(driver.ConnStrs[0]).ToNot(ContainSubstring("statement_timeout"))
+		})
+	})
+	Describe("DBConn.DefaultQueryTimeout", func() {
+		var deadlines []time.Time
+		BeforeEach(func() {
+			connection.MustConnect(1)
+			deadlines = nil
+			connection.AddQueryHook(dbconn.QueryHookFuncs{Before: func(ctx context.Context, event *dbconn.QueryEvent) context.Context {
+				deadline, _ := ctx.Deadline()
+				deadlines = append(deadlines, deadline)
+				return ctx
+			}})
+		})
+		It("applies the default deadline to queries without one", func() {
+			connection.DefaultQueryTimeout = time.Minute
+			mock.ExpectExec("DELETE FROM foo").WillReturnResult(testhelper.TestResult{Rows: 1})
+			mock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+
+			start := time.Now()
+			connection.MustExec("DELETE FROM foo")
+			var n int
+			Expect(connection.Get(&n, "SELECT 1")).To(Succeed())
+			Expect(deadlines).To(HaveLen(2))
+			for _, deadline := range deadlines {
+				Expect(deadline).To(BeTemporally("~", start.Add(time.Minute), 5*time.Second))
+			}
+		})
+		It("lets a per-call deadline take precedence", func() {
+			connection.DefaultQueryTimeout = time.Minute
+			mock.ExpectExec("DELETE FROM foo").WillReturnResult(testhelper.TestResult{Rows: 1})
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+			defer cancel()
+			connection.MustExecContext(ctx, "DELETE FROM foo")
+			expected, _ := ctx.Deadline()
+			Expect(deadlines).To(Equal([]time.Time{expected}))
+		})
+		It("does not apply a deadline to queries exempted with WithoutQueryTimeout", func() {
+			connection.DefaultQueryTimeout = time.Minute
+			mock.ExpectExec("VACUUM").WillReturnResult(testhelper.TestResult{})
+
+			connection.MustExecContext(dbconn.WithoutQueryTimeout(context.Background()), "VACUUM")
+			Expect(deadlines).To(Equal([]time.Time{{}}))
+		})
+		It("does not apply a deadline by default", func() {
+			mock.ExpectExec("DELETE FROM foo").WillReturnResult(testhelper.TestResult{Rows: 1})
+
+			connection.MustExec("DELETE FROM foo")
+			Expect(deadlines).To(Equal([]time.Time{{}}))
+		})
+		It("does not cancel the rows of a query once it has returned", func() {
+			connection.DefaultQueryTimeout = 10 * time.Millisecond
+			mock.ExpectQuery("SELECT n").WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1).AddRow(2))
+
+			rows, err := connection.Query("SELECT n FROM numbers")
+			Expect(err).ToNot(HaveOccurred())
+			defer rows.Close()
+			time.Sleep(50 * time.Millisecond)
+			var values []int
+			for rows.Next() {
+				var n int
+				Expect(rows.Scan(&n)).To(Succeed())
+				values = append(values, n)
+			}
+			Expect(rows.Err()).ToNot(HaveOccurred())
+			Expect(values).To(Equal([]int{1, 2}))
+		})
+		It("cancels a query returning rows that runs past the deadline", func() {
+			connection.DefaultQueryTimeout = 10 * time.Millisecond
+			mock.ExpectQuery("SELECT pg_sleep").WillDelayFor(time.Second).WillReturnRows(sqlmock.NewRows([]string{"n"}))
+
+			start := time.Now()
+			_, err := connection.Query("SELECT pg_sleep(1)")
+			Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+			Expect(err).To(MatchError("Query on connection 0 did not complete within 10ms: context deadline exceeded"))
+			Expect(time.Since(start)).To(BeNumerically("<", 500*time.Millisecond))
+		})
+		It("cancels a query that runs past the deadline", func() {
+			connection.DefaultQueryTimeout = 10 * time.Millisecond
+			mock.ExpectExec("SELECT pg_sleep").WillDelayFor(time.Second).WillReturnResult(testhelper.TestResult{})
+
+			start := time.Now()
+			_, err := connection.Exec("SELECT pg_sleep(1)")
+			Expect(err).To(HaveOccurred())
+			Expect(time.Since(start)).To(BeNumerically("<", 500*time.Millisecond))
+		})
+	})
+})
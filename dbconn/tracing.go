@@ -0,0 +1,109 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to recording a tracing span
+ * for each query run through a DBConn.
+ *
+ * To avoid tying every utility to a particular tracing library, spans are
+ * created through the small Tracer and Span interfaces below rather than
+ * through OpenTelemetry directly.  Programs using OpenTelemetry can adapt a
+ * trace.Tracer from their TracerProvider with a few lines, for example:
+ *
+ *   type otelTracer struct{ tracer trace.Tracer }
+ *
+ *   func (t otelTracer) Start(ctx context.Context, name string) (context.Context, dbconn.Span) {
+ *       ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+ *       return ctx, otelSpan{span}
+ *   }
+ *
+ *   type otelSpan struct{ trace.Span }
+ *
+ *   func (s otelSpan) SetAttribute(key string, value interface{}) {
+ *       s.SetAttributes(attribute.String(key, fmt.Sprint(value)))
+ *   }
+ *
+ *   func (s otelSpan) RecordError(err error) {
+ *       s.Span.RecordError(err)
+ *       s.SetStatus(codes.Error, err.Error())
+ *   }
+ *
+ *   func (s otelSpan) End() { s.Span.End() }
+ *
+ * and then call connection.EnableTracing(otelTracer{provider.Tracer("gpbackup")}).
+ */
+
+import (
+	"context"
+	"strings"
+)
+
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+/*
+ * A Tracer starts a span as a child of any span in ctx, and returns a context
+ * containing the new span.
+ */
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+type spanContextKey struct{}
+
+/*
+ * TracingHook is a QueryHook that records a span for each query, named after
+ * the query's operation (such as "SELECT") and database.  Spans have the
+ * OpenTelemetry database client attributes db.system, db.name, db.user,
+ * db.statement, net.peer.name, and net.peer.port, plus db.rows_affected if
+ * known; failed queries record their error.  Use the Context functions, such
+ * as QueryContext, to make query spans children of the caller's span.
+ */
+type TracingHook struct {
+	Tracer Tracer
+	dbconn *DBConn
+}
+
+func NewTracingHook(dbconn *DBConn, tracer Tracer) *TracingHook {
+	return &TracingHook{Tracer: tracer, dbconn: dbconn}
+}
+
+func queryOperation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "QUERY"
+	}
+	return strings.ToUpper(strings.TrimLeft(fields[0], "("))
+}
+
+func (hook *TracingHook) BeforeQuery(ctx context.Context, event *QueryEvent) context.Context {
+	ctx, span := hook.Tracer.Start(ctx, queryOperation(event.Query)+" "+hook.dbconn.DBName)
+	span.SetAttribute("db.system", "postgresql")
+	span.SetAttribute("db.name", hook.dbconn.DBName)
+	span.SetAttribute("db.user", hook.dbconn.User)
+	span.SetAttribute("db.statement", event.Query)
+	span.SetAttribute("net.peer.name", hook.dbconn.Host)
+	span.SetAttribute("net.peer.port", hook.dbconn.Port)
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+func (hook *TracingHook) AfterQuery(ctx context.Context, event *QueryEvent) {
+	span, ok := ctx.Value(spanContextKey{}).(Span)
+	if !ok {
+		return
+	}
+	if event.RowsAffected >= 0 {
+		span.SetAttribute("db.rows_affected", event.RowsAffected)
+	}
+	if event.Err != nil {
+		span.RecordError(event.Err)
+	}
+	span.End()
+}
+
+// EnableTracing records a span with the given tracer for each subsequent query; see TracingHook.
+func (dbconn *DBConn) EnableTracing(tracer Tracer) {
+	dbconn.AddQueryHook(NewTracingHook(dbconn, tracer))
+}
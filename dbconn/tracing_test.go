@@ -0,0 +1,85 @@
+package dbconn_test
+
+import (
+	"context"
+	"errors"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type testSpan struct {
+	name       string
+	parent     *testSpan
+	attributes map[string]interface{}
+	err        error
+	ended      bool
+}
+
+func (span *testSpan) SetAttribute(key string, value interface{}) { span.attributes[key] = value }
+func (span *testSpan) RecordError(err error)                      { span.err = err }
+func (span *testSpan) End()                                       { span.ended = true }
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (tracer *testTracer) Start(ctx context.Context, name string) (context.Context, dbconn.Span) {
+	parent, _ := ctx.Value(contextKey("span")).(*testSpan)
+	span := &testSpan{name: name, parent: parent, attributes: map[string]interface{}{}}
+	tracer.spans = append(tracer.spans, span)
+	return context.WithValue(ctx, contextKey("span"), span), span
+}
+
+var _ = Describe("dbconn/tracing tests", func() {
+	var tracer *testTracer
+	BeforeEach(func() {
+		connection, mock = testhelper.CreateAndConnectMockDB(1)
+		tracer = &testTracer{}
+		connection.EnableTracing(tracer)
+	})
+	It("records a span for each query", func() {
+		mock.ExpectQuery("SELECT relname").WillReturnRows(sqlmock.NewRows([]string{"relname"}).AddRow("foo").AddRow("bar"))
+
+		names := make([]string, 0)
+		Expect(connection.Select(&names, "SELECT relname FROM pg_class")).To(Succeed())
+		Expect(tracer.spans).To(HaveLen(1))
+		span := tracer.spans[0]
+		Expect(span.name).To(Equal("SELECT testdb"))
+		Expect(span.attributes).To(Equal(map[string]interface{}{
+			"db.system":        "postgresql",
+			"db.name":          "testdb",
+			"db.user":          connection.User,
+			"db.statement":     "SELECT relname FROM pg_class",
+			"net.peer.name":    connection.Host,
+			"net.peer.port":    connection.Port,
+			"db.rows_affected": int64(2),
+		}))
+		Expect(span.err).ToNot(HaveOccurred())
+		Expect(span.ended).To(BeTrue())
+	})
+	It("records errors", func() {
+		mock.ExpectExec("DROP TABLE foo").WillReturnError(errors.New(`table "foo" does not exist`))
+
+		_, err := connection.Exec("DROP TABLE foo")
+		Expect(err).To(HaveOccurred())
+		Expect(tracer.spans[0].name).To(Equal("DROP testdb"))
+		Expect(tracer.spans[0].err).To(MatchError(`table "foo" does not exist`))
+		Expect(tracer.spans[0].attributes).ToNot(HaveKey("db.rows_affected"))
+		Expect(tracer.spans[0].ended).To(BeTrue())
+	})
+	It("makes query spans children of the caller's span", func() {
+		mock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow(1))
+		ctx, parent := tracer.Start(context.Background(), "backup")
+
+		rows, err := connection.QueryContext(ctx, "SELECT 1")
+		Expect(err).ToNot(HaveOccurred())
+		rows.Close()
+		Expect(tracer.spans).To(HaveLen(2))
+		Expect(tracer.spans[1].parent).To(Equal(parent))
+	})
+})
@@ -0,0 +1,281 @@
+package dbconn
+
+/*
+ * This file contains structs and functions related to running queries in an
+ * explicit transaction.
+ */
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/*
+ * A Tx is a transaction on one connection of a DBConn.  While it is open, it
+ * is also that connection's transaction in DBConn.Tx, so queries run through
+ * the DBConn on that connection number are part of the transaction, as with
+ * Begin.  It is finished by calling Commit or Rollback, after which it cannot
+ * be used.
+ */
+type Tx struct {
+	tx      *sqlx.Tx
+	dbconn  *DBConn
+	connNum int
+}
+
+type IsolationLevel int
+
+const (
+	ISOLATION_SERIALIZABLE IsolationLevel = iota
+	ISOLATION_REPEATABLE_READ
+	ISOLATION_READ_COMMITTED
+	ISOLATION_READ_UNCOMMITTED
+)
+
+func (level IsolationLevel) String() string {
+	switch level {
+	case ISOLATION_SERIALIZABLE:
+		return "SERIALIZABLE"
+	case ISOLATION_REPEATABLE_READ:
+		return "REPEATABLE READ"
+	case ISOLATION_READ_COMMITTED:
+		return "READ COMMITTED"
+	case ISOLATION_READ_UNCOMMITTED:
+		return "READ UNCOMMITTED"
+	}
+	return fmt.Sprintf("IsolationLevel(%d)", int(level))
+}
+
+/*
+ * TxOptions controls the transactions started by BeginWithOptions.  The zero
+ * value gives the SERIALIZABLE, read-write transaction that Begin starts.
+ * Deferrable only has an effect on SERIALIZABLE, read-only transactions.
+ */
+type TxOptions struct {
+	Isolation  IsolationLevel
+	ReadOnly   bool
+	Deferrable bool
+}
+
+/*
+ * setTransactionQuery returns the SET TRANSACTION statement for opts, adjusted
+ * for what the server supports.  GPDB 5 and earlier reject REPEATABLE READ,
+ * but their SERIALIZABLE is the same snapshot isolation that REPEATABLE READ
+ * gives in later versions, so it is used instead; nor do they have DEFERRABLE,
+ * which is only an optimization and so is omitted.
+ */
+func (dbconn *DBConn) setTransactionQuery(opts TxOptions) (string, error) {
+	if opts.Isolation < ISOLATION_SERIALIZABLE || opts.Isolation > ISOLATION_READ_UNCOMMITTED {
+		return "", errors.Errorf("Invalid isolation level: %s", opts.Isolation)
+	}
+	legacyIsolation := dbconn.Version.IsGPDB() && dbconn.Version.Before("6")
+	isolation := opts.Isolation
+	if legacyIsolation && isolation == ISOLATION_REPEATABLE_READ {
+		isolation = ISOLATION_SERIALIZABLE
+	}
+	query := "SET TRANSACTION ISOLATION LEVEL " + isolation.String()
+	if opts.ReadOnly {
+		query += ", READ ONLY"
+	}
+	if opts.Deferrable && !legacyIsolation {
+		query += ", DEFERRABLE"
+	}
+	return query, nil
+}
+
+func (dbconn *DBConn) MustBeginWithOptions(opts TxOptions, whichConn ...int) {
+	err := dbconn.BeginWithOptions(opts, whichConn...)
+	gplog.FatalOnError(err)
+}
+
+/*
+ * BeginWithOptions starts a transaction on the given connection, as Begin
+ * does, with the isolation level and access mode in opts.  For example, a
+ * consistent snapshot of the catalog for a metadata dump can be taken with
+ * TxOptions{Isolation: ISOLATION_REPEATABLE_READ, ReadOnly: true}.
+ */
+func (dbconn *DBConn) BeginWithOptions(opts TxOptions, whichConn ...int) error {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	if dbconn.Tx[connNum] != nil {
+		return errors.New("Cannot begin transaction; there is already a transaction in progress")
+	}
+	query, err := dbconn.setTransactionQuery(opts)
+	if err != nil {
+		return err
+	}
+	conn, err := dbconn.pooledConn(connNum)
+	if err != nil {
+		return err
+	}
+	dbconn.Tx[connNum], err = conn.Beginx()
+	if err != nil {
+		return err
+	}
+	_, err = dbconn.Exec(query, connNum)
+	return err
+}
+
+/*
+ * BeginTx starts a transaction on the given connection.  Unlike Begin, which
+ * always uses SERIALIZABLE isolation, it uses the isolation level and access
+ * mode in opts, or the server defaults if opts is nil.  The transaction is
+ * rolled back if ctx is canceled before it is committed.
+ */
+func (dbconn *DBConn) BeginTx(ctx context.Context, opts *sql.TxOptions, whichConn ...int) (*Tx, error) {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	if dbconn.Tx[connNum] != nil {
+		return nil, errors.New("Cannot begin transaction; there is already a transaction in progress")
+	}
+	conn, err := dbconn.pooledConn(connNum)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := conn.BeginTxx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	dbconn.Tx[connNum] = tx
+	return &Tx{tx: tx, dbconn: dbconn, connNum: connNum}, nil
+}
+
+/*
+ * RunInTransaction runs fn in a new transaction on the given connection,
+ * committing the transaction if fn returns nil and rolling it back if fn
+ * returns an error or panics.  The error from fn is returned as is, so callers
+ * can check for their own errors.
+ *
+ * If the DBConn has a TransactionRetryPolicy and the transaction fails with a
+ * serialization failure or deadlock, whether in fn or on commit, the whole
+ * transaction is rolled back and fn is run again in a new one, backing off
+ * between attempts as the policy specifies.  fn must therefore have no
+ * effects outside the transaction that would be wrong to repeat.
+ */
+func (dbconn *DBConn) RunInTransaction(fn func(tx *Tx) error, whichConn ...int) error {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	err := dbconn.runInTransaction(fn, connNum)
+	if dbconn.TransactionRetryPolicy == nil || !isTransactionConflict(err) {
+		return err
+	}
+	policy := dbconn.TransactionRetryPolicy.withDefaults()
+	backoff := policy.InitialBackoff
+	for attempt := 2; attempt <= policy.MaxAttempts && isTransactionConflict(err); attempt++ {
+		log.Verbose("Transaction on connection %d failed with a %s, retrying in %v (attempt %d of %d): %v", connNum, ClassifyError(err), backoff, attempt, policy.MaxAttempts, err)
+		time.Sleep(backoff)
+		backoff = policy.nextBackoff(backoff)
+		err = dbconn.runInTransaction(fn, connNum)
+	}
+	return err
+}
+
+func isTransactionConflict(err error) bool {
+	category := ClassifyError(err)
+	return category == ERROR_SERIALIZATION_FAILURE || category == ERROR_DEADLOCK
+}
+
+func (dbconn *DBConn) runInTransaction(fn func(tx *Tx) error, connNum int) error {
+	tx, err := dbconn.BeginTx(context.Background(), nil, connNum)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			_ = tx.Rollback()
+			panic(panicErr)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (tx *Tx) finish() {
+	if tx.dbconn.Tx != nil && tx.dbconn.Tx[tx.connNum] == tx.tx {
+		tx.dbconn.Tx[tx.connNum] = nil
+	}
+}
+
+func (tx *Tx) Commit() error {
+	defer tx.finish()
+	return tx.tx.Commit()
+}
+
+func (tx *Tx) Rollback() error {
+	defer tx.finish()
+	return tx.tx.Rollback()
+}
+
+func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.dbconn.exec(context.Background(), tx.tx, tx.connNum, query, args...)
+}
+
+func (tx *Tx) Get(destination interface{}, query string, args ...interface{}) error {
+	return tx.dbconn.get(context.Background(), tx.tx, tx.connNum, destination, query, args...)
+}
+
+func (tx *Tx) Select(destination interface{}, query string, args ...interface{}) error {
+	return tx.dbconn.selectRows(context.Background(), tx.tx, tx.connNum, destination, query, args...)
+}
+
+func (tx *Tx) Query(query string, args ...interface{}) (*sqlx.Rows, error) {
+	return tx.dbconn.query(context.Background(), tx.tx, tx.connNum, query, args...)
+}
+
+/*
+ * Savepoint creates a savepoint with the given name in the transaction.
+ * RollbackToSavepoint undoes everything done since then, including after an
+ * error, without ending the transaction, and ReleaseSavepoint discards the
+ * savepoint while keeping what was done since.
+ */
+func (tx *Tx) Savepoint(name string) error {
+	_, err := tx.Exec(fmt.Sprintf("SAVEPOINT %s", QuoteIdentifier(name)))
+	return errors.Wrapf(err, "Failed to create savepoint %s", name)
+}
+
+func (tx *Tx) RollbackToSavepoint(name string) error {
+	_, err := tx.Exec(fmt.Sprintf("ROLLBACK TO SAVEPOINT %s", QuoteIdentifier(name)))
+	return errors.Wrapf(err, "Failed to roll back to savepoint %s", name)
+}
+
+func (tx *Tx) ReleaseSavepoint(name string) error {
+	_, err := tx.Exec(fmt.Sprintf("RELEASE SAVEPOINT %s", QuoteIdentifier(name)))
+	return errors.Wrapf(err, "Failed to release savepoint %s", name)
+}
+
+/*
+ * WithSavepoint runs fn within a savepoint with the given name, so that if fn
+ * fails only what it did is undone and the transaction can continue, as when
+ * restoring many objects in one transaction and skipping those that fail.
+ * The savepoint is released if fn returns nil, and rolled back to and then
+ * released if fn returns an error or panics.  The error from fn is returned
+ * as is, unless the transaction cannot be returned to the savepoint, in which
+ * case that error is returned instead, as the transaction is then unusable.
+ */
+func (tx *Tx) WithSavepoint(name string, fn func(tx *Tx) error) error {
+	if err := tx.Savepoint(name); err != nil {
+		return err
+	}
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			_ = tx.RollbackToSavepoint(name)
+			panic(panicErr)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		if rollbackErr := tx.RollbackToSavepoint(name); rollbackErr != nil {
+			return rollbackErr
+		}
+		if releaseErr := tx.ReleaseSavepoint(name); releaseErr != nil {
+			return releaseErr
+		}
+		return err
+	}
+	return tx.ReleaseSavepoint(name)
+}
@@ -0,0 +1,303 @@
+package dbconn_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/blang/semver"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/tx tests", func() {
+	fakeResult := testhelper.TestResult{Rows: 1}
+	BeforeEach(func() {
+		connection, mock = testhelper.CreateAndConnectMockDB(2)
+	})
+	Describe("DBConn.BeginWithOptions", func() {
+		expectBeginWith := func(setTransaction string) {
+			mock.ExpectBegin()
+			mock.ExpectExec("^" + regexp.QuoteMeta(setTransaction) + "$").WillReturnResult(fakeResult)
+		}
+		DescribeTable("sets the transaction's isolation level and access mode", func(version string, opts dbconn.TxOptions, setTransaction string) {
+			testhelper.SetDBVersion(connection, version)
+			expectBeginWith(setTransaction)
+
+			Expect(connection.BeginWithOptions(opts, 1)).To(Succeed())
+			Expect(connection.Tx[1]).ToNot(BeNil())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		},
+			Entry("by default", "7.0.0", dbconn.TxOptions{}, "SET TRANSACTION ISOLATION LEVEL SERIALIZABLE"),
+			Entry("with REPEATABLE READ", "6.0.0", dbconn.TxOptions{Isolation: dbconn.ISOLATION_REPEATABLE_READ}, "SET TRANSACTION ISOLATION LEVEL REPEATABLE READ"),
+			Entry("with READ COMMITTED and READ ONLY", "7.0.0", dbconn.TxOptions{Isolation: dbconn.ISOLATION_READ_COMMITTED, ReadOnly: true}, "SET TRANSACTION ISOLATION LEVEL READ COMMITTED, READ ONLY"),
+			Entry("with DEFERRABLE", "6.0.0", dbconn.TxOptions{ReadOnly: true, Deferrable: true}, "SET TRANSACTION ISOLATION LEVEL SERIALIZABLE, READ ONLY, DEFERRABLE"),
+			Entry("with REPEATABLE READ before GPDB 6", "5.28.0", dbconn.TxOptions{Isolation: dbconn.ISOLATION_REPEATABLE_READ, ReadOnly: true}, "SET TRANSACTION ISOLATION LEVEL SERIALIZABLE, READ ONLY"),
+			Entry("with DEFERRABLE before GPDB 6", "4.3.0", dbconn.TxOptions{ReadOnly: true, Deferrable: true}, "SET TRANSACTION ISOLATION LEVEL SERIALIZABLE, READ ONLY"),
+		)
+		It("uses REPEATABLE READ for CBDB", func() {
+			connection.Version = dbconn.GPDBVersion{SemVer: semver.MustParse("1.6.0"), Type: dbconn.CBDB}
+			expectBeginWith("SET TRANSACTION ISOLATION LEVEL REPEATABLE READ")
+
+			Expect(connection.BeginWithOptions(dbconn.TxOptions{Isolation: dbconn.ISOLATION_REPEATABLE_READ})).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("rejects an invalid isolation level", func() {
+			err := connection.BeginWithOptions(dbconn.TxOptions{Isolation: dbconn.IsolationLevel(7)})
+			Expect(err).To(MatchError("Invalid isolation level: IsolationLevel(7)"))
+			Expect(connection.Tx[0]).To(BeNil())
+		})
+		It("returns an error if a transaction is already in progress", func() {
+			expectBeginWith("SET TRANSACTION ISOLATION LEVEL SERIALIZABLE")
+			connection.MustBegin()
+
+			err := connection.BeginWithOptions(dbconn.TxOptions{Isolation: dbconn.ISOLATION_READ_COMMITTED})
+			Expect(err).To(MatchError("Cannot begin transaction; there is already a transaction in progress"))
+		})
+	})
+	Describe("DBConn.BeginTx", func() {
+		It("runs queries in the transaction and commits it", func() {
+			mock.ExpectBegin()
+			mock.ExpectExec("INSERT INTO foo").WillReturnResult(fakeResult)
+			mock.ExpectQuery("SELECT count").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			mock.ExpectCommit()
+
+			tx, err := connection.BeginTx(context.Background(), nil, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(connection.Tx[1]).ToNot(BeNil())
+			_, err = tx.Exec("INSERT INTO foo VALUES ($1)", 1)
+			Expect(err).ToNot(HaveOccurred())
+			var count int
+			Expect(tx.Get(&count, "SELECT count(*) FROM foo")).To(Succeed())
+			Expect(count).To(Equal(1))
+			Expect(tx.Commit()).To(Succeed())
+			Expect(connection.Tx[1]).To(BeNil())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("makes the transaction available to the DBConn's own functions", func() {
+			mock.ExpectBegin()
+			mock.ExpectExec("INSERT INTO foo").WillReturnResult(fakeResult)
+			mock.ExpectRollback()
+
+			tx, err := connection.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+			Expect(err).ToNot(HaveOccurred())
+			connection.MustExec("INSERT INTO foo VALUES (1)")
+			Expect(tx.Rollback()).To(Succeed())
+			Expect(connection.Tx[0]).To(BeNil())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("fails if a transaction is already in progress", func() {
+			ExpectBegin(mock)
+			connection.MustBegin()
+
+			_, err := connection.BeginTx(context.Background(), nil)
+			Expect(err).To(MatchError("Cannot begin transaction; there is already a transaction in progress"))
+		})
+	})
+	Describe("Tx savepoints", func() {
+		It("creates, rolls back to, and releases named savepoints", func() {
+			mock.ExpectBegin()
+			mock.ExpectExec(`SAVEPOINT "before load"`).WillReturnResult(fakeResult)
+			mock.ExpectExec(`ROLLBACK TO SAVEPOINT "before load"`).WillReturnResult(fakeResult)
+			mock.ExpectExec(`RELEASE SAVEPOINT "before load"`).WillReturnResult(fakeResult)
+			mock.ExpectCommit()
+
+			tx, _ := connection.BeginTx(context.Background(), nil)
+			Expect(tx.Savepoint("before load")).To(Succeed())
+			Expect(tx.RollbackToSavepoint("before load")).To(Succeed())
+			Expect(tx.ReleaseSavepoint("before load")).To(Succeed())
+			Expect(tx.Commit()).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns an error if a savepoint does not exist", func() {
+			mock.ExpectBegin()
+			mock.ExpectExec(`ROLLBACK TO SAVEPOINT "missing"`).WillReturnError(errors.New(`savepoint "missing" does not exist`))
+
+			tx, _ := connection.BeginTx(context.Background(), nil)
+			Expect(tx.RollbackToSavepoint("missing")).To(MatchError(`Failed to roll back to savepoint missing: savepoint "missing" does not exist`))
+		})
+	})
+	Describe("Tx.WithSavepoint", func() {
+		It("releases the savepoint if the function succeeds", func() {
+			mock.ExpectBegin()
+			mock.ExpectExec(`SAVEPOINT "object1"`).WillReturnResult(fakeResult)
+			mock.ExpectExec("CREATE TABLE foo").WillReturnResult(fakeResult)
+			mock.ExpectExec(`RELEASE SAVEPOINT "object1"`).WillReturnResult(fakeResult)
+
+			tx, _ := connection.BeginTx(context.Background(), nil)
+			err := tx.WithSavepoint("object1", func(tx *dbconn.Tx) error {
+				_, err := tx.Exec("CREATE TABLE foo (i int)")
+				return err
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("rolls back to the savepoint and keeps the transaction open if the function fails", func() {
+			mock.ExpectBegin()
+			mock.ExpectExec(`SAVEPOINT "object1"`).WillReturnResult(fakeResult)
+			mock.ExpectExec("CREATE TABLE foo").WillReturnError(errors.New(`relation "foo" already exists`))
+			mock.ExpectExec(`ROLLBACK TO SAVEPOINT "object1"`).WillReturnResult(fakeResult)
+			mock.ExpectExec(`RELEASE SAVEPOINT "object1"`).WillReturnResult(fakeResult)
+			mock.ExpectExec("CREATE TABLE bar").WillReturnResult(fakeResult)
+			mock.ExpectCommit()
+
+			tx, _ := connection.BeginTx(context.Background(), nil)
+			err := tx.WithSavepoint("object1", func(tx *dbconn.Tx) error {
+				_, err := tx.Exec("CREATE TABLE foo (i int)")
+				return err
+			})
+			Expect(err).To(MatchError(`relation "foo" already exists`))
+			_, err = tx.Exec("CREATE TABLE bar (i int)")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tx.Commit()).To(Succeed())
+			Expect(connection.Tx[0]).To(BeNil())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns the error from rolling back to the savepoint if that fails", func() {
+			mock.ExpectBegin()
+			mock.ExpectExec(`SAVEPOINT "object1"`).WillReturnResult(fakeResult)
+			mock.ExpectExec(`ROLLBACK TO SAVEPOINT "object1"`).WillReturnError(errors.New("connection reset"))
+
+			tx, _ := connection.BeginTx(context.Background(), nil)
+			err := tx.WithSavepoint("object1", func(tx *dbconn.Tx) error {
+				return errors.New("function failed")
+			})
+			Expect(err).To(MatchError("Failed to roll back to savepoint object1: connection reset"))
+		})
+		It("rolls back to the savepoint and re-panics if the function panics", func() {
+			mock.ExpectBegin()
+			mock.ExpectExec(`SAVEPOINT "object1"`).WillReturnResult(fakeResult)
+			mock.ExpectExec(`ROLLBACK TO SAVEPOINT "object1"`).WillReturnResult(fakeResult)
+
+			tx, _ := connection.BeginTx(context.Background(), nil)
+			Expect(func() {
+				_ = tx.WithSavepoint("object1", func(tx *dbconn.Tx) error {
+					panic("function panicked")
+				})
+			}).To(PanicWith("function panicked"))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("does not run the function if the savepoint cannot be created", func() {
+			mock.ExpectBegin()
+			mock.ExpectExec(`SAVEPOINT "object1"`).WillReturnError(errors.New("current transaction is aborted"))
+
+			tx, _ := connection.BeginTx(context.Background(), nil)
+			called := false
+			err := tx.WithSavepoint("object1", func(tx *dbconn.Tx) error {
+				called = true
+				return nil
+			})
+			Expect(err).To(MatchError("Failed to create savepoint object1: current transaction is aborted"))
+			Expect(called).To(BeFalse())
+		})
+	})
+	Describe("DBConn.RunInTransaction", func() {
+		It("commits if the function succeeds", func() {
+			mock.ExpectBegin()
+			mock.ExpectExec("INSERT INTO foo").WillReturnResult(fakeResult)
+			mock.ExpectCommit()
+
+			err := connection.RunInTransaction(func(tx *dbconn.Tx) error {
+				_, err := tx.Exec("INSERT INTO foo VALUES (1)")
+				return err
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(connection.Tx[0]).To(BeNil())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("rolls back and returns the error if the function fails", func() {
+			mock.ExpectBegin()
+			mock.ExpectRollback()
+			fnErr := errors.New("validation failed")
+
+			err := connection.RunInTransaction(func(tx *dbconn.Tx) error { return fnErr }, 1)
+			Expect(err).To(Equal(fnErr))
+			Expect(connection.Tx[1]).To(BeNil())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("rolls back and re-panics if the function panics", func() {
+			mock.ExpectBegin()
+			mock.ExpectRollback()
+
+			Expect(func() {
+				_ = connection.RunInTransaction(func(tx *dbconn.Tx) error { panic("boom") })
+			}).To(PanicWith("boom"))
+			Expect(connection.Tx[0]).To(BeNil())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns the error if the commit fails", func() {
+			mock.ExpectBegin()
+			mock.ExpectCommit().WillReturnError(errors.New("could not serialize access"))
+
+			err := connection.RunInTransaction(func(tx *dbconn.Tx) error { return nil })
+			Expect(err).To(MatchError("could not serialize access"))
+			Expect(connection.Tx[0]).To(BeNil())
+		})
+		Context("with a TransactionRetryPolicy", func() {
+			serializationErr := errors.New("ERROR: could not serialize access due to concurrent update (SQLSTATE 40001)")
+			BeforeEach(func() {
+				connection.TransactionRetryPolicy = &dbconn.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+			})
+			It("retries the transaction after a serialization failure", func() {
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE foo").WillReturnError(serializationErr)
+				mock.ExpectRollback()
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE foo").WillReturnResult(fakeResult)
+				mock.ExpectCommit()
+
+				attempts := 0
+				err := connection.RunInTransaction(func(tx *dbconn.Tx) error {
+					attempts++
+					_, err := tx.Exec("UPDATE foo SET a = 1")
+					return err
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(attempts).To(Equal(2))
+				Expect(mock.ExpectationsWereMet()).To(Succeed())
+			})
+			It("retries the transaction after a deadlock on commit", func() {
+				mock.ExpectBegin()
+				mock.ExpectCommit().WillReturnError(errors.New("ERROR: deadlock detected (SQLSTATE 40P01)"))
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+
+				Expect(connection.RunInTransaction(func(tx *dbconn.Tx) error { return nil })).To(Succeed())
+				Expect(mock.ExpectationsWereMet()).To(Succeed())
+			})
+			It("returns the last error once MaxAttempts is reached", func() {
+				for i := 0; i < 3; i++ {
+					mock.ExpectBegin()
+					mock.ExpectRollback()
+				}
+
+				attempts := 0
+				err := connection.RunInTransaction(func(tx *dbconn.Tx) error {
+					attempts++
+					return serializationErr
+				})
+				Expect(err).To(Equal(serializationErr))
+				Expect(attempts).To(Equal(3))
+			})
+			It("does not retry other errors", func() {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+				fnErr := errors.New("validation failed")
+
+				attempts := 0
+				err := connection.RunInTransaction(func(tx *dbconn.Tx) error {
+					attempts++
+					return fnErr
+				})
+				Expect(err).To(Equal(fnErr))
+				Expect(attempts).To(Equal(1))
+			})
+		})
+	})
+})
@@ -0,0 +1,120 @@
+package dbconn
+
+/*
+ * This file contains functions related to creating a DBConn from a libpq
+ * connection URI.
+ */
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+/*
+ * NewDBConnFromURI creates a DBConn from a libpq connection URI of the form
+ *
+ *   postgresql://[user[:password]@][host][:port][/dbname][?param=value&...]
+ *
+ * Any component missing from the URI is taken from the environment in the same
+ * way as NewDBConnFromEnvironment, except that the database name defaults to
+ * $PGDATABASE and then to the user name, as in libpq.  The host, port, user,
+ * password, dbname, and ssl* parameters may also be given in the query string,
+ * where they override the corresponding URI components; all other parameters
+ * are passed to the server as startup parameters.  URIs with multiple hosts
+ * are not supported.
+ */
+func NewDBConnFromURI(uri string) (*DBConn, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid connection URI")
+	}
+	if parsed.Scheme != "postgresql" && parsed.Scheme != "postgres" {
+		return nil, errors.Errorf(`Invalid connection URI scheme %q; must be "postgresql" or "postgres"`, parsed.Scheme)
+	}
+	if strings.Contains(parsed.Host, ",") {
+		return nil, errors.New("Connection URIs with multiple hosts are not supported")
+	}
+
+	username := parsed.User.Username()
+	password, _ := parsed.User.Password()
+	host := parsed.Hostname()
+	portStr := parsed.Port()
+	dbname := strings.TrimPrefix(parsed.Path, "/")
+	ssl := SSLOptions{}
+	startupParams := make(map[string]string)
+
+	query, err := url.ParseQuery(parsed.RawQuery)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid connection URI parameters")
+	}
+	for name, values := range query {
+		value := values[len(values)-1]
+		switch name {
+		case "host":
+			host = value
+		case "port":
+			portStr = value
+		case "user":
+			username = value
+		case "password":
+			password = value
+		case "dbname":
+			dbname = value
+		case "sslmode":
+			ssl.Mode = value
+		case "sslrootcert":
+			ssl.RootCert = value
+		case "sslcert":
+			ssl.Cert = value
+		case "sslkey":
+			ssl.Key = value
+		default:
+			startupParams[name] = value
+		}
+	}
+
+	if username == "" {
+		username = operating.System.Getenv("PGUSER")
+	}
+	if username == "" {
+		currentUser, err := operating.System.CurrentUser()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Cannot determine user name")
+		}
+		username = currentUser.Username
+	}
+	if host == "" {
+		host = operating.System.Getenv("PGHOST")
+	}
+	if host == "" {
+		host, _ = operating.System.Hostname()
+	}
+	if portStr == "" {
+		portStr = operating.System.Getenv("PGPORT")
+	}
+	port := 5432
+	if portStr != "" {
+		port, err = strconv.Atoi(portStr)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, errors.Errorf("Invalid port %q in connection URI", portStr)
+		}
+	}
+	if dbname == "" {
+		dbname = operating.System.Getenv("PGDATABASE")
+	}
+	if dbname == "" {
+		dbname = username
+	}
+
+	connection := NewDBConn(dbname, username, host, port)
+	connection.Password = password
+	connection.SSL = ssl
+	if len(startupParams) > 0 {
+		connection.StartupParameters = startupParams
+	}
+	return connection, nil
+}
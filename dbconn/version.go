@@ -1,11 +1,15 @@
 package dbconn
 
 import (
+	"fmt"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/blang/semver"
-	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/pkg/errors"
 )
 
 // DBType represents the type of database
@@ -17,11 +21,26 @@ const (
 	CBDB           // Apache Cloudberry Database
 )
 
+/*
+ * The version in a banner may be followed by a pre-release component, as in
+ * "7.0.0-beta.3", and by build metadata, as in "2.1.0+dev.123"; both are kept
+ * in SemVer.  See IsPreRelease.
+ */
 const (
-	gpdbPattern = `\(Greenplum Database ([0-9]+\.[0-9]+\.[0-9]+)[^)]*\)`
-	cbdbPattern = `\(Apache Cloudberry ([0-9]+\.[0-9]+\.[0-9]+)[^)]*\)`
+	semverPattern = `[0-9]+\.[0-9]+\.[0-9]+(?:-[0-9A-Za-z.-]+)?(?:\+[0-9A-Za-z.-]+)?`
+	gpdbPattern   = `\(Greenplum Database (` + semverPattern + `)[^)]*\)`
+	cbdbPattern   = `\(Apache Cloudberry (` + semverPattern + `)[^)]*\)`
 )
 
+var releaseVersionRegex = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+`)
+
+/*
+ * PostgreSQL has used two-part version numbers (e.g. "14.4") since version 10
+ * and three-part numbers (e.g. "9.4.26") before that; development and beta
+ * versions append a suffix such as "devel" or "beta2" to the major version.
+ */
+var pgVersionRegex = regexp.MustCompile(`^PostgreSQL ([0-9]+)(?:\.([0-9]+))?(?:\.([0-9]+))?`)
+
 // String provides string representation of DBType
 func (t DBType) String() string {
 	switch t {
@@ -29,9 +48,71 @@ func (t DBType) String() string {
 		return "Greenplum Database"
 	case CBDB:
 		return "Apache Cloudberry"
-	default:
-		return "Unknown Database"
 	}
+	versionPatternsMutex.RLock()
+	defer versionPatternsMutex.RUnlock()
+	if name, ok := registeredDBTypeNames[t]; ok {
+		return name
+	}
+	return "Unknown Database"
+}
+
+type versionPattern struct {
+	dbType DBType
+	regex  *regexp.Regexp
+}
+
+/*
+ * ParseVersionInfo tries each pattern in order and uses the first one that
+ * matches.  We check for Apache Cloudberry first as its string may be a
+ * superset of others in the future, and patterns registered by callers are
+ * checked before either, so that a derivative whose banner also matches one
+ * of the built-in patterns can still be told apart.
+ */
+var (
+	versionPatternsMutex  sync.RWMutex
+	registeredPatterns    []versionPattern
+	builtinPatterns       = []versionPattern{{CBDB, regexp.MustCompile(cbdbPattern)}, {GPDB, regexp.MustCompile(gpdbPattern)}}
+	registeredDBTypeNames = map[DBType]string{}
+	nextDBType            = CBDB + 1
+)
+
+/*
+ * RegisterDBType adds a database type with the given name, such as a
+ * commercial fork or renamed distribution, and returns its DBType.  The
+ * patterns are registered for it as with RegisterVersionPattern.  It is
+ * intended to be called when a program starts, such as from an init function,
+ * and panics if a pattern is invalid.
+ */
+func RegisterDBType(name string, patterns ...string) DBType {
+	versionPatternsMutex.Lock()
+	dbType := nextDBType
+	nextDBType++
+	registeredDBTypeNames[dbType] = name
+	versionPatternsMutex.Unlock()
+	for _, pattern := range patterns {
+		RegisterVersionPattern(dbType, pattern)
+	}
+	return dbType
+}
+
+/*
+ * RegisterVersionPattern adds a regular expression that identifies a database
+ * of the given type from the output of version(), such as a variant banner for
+ * one of the built-in types.  Its first capture group must match the database
+ * version in X.Y.Z form, optionally followed by a pre-release component or
+ * build metadata.  Patterns are tried in the order they are registered.
+ * It is intended to be called when a program starts, such as from an init
+ * function, and panics if the pattern is invalid.
+ */
+func RegisterVersionPattern(dbType DBType, pattern string) {
+	regex := regexp.MustCompile(pattern)
+	if regex.NumSubexp() < 1 {
+		panic(fmt.Sprintf("Version pattern %q must have a capture group for the version", pattern))
+	}
+	versionPatternsMutex.Lock()
+	defer versionPatternsMutex.Unlock()
+	registeredPatterns = append(registeredPatterns, versionPattern{dbType: dbType, regex: regex})
 }
 
 // GPDBVersion represents version information for a database
@@ -39,6 +120,8 @@ type GPDBVersion struct {
 	VersionString string
 	SemVer        semver.Version
 	Type          DBType
+	// The version of PostgreSQL the database is based on, or 0.0.0 if unknown
+	PGSemVer semver.Version
 }
 
 /*
@@ -63,7 +146,21 @@ func NewVersion(versionStr string) GPDBVersion {
 // InitializeVersion parses database version string and returns version information
 // It can distinguish between Greenplum Database and Apache Cloudberry Database.
 func InitializeVersion(dbconn *DBConn) (dbversion GPDBVersion, err error) {
-	err = dbconn.Get(&dbversion, "SELECT pg_catalog.version() AS versionstring")
+	/*
+	 * Prefer a connection with no transaction in progress, so that the query
+	 * still succeeds if a long-lived program calls this while a transaction on
+	 * the first connection has failed and not yet been rolled back, and one
+	 * that has been established, so that a lazily connected DBConn does not
+	 * establish another just for this.
+	 */
+	connNum := 0
+	for i := range dbconn.Tx {
+		if dbconn.Tx[i] == nil && dbconn.pooled(i) != nil {
+			connNum = i
+			break
+		}
+	}
+	err = dbconn.Get(&dbversion, "SELECT pg_catalog.version() AS versionstring", connNum)
 	if err != nil {
 		return
 	}
@@ -71,28 +168,50 @@ func InitializeVersion(dbconn *DBConn) (dbversion GPDBVersion, err error) {
 	// Determine database type and parse version
 	dbversion.ParseVersionInfo(dbversion.VersionString)
 
-	gplog.Debug("Initialized database version - Full Version: %s, Database Type: %s, Semantic Version: %s",
+	log.Debug("Initialized database version - Full Version: %s, Database Type: %s, Semantic Version: %s",
 		dbversion.VersionString, dbversion.Type, dbversion.SemVer)
 	return
 }
 
+/*
+ * ReloadVersion queries the database version again and updates dbconn.Version,
+ * so that a long-lived program can detect that the server has been upgraded,
+ * such as to a new minor version, without reconnecting.  It returns whether
+ * the version changed.  If the query fails, dbconn.Version is left unchanged.
+ * As with other fields of DBConn, dbconn.Version should not be read by other
+ * goroutines while this runs.
+ */
+func (dbconn *DBConn) ReloadVersion() (bool, error) {
+	version, err := InitializeVersion(dbconn)
+	if err != nil {
+		return false, errors.Wrap(err, "Failed to determine database version")
+	}
+	changed := version.VersionString != dbconn.Version.VersionString
+	if changed {
+		log.Info("Database version changed from %s %s to %s %s", dbconn.Version.Type, dbconn.Version.SemVer, version.Type, version.SemVer)
+	}
+	dbconn.Version = version
+	return changed, nil
+}
+
 func (dbversion *GPDBVersion) ParseVersionInfo(versionString string) {
 	dbversion.VersionString = versionString
 	dbversion.Type = Unknown
+	dbversion.PGSemVer = parsePGVersion(versionString)
 
-	// Try to match each database type.
-	// We check for Apache Cloudberry first as its string may be a superset of others in the future.
-	if ver, ok := dbversion.extractVersion(cbdbPattern); ok {
-		dbversion.Type = CBDB
-		dbversion.SemVer = ver
-	} else if ver, ok := dbversion.extractVersion(gpdbPattern); ok {
-		dbversion.Type = GPDB
-		dbversion.SemVer = ver
+	versionPatternsMutex.RLock()
+	patterns := append(append([]versionPattern{}, registeredPatterns...), builtinPatterns...)
+	versionPatternsMutex.RUnlock()
+	for _, pattern := range patterns {
+		if ver, ok := dbversion.extractVersion(pattern.regex); ok {
+			dbversion.Type = pattern.dbType
+			dbversion.SemVer = ver
+			return
+		}
 	}
 }
 
-func (dbversion GPDBVersion) extractVersion(pattern string) (semver.Version, bool) {
-	re := regexp.MustCompile(pattern)
+func (dbversion GPDBVersion) extractVersion(re *regexp.Regexp) (semver.Version, bool) {
 	matches := re.FindStringSubmatch(dbversion.VersionString)
 	if len(matches) < 2 {
 		return semver.Version{}, false
@@ -100,11 +219,48 @@ func (dbversion GPDBVersion) extractVersion(pattern string) (semver.Version, boo
 
 	ver, err := semver.Make(matches[1])
 	if err != nil {
-		return semver.Version{}, false
+		// Fall back to the release version if the pre-release component or
+		// build metadata is not valid semver, such as "beta.03".
+		ver, err = semver.Make(releaseVersionRegex.FindString(matches[1]))
+		if err != nil {
+			return semver.Version{}, false
+		}
 	}
+	// Apache Cloudberry releases made while it was incubating are marked
+	// "-incubating", which is not a pre-release in the semver sense.
+	pre := make([]semver.PRVersion, 0, len(ver.Pre))
+	for _, part := range ver.Pre {
+		if part.VersionStr != "incubating" {
+			pre = append(pre, part)
+		}
+	}
+	if len(pre) == 0 {
+		pre = nil
+	}
+	ver.Pre = pre
 	return ver, true
 }
 
+// parsePGVersion returns the version from the leading "PostgreSQL X.Y" of a version string, padded to X.Y.Z.
+func parsePGVersion(versionString string) semver.Version {
+	matches := pgVersionRegex.FindStringSubmatch(versionString)
+	if matches == nil {
+		return semver.Version{}
+	}
+	parts := make([]uint64, 3)
+	for i, match := range matches[1:] {
+		if match == "" {
+			continue
+		}
+		part, err := strconv.ParseUint(match, 10, 64)
+		if err != nil {
+			return semver.Version{}
+		}
+		parts[i] = part
+	}
+	return semver.Version{Major: parts[0], Minor: parts[1], Patch: parts[2]}
+}
+
 func (dbversion GPDBVersion) StringToSemVerRange(versionStr string) semver.Range {
 	numDigits := len(strings.Split(versionStr, "."))
 	if numDigits < 3 {
@@ -114,19 +270,65 @@ func (dbversion GPDBVersion) StringToSemVerRange(versionStr string) semver.Range
 	return validRange
 }
 
+/*
+ * Before, AtLeast, and Is compare the release version, ignoring any
+ * pre-release component or build metadata, so that a beta of 7.0.0 is treated
+ * as 7.0.0 for feature checks rather than as older than every 7.x release.
+ */
 func (dbversion GPDBVersion) Before(targetVersion string) bool {
 	validRange := dbversion.StringToSemVerRange("<" + targetVersion)
-	return validRange(dbversion.SemVer)
+	return validRange(dbversion.releaseSemVer())
 }
 
 func (dbversion GPDBVersion) AtLeast(targetVersion string) bool {
 	validRange := dbversion.StringToSemVerRange(">=" + targetVersion)
-	return validRange(dbversion.SemVer)
+	return validRange(dbversion.releaseSemVer())
 }
 
 func (dbversion GPDBVersion) Is(targetVersion string) bool {
 	validRange := dbversion.StringToSemVerRange("==" + targetVersion)
-	return validRange(dbversion.SemVer)
+	return validRange(dbversion.releaseSemVer())
+}
+
+func (dbversion GPDBVersion) releaseSemVer() semver.Version {
+	return semver.Version{Major: dbversion.SemVer.Major, Minor: dbversion.SemVer.Minor, Patch: dbversion.SemVer.Patch}
+}
+
+/*
+ * IsPreRelease reports whether the database is a pre-release, such as
+ * 7.0.0-beta.3, or a development build, such as 2.1.0+dev.123, which callers
+ * may want to warn about or refuse to support.
+ */
+func (dbversion GPDBVersion) IsPreRelease() bool {
+	if len(dbversion.SemVer.Pre) > 0 {
+		return true
+	}
+	for _, build := range dbversion.SemVer.Build {
+		if strings.HasPrefix(build, "dev") {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * PGVersion returns the version of PostgreSQL the database is based on, and
+ * PGAtLeast and PGBefore compare it as AtLeast and Before compare the database
+ * version, so that features inherited from PostgreSQL can be checked directly
+ * rather than through the corresponding Greenplum or Cloudberry version.
+ */
+func (dbversion GPDBVersion) PGVersion() semver.Version {
+	return dbversion.PGSemVer
+}
+
+func (dbversion GPDBVersion) PGAtLeast(targetVersion string) bool {
+	validRange := dbversion.StringToSemVerRange(">=" + targetVersion)
+	return validRange(dbversion.PGSemVer)
+}
+
+func (dbversion GPDBVersion) PGBefore(targetVersion string) bool {
+	validRange := dbversion.StringToSemVerRange("<" + targetVersion)
+	return validRange(dbversion.PGSemVer)
 }
 
 func (dbversion GPDBVersion) IsGPDB() bool {
@@ -144,3 +346,82 @@ func (srcVersion GPDBVersion) Equals(destVersion GPDBVersion) bool {
 
 	return srcVersion.SemVer.Major == destVersion.SemVer.Major
 }
+
+/*
+ * EffectiveGPDBMajor returns the major version of Greenplum whose features and
+ * syntax the database corresponds to, so that decisions about what a database
+ * supports can be made the same way for each database type.  Apache
+ * Cloudberry 1.x and 2.x are derived from Greenplum 7 and so are treated as
+ * equivalent to it.  It returns 0 for an Unknown or registered database type.
+ */
+func (dbversion GPDBVersion) EffectiveGPDBMajor() uint64 {
+	switch dbversion.Type {
+	case GPDB:
+		return dbversion.SemVer.Major
+	case CBDB:
+		return 7
+	}
+	return 0
+}
+
+// AtLeastFeatureLevel reports whether the database has at least the features of the given major version of Greenplum.
+func (dbversion GPDBVersion) AtLeastFeatureLevel(gpdbMajor uint64) bool {
+	effectiveMajor := dbversion.EffectiveGPDBMajor()
+	return effectiveMajor != 0 && effectiveMajor >= gpdbMajor
+}
+
+/*
+ * EquivalentTo is like Equals, but compares the Greenplum major version each
+ * database corresponds to rather than its own type and major version, so that
+ * for example Apache Cloudberry 1.x is equivalent to Greenplum 7.  Databases
+ * of unknown feature level are not equivalent to any other.
+ */
+func (srcVersion GPDBVersion) EquivalentTo(destVersion GPDBVersion) bool {
+	srcMajor := srcVersion.EffectiveGPDBMajor()
+	return srcMajor != 0 && srcMajor == destVersion.EffectiveGPDBMajor()
+}
+
+/*
+ * ValidateSupported returns an error suitable for showing to the user if the
+ * database is not one a utility supports.  The constraints map each supported
+ * database type to either its minimum version, such as "6" or "6.2.0", or a
+ * range, such as ">=6.2.0 <8.0.0"; a database of any other type is not
+ * supported.  A constraint that is not a valid version or range is considered
+ * programmer error, and causes a panic.
+ */
+func (dbversion GPDBVersion) ValidateSupported(constraints map[DBType]string) error {
+	constraint, ok := constraints[dbversion.Type]
+	if !ok {
+		types := make([]DBType, 0, len(constraints))
+		for dbType := range constraints {
+			types = append(types, dbType)
+		}
+		sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+		supported := make([]string, len(types))
+		for i, dbType := range types {
+			supported[i] = fmt.Sprintf("%s %s", dbType, describeVersionConstraint(constraints[dbType]))
+		}
+		return errors.Errorf("%s is not supported; supported databases are: %s", dbversion.Type, strings.Join(supported, ", "))
+	}
+	var supported bool
+	if isVersionRange(constraint) {
+		supported = semver.MustParseRange(constraint)(dbversion.releaseSemVer())
+	} else {
+		supported = dbversion.AtLeast(constraint)
+	}
+	if !supported {
+		return errors.Errorf("%s %s is not supported; version %s is required", dbversion.Type, dbversion.SemVer, describeVersionConstraint(constraint))
+	}
+	return nil
+}
+
+func isVersionRange(constraint string) bool {
+	return strings.ContainsAny(constraint, "<>=! ")
+}
+
+func describeVersionConstraint(constraint string) string {
+	if isVersionRange(constraint) {
+		return fmt.Sprintf("%q", constraint)
+	}
+	return constraint + " or later"
+}
@@ -1,8 +1,12 @@
 package dbconn_test
 
 import (
+	"context"
+	"errors"
+
 	"github.com/blang/semver"
 	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -22,6 +26,7 @@ var _ = Describe("dbconn/version tests", func() {
 			dbVersion.ParseVersionInfo(versionStr)
 			Expect(dbVersion.Type).To(Equal(dbconn.GPDB))
 			Expect(dbVersion.SemVer.String()).To(Equal("7.0.0"))
+			Expect(dbVersion.PGVersion().String()).To(Equal("12.12.0"))
 			Expect(dbVersion.IsGPDB()).To(BeTrue())
 			Expect(dbVersion.IsCBDB()).To(BeFalse())
 		})
@@ -31,6 +36,7 @@ var _ = Describe("dbconn/version tests", func() {
 			dbVersion.ParseVersionInfo(versionStr)
 			Expect(dbVersion.Type).To(Equal(dbconn.CBDB))
 			Expect(dbVersion.SemVer.String()).To(Equal("2.0.0"))
+			Expect(dbVersion.PGVersion().String()).To(Equal("14.4.0"))
 			Expect(dbVersion.IsCBDB()).To(BeTrue())
 			Expect(dbVersion.IsGPDB()).To(BeFalse())
 		})
@@ -40,6 +46,83 @@ var _ = Describe("dbconn/version tests", func() {
 			dbVersion.ParseVersionInfo(versionStr)
 			Expect(dbVersion.Type).To(Equal(dbconn.Unknown))
 			Expect(dbVersion.SemVer.String()).To(Equal("0.0.0"))
+			Expect(dbVersion.PGVersion().String()).To(Equal("0.0.0"))
+		})
+	})
+	Describe("RegisterDBType", func() {
+		It("identifies a registered database type, even if its banner matches a built-in pattern", func() {
+			forkType := dbconn.RegisterDBType("Example Fork", `\(Example Fork ([0-9]+\.[0-9]+\.[0-9]+)[^)]*\)`)
+			Expect(forkType.String()).To(Equal("Example Fork"))
+			Expect(forkType).ToNot(BeElementOf(dbconn.Unknown, dbconn.GPDB, dbconn.CBDB))
+
+			dbVersion := dbconn.GPDBVersion{}
+			dbVersion.ParseVersionInfo("PostgreSQL 12.12 (Example Fork 3.1.4 build 1) (Greenplum Database 7.0.0 build commit:abc) on x86_64-pc-linux-gnu")
+			Expect(dbVersion.Type).To(Equal(forkType))
+			Expect(dbVersion.SemVer.String()).To(Equal("3.1.4"))
+			Expect(dbVersion.IsGPDB()).To(BeFalse())
+		})
+		It("gives each registered type a distinct value", func() {
+			Expect(dbconn.RegisterDBType("Fork A")).ToNot(Equal(dbconn.RegisterDBType("Fork B")))
+		})
+	})
+	Describe("RegisterVersionPattern", func() {
+		It("identifies a variant banner for a built-in type", func() {
+			dbconn.RegisterVersionPattern(dbconn.GPDB, `\(Example Greenplum ([0-9]+\.[0-9]+\.[0-9]+)[^)]*\)`)
+
+			dbVersion := dbconn.GPDBVersion{}
+			dbVersion.ParseVersionInfo("PostgreSQL 12.12 (Example Greenplum 7.1.0 build commercial) on x86_64-pc-linux-gnu")
+			Expect(dbVersion.Type).To(Equal(dbconn.GPDB))
+			Expect(dbVersion.SemVer.String()).To(Equal("7.1.0"))
+		})
+		It("panics if the pattern has no capture group", func() {
+			defer testhelper.ShouldPanicWithMessage(`Version pattern "Example DB [0-9.]+" must have a capture group for the version`)
+			dbconn.RegisterVersionPattern(dbconn.GPDB, `Example DB [0-9.]+`)
+		})
+	})
+	Describe("pre-release versions", func() {
+		DescribeTable("parses the pre-release component", func(versionStr string, expectedType dbconn.DBType, expected string, preRelease bool) {
+			dbVersion := dbconn.GPDBVersion{}
+			dbVersion.ParseVersionInfo(versionStr)
+			Expect(dbVersion.Type).To(Equal(expectedType))
+			Expect(dbVersion.SemVer.String()).To(Equal(expected))
+			Expect(dbVersion.IsPreRelease()).To(Equal(preRelease))
+		},
+			Entry("a beta", "PostgreSQL 12.12 (Greenplum Database 7.0.0-beta.3 build commit:abc) on x86_64-pc-linux-gnu", dbconn.GPDB, "7.0.0-beta.3", true),
+			Entry("a development build", "PostgreSQL 14.4 (Apache Cloudberry 2.1.0+dev.123 build dev) on x86_64-pc-linux-gnu", dbconn.CBDB, "2.1.0+dev.123", true),
+			Entry("an incubating release", "PostgreSQL 14.4 (Apache Cloudberry 2.0.0-incubating build 1) on x86_64-pc-linux-gnu", dbconn.CBDB, "2.0.0", false),
+			Entry("an incubating release candidate", "PostgreSQL 14.4 (Apache Cloudberry 2.0.0-incubating-rc1 build 1) on x86_64-pc-linux-gnu", dbconn.CBDB, "2.0.0-incubating-rc1", true),
+			Entry("an invalid pre-release component", "PostgreSQL 12.12 (Greenplum Database 7.0.0-beta.03 build commit:abc) on x86_64-pc-linux-gnu", dbconn.GPDB, "7.0.0", false),
+			Entry("a release", "PostgreSQL 9.4.26 (Greenplum Database 6.25.3 build commit:abc) on x86_64-unknown-linux-gnu", dbconn.GPDB, "6.25.3", false),
+		)
+		It("compares a pre-release as its release version", func() {
+			dbVersion := dbconn.GPDBVersion{}
+			dbVersion.ParseVersionInfo("PostgreSQL 12.12 (Greenplum Database 7.0.0-beta.3 build commit:abc) on x86_64-pc-linux-gnu")
+			Expect(dbVersion.AtLeast("7")).To(BeTrue())
+			Expect(dbVersion.Is("7.0.0")).To(BeTrue())
+			Expect(dbVersion.Before("7.0.1")).To(BeTrue())
+			Expect(dbVersion.AtLeastFeatureLevel(7)).To(BeTrue())
+		})
+	})
+	Describe("PGVersion", func() {
+		DescribeTable("parses the PostgreSQL version", func(versionStr string, expected string) {
+			dbVersion := dbconn.GPDBVersion{}
+			dbVersion.ParseVersionInfo(versionStr)
+			Expect(dbVersion.PGVersion().String()).To(Equal(expected))
+		},
+			Entry("a three-part version", "PostgreSQL 9.4.26 (Greenplum Database 6.25.3 build commit:abc) on x86_64-unknown-linux-gnu", "9.4.26"),
+			Entry("an older three-part version", "PostgreSQL 8.3.23 (Greenplum Database 5.29.1 build commit:abc) on x86_64-pc-linux-gnu", "8.3.23"),
+			Entry("a development version", "PostgreSQL 16devel (Apache Cloudberry 3.0.0 build dev) on x86_64-pc-linux-gnu", "16.0.0"),
+			Entry("a version string without PostgreSQL first", "Greenplum Database 7.0.0 (PostgreSQL 12.12)", "0.0.0"),
+		)
+		It("compares the PostgreSQL version independently of the database version", func() {
+			dbVersion := dbconn.GPDBVersion{}
+			dbVersion.ParseVersionInfo("PostgreSQL 12.12 (Greenplum Database 7.0.0 build commit:abc) on x86_64-pc-linux-gnu")
+			Expect(dbVersion.AtLeast("7")).To(BeTrue())
+			Expect(dbVersion.PGAtLeast("12")).To(BeTrue())
+			Expect(dbVersion.PGAtLeast("12.13")).To(BeFalse())
+			Expect(dbVersion.PGAtLeast("14")).To(BeFalse())
+			Expect(dbVersion.PGBefore("14")).To(BeTrue())
+			Expect(dbVersion.PGBefore("9.4")).To(BeFalse())
 		})
 	})
 	Describe("StringToSemVerRange", func() {
@@ -147,4 +230,114 @@ var _ = Describe("dbconn/version tests", func() {
 			Expect(fakeGPDB5.Equals(fakeGPDB43)).To(BeFalse())
 		})
 	})
+	Describe("EffectiveGPDBMajor", func() {
+		It("returns the major version for GPDB", func() {
+			Expect(fakeGPDB5.EffectiveGPDBMajor()).To(Equal(uint64(5)))
+		})
+		It("treats every CBDB version as GPDB 7", func() {
+			cbdb1 := dbconn.GPDBVersion{SemVer: semver.MustParse("1.6.0"), Type: dbconn.CBDB}
+			Expect(cbdb1.EffectiveGPDBMajor()).To(Equal(uint64(7)))
+			Expect(fakeCBDB2.EffectiveGPDBMajor()).To(Equal(uint64(7)))
+		})
+		It("returns 0 for an unknown database type", func() {
+			unknown := dbconn.GPDBVersion{SemVer: semver.MustParse("9.0.0"), Type: dbconn.Unknown}
+			Expect(unknown.EffectiveGPDBMajor()).To(Equal(uint64(0)))
+		})
+	})
+	Describe("AtLeastFeatureLevel", func() {
+		It("compares the GPDB major version", func() {
+			Expect(fakeGPDB5.AtLeastFeatureLevel(5)).To(BeTrue())
+			Expect(fakeGPDB5.AtLeastFeatureLevel(6)).To(BeFalse())
+		})
+		It("compares CBDB as GPDB 7", func() {
+			Expect(fakeCBDB2.AtLeastFeatureLevel(7)).To(BeTrue())
+			Expect(fakeCBDB2.AtLeastFeatureLevel(8)).To(BeFalse())
+		})
+		It("returns false for an unknown database type", func() {
+			unknown := dbconn.GPDBVersion{SemVer: semver.MustParse("9.0.0"), Type: dbconn.Unknown}
+			Expect(unknown.AtLeastFeatureLevel(1)).To(BeFalse())
+		})
+	})
+	Describe("EquivalentTo", func() {
+		It("returns true for CBDB and GPDB 7", func() {
+			gpdb7 := dbconn.GPDBVersion{SemVer: semver.MustParse("7.1.0"), Type: dbconn.GPDB}
+			Expect(fakeCBDB2.EquivalentTo(gpdb7)).To(BeTrue())
+			Expect(gpdb7.EquivalentTo(fakeCBDB2)).To(BeTrue())
+		})
+		It("returns false for CBDB and earlier GPDB versions", func() {
+			Expect(fakeCBDB2.EquivalentTo(fakeGPDB5)).To(BeFalse())
+		})
+		It("returns true for GPDB versions with the same major version", func() {
+			Expect(fakeGPDB5.EquivalentTo(fakeGPDB51)).To(BeTrue())
+		})
+		It("returns false for unknown database types", func() {
+			unknown := dbconn.GPDBVersion{SemVer: semver.MustParse("9.0.0"), Type: dbconn.Unknown}
+			Expect(unknown.EquivalentTo(unknown)).To(BeFalse())
+		})
+	})
+	Describe("ReloadVersion", func() {
+		It("returns false if the version has not changed", func() {
+			testhelper.ExpectVersionQuery(mock, "5.1.0")
+			changed, err := connection.ReloadVersion()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changed).To(BeFalse())
+			Expect(connection.Version.SemVer).To(Equal(semver.MustParse("5.1.0")))
+		})
+		It("updates the version and returns true if the version has changed", func() {
+			stdout, _, _ := testhelper.SetupTestLogger()
+			testhelper.ExpectVersionQuery(mock, "5.2.0")
+			changed, err := connection.ReloadVersion()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changed).To(BeTrue())
+			Expect(connection.Version.SemVer).To(Equal(semver.MustParse("5.2.0")))
+			Expect(connection.Version.VersionString).To(Equal("(Greenplum Database 5.2.0)"))
+			testhelper.ExpectRegexp(stdout, "Database version changed from Greenplum Database 5.1.0 to Greenplum Database 5.2.0")
+		})
+		It("leaves the version unchanged if the query fails", func() {
+			mock.ExpectQuery("SELECT pg_catalog.version()").WillReturnError(errors.New("connection reset"))
+			changed, err := connection.ReloadVersion()
+			Expect(err).To(MatchError("Failed to determine database version: connection reset"))
+			Expect(changed).To(BeFalse())
+			Expect(connection.Version.SemVer).To(Equal(semver.MustParse("5.1.0")))
+		})
+		It("queries a connection with no transaction in progress", func() {
+			multiConn, multiMock := testhelper.CreateAndConnectMockDB(2)
+			defer multiConn.Close()
+			// The mock connections share one database, so begin the transaction on another
+			txDB, txMock := testhelper.CreateMockDB()
+			txMock.ExpectBegin()
+			multiConn.Tx[0] = txDB.MustBegin()
+			var connNums []int
+			multiConn.AddQueryHook(dbconn.QueryHookFuncs{After: func(ctx context.Context, event *dbconn.QueryEvent) {
+				connNums = append(connNums, event.ConnNum)
+			}})
+			testhelper.ExpectVersionQuery(multiMock, "5.1.0")
+			_, err := multiConn.ReloadVersion()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(connNums).To(Equal([]int{1}))
+		})
+	})
+	Describe("ValidateSupported", func() {
+		constraints := map[dbconn.DBType]string{dbconn.GPDB: "6", dbconn.CBDB: "1.5"}
+		It("accepts a supported version", func() {
+			Expect(dbconn.NewVersion("6.25.3").ValidateSupported(constraints)).To(Succeed())
+		})
+		It("rejects a version older than the minimum", func() {
+			err := dbconn.NewVersion("5.29.1").ValidateSupported(constraints)
+			Expect(err).To(MatchError("Greenplum Database 5.29.1 is not supported; version 6 or later is required"))
+		})
+		It("accepts a pre-release of the minimum version", func() {
+			Expect(dbconn.NewVersion("6.0.0-beta.1").ValidateSupported(constraints)).To(Succeed())
+		})
+		It("checks a range", func() {
+			rangeConstraints := map[dbconn.DBType]string{dbconn.GPDB: ">=6.2.0 <8.0.0"}
+			Expect(dbconn.NewVersion("7.1.0").ValidateSupported(rangeConstraints)).To(Succeed())
+			err := dbconn.NewVersion("8.0.0").ValidateSupported(rangeConstraints)
+			Expect(err).To(MatchError(`Greenplum Database 8.0.0 is not supported; version ">=6.2.0 <8.0.0" is required`))
+		})
+		It("rejects an unsupported database type", func() {
+			err := dbconn.GPDBVersion{Type: dbconn.Unknown}.ValidateSupported(constraints)
+			Expect(err).To(MatchError("Unknown Database is not supported; supported databases are: Greenplum Database 6 or later, Apache Cloudberry 1.5 or later"))
+		})
+	})
 })
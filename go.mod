@@ -5,13 +5,17 @@ go 1.21
 require (
 	github.com/DATA-DOG/go-sqlmock v1.5.0
 	github.com/blang/semver v3.5.1+incompatible
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/jackc/pgx/v4 v4.18.2
 	github.com/jmoiron/sqlx v1.3.5
 	github.com/onsi/gomega v1.27.10
 	github.com/pkg/errors v0.9.1
 )
 
-require github.com/onsi/ginkgo/v2 v2.13.0
+require (
+	github.com/jackc/pgconn v1.14.3
+	github.com/onsi/ginkgo/v2 v2.13.0
+)
 
 require (
 	github.com/go-logr/logr v1.2.4 // indirect
@@ -20,7 +24,6 @@ require (
 	github.com/google/go-cmp v0.5.9 // indirect
 	github.com/google/pprof v0.0.0-20210407192527-94a9f03dee38 // indirect
 	github.com/jackc/chunkreader/v2 v2.0.1 // indirect
-	github.com/jackc/pgconn v1.14.3 // indirect
 	github.com/jackc/pgio v1.0.0 // indirect
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgproto3/v2 v2.3.3 // indirect
@@ -0,0 +1,141 @@
+package gplog
+
+/*
+ * This file contains structs and functions for setting the verbosity of
+ * individual components, such as cluster or dbconn, so that debugging one
+ * subsystem does not fill the logs with messages from the others.
+ *
+ * Messages logged through a ComponentLogger are written to the shell and the
+ * log file if the level set for the component with SetLevelFor is at least
+ * that of the message, in place of the shell and log file verbosities, which
+ * still apply to components without a level of their own.  Each message has
+ * the component's name as a "component" field in JSON entries, hooks, and
+ * slog, so that it can be told apart; text messages are left as they are.
+ */
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var levelNames = map[string]int{
+	"error":   LOGERROR,
+	"info":    LOGINFO,
+	"verbose": LOGVERBOSE,
+	"debug":   LOGDEBUG,
+}
+
+// ParseLevel returns the verbosity with the given name: error, info, verbose, or debug, in any case.
+func ParseLevel(name string) (int, error) {
+	level, ok := levelNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return 0, errors.Errorf("Unknown log level %s", name)
+	}
+	return level, nil
+}
+
+// SetLevelFor sets the verbosity of messages from the given component.
+func SetLevelFor(component string, level int) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	if logger.componentLevels == nil {
+		logger.componentLevels = make(map[string]int)
+	}
+	logger.componentLevels[component] = level
+}
+
+// GetLevelFor returns the verbosity set for the given component, if any.
+func GetLevelFor(component string) (int, bool) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	level, ok := logger.componentLevels[component]
+	return level, ok
+}
+
+// ClearLevelFor removes the verbosity set for the given component, so that its messages follow the shell and log file verbosities.
+func ClearLevelFor(component string) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	delete(logger.componentLevels, component)
+}
+
+/*
+ * SetLevels sets the verbosity of each component in a comma-separated list of
+ * component=level pairs, such as "cluster=debug,dbconn=info", as might be
+ * passed in a flag or environment variable.  Nothing is set if any pair is
+ * invalid.
+ */
+func SetLevels(spec string) error {
+	levels := make(map[string]int)
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		component, name, found := strings.Cut(pair, "=")
+		component = strings.TrimSpace(component)
+		if !found || component == "" {
+			return errors.Errorf("Invalid component log level %s; expected component=level", strings.TrimSpace(pair))
+		}
+		level, err := ParseLevel(name)
+		if err != nil {
+			return err
+		}
+		levels[component] = level
+	}
+	for component, level := range levels {
+		SetLevelFor(component, level)
+	}
+	return nil
+}
+
+// A ComponentLogger logs messages from one component, as the package's functions of the same names do.
+type ComponentLogger struct {
+	name string
+}
+
+func Component(name string) ComponentLogger {
+	return ComponentLogger{name: name}
+}
+
+func (component ComponentLogger) Name() string {
+	return component.name
+}
+
+func (component ComponentLogger) Info(s string, v ...interface{}) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	writeLeveled(component.name, LOGINFO, logger.logStdout, NONE, "INFO", fmt.Sprintf(s, v...), nil)
+}
+
+func (component ComponentLogger) Warn(s string, v ...interface{}) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	writeLeveled(component.name, LOGERROR, logger.logStdout, YELLOW, "WARNING", fmt.Sprintf(s, v...), nil)
+}
+
+func (component ComponentLogger) Verbose(s string, v ...interface{}) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	writeLeveled(component.name, LOGVERBOSE, logger.logStdout, NONE, "DEBUG", fmt.Sprintf(s, v...), nil)
+}
+
+func (component ComponentLogger) Debug(s string, v ...interface{}) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	writeLeveled(component.name, LOGDEBUG, logger.logStdout, NONE, "DEBUG", fmt.Sprintf(s, v...), nil)
+}
+
+func (component ComponentLogger) Error(s string, v ...interface{}) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	errorCode = 1
+	writeLeveled(component.name, LOGERROR, logger.logStderr, RED, "ERROR", fmt.Sprintf(s, v...), nil)
+}
+
+func (component ComponentLogger) Custom(customFileVerbosity int, customShellVerbosity int, s string, v ...interface{}) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	writeCustom(component.name, customFileVerbosity, customShellVerbosity, fmt.Sprintf(s, v...))
+}
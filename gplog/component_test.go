@@ -0,0 +1,126 @@
+package gplog_test
+
+import (
+	"os/user"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("gplog/component tests", func() {
+	var (
+		stdout  *gbytes.Buffer
+		stderr  *gbytes.Buffer
+		logfile *gbytes.Buffer
+		cluster gplog.ComponentLogger
+		dbconn  gplog.ComponentLogger
+	)
+
+	BeforeEach(func() {
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "testUser", HomeDir: "testDir"}, nil }
+		operating.System.Getpid = func() int { return 0 }
+		operating.System.Hostname = func() (string, error) { return "testHost", nil }
+		stdout, stderr, logfile = gbytes.NewBuffer(), gbytes.NewBuffer(), gbytes.NewBuffer()
+		gplog.SetLogger(gplog.NewLogger(stdout, stderr, logfile, "gbytes.Buffer", gplog.LOGINFO, "testProgram"))
+		gplog.SetErrorCode(0)
+		cluster = gplog.Component("cluster")
+		dbconn = gplog.Component("dbconn")
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		gplog.SetErrorCode(0)
+	})
+
+	Describe("ComponentLogger", func() {
+		It("follows the shell and log file verbosities if no level is set for it", func() {
+			cluster.Info("starting")
+			cluster.Debug("details")
+
+			Expect(stdout).To(gbytes.Say(`\[INFO\]:-starting\n`))
+			Expect(stdout).ToNot(gbytes.Say("details"))
+			Expect(logfile).To(gbytes.Say(`\[INFO\]:-starting\n`))
+			Expect(logfile).To(gbytes.Say(`\[DEBUG\]:-details\n`))
+		})
+		It("follows the level set for it in place of the shell and log file verbosities", func() {
+			gplog.SetLevelFor("cluster", gplog.LOGDEBUG)
+			gplog.SetLevelFor("dbconn", gplog.LOGINFO)
+			cluster.Debug("cluster details")
+			dbconn.Debug("dbconn details")
+			dbconn.Info("dbconn summary")
+
+			Expect(stdout).To(gbytes.Say(`cluster details\n`))
+			Expect(logfile).To(gbytes.Say(`cluster details\n`))
+			Expect(logfile).ToNot(gbytes.Say("dbconn details"))
+			Expect(stdout).To(gbytes.Say(`dbconn summary\n`))
+		})
+		It("always writes warnings and errors", func() {
+			gplog.SetLevelFor("cluster", gplog.LOGERROR)
+			cluster.Warn("segment slow")
+			cluster.Error("segment down")
+
+			Expect(stdout).To(gbytes.Say(`\[WARNING\]:-segment slow\n`))
+			Expect(stderr).To(gbytes.Say(`\[ERROR\]:-segment down\n`))
+			Expect(gplog.GetErrorCode()).To(Equal(1))
+		})
+		It("adds the component as a field to JSON entries only", func() {
+			gplog.SetShellFormat(gplog.FORMAT_JSON)
+			defer gplog.SetShellFormat(gplog.FORMAT_TEXT)
+			cluster.Info("starting")
+
+			Expect(stdout).To(gbytes.Say(`"component":"cluster"`))
+			Expect(logfile).ToNot(gbytes.Say("component"))
+		})
+		It("applies the level set for it to Custom messages", func() {
+			cluster.Custom(gplog.LOGVERBOSE, gplog.LOGVERBOSE, "hidden")
+			gplog.SetLevelFor("cluster", gplog.LOGVERBOSE)
+			cluster.Custom(gplog.LOGVERBOSE, gplog.LOGVERBOSE, "shown")
+			cluster.Custom(gplog.LOGVERBOSE, gplog.LOGERROR, "failed")
+
+			Expect(stdout).ToNot(gbytes.Say("hidden"))
+			Expect(stdout).To(gbytes.Say(`\[DEBUG\]:-shown\n`))
+			Expect(logfile).To(gbytes.Say(`\[DEBUG\]:-shown\n`))
+			Expect(stderr).To(gbytes.Say(`\[ERROR\]:-failed\n`))
+		})
+		It("does not affect messages logged without a component", func() {
+			gplog.SetLevelFor("cluster", gplog.LOGERROR)
+			gplog.Info("starting")
+
+			Expect(stdout).To(gbytes.Say(`\[INFO\]:-starting\n`))
+		})
+	})
+	Describe("GetLevelFor and ClearLevelFor", func() {
+		It("reports and removes the level set for a component", func() {
+			_, ok := gplog.GetLevelFor("cluster")
+			Expect(ok).To(BeFalse())
+
+			gplog.SetLevelFor("cluster", gplog.LOGVERBOSE)
+			level, ok := gplog.GetLevelFor("cluster")
+			Expect(ok).To(BeTrue())
+			Expect(level).To(Equal(gplog.LOGVERBOSE))
+
+			gplog.ClearLevelFor("cluster")
+			cluster.Verbose("progress")
+			Expect(stdout).ToNot(gbytes.Say("progress"))
+		})
+	})
+	Describe("SetLevels", func() {
+		It("sets the level of each component in the list", func() {
+			Expect(gplog.SetLevels("cluster=debug, dbconn=INFO,")).To(Succeed())
+
+			clusterLevel, _ := gplog.GetLevelFor("cluster")
+			dbconnLevel, _ := gplog.GetLevelFor("dbconn")
+			Expect(clusterLevel).To(Equal(gplog.LOGDEBUG))
+			Expect(dbconnLevel).To(Equal(gplog.LOGINFO))
+		})
+		It("sets nothing if any pair is invalid", func() {
+			Expect(gplog.SetLevels("cluster=debug,dbconn=loud")).To(MatchError("Unknown log level loud"))
+			Expect(gplog.SetLevels("cluster")).To(MatchError("Invalid component log level cluster; expected component=level"))
+
+			_, ok := gplog.GetLevelFor("cluster")
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
@@ -8,8 +8,10 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -111,6 +113,19 @@ type GpLogger struct {
 	logPrefixFunc      LogPrefixFunc
 	shellLogPrefixFunc LogPrefixFunc
 	colorize           bool
+	program            string
+	user               string
+	host               string
+	pid                int
+	shellFormat        LogFormat
+	fileFormat         LogFormat
+	fields             map[string]interface{}
+	slogLogger         *slog.Logger
+	syslog             syslogWriter
+	syslogVerbosity    int
+	syslogFormat       LogFormat
+	componentLevels    map[string]int
+	hooks              []registeredHook
 }
 
 /*
@@ -167,6 +182,7 @@ func NewLogger(stdout io.Writer, stderr io.Writer, logFile io.Writer, logFileNam
 	if len(logFileVerbosity) == 1 && logFileVerbosity[0] >= LOGERROR && logFileVerbosity[0] <= LOGDEBUG {
 		fileVerbosity = logFileVerbosity[0]
 	}
+	user, host, pid := processInfo()
 	return &GpLogger{
 		logStdout:          log.New(stdout, "", 0),
 		logStderr:          log.New(stderr, "", 0),
@@ -178,15 +194,25 @@ func NewLogger(stdout io.Writer, stderr io.Writer, logFile io.Writer, logFileNam
 		logPrefixFunc:      nil,
 		shellLogPrefixFunc: nil,
 		colorize:           false,
+		program:            program,
+		user:               user,
+		host:               host,
+		pid:                pid,
+		syslogVerbosity:    LOGINFO,
+		shellFormat:        FORMAT_TEXT,
+		fileFormat:         FORMAT_TEXT,
 	}
 }
 
-func GetHeader(program string) string {
-	headerFormatStr := "%s:%s:%s:%06d-[%s]:-" // PROGRAMNAME:USERNAME:HOSTNAME:PID-[LOGLEVEL]:-
+func processInfo() (string, string, int) {
 	currentUser, _ := operating.System.CurrentUser()
-	user := currentUser.Username
 	host, _ := operating.System.Hostname()
-	pid := operating.System.Getpid()
+	return currentUser.Username, host, operating.System.Getpid()
+}
+
+func GetHeader(program string) string {
+	headerFormatStr := "%s:%s:%s:%06d-[%s]:-" // PROGRAMNAME:USERNAME:HOSTNAME:PID-[LOGLEVEL]:-
+	user, host, pid := processInfo()
 	header := fmt.Sprintf(headerFormatStr, program, user, host, pid, "%s")
 	return header
 }
@@ -312,77 +338,130 @@ func getVerbosityString(verbosity int) string {
  * Log output functions, as described above
  */
 
+/*
+ * A field is a key and value added to a log entry, such as an slog attribute.
+ * A structuredOnly field is left out of text messages, and only appears where
+ * entries have keys of their own: in JSON entries, hooks, and slog.
+ */
+type field struct {
+	key            string
+	value          interface{}
+	structuredOnly bool
+}
+
+// formatFields returns the fields as they are appended to a text message, like those of an slog.TextHandler.
+func formatFields(fields []field) string {
+	text := ""
+	for _, f := range fields {
+		if f.structuredOnly {
+			continue
+		}
+		value := fmt.Sprintf("%v", f.value)
+		if value == "" || strings.ContainsAny(value, " \"=\n") {
+			value = strconv.Quote(value)
+		}
+		text += fmt.Sprintf(" %s=%s", f.key, value)
+	}
+	return text
+}
+
+// writeToFile writes a message to the log file in the log file's format.
+func writeToFile(level string, message string, fields []field) {
+	if logger.fileFormat == FORMAT_JSON {
+		_ = logger.logFile.Output(1, jsonEntry(level, message, fields))
+		return
+	}
+	_ = logger.logFile.Output(1, GetLogPrefix(level)+message+formatFields(fields))
+}
+
+// writeToShell writes a message to stdout or stderr in the shell's format, in the given color if it is text.
+func writeToShell(shell *log.Logger, c Color, level string, message string, fields []field) {
+	if logger.shellFormat == FORMAT_JSON {
+		_ = shell.Output(1, jsonEntry(level, message, fields))
+		return
+	}
+	message = GetShellLogPrefix(level) + message + formatFields(fields)
+	if c != NONE {
+		message = Colorize(c, message)
+	}
+	_ = shell.Output(1, message)
+}
+
+/*
+ * dispatch sends a message at the given verbosity to the destinations other
+ * than the log file and the shell, each of which decides for itself whether
+ * to write it.
+ */
+func dispatch(verbosity int, level string, message string, fields []field) {
+	runHooks(verbosity, level, message, fields)
+	forwardToSlog(level, message, fields)
+	writeToSyslog(verbosity, level, message, fields)
+}
+
+/*
+ * writeLeveled writes a message to the log file and the given shell stream
+ * if their verbosities, or the level set for the component, if any, are at
+ * least the given one, and dispatches it to the other destinations.  A
+ * message from a component has the component's name as a field.
+ */
+func writeLeveled(component string, verbosity int, shell *log.Logger, c Color, level string, message string, fields []field) {
+	fileVerbosity, shellVerbosity := logger.fileVerbosity, logger.shellVerbosity
+	if component != "" {
+		if componentLevel, ok := logger.componentLevels[component]; ok {
+			fileVerbosity, shellVerbosity = componentLevel, componentLevel
+		}
+		fields = append([]field{{key: "component", value: component, structuredOnly: true}}, fields...)
+	}
+	dispatch(verbosity, level, message, fields)
+	if fileVerbosity >= verbosity {
+		writeToFile(level, message, fields)
+	}
+	if shellVerbosity >= verbosity {
+		writeToShell(shell, c, level, message, fields)
+	}
+}
+
 func Info(s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
-	if logger.fileVerbosity >= LOGINFO {
-		message := GetLogPrefix("INFO") + fmt.Sprintf(s, v...)
-		_ = logger.logFile.Output(1, message)
-	}
-	if logger.shellVerbosity >= LOGINFO {
-		message := GetShellLogPrefix("INFO") + fmt.Sprintf(s, v...)
-		_ = logger.logStdout.Output(1, message)
-	}
+	writeLeveled("", LOGINFO, logger.logStdout, NONE, "INFO", fmt.Sprintf(s, v...), nil)
 }
 
 func Success(s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
-	if logger.fileVerbosity >= LOGINFO {
-		message := GetLogPrefix("INFO") + fmt.Sprintf(s, v...)
-		_ = logger.logFile.Output(1, message)
-	}
-	if logger.shellVerbosity >= LOGINFO {
-		message := GetShellLogPrefix("INFO") + fmt.Sprintf(s, v...)
-		_ = logger.logStdout.Output(1, Colorize(GREEN, message))
-	}
+	writeLeveled("", LOGINFO, logger.logStdout, GREEN, "INFO", fmt.Sprintf(s, v...), nil)
 }
 
 func Warn(s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
-	message := GetLogPrefix("WARNING") + fmt.Sprintf(s, v...)
-	_ = logger.logFile.Output(1, message)
-	message = GetShellLogPrefix("WARNING") + fmt.Sprintf(s, v...)
-	_ = logger.logStdout.Output(1, Colorize(YELLOW, message))
+	writeLeveled("", LOGERROR, logger.logStdout, YELLOW, "WARNING", fmt.Sprintf(s, v...), nil)
 }
 
 func Verbose(s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
-	if logger.fileVerbosity >= LOGVERBOSE {
-		message := GetLogPrefix("DEBUG") + fmt.Sprintf(s, v...)
-		_ = logger.logFile.Output(1, message)
-	}
-	if logger.shellVerbosity >= LOGVERBOSE {
-		message := GetShellLogPrefix("DEBUG") + fmt.Sprintf(s, v...)
-		_ = logger.logStdout.Output(1, message)
-	}
+	writeLeveled("", LOGVERBOSE, logger.logStdout, NONE, "DEBUG", fmt.Sprintf(s, v...), nil)
 }
 
 func Debug(s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
-	if logger.fileVerbosity >= LOGDEBUG {
-		message := GetLogPrefix("DEBUG") + fmt.Sprintf(s, v...)
-		_ = logger.logFile.Output(1, message)
-	}
-	if logger.shellVerbosity >= LOGDEBUG {
-		message := GetShellLogPrefix("DEBUG") + fmt.Sprintf(s, v...)
-		_ = logger.logStdout.Output(1, message)
-	}
+	writeLeveled("", LOGDEBUG, logger.logStdout, NONE, "DEBUG", fmt.Sprintf(s, v...), nil)
 }
 
 func Error(s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
 	errorCode = 1
-	message := GetLogPrefix("ERROR") + fmt.Sprintf(s, v...)
-	_ = logger.logFile.Output(1, message)
-	message = GetShellLogPrefix("ERROR") + fmt.Sprintf(s, v...)
-	_ = logger.logStderr.Output(1, Colorize(RED, message))
+	writeLeveled("", LOGERROR, logger.logStderr, RED, "ERROR", fmt.Sprintf(s, v...), nil)
 }
 
+/*
+ * Fatal panics with the message in text form, whatever the shell format, so
+ * that the recover() in the main utility can inspect and print it.
+ */
 func Fatal(err error, s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
@@ -397,9 +476,17 @@ func Fatal(err error, s string, v ...interface{}) {
 		}
 	}
 	message += strings.TrimSpace(fmt.Sprintf(s, v...))
-	fullMessage := GetLogPrefix("CRITICAL") + message
-	_ = logger.logFile.Output(1, fullMessage+stackTraceStr)
-	fullMessage = GetShellLogPrefix("CRITICAL") + message
+	var stackFields []field
+	if stackTraceStr != "" {
+		stackFields = []field{{key: "stack", value: strings.TrimSpace(stackTraceStr)}}
+	}
+	dispatch(LOGERROR, "CRITICAL", message, stackFields)
+	if logger.fileFormat == FORMAT_JSON {
+		_ = logger.logFile.Output(1, jsonEntry("CRITICAL", message, stackFields))
+	} else {
+		_ = logger.logFile.Output(1, GetLogPrefix("CRITICAL")+message+stackTraceStr)
+	}
+	fullMessage := GetShellLogPrefix("CRITICAL") + message
 	// messages for panic are not colorized to allow any recover logic to inspect the actual fullMessage
 	// if the fullMessage needs to be output to the shell console, the caller should colorize it explicitly, if desired
 	if logger.shellVerbosity >= LOGVERBOSE {
@@ -416,17 +503,27 @@ func Fatal(err error, s string, v ...interface{}) {
 func Custom(customFileVerbosity int, customShellVerbosity int, s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
-	var message string
-	if logger.fileVerbosity >= customFileVerbosity {
-		message = GetLogPrefix(getVerbosityString(customFileVerbosity)) + fmt.Sprintf(s, v...)
-		_ = logger.logFile.Output(1, message)
+	writeCustom("", customFileVerbosity, customShellVerbosity, fmt.Sprintf(s, v...))
+}
+
+// writeCustom writes a message for Custom, using the level set for the component, if any, as writeLeveled does.
+func writeCustom(component string, customFileVerbosity int, customShellVerbosity int, message string) {
+	fileVerbosity, shellVerbosity := logger.fileVerbosity, logger.shellVerbosity
+	var fields []field
+	if component != "" {
+		if componentLevel, ok := logger.componentLevels[component]; ok {
+			fileVerbosity, shellVerbosity = componentLevel, componentLevel
+		}
+		fields = []field{{key: "component", value: component, structuredOnly: true}}
+	}
+	dispatch(customFileVerbosity, getVerbosityString(customFileVerbosity), message, fields)
+	if fileVerbosity >= customFileVerbosity {
+		writeToFile(getVerbosityString(customFileVerbosity), message, fields)
 	}
 	if customShellVerbosity == LOGERROR {
-		message = GetShellLogPrefix("ERROR") + fmt.Sprintf(s, v...)
-		_ = logger.logStderr.Output(1, Colorize(RED, message))
-	} else if logger.shellVerbosity >= customShellVerbosity {
-		message = GetShellLogPrefix(getVerbosityString(customShellVerbosity)) + fmt.Sprintf(s, v...)
-		_ = logger.logStdout.Output(1, message)
+		writeToShell(logger.logStderr, RED, "ERROR", message, fields)
+	} else if shellVerbosity >= customShellVerbosity {
+		writeToShell(logger.logStdout, NONE, getVerbosityString(customShellVerbosity), message, fields)
 	}
 }
 
@@ -444,10 +541,9 @@ func FatalWithoutPanic(s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
 	errorCode = 2
-	message := GetLogPrefix("CRITICAL") + fmt.Sprintf(s, v...)
-	_ = logger.logFile.Output(1, message)
-	message = GetShellLogPrefix("CRITICAL") + fmt.Sprintf(s, v...)
-	_ = logger.logStderr.Output(1, Colorize(RED, message))
+	dispatch(LOGERROR, "CRITICAL", fmt.Sprintf(s, v...), nil)
+	writeToFile("CRITICAL", fmt.Sprintf(s, v...), nil)
+	writeToShell(logger.logStderr, RED, "CRITICAL", fmt.Sprintf(s, v...), nil)
 	exitFunc()
 }
 
@@ -480,6 +576,13 @@ func abort(output ...interface{}) {
 	panic(errStr)
 }
 func openLogFile(filename string) io.WriteCloser {
+	if logFileRotation != nil {
+		rotating, err := NewRotatingFile(filename, *logFileRotation)
+		if err != nil {
+			abort(err)
+		}
+		return rotating
+	}
 	flags := os.O_APPEND | os.O_CREATE | os.O_WRONLY
 	fileHandle, err := operating.System.OpenFileWrite(filename, flags, 0644)
 	if err != nil {
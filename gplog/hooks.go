@@ -0,0 +1,143 @@
+package gplog
+
+/*
+ * This file contains structs and functions for hooks, which are called with
+ * each log message within their verbosity, so that callers can send errors
+ * to other systems, such as an HTTP endpoint or a chat channel, or keep the
+ * latest messages in memory for crash reports, without replacing the logger.
+ *
+ * Hooks are called in the order they were added, whatever the verbosities of
+ * the shell and the log file, before the message is written to them, and
+ * while other goroutines are kept from logging, so a hook must not log
+ * through gplog itself, and a hook that may be slow, as one making network
+ * requests, should hand entries off to a goroutine of its own.  Fatal calls
+ * hooks before it panics.
+ */
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+)
+
+// An Entry is a log message as passed to hooks.
+type Entry struct {
+	Time time.Time
+	// DEBUG, INFO, WARNING, ERROR, or CRITICAL
+	Level string
+	// The verbosity at which the message is logged, such as LOGVERBOSE for Verbose, which logs DEBUG messages
+	Verbosity int
+	Program   string
+	Message   string
+	// The fields set with SetLogFields, along with those of the message, such as its component or slog attributes
+	Fields map[string]interface{}
+}
+
+type Hook interface {
+	Fire(entry Entry) error
+}
+
+// HookFunc adapts an ordinary function to a Hook.
+type HookFunc func(entry Entry) error
+
+func (f HookFunc) Fire(entry Entry) error {
+	return f(entry)
+}
+
+type registeredHook struct {
+	hook      Hook
+	verbosity int
+}
+
+/*
+ * AddHook calls the hook with each message whose verbosity is at most the
+ * given one; a hook added with LOGERROR, for instance, is called with
+ * warnings, errors, and fatal errors.  Errors returned by the hook are
+ * written to stderr.
+ */
+func AddHook(hook Hook, verbosity int) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	logger.hooks = append(logger.hooks, registeredHook{hook: hook, verbosity: verbosity})
+}
+
+// ClearHooks removes all hooks.
+func ClearHooks() {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	logger.hooks = nil
+}
+
+func runHooks(verbosity int, level string, message string, fields []field) {
+	if len(logger.hooks) == 0 {
+		return
+	}
+	var entry *Entry
+	for _, registered := range logger.hooks {
+		if registered.verbosity < verbosity {
+			continue
+		}
+		if entry == nil {
+			entry = newEntry(verbosity, level, message, fields)
+		}
+		if err := registered.hook.Fire(*entry); err != nil {
+			_ = logger.logStderr.Output(1, GetShellLogPrefix("ERROR")+"Log hook failed: "+err.Error())
+		}
+	}
+}
+
+func newEntry(verbosity int, level string, message string, fields []field) *Entry {
+	entry := &Entry{
+		Time:      operating.System.Now(),
+		Level:     level,
+		Verbosity: verbosity,
+		Program:   logger.program,
+		Message:   message,
+		Fields:    make(map[string]interface{}, len(logger.fields)+len(fields)),
+	}
+	for key, value := range logger.fields {
+		entry.Fields[key] = value
+	}
+	for _, f := range fields {
+		entry.Fields[f.key] = f.value
+	}
+	return entry
+}
+
+/*
+ * RingBufferHook keeps the latest entries passed to it, as for including the
+ * messages that led up to a crash in a report.
+ */
+type RingBufferHook struct {
+	entries []Entry
+	next    int
+	full    bool
+	mutex   sync.Mutex
+}
+
+// NewRingBufferHook returns a hook that keeps the given number of entries, which must be positive.
+func NewRingBufferHook(size int) *RingBufferHook {
+	return &RingBufferHook{entries: make([]Entry, size)}
+}
+
+func (ring *RingBufferHook) Fire(entry Entry) error {
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+	ring.entries[ring.next] = entry
+	ring.next = (ring.next + 1) % len(ring.entries)
+	if ring.next == 0 {
+		ring.full = true
+	}
+	return nil
+}
+
+// Entries returns the entries kept, oldest first.
+func (ring *RingBufferHook) Entries() []Entry {
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+	if !ring.full {
+		return append([]Entry(nil), ring.entries[:ring.next]...)
+	}
+	return append(append([]Entry(nil), ring.entries[ring.next:]...), ring.entries[:ring.next]...)
+}
@@ -0,0 +1,116 @@
+package gplog_test
+
+import (
+	"os/user"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pkg/errors"
+)
+
+var _ = Describe("gplog/hooks tests", func() {
+	var (
+		stdout  *gbytes.Buffer
+		stderr  *gbytes.Buffer
+		logfile *gbytes.Buffer
+		entries []gplog.Entry
+		record  gplog.HookFunc
+	)
+	now := time.Date(2017, time.January, 1, 1, 1, 1, 1, time.UTC)
+
+	BeforeEach(func() {
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "testUser", HomeDir: "testDir"}, nil }
+		operating.System.Getpid = func() int { return 0 }
+		operating.System.Hostname = func() (string, error) { return "testHost", nil }
+		operating.System.Now = func() time.Time { return now }
+		stdout, stderr, logfile = gbytes.NewBuffer(), gbytes.NewBuffer(), gbytes.NewBuffer()
+		gplog.SetLogger(gplog.NewLogger(stdout, stderr, logfile, "gbytes.Buffer", gplog.LOGINFO, "testProgram"))
+		entries = nil
+		record = func(entry gplog.Entry) error {
+			entries = append(entries, entry)
+			return nil
+		}
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		gplog.SetErrorCode(0)
+	})
+
+	Describe("AddHook", func() {
+		It("calls the hook with each message within its verbosity", func() {
+			gplog.AddHook(record, gplog.LOGERROR)
+			gplog.Info("starting")
+			gplog.Warn("segment slow")
+			gplog.Error("segment %d down", 3)
+
+			Expect(entries).To(Equal([]gplog.Entry{
+				{Time: now, Level: "WARNING", Verbosity: gplog.LOGERROR, Program: "testProgram", Message: "segment slow", Fields: map[string]interface{}{}},
+				{Time: now, Level: "ERROR", Verbosity: gplog.LOGERROR, Program: "testProgram", Message: "segment 3 down", Fields: map[string]interface{}{}},
+			}))
+		})
+		It("calls the hook whatever the shell and log file verbosities", func() {
+			gplog.SetLogFileVerbosity(gplog.LOGINFO)
+			gplog.AddHook(record, gplog.LOGDEBUG)
+			gplog.Verbose("progress")
+
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].Level).To(Equal("DEBUG"))
+			Expect(entries[0].Verbosity).To(Equal(gplog.LOGVERBOSE))
+			Expect(logfile).ToNot(gbytes.Say("progress"))
+		})
+		It("includes the log fields and the fields of the message", func() {
+			gplog.SetLogFields(map[string]interface{}{"cluster": "prod"})
+			gplog.AddHook(record, gplog.LOGINFO)
+			gplog.Component("dbconn").Info("connected")
+
+			Expect(entries[0].Fields).To(Equal(map[string]interface{}{"cluster": "prod", "component": "dbconn"}))
+		})
+		It("calls the hook with fatal errors before panicking", func() {
+			gplog.AddHook(record, gplog.LOGERROR)
+			Expect(func() { gplog.Fatal(errors.New("connection lost"), "") }).To(Panic())
+
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].Level).To(Equal("CRITICAL"))
+			Expect(entries[0].Message).To(Equal("connection lost"))
+			Expect(entries[0].Fields).To(HaveKey("stack"))
+		})
+		It("writes the hook's errors to stderr and still logs the message", func() {
+			gplog.AddHook(gplog.HookFunc(func(entry gplog.Entry) error {
+				return errors.New("endpoint unreachable")
+			}), gplog.LOGINFO)
+			gplog.Info("starting")
+
+			Expect(stderr).To(gbytes.Say(`\[ERROR\]:-Log hook failed: endpoint unreachable`))
+			Expect(stdout).To(gbytes.Say(`\[INFO\]:-starting`))
+		})
+	})
+	Describe("ClearHooks", func() {
+		It("removes all hooks", func() {
+			gplog.AddHook(record, gplog.LOGINFO)
+			gplog.ClearHooks()
+			gplog.Info("starting")
+
+			Expect(entries).To(BeEmpty())
+		})
+	})
+	Describe("RingBufferHook", func() {
+		It("keeps the latest entries, oldest first", func() {
+			ring := gplog.NewRingBufferHook(2)
+			gplog.AddHook(ring, gplog.LOGINFO)
+			gplog.Info("first")
+			Expect(ring.Entries()).To(HaveLen(1))
+
+			gplog.Info("second")
+			gplog.Info("third")
+			messages := []string{}
+			for _, entry := range ring.Entries() {
+				messages = append(messages, entry.Message)
+			}
+			Expect(messages).To(Equal([]string{"second", "third"}))
+		})
+	})
+})
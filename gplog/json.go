@@ -0,0 +1,120 @@
+package gplog
+
+/*
+ * This file contains structs and functions for writing log entries as JSON
+ * objects, one per line, so that log aggregators can ingest them without
+ * parsing the text format.  The format is chosen separately for the log file
+ * and the shell, so that, for instance, a daemon can keep human-readable
+ * output on the console while its log file is shipped elsewhere.
+ *
+ * Each entry has the fields timestamp, level, pid, program, user, host, and
+ * message, along with any set with SetLogFields and the attributes of records
+ * logged through NewSlogHandler.  The levels are the same as in the text
+ * format, and Fatal adds the stack trace, if any, as stack.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+)
+
+type LogFormat int
+
+const (
+	FORMAT_TEXT LogFormat = iota
+	FORMAT_JSON
+)
+
+func (format LogFormat) String() string {
+	switch format {
+	case FORMAT_TEXT:
+		return "text"
+	case FORMAT_JSON:
+		return "json"
+	}
+	return fmt.Sprintf("LogFormat(%d)", int(format))
+}
+
+const jsonTimestampFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// The fields of every JSON entry, which fields set with SetLogFields cannot replace.
+var standardJSONFields = map[string]bool{
+	"timestamp": true,
+	"level":     true,
+	"pid":       true,
+	"program":   true,
+	"user":      true,
+	"host":      true,
+	"message":   true,
+}
+
+func GetLogFileFormat() LogFormat {
+	return logger.fileFormat
+}
+
+func SetLogFileFormat(format LogFormat) {
+	logger.fileFormat = format
+}
+
+// GetShellFormat returns the format of output to the shell console.
+func GetShellFormat() LogFormat {
+	return logger.shellFormat
+}
+
+// SetShellFormat sets the format of output to the shell console.  JSON output is never colorized.
+func SetShellFormat(format LogFormat) {
+	logger.shellFormat = format
+}
+
+/*
+ * SetLogFields sets fields, such as an identifier for the cluster or the
+ * operation in progress, to add to every JSON entry, replacing any set
+ * before.  Fields with the same name as a standard field are ignored, and
+ * values that cannot be encoded as JSON are written as strings.  The fields
+ * do not appear in text output.
+ */
+func SetLogFields(fields map[string]interface{}) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	logger.fields = make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		logger.fields[key] = value
+	}
+}
+
+/*
+ * jsonEntry returns the JSON entry for a message, with the logger's fields
+ * and the given extra fields.  Its keys are sorted, so entries are stable.
+ */
+func jsonEntry(level string, message string, extra []field) string {
+	entry := make(map[string]interface{}, len(standardJSONFields)+len(logger.fields)+len(extra))
+	for key, value := range logger.fields {
+		if !standardJSONFields[key] {
+			entry[key] = value
+		}
+	}
+	for _, f := range extra {
+		if !standardJSONFields[f.key] {
+			entry[f.key] = f.value
+		}
+	}
+	entry["timestamp"] = operating.System.Now().Format(jsonTimestampFormat)
+	entry["level"] = level
+	entry["pid"] = logger.pid
+	entry["program"] = logger.program
+	entry["user"] = logger.user
+	entry["host"] = logger.host
+	entry["message"] = message
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		for key, value := range entry {
+			if _, err := json.Marshal(value); err != nil {
+				entry[key] = fmt.Sprintf("%v", value)
+			}
+		}
+		encoded, _ = json.Marshal(entry)
+	}
+	return string(encoded)
+}
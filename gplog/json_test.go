@@ -0,0 +1,98 @@
+package gplog_test
+
+import (
+	"encoding/json"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pkg/errors"
+)
+
+var _ = Describe("gplog/json tests", func() {
+	var (
+		stdout  *gbytes.Buffer
+		stderr  *gbytes.Buffer
+		logfile *gbytes.Buffer
+	)
+	decode := func(buffer *gbytes.Buffer) map[string]interface{} {
+		var entry map[string]interface{}
+		Expect(json.Unmarshal(buffer.Contents(), &entry)).To(Succeed())
+		return entry
+	}
+
+	BeforeEach(func() {
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "testUser", HomeDir: "testDir"}, nil }
+		operating.System.Getpid = func() int { return 42 }
+		operating.System.Hostname = func() (string, error) { return "testHost", nil }
+		operating.System.Now = func() time.Time { return time.Date(2017, time.January, 1, 1, 1, 1, 1000, time.UTC) }
+		stdout, stderr, logfile = gbytes.NewBuffer(), gbytes.NewBuffer(), gbytes.NewBuffer()
+		gplog.SetLogger(gplog.NewLogger(stdout, stderr, logfile, "gbytes.Buffer", gplog.LOGINFO, "testProgram"))
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("SetLogFileFormat", func() {
+		It("writes each log file entry as a JSON object on one line", func() {
+			gplog.SetLogFileFormat(gplog.FORMAT_JSON)
+			gplog.Info("backup of %s started", "testdb")
+
+			Expect(string(logfile.Contents())).To(Equal(`{"host":"testHost","level":"INFO","message":"backup of testdb started","pid":42,"program":"testProgram","timestamp":"2017-01-01T01:01:01.000001Z","user":"testUser"}` + "\n"))
+			Expect(stdout).To(gbytes.Say(`testProgram:testUser:testHost:000042-\[INFO\]:-backup of testdb started`))
+		})
+		It("escapes messages that span lines", func() {
+			gplog.SetLogFileFormat(gplog.FORMAT_JSON)
+			gplog.Warn("line 1\nline \"2\"")
+
+			Expect(strings.Count(string(logfile.Contents()), "\n")).To(Equal(1))
+			Expect(string(logfile.Contents())).To(ContainSubstring(`"message":"line 1\nline \"2\""`))
+			Expect(decode(logfile)["level"]).To(Equal("WARNING"))
+		})
+		It("includes the stack trace of a fatal error as a separate field", func() {
+			gplog.SetLogFileFormat(gplog.FORMAT_JSON)
+			Expect(func() { gplog.Fatal(errors.New("connection lost"), "backup failed") }).To(Panic())
+
+			entry := decode(logfile)
+			Expect(entry["level"]).To(Equal("CRITICAL"))
+			Expect(entry["message"]).To(Equal("connection lost: backup failed"))
+			Expect(entry["stack"]).To(ContainSubstring("json_test.go"))
+		})
+	})
+	Describe("SetShellFormat", func() {
+		It("writes shell output as JSON without colorizing it", func() {
+			gplog.SetShellFormat(gplog.FORMAT_JSON)
+			gplog.SetColorize(true)
+			gplog.Error("segment %d is down", 3)
+
+			entry := decode(stderr)
+			Expect(entry["level"]).To(Equal("ERROR"))
+			Expect(entry["message"]).To(Equal("segment 3 is down"))
+			Expect(logfile).To(gbytes.Say(`\[ERROR\]:-segment 3 is down`))
+		})
+	})
+	Describe("SetLogFields", func() {
+		It("adds the fields to each JSON entry without replacing the standard fields", func() {
+			gplog.SetLogFileFormat(gplog.FORMAT_JSON)
+			gplog.SetLogFields(map[string]interface{}{"cluster": "prod", "attempt": 2, "level": "bogus", "callback": func() {}})
+			gplog.Info("starting")
+
+			entry := decode(logfile)
+			Expect(entry["cluster"]).To(Equal("prod"))
+			Expect(entry["attempt"]).To(BeNumerically("==", 2))
+			Expect(entry["level"]).To(Equal("INFO"))
+			Expect(entry["callback"]).To(HavePrefix("0x"))
+		})
+	})
+	Describe("LogFormat.String", func() {
+		It("names the format", func() {
+			Expect(gplog.FORMAT_TEXT.String()).To(Equal("text"))
+			Expect(gplog.FORMAT_JSON.String()).To(Equal("json"))
+		})
+	})
+})
@@ -0,0 +1,290 @@
+package gplog
+
+/*
+ * This file contains structs and functions for rotating log files, so that
+ * long-running daemons do not need external logrotate configuration to keep
+ * their logs from growing without bound.
+ *
+ * When a log file would grow past the maximum size, it is renamed with the
+ * time of rotation appended, as in program_20170101.log.20170102T150405.000,
+ * and a new file is started in its place.  Compressing the rotated file with
+ * gzip, if requested, and deleting rotated files past the maximum number or
+ * age then happen in the background, so that logging is not held up.
+ */
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+const rotationTimestampFormat = "20060102T150405.000"
+
+/*
+ * RotationOptions sets when log files are rotated and how many rotated files
+ * are kept.  A zero value for any option disables it, so the zero value of
+ * RotationOptions never rotates or deletes anything.
+ */
+type RotationOptions struct {
+	// The size in bytes past which the log file is rotated
+	MaxSize int64
+	// How long to keep rotated files, judged by the time of rotation
+	MaxAge time.Duration
+	// How many rotated files to keep
+	MaxBackups int
+	// Whether to compress rotated files with gzip, which happens in the background after they are rotated
+	Compress bool
+}
+
+var logFileRotation *RotationOptions
+
+/*
+ * SetLogFileRotation sets the rotation of log files opened by later calls to
+ * InitializeLogging, as with SetLogFileNameFunc; the zero value disables it.
+ * Loggers created with NewLogger can instead be given a RotatingFile.
+ */
+func SetLogFileRotation(options RotationOptions) {
+	if options == (RotationOptions{}) {
+		logFileRotation = nil
+		return
+	}
+	logFileRotation = &options
+}
+
+/*
+ * RotatingFile is a log file that rotates itself according to its
+ * RotationOptions.  It is safe for concurrent use.  As rotated files are
+ * compressed and deleted in the background, errors doing so are returned by
+ * Close, which waits for any still in progress.
+ */
+type RotatingFile struct {
+	filename string
+	options  RotationOptions
+	file     io.WriteCloser
+	size     int64
+	mutex    sync.Mutex
+
+	// Held while compressing and deleting rotated files, one rotation at a time.
+	cleanupMutex sync.Mutex
+	cleanupErr   error
+	cleanups     sync.WaitGroup
+}
+
+// NewRotatingFile opens the given log file for appending, creating it if necessary.
+func NewRotatingFile(filename string, options RotationOptions) (*RotatingFile, error) {
+	rotating := &RotatingFile{filename: filename, options: options}
+	file, size, err := rotating.open()
+	if err != nil {
+		return nil, err
+	}
+	rotating.file = file
+	rotating.size = size
+	return rotating, nil
+}
+
+func (rotating *RotatingFile) open() (io.WriteCloser, int64, error) {
+	var size int64
+	if info, err := operating.System.Stat(rotating.filename); err == nil {
+		size = info.Size()
+	}
+	flags := os.O_APPEND | os.O_CREATE | os.O_WRONLY
+	file, err := operating.System.OpenFileWrite(rotating.filename, flags, 0644)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "Cannot open log file %s", rotating.filename)
+	}
+	return file, size, nil
+}
+
+/*
+ * Write writes to the log file, rotating it first if the write would take it
+ * past the maximum size.  A single write larger than the maximum size is
+ * written to a file of its own.  If rotation fails, the data is still written
+ * to the current file and the rotation error is returned.
+ */
+func (rotating *RotatingFile) Write(p []byte) (int, error) {
+	rotating.mutex.Lock()
+	defer rotating.mutex.Unlock()
+	var rotateErr error
+	if rotating.options.MaxSize > 0 && rotating.size > 0 && rotating.size+int64(len(p)) > rotating.options.MaxSize {
+		rotateErr = rotating.rotate()
+	}
+	n, err := rotating.file.Write(p)
+	rotating.size += int64(n)
+	if err != nil {
+		return n, err
+	}
+	return n, rotateErr
+}
+
+// Rotate rotates the log file now, whatever its size.
+func (rotating *RotatingFile) Rotate() error {
+	rotating.mutex.Lock()
+	defer rotating.mutex.Unlock()
+	return rotating.rotate()
+}
+
+// Close closes the log file, after waiting for rotated files to be compressed and deleted, and returns the first error doing any of these.
+func (rotating *RotatingFile) Close() error {
+	rotating.mutex.Lock()
+	defer rotating.mutex.Unlock()
+	rotating.cleanups.Wait()
+	err := rotating.file.Close()
+	if rotating.cleanupErr != nil {
+		err = rotating.cleanupErr
+	}
+	return err
+}
+
+func (rotating *RotatingFile) rotate() error {
+	now := operating.System.Now()
+	backupName := rotating.backupName(now)
+	if err := operating.System.Rename(rotating.filename, backupName); err != nil {
+		return errors.Wrapf(err, "Cannot rotate log file %s", rotating.filename)
+	}
+	file, size, err := rotating.open()
+	if err != nil {
+		// Keep writing to the renamed file rather than losing output.
+		return err
+	}
+	_ = rotating.file.Close()
+	rotating.file = file
+	rotating.size = size
+
+	if rotating.options.Compress || rotating.options.MaxBackups > 0 || rotating.options.MaxAge > 0 {
+		rotating.cleanups.Add(1)
+		go rotating.cleanUp(backupName, now)
+	}
+	return nil
+}
+
+/*
+ * backupName returns the name to rotate the log file to at the given time.
+ * If the file was already rotated within the same millisecond, as when Rotate
+ * is called just before the file reaches the maximum size, a sequence number
+ * is appended, as in program_20170101.log.20170102T150405.000-1, so that the
+ * earlier backup is not overwritten.
+ */
+func (rotating *RotatingFile) backupName(now time.Time) string {
+	name := rotating.filename + "." + now.Format(rotationTimestampFormat)
+	for sequence := 1; backupExists(name); sequence++ {
+		name = fmt.Sprintf("%s.%s-%d", rotating.filename, now.Format(rotationTimestampFormat), sequence)
+	}
+	return name
+}
+
+// backupExists reports whether a rotated file with the given name exists, compressed or not.
+func backupExists(name string) bool {
+	for _, candidate := range []string{name, name + ".gz"} {
+		if _, err := operating.System.Stat(candidate); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanUp compresses the file rotated at the given time, if requested, and deletes old rotated files.
+func (rotating *RotatingFile) cleanUp(backupName string, rotatedAt time.Time) {
+	defer rotating.cleanups.Done()
+	rotating.cleanupMutex.Lock()
+	defer rotating.cleanupMutex.Unlock()
+	var err error
+	if rotating.options.Compress {
+		err = compressLogFile(backupName)
+	}
+	if err == nil {
+		err = rotating.removeOldBackups(rotatedAt)
+	}
+	if err != nil && rotating.cleanupErr == nil {
+		rotating.cleanupErr = err
+	}
+}
+
+func compressLogFile(filename string) error {
+	input, err := operating.System.OpenFileRead(filename, os.O_RDONLY, 0)
+	if err != nil {
+		return errors.Wrapf(err, "Cannot compress rotated log file %s", filename)
+	}
+	defer input.Close()
+	output, err := operating.System.OpenFileWrite(filename+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "Cannot compress rotated log file %s", filename)
+	}
+	writer := gzip.NewWriter(output)
+	_, err = io.Copy(writer, input)
+	if err == nil {
+		err = writer.Close()
+	}
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = operating.System.Remove(filename + ".gz")
+		return errors.Wrapf(err, "Cannot compress rotated log file %s", filename)
+	}
+	return operating.System.Remove(filename)
+}
+
+type backup struct {
+	name      string
+	timestamp string
+	sequence  int
+}
+
+// backups returns the rotated files of the log file, newest first.
+func (rotating *RotatingFile) backups() ([]backup, error) {
+	matches, err := operating.System.Glob(rotating.filename + ".*")
+	if err != nil {
+		return nil, err
+	}
+	var backups []backup
+	for _, match := range matches {
+		suffix := strings.TrimSuffix(strings.TrimPrefix(match, rotating.filename+"."), ".gz")
+		timestamp, sequenceStr, hasSequence := strings.Cut(suffix, "-")
+		sequence := 0
+		if hasSequence {
+			if sequence, err = strconv.Atoi(sequenceStr); err != nil || sequence < 1 {
+				continue
+			}
+		}
+		if _, err := time.Parse(rotationTimestampFormat, timestamp); err == nil {
+			backups = append(backups, backup{name: match, timestamp: timestamp, sequence: sequence})
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].timestamp != backups[j].timestamp {
+			return backups[i].timestamp > backups[j].timestamp
+		}
+		return backups[i].sequence > backups[j].sequence
+	})
+	return backups, nil
+}
+
+func (rotating *RotatingFile) removeOldBackups(rotatedAt time.Time) error {
+	if rotating.options.MaxBackups <= 0 && rotating.options.MaxAge <= 0 {
+		return nil
+	}
+	backups, err := rotating.backups()
+	if err != nil {
+		return errors.Wrapf(err, "Cannot list rotated log files of %s", rotating.filename)
+	}
+	cutoff := rotatedAt.Add(-rotating.options.MaxAge).Format(rotationTimestampFormat)
+	for i, old := range backups {
+		tooMany := rotating.options.MaxBackups > 0 && i >= rotating.options.MaxBackups
+		tooOld := rotating.options.MaxAge > 0 && old.timestamp < cutoff
+		if tooMany || tooOld {
+			if err := operating.System.Remove(old.name); err != nil {
+				return errors.Wrapf(err, "Cannot remove rotated log file %s", old.name)
+			}
+		}
+	}
+	return nil
+}
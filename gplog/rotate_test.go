@@ -0,0 +1,206 @@
+package gplog_test
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+var _ = Describe("gplog/rotate tests", func() {
+	var (
+		dir      string
+		filename string
+		now      time.Time
+	)
+	listDir := func() []string {
+		entries, err := os.ReadDir(dir)
+		Expect(err).ToNot(HaveOccurred())
+		names := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		sort.Strings(names)
+		return names
+	}
+	readFile := func(name string) string {
+		contents, err := os.ReadFile(filepath.Join(dir, name))
+		Expect(err).ToNot(HaveOccurred())
+		return string(contents)
+	}
+	// Each write advances the clock by a second, so that each rotation has its own timestamp.
+	write := func(file *gplog.RotatingFile, text string) {
+		now = now.Add(time.Second)
+		_, err := file.Write([]byte(text))
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		filename = filepath.Join(dir, "testProgram.log")
+		now = time.Date(2017, time.January, 1, 1, 1, 0, 0, time.UTC)
+		operating.System.Now = func() time.Time { return now }
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		gplog.SetLogFileRotation(gplog.RotationOptions{})
+	})
+
+	Describe("RotatingFile", func() {
+		It("rotates the file before a write that would take it past the maximum size", func() {
+			file, err := gplog.NewRotatingFile(filename, gplog.RotationOptions{MaxSize: 12})
+			Expect(err).ToNot(HaveOccurred())
+			defer file.Close()
+			write(file, "12345\n")
+			write(file, "6789\n")
+			write(file, "abcdef\n")
+
+			Expect(listDir()).To(Equal([]string{"testProgram.log", "testProgram.log.20170101T010103.000"}))
+			Expect(readFile("testProgram.log.20170101T010103.000")).To(Equal("12345\n6789\n"))
+			Expect(readFile("testProgram.log")).To(Equal("abcdef\n"))
+		})
+		It("counts the contents of an existing file toward its size", func() {
+			Expect(os.WriteFile(filename, []byte("previous run\n"), 0644)).To(Succeed())
+			file, err := gplog.NewRotatingFile(filename, gplog.RotationOptions{MaxSize: 16})
+			Expect(err).ToNot(HaveOccurred())
+			defer file.Close()
+			write(file, "this run\n")
+
+			Expect(readFile("testProgram.log.20170101T010101.000")).To(Equal("previous run\n"))
+			Expect(readFile("testProgram.log")).To(Equal("this run\n"))
+		})
+		It("writes a message larger than the maximum size to a file of its own", func() {
+			file, err := gplog.NewRotatingFile(filename, gplog.RotationOptions{MaxSize: 4})
+			Expect(err).ToNot(HaveOccurred())
+			defer file.Close()
+			write(file, "a long message\n")
+
+			Expect(listDir()).To(Equal([]string{"testProgram.log"}))
+			Expect(readFile("testProgram.log")).To(Equal("a long message\n"))
+		})
+		It("keeps only the newest rotated files", func() {
+			file, err := gplog.NewRotatingFile(filename, gplog.RotationOptions{MaxSize: 1, MaxBackups: 2})
+			Expect(err).ToNot(HaveOccurred())
+			for _, text := range []string{"1", "2", "3", "4"} {
+				write(file, text)
+			}
+			Expect(file.Close()).To(Succeed())
+
+			Expect(listDir()).To(Equal([]string{"testProgram.log", "testProgram.log.20170101T010103.000", "testProgram.log.20170101T010104.000"}))
+			Expect(readFile("testProgram.log.20170101T010104.000")).To(Equal("3"))
+		})
+		It("does not overwrite a file rotated within the same millisecond", func() {
+			file, err := gplog.NewRotatingFile(filename, gplog.RotationOptions{MaxSize: 6})
+			Expect(err).ToNot(HaveOccurred())
+			defer file.Close()
+			for _, text := range []string{"first", "second", "third"} {
+				_, err := file.Write([]byte(text))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(file.Rotate()).To(Succeed())
+			}
+
+			Expect(listDir()).To(Equal([]string{"testProgram.log", "testProgram.log.20170101T010100.000",
+				"testProgram.log.20170101T010100.000-1", "testProgram.log.20170101T010100.000-2"}))
+			Expect(readFile("testProgram.log.20170101T010100.000")).To(Equal("first"))
+			Expect(readFile("testProgram.log.20170101T010100.000-1")).To(Equal("second"))
+			Expect(readFile("testProgram.log.20170101T010100.000-2")).To(Equal("third"))
+		})
+		It("keeps the newest of several files rotated within the same millisecond", func() {
+			file, err := gplog.NewRotatingFile(filename, gplog.RotationOptions{MaxBackups: 1})
+			Expect(err).ToNot(HaveOccurred())
+			for _, text := range []string{"first", "second"} {
+				_, err := file.Write([]byte(text))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(file.Rotate()).To(Succeed())
+			}
+			Expect(file.Close()).To(Succeed())
+
+			Expect(listDir()).To(Equal([]string{"testProgram.log", "testProgram.log.20170101T010100.000-1"}))
+			Expect(readFile("testProgram.log.20170101T010100.000-1")).To(Equal("second"))
+		})
+		It("removes rotated files older than the maximum age", func() {
+			Expect(os.WriteFile(filename+".20161201T000000.000", []byte("old"), 0644)).To(Succeed())
+			Expect(os.WriteFile(filename+".notes", []byte("unrelated"), 0644)).To(Succeed())
+			file, err := gplog.NewRotatingFile(filename, gplog.RotationOptions{MaxAge: 24 * time.Hour})
+			Expect(err).ToNot(HaveOccurred())
+			write(file, "recent")
+			Expect(file.Rotate()).To(Succeed())
+			Expect(file.Close()).To(Succeed())
+
+			Expect(listDir()).To(Equal([]string{"testProgram.log", "testProgram.log.20170101T010101.000", "testProgram.log.notes"}))
+		})
+		It("compresses rotated files", func() {
+			file, err := gplog.NewRotatingFile(filename, gplog.RotationOptions{MaxSize: 8, Compress: true})
+			Expect(err).ToNot(HaveOccurred())
+			write(file, "first\n")
+			write(file, "second\n")
+			Expect(file.Close()).To(Succeed())
+
+			Expect(listDir()).To(Equal([]string{"testProgram.log", "testProgram.log.20170101T010102.000.gz"}))
+			compressed, err := os.Open(filepath.Join(dir, "testProgram.log.20170101T010102.000.gz"))
+			Expect(err).ToNot(HaveOccurred())
+			defer compressed.Close()
+			reader, err := gzip.NewReader(compressed)
+			Expect(err).ToNot(HaveOccurred())
+			contents, err := io.ReadAll(reader)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("first\n"))
+		})
+		It("compresses and deletes rotated files without holding up writes", func() {
+			release := make(chan struct{})
+			operating.System.OpenFileRead = func(name string, flag int, perm os.FileMode) (operating.ReadCloserAt, error) {
+				<-release
+				return operating.OpenFileRead(name, flag, perm)
+			}
+			file, err := gplog.NewRotatingFile(filename, gplog.RotationOptions{MaxSize: 8, Compress: true})
+			Expect(err).ToNot(HaveOccurred())
+			write(file, "first\n")
+			write(file, "second\n")
+			write(file, "third\n")
+
+			Expect(readFile("testProgram.log")).To(Equal("third\n"))
+			close(release)
+			Expect(file.Close()).To(Succeed())
+			Expect(listDir()).To(Equal([]string{"testProgram.log", "testProgram.log.20170101T010102.000.gz", "testProgram.log.20170101T010103.000.gz"}))
+		})
+		It("returns errors compressing rotated files from Close", func() {
+			operating.System.OpenFileRead = func(name string, flag int, perm os.FileMode) (operating.ReadCloserAt, error) {
+				return nil, errors.New("permission denied")
+			}
+			file, err := gplog.NewRotatingFile(filename, gplog.RotationOptions{MaxSize: 8, Compress: true})
+			Expect(err).ToNot(HaveOccurred())
+			write(file, "first\n")
+			write(file, "second\n")
+
+			Expect(file.Close()).To(MatchError(ContainSubstring("Cannot compress rotated log file " + filename + ".20170101T010102.000: permission denied")))
+		})
+		It("returns an error if the log file cannot be opened", func() {
+			_, err := gplog.NewRotatingFile(filepath.Join(dir, "missing", "testProgram.log"), gplog.RotationOptions{})
+			Expect(err).To(MatchError(ContainSubstring("Cannot open log file " + dir + "/missing/testProgram.log")))
+		})
+	})
+	Describe("SetLogFileRotation", func() {
+		It("rotates the log file opened by InitializeLogging", func() {
+			operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "testUser", HomeDir: "testDir"}, nil }
+			gplog.SetLogger(nil)
+			defer gplog.SetLogger(nil)
+			gplog.SetLogFileRotation(gplog.RotationOptions{MaxSize: 100})
+			gplog.InitializeLogging("testProgram", dir)
+			gplog.SetVerbosity(gplog.LOGERROR)
+			gplog.Debug("%s", make([]byte, 80))
+			gplog.Debug("second message")
+
+			Expect(listDir()).To(HaveLen(2))
+			Expect(readFile("testProgram_20170101.log")).To(ContainSubstring("second message"))
+		})
+	})
+})
@@ -0,0 +1,166 @@
+package gplog
+
+/*
+ * This file contains structs and functions for using gplog together with the
+ * standard library's log/slog package, so that downstream projects can move
+ * to slog gradually.
+ *
+ * NewSlogHandler returns a handler that writes slog records through gplog,
+ * with the same verbosities, formats, and destinations as messages logged
+ * with gplog's own functions, so code using slog can run in a utility that
+ * uses gplog, as with slog.SetDefault(slog.New(gplog.NewSlogHandler())).
+ * Conversely, ForwardToSlog sends each message logged through gplog to an
+ * slog.Logger as well.
+ */
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/pkg/errors"
+)
+
+// The slog level of CRITICAL messages, which slog has no name for.
+const SlogLevelCritical = slog.LevelError + 4
+
+// The slog level of each gplog level name.
+var slogLevels = map[string]slog.Level{
+	"DEBUG":    slog.LevelDebug,
+	"INFO":     slog.LevelInfo,
+	"WARNING":  slog.LevelWarn,
+	"ERROR":    slog.LevelError,
+	"CRITICAL": SlogLevelCritical,
+}
+
+/*
+ * slogHandler writes records as the gplog function for their level does:
+ * Error and above as Error, Warn as Warn, Info as Info, levels between Debug
+ * and Info as Verbose, and Debug and below as Debug.  Attributes are appended
+ * to text messages as key=value pairs and added as fields to JSON entries,
+ * with the names of enclosing groups as prefixes separated by periods.  The
+ * record's time is not used; messages are timestamped as gplog's are.
+ */
+type slogHandler struct {
+	attrs  []field
+	prefix string
+}
+
+func NewSlogHandler() slog.Handler {
+	return &slogHandler{}
+}
+
+func slogVerbosity(level slog.Level) int {
+	switch {
+	case level >= slog.LevelWarn:
+		return LOGERROR
+	case level >= slog.LevelInfo:
+		return LOGINFO
+	case level > slog.LevelDebug:
+		return LOGVERBOSE
+	}
+	return LOGDEBUG
+}
+
+func (handler *slogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	verbosity := slogVerbosity(level)
+	return logger.fileVerbosity >= verbosity || logger.shellVerbosity >= verbosity
+}
+
+func (handler *slogHandler) Handle(ctx context.Context, record slog.Record) error {
+	fields := make([]field, len(handler.attrs), len(handler.attrs)+record.NumAttrs())
+	copy(fields, handler.attrs)
+	record.Attrs(func(attr slog.Attr) bool {
+		fields = appendAttr(fields, handler.prefix, attr)
+		return true
+	})
+
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	switch {
+	case record.Level >= slog.LevelError:
+		errorCode = 1
+		writeLeveled("", LOGERROR, logger.logStderr, RED, "ERROR", record.Message, fields)
+	case record.Level >= slog.LevelWarn:
+		writeLeveled("", LOGERROR, logger.logStdout, YELLOW, "WARNING", record.Message, fields)
+	case record.Level >= slog.LevelInfo:
+		writeLeveled("", LOGINFO, logger.logStdout, NONE, "INFO", record.Message, fields)
+	default:
+		writeLeveled("", slogVerbosity(record.Level), logger.logStdout, NONE, "DEBUG", record.Message, fields)
+	}
+	return nil
+}
+
+func (handler *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make([]field, len(handler.attrs), len(handler.attrs)+len(attrs))
+	copy(fields, handler.attrs)
+	for _, attr := range attrs {
+		fields = appendAttr(fields, handler.prefix, attr)
+	}
+	return &slogHandler{attrs: fields, prefix: handler.prefix}
+}
+
+func (handler *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return handler
+	}
+	return &slogHandler{attrs: handler.attrs, prefix: handler.prefix + name + "."}
+}
+
+// appendAttr appends the fields for an attribute, flattening groups, and ignoring empty attributes as slog handlers should.
+func appendAttr(fields []field, prefix string, attr slog.Attr) []field {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return fields
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, groupAttr := range attr.Value.Group() {
+			fields = appendAttr(fields, prefix, groupAttr)
+		}
+		return fields
+	}
+	value := attr.Value.Any()
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+	return append(fields, field{key: prefix + attr.Key, value: value})
+}
+
+/*
+ * ForwardToSlog sends each message logged through gplog to the given logger
+ * as well, whatever the verbosity of gplog's destinations, at the slog level
+ * of the message's level: Debug, Info, Warn, Error, or SlogLevelCritical, as
+ * for Fatal.  Passing nil stops forwarding.
+ *
+ * The logger must not write back to gplog, as through a handler returned by
+ * NewSlogHandler, or messages would be logged again without end.
+ */
+func ForwardToSlog(slogLogger *slog.Logger) error {
+	if slogLogger != nil {
+		if _, ok := slogLogger.Handler().(*slogHandler); ok {
+			return errors.New("Cannot forward gplog output to a logger that writes to gplog")
+		}
+	}
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	logger.slogLogger = slogLogger
+	return nil
+}
+
+func forwardToSlog(level string, message string, fields []field) {
+	if logger.slogLogger == nil {
+		return
+	}
+	slogLevel := slogLevels[level]
+	ctx := context.Background()
+	if !logger.slogLogger.Enabled(ctx, slogLevel) {
+		return
+	}
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, f := range fields {
+		attrs = append(attrs, slog.Any(f.key, f.value))
+	}
+	logger.slogLogger.LogAttrs(ctx, slogLevel, message, attrs...)
+}
@@ -0,0 +1,124 @@
+package gplog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os/user"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pkg/errors"
+)
+
+var _ = Describe("gplog/slog tests", func() {
+	var (
+		stdout  *gbytes.Buffer
+		stderr  *gbytes.Buffer
+		logfile *gbytes.Buffer
+		slogger *slog.Logger
+	)
+
+	BeforeEach(func() {
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "testUser", HomeDir: "testDir"}, nil }
+		operating.System.Getpid = func() int { return 0 }
+		operating.System.Hostname = func() (string, error) { return "testHost", nil }
+		operating.System.Now = func() time.Time { return time.Date(2017, time.January, 1, 1, 1, 1, 1, time.UTC) }
+		stdout, stderr, logfile = gbytes.NewBuffer(), gbytes.NewBuffer(), gbytes.NewBuffer()
+		gplog.SetLogger(gplog.NewLogger(stdout, stderr, logfile, "gbytes.Buffer", gplog.LOGINFO, "testProgram"))
+		gplog.SetErrorCode(0)
+		slogger = slog.New(gplog.NewSlogHandler())
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		gplog.SetErrorCode(0)
+	})
+
+	Describe("NewSlogHandler", func() {
+		It("writes records through gplog with their attributes", func() {
+			slogger.Info("copied table", "table", "public.foo", "rows", 42, "note", "took a while")
+
+			Expect(stdout).To(gbytes.Say(`\[INFO\]:-copied table table=public.foo rows=42 note="took a while"`))
+			Expect(logfile).To(gbytes.Say(`\[INFO\]:-copied table table=public.foo rows=42 note="took a while"`))
+		})
+		It("maps slog levels to gplog levels and verbosities", func() {
+			slogger.Debug("debug message")
+			slogger.Log(context.Background(), slog.LevelDebug+2, "verbose message")
+			slogger.Warn("warning message")
+			slogger.Error("error message", "err", errors.New("disk full"))
+
+			Expect(stdout).ToNot(gbytes.Say("debug message"))
+			Expect(logfile).To(gbytes.Say(`\[DEBUG\]:-debug message`))
+			Expect(logfile).To(gbytes.Say(`\[DEBUG\]:-verbose message`))
+			Expect(stdout).To(gbytes.Say(`\[WARNING\]:-warning message`))
+			Expect(stderr).To(gbytes.Say(`\[ERROR\]:-error message err="disk full"`))
+			Expect(gplog.GetErrorCode()).To(Equal(1))
+		})
+		It("reports whether either destination is verbose enough", func() {
+			gplog.SetLogFileVerbosity(gplog.LOGINFO)
+
+			Expect(slogger.Enabled(context.Background(), slog.LevelDebug)).To(BeFalse())
+			Expect(slogger.Enabled(context.Background(), slog.LevelInfo)).To(BeTrue())
+			gplog.SetVerbosity(gplog.LOGDEBUG)
+			Expect(slogger.Enabled(context.Background(), slog.LevelDebug)).To(BeTrue())
+		})
+		It("qualifies attributes with their groups", func() {
+			slogger.With("job", 7).WithGroup("segment").With("content", 1).Info("restored", slog.Group("size", "bytes", 1024))
+
+			Expect(stdout).To(gbytes.Say(`restored job=7 segment.content=1 segment.size.bytes=1024`))
+		})
+		It("adds attributes as fields in JSON output", func() {
+			gplog.SetLogFileFormat(gplog.FORMAT_JSON)
+			slogger.WithGroup("segment").Info("restored", "content", 1)
+
+			var entry map[string]interface{}
+			Expect(json.Unmarshal(logfile.Contents(), &entry)).To(Succeed())
+			Expect(entry["message"]).To(Equal("restored"))
+			Expect(entry["segment.content"]).To(BeNumerically("==", 1))
+		})
+	})
+	Describe("ForwardToSlog", func() {
+		var output *bytes.Buffer
+		BeforeEach(func() {
+			output = &bytes.Buffer{}
+			forwarded := slog.New(slog.NewTextHandler(output, &slog.HandlerOptions{
+				Level: slog.LevelDebug,
+				ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+					if attr.Key == slog.TimeKey {
+						return slog.Attr{}
+					}
+					return attr
+				},
+			}))
+			Expect(gplog.ForwardToSlog(forwarded)).To(Succeed())
+		})
+
+		It("sends each message to the logger, whatever gplog's verbosity", func() {
+			gplog.Info("starting")
+			gplog.Debug("details")
+			gplog.Warn("careful")
+
+			Expect(output.String()).To(Equal("level=INFO msg=starting\nlevel=DEBUG msg=details\nlevel=WARN msg=careful\n"))
+			Expect(stdout).ToNot(gbytes.Say("details"))
+		})
+		It("sends fatal errors at the critical level", func() {
+			Expect(func() { gplog.Fatal(nil, "cannot continue") }).To(Panic())
+
+			Expect(output.String()).To(Equal("level=ERROR+4 msg=\"cannot continue\"\n"))
+		})
+		It("stops when passed nil", func() {
+			Expect(gplog.ForwardToSlog(nil)).To(Succeed())
+			gplog.Info("starting")
+
+			Expect(output.String()).To(BeEmpty())
+		})
+		It("refuses a logger that writes back to gplog", func() {
+			Expect(gplog.ForwardToSlog(slogger)).To(MatchError("Cannot forward gplog output to a logger that writes to gplog"))
+		})
+	})
+})
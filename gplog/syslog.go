@@ -0,0 +1,132 @@
+package gplog
+
+/*
+ * This file contains structs and functions for sending log messages to
+ * syslog, locally or on a remote host, alongside the log file and the shell,
+ * for sites whose policy requires all daemon logs to flow through syslog.
+ *
+ * Syslog has a verbosity of its own, which defaults to LOGINFO, and messages
+ * are sent at the syslog severity matching their level.  As syslog records
+ * the time, host, and program itself, text messages are sent without the
+ * prefix written to the log file.
+ */
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// A syslogWriter is a connection to syslog, as made by dialSyslog.
+type syslogWriter interface {
+	Debug(message string) error
+	Info(message string) error
+	Warning(message string) error
+	Err(message string) error
+	Crit(message string) error
+	Close() error
+}
+
+type SyslogOptions struct {
+	// The network to reach a remote syslog server, "udp" or "tcp", or "" for the local syslog
+	Network string
+	// The host:port of a remote syslog server
+	Address string
+	// The facility, such as "user", "daemon", or "local0" through "local7"; "user" if unset
+	Facility string
+	// The tag on each message; the program name if unset
+	Tag string
+	// The format of messages; JSON entries include the standard fields, as in the log file
+	Format LogFormat
+}
+
+/*
+ * ConnectSyslog starts sending log messages to syslog, replacing any earlier
+ * connection.  Connecting fails on platforms without syslog.  The syslog
+ * verbosity is left as it is, so it may be set before or after connecting.
+ */
+func ConnectSyslog(options SyslogOptions) error {
+	if options.Tag == "" {
+		options.Tag = logger.program
+	}
+	writer, err := dialSyslog(options)
+	if err != nil {
+		return err
+	}
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	if logger.syslog != nil {
+		_ = logger.syslog.Close()
+	}
+	logger.syslog = writer
+	logger.syslogFormat = options.Format
+	return nil
+}
+
+// DisconnectSyslog stops sending log messages to syslog.
+func DisconnectSyslog() error {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	if logger.syslog == nil {
+		return nil
+	}
+	err := logger.syslog.Close()
+	logger.syslog = nil
+	return err
+}
+
+func GetSyslogVerbosity() int {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	return logger.syslogVerbosity
+}
+
+func SetSyslogVerbosity(verbosity int) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	logger.syslogVerbosity = verbosity
+}
+
+func writeToSyslog(verbosity int, level string, message string, fields []field) {
+	if logger.syslog == nil || logger.syslogVerbosity < verbosity {
+		return
+	}
+	if logger.syslogFormat == FORMAT_JSON {
+		message = jsonEntry(level, message, fields)
+	} else {
+		message += formatFields(fields)
+	}
+	switch level {
+	case "CRITICAL":
+		_ = logger.syslog.Crit(message)
+	case "ERROR":
+		_ = logger.syslog.Err(message)
+	case "WARNING":
+		_ = logger.syslog.Warning(message)
+	case "INFO":
+		_ = logger.syslog.Info(message)
+	default:
+		_ = logger.syslog.Debug(message)
+	}
+}
+
+// The syslog facilities, in the order of their codes, which are the same on every platform with syslog.
+var syslogFacilities = []string{"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news", "uucp", "cron", "authpriv", "ftp"}
+
+// syslogFacilityCode returns the code of the named facility, as shifted into a syslog priority.
+func syslogFacilityCode(name string) (int, error) {
+	if name == "" {
+		name = "user"
+	}
+	for code, facility := range syslogFacilities {
+		if name == facility {
+			return code << 3, nil
+		}
+	}
+	for i := 0; i < 8; i++ {
+		if name == fmt.Sprintf("local%d", i) {
+			return (16 + i) << 3, nil
+		}
+	}
+	return 0, errors.Errorf("Unknown syslog facility %s", name)
+}
@@ -0,0 +1,18 @@
+//go:build windows || plan9
+
+package gplog
+
+/*
+ * This file contains the stand-in for the connection to syslog on platforms
+ * that do not have it.
+ */
+
+import (
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+func dialSyslog(options SyslogOptions) (syslogWriter, error) {
+	return nil, errors.Errorf("Syslog is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,102 @@
+//go:build !windows && !plan9
+
+package gplog_test
+
+import (
+	"net"
+	"os/user"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("gplog/syslog tests", func() {
+	var (
+		server  net.PacketConn
+		logfile *gbytes.Buffer
+	)
+	receive := func() string {
+		buffer := make([]byte, 4096)
+		Expect(server.SetReadDeadline(time.Now().Add(5 * time.Second))).To(Succeed())
+		n, _, err := server.ReadFrom(buffer)
+		Expect(err).ToNot(HaveOccurred())
+		return string(buffer[:n])
+	}
+
+	BeforeEach(func() {
+		var err error
+		server, err = net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "testUser", HomeDir: "testDir"}, nil }
+		operating.System.Getpid = func() int { return 0 }
+		operating.System.Hostname = func() (string, error) { return "testHost", nil }
+		logfile = gbytes.NewBuffer()
+		gplog.SetLogger(gplog.NewLogger(gbytes.NewBuffer(), gbytes.NewBuffer(), logfile, "gbytes.Buffer", gplog.LOGINFO, "testProgram"))
+	})
+	AfterEach(func() {
+		Expect(gplog.DisconnectSyslog()).To(Succeed())
+		server.Close()
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("ConnectSyslog", func() {
+		It("sends messages at the matching severity, tagged with the program name", func() {
+			Expect(gplog.ConnectSyslog(gplog.SyslogOptions{Network: "udp", Address: server.LocalAddr().String()})).To(Succeed())
+			gplog.Info("backup started")
+			gplog.Error("segment %d is down", 3)
+
+			Expect(receive()).To(MatchRegexp(`^<14>\S+ \S+ testProgram\[\d+\]: backup started\n$`))
+			Expect(receive()).To(MatchRegexp(`^<11>\S+ \S+ testProgram\[\d+\]: segment 3 is down\n$`))
+			Expect(logfile).To(gbytes.Say(`\[INFO\]:-backup started`))
+		})
+		It("uses the given facility, tag, and format", func() {
+			Expect(gplog.ConnectSyslog(gplog.SyslogOptions{Network: "udp", Address: server.LocalAddr().String(), Facility: "local3", Tag: "gpbackupd", Format: gplog.FORMAT_JSON})).To(Succeed())
+			gplog.Warn("disk nearly full")
+
+			Expect(receive()).To(MatchRegexp(`^<156>\S+ \S+ gpbackupd\[\d+\]: \{.*"level":"WARNING","message":"disk nearly full".*\}\n$`))
+		})
+		It("sends only messages within its verbosity", func() {
+			Expect(gplog.ConnectSyslog(gplog.SyslogOptions{Network: "udp", Address: server.LocalAddr().String()})).To(Succeed())
+			Expect(gplog.GetSyslogVerbosity()).To(Equal(gplog.LOGINFO))
+			gplog.Debug("not sent")
+			gplog.SetSyslogVerbosity(gplog.LOGVERBOSE)
+			gplog.Debug("still not sent")
+			gplog.Verbose("sent")
+
+			Expect(receive()).To(MatchRegexp(`^<15>.*: sent\n$`))
+		})
+		It("keeps a verbosity set before connecting", func() {
+			gplog.SetSyslogVerbosity(gplog.LOGVERBOSE)
+			Expect(gplog.ConnectSyslog(gplog.SyslogOptions{Network: "udp", Address: server.LocalAddr().String()})).To(Succeed())
+			Expect(gplog.GetSyslogVerbosity()).To(Equal(gplog.LOGVERBOSE))
+			gplog.Verbose("sent")
+
+			Expect(receive()).To(MatchRegexp(`^<15>.*: sent\n$`))
+		})
+		It("keeps its verbosity when reconnecting", func() {
+			Expect(gplog.ConnectSyslog(gplog.SyslogOptions{Network: "udp", Address: server.LocalAddr().String()})).To(Succeed())
+			gplog.SetSyslogVerbosity(gplog.LOGERROR)
+			Expect(gplog.ConnectSyslog(gplog.SyslogOptions{Network: "udp", Address: server.LocalAddr().String()})).To(Succeed())
+			Expect(gplog.GetSyslogVerbosity()).To(Equal(gplog.LOGERROR))
+		})
+		It("rejects an unknown facility", func() {
+			err := gplog.ConnectSyslog(gplog.SyslogOptions{Facility: "local9"})
+			Expect(err).To(MatchError("Unknown syslog facility local9"))
+		})
+	})
+	Describe("DisconnectSyslog", func() {
+		It("stops sending messages to syslog", func() {
+			Expect(gplog.ConnectSyslog(gplog.SyslogOptions{Network: "udp", Address: server.LocalAddr().String()})).To(Succeed())
+			Expect(gplog.DisconnectSyslog()).To(Succeed())
+			gplog.Info("not sent")
+
+			Expect(server.SetReadDeadline(time.Now().Add(100 * time.Millisecond))).To(Succeed())
+			_, _, err := server.ReadFrom(make([]byte, 4096))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
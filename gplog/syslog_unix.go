@@ -0,0 +1,25 @@
+//go:build !windows && !plan9
+
+package gplog
+
+/*
+ * This file contains the connection to syslog on platforms that have it.
+ */
+
+import (
+	"log/syslog"
+
+	"github.com/pkg/errors"
+)
+
+func dialSyslog(options SyslogOptions) (syslogWriter, error) {
+	facility, err := syslogFacilityCode(options.Facility)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := syslog.Dial(options.Network, options.Address, syslog.Priority(facility)|syslog.LOG_INFO, options.Tag)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot connect to syslog")
+	}
+	return writer, nil
+}
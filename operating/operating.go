@@ -71,6 +71,7 @@ type SystemFunctions struct {
 	ReadFile      func(filename string) ([]byte, error)
 	Remove        func(name string) error
 	RemoveAll     func(name string) error
+	Rename        func(oldpath, newpath string) error
 	Stat          func(name string) (os.FileInfo, error)
 	Stdin         ReadCloserAt
 	Stdout        io.WriteCloser
@@ -96,6 +97,7 @@ func InitializeSystemFunctions() *SystemFunctions {
 		ReadFile:      ioutil.ReadFile,
 		Remove:        os.Remove,
 		RemoveAll:     os.RemoveAll,
+		Rename:        os.Rename,
 		Stat:          os.Stat,
 		Stdin:         os.Stdin,
 		Stdout:        os.Stdout,
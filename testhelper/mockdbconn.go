@@ -0,0 +1,121 @@
+package testhelper
+
+/*
+ * This file contains a DBConn test double for packages that use dbconn, so
+ * that their tests need not each set up a sqlmock connection, pretend to be a
+ * particular database version, and track the queries run.
+ */
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+	"sync"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/blang/semver"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	. "github.com/onsi/gomega"
+)
+
+/*
+ * A MockDBConn is a DBConn connected to a sqlmock database that reports the
+ * version it was created with.  It records every query run through it, in
+ * order, in its transcript.  The embedded DBConn can be passed to the code
+ * under test, and Mock used directly for expectations the helpers below do
+ * not cover.
+ */
+type MockDBConn struct {
+	*dbconn.DBConn
+	Mock sqlmock.Sqlmock
+
+	mutex      sync.Mutex
+	transcript []string
+}
+
+/*
+ * MakeVersion returns the version of a database of the given type, such as
+ * MakeVersion(dbconn.CBDB, "1.6.0"), for NewMockDBConn or SetDBVersionAndType.
+ * The version of PostgreSQL it is based on is set if given, as in "14.4".
+ */
+func MakeVersion(dbType dbconn.DBType, versionStr string, pgVersionStr ...string) dbconn.GPDBVersion {
+	version := dbconn.NewVersion(versionStr)
+	version.Type = dbType
+	if len(pgVersionStr) == 1 {
+		pgVersion, err := semver.ParseTolerant(pgVersionStr[0])
+		Expect(err).ToNot(HaveOccurred())
+		version.PGSemVer = pgVersion
+	}
+	return version
+}
+
+// SetDBVersionAndType is SetDBVersion for a database type other than GPDB.
+func SetDBVersionAndType(connection *dbconn.DBConn, dbType dbconn.DBType, versionStr string) {
+	connection.Version = MakeVersion(dbType, versionStr)
+}
+
+/*
+ * NewMockDBConn returns a MockDBConn with the given number of connections,
+ * whose Version is version.  Any expectations not met at the end of a test
+ * can be checked with ExpectationsWereMet.
+ */
+func NewMockDBConn(version dbconn.GPDBVersion, numConns int) *MockDBConn {
+	connection, mock := CreateMockDBConn()
+	ExpectVersionQuery(mock, "5.1.0")
+	connection.MustConnect(numConns)
+	connection.Version = version
+	mockConn := &MockDBConn{DBConn: connection, Mock: mock}
+	connection.AddQueryHook(dbconn.QueryHookFuncs{After: func(ctx context.Context, event *dbconn.QueryEvent) {
+		mockConn.mutex.Lock()
+		defer mockConn.mutex.Unlock()
+		mockConn.transcript = append(mockConn.transcript, event.Query)
+	}})
+	return mockConn
+}
+
+// Transcript returns the queries run through the connection since it was created or the transcript was last reset.
+func (mockConn *MockDBConn) Transcript() []string {
+	mockConn.mutex.Lock()
+	defer mockConn.mutex.Unlock()
+	return append([]string{}, mockConn.transcript...)
+}
+
+func (mockConn *MockDBConn) ResetTranscript() {
+	mockConn.mutex.Lock()
+	defer mockConn.mutex.Unlock()
+	mockConn.transcript = nil
+}
+
+/*
+ * ExpectSelect expects a query containing the given text, matched literally
+ * rather than as a regular expression, and has it return the given rows,
+ * each of which has one value per column.  It can be used for Get and Query
+ * as well as Select.
+ */
+func (mockConn *MockDBConn) ExpectSelect(query string, columns []string, rows ...[]driver.Value) *sqlmock.ExpectedQuery {
+	mockRows := sqlmock.NewRows(columns)
+	for _, row := range rows {
+		mockRows.AddRow(row...)
+	}
+	return mockConn.Mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(mockRows)
+}
+
+// ExpectSelectError expects a query containing the given text, as ExpectSelect does, and has it fail with err.
+func (mockConn *MockDBConn) ExpectSelectError(query string, err error) *sqlmock.ExpectedQuery {
+	return mockConn.Mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnError(err)
+}
+
+// ExpectExec expects a statement containing the given text, matched literally, and has it report rowsAffected.
+func (mockConn *MockDBConn) ExpectExec(query string, rowsAffected int64) *sqlmock.ExpectedExec {
+	return mockConn.Mock.ExpectExec(regexp.QuoteMeta(query)).WillReturnResult(sqlmock.NewResult(0, rowsAffected))
+}
+
+// ExpectExecError expects a statement containing the given text, matched literally, and has it fail with err.
+func (mockConn *MockDBConn) ExpectExecError(query string, err error) *sqlmock.ExpectedExec {
+	return mockConn.Mock.ExpectExec(regexp.QuoteMeta(query)).WillReturnError(err)
+}
+
+// ExpectationsWereMet fails the test if any expected query was not run.
+func (mockConn *MockDBConn) ExpectationsWereMet() {
+	Expect(mockConn.Mock.ExpectationsWereMet()).To(Succeed())
+}
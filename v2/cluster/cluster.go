@@ -0,0 +1,154 @@
+package cluster
+
+/*
+ * This package is the next major version of the cluster API.  Commands are
+ * generated from a typed Generator, whose type parameter determines whether
+ * one command runs per segment or per host, rather than from an interface{}
+ * that is type-switched at runtime, and the per-call settings that v1 passes
+ * as variadic booleans or separate function variants are fields of
+ * CommandOptions.  Failures are returned as errors instead of being Fatal.
+ *
+ * A Cluster wraps a v1 cluster.Cluster and delegates to it, so FromV1 and
+ * Cluster.V1 can be used to migrate one call site at a time.
+ */
+
+import (
+	"context"
+
+	v1 "github.com/cloudberrydb/gp-common-go-libs/cluster"
+	"github.com/cloudberrydb/gp-common-go-libs/v2/dbconn"
+	"github.com/pkg/errors"
+)
+
+// These v1 types already take the form the v2 API uses, so they are shared.
+type SegConfig = v1.SegConfig
+type Scope = v1.Scope
+type ShellCommand = v1.ShellCommand
+type RemoteOutput = v1.RemoteOutput
+type TargetSelection = v1.TargetSelection
+type SegmentConfigOptions = v1.SegmentConfigOptions
+type ClusterOption = v1.ClusterOption
+
+const (
+	ON_SEGMENTS         = v1.ON_SEGMENTS
+	ON_HOSTS            = v1.ON_HOSTS
+	EXCLUDE_COORDINATOR = v1.EXCLUDE_COORDINATOR
+	INCLUDE_COORDINATOR = v1.INCLUDE_COORDINATOR
+	ON_REMOTE           = v1.ON_REMOTE
+	ON_LOCAL            = v1.ON_LOCAL
+	EXCLUDE_MIRRORS     = v1.EXCLUDE_MIRRORS
+	INCLUDE_MIRRORS     = v1.INCLUDE_MIRRORS
+
+	TARGET_HOSTNAME = v1.TARGET_HOSTNAME
+	TARGET_ADDRESS  = v1.TARGET_ADDRESS
+)
+
+type Cluster struct {
+	cluster *v1.Cluster
+}
+
+func New(segConfigs []SegConfig, opts ...ClusterOption) *Cluster {
+	return FromV1(v1.NewCluster(segConfigs, opts...))
+}
+
+// FromV1 wraps an existing v1 Cluster.
+func FromV1(cluster *v1.Cluster) *Cluster {
+	return &Cluster{cluster: cluster}
+}
+
+// V1 returns the wrapped v1 Cluster, for calling code that has not been migrated.
+func (cluster *Cluster) V1() *v1.Cluster {
+	return cluster.cluster
+}
+
+/*
+ * A Unit is what a command is generated for: a content ID, for a command run
+ * once per segment, or a hostname, for a command run once per host.
+ */
+type Unit interface {
+	int | string
+}
+
+// A Generator returns the shell command to run for a single content ID or hostname.
+type Generator[T Unit] func(unit T) string
+
+/*
+ * CommandOptions controls how commands are generated and executed.  Scope is
+ * as in v1, except that its ON_SEGMENTS or ON_HOSTS bit must agree with the
+ * Generator.  Target overrides the cluster's Target if set, and Force allows
+ * commands that the cluster's Guardrails would otherwise reject.
+ */
+type CommandOptions struct {
+	Scope  Scope
+	Target *TargetSelection
+	Force  bool
+}
+
+func (opts CommandOptions) target(cluster *v1.Cluster) TargetSelection {
+	if opts.Target != nil {
+		return *opts.Target
+	}
+	return cluster.Target
+}
+
+// GenerateCommands returns the ssh commands that Execute would run, without running them.
+func GenerateCommands[T Unit](cluster *Cluster, opts CommandOptions, generator Generator[T]) ([]ShellCommand, error) {
+	if err := opts.Scope.Validate(); err != nil {
+		return nil, err
+	}
+	target := opts.target(cluster.cluster)
+	switch generate := any(generator).(type) {
+	case Generator[int]:
+		if opts.Scope&ON_HOSTS != ON_SEGMENTS {
+			return nil, errors.Errorf("Cannot generate per-segment commands for scope %s", opts.Scope)
+		}
+		return cluster.cluster.GenerateContentSSHCommandList(opts.Scope, target, v1.ContentShellGenerator(generate)), nil
+	case Generator[string]:
+		if opts.Scope&ON_HOSTS != ON_HOSTS {
+			return nil, errors.Errorf("Cannot generate per-host commands for scope %s", opts.Scope)
+		}
+		return cluster.cluster.GenerateHostSSHCommandList(opts.Scope, target, v1.HostShellGenerator(generate)), nil
+	}
+	return nil, errors.Errorf("Unsupported generator type %T", generator)
+}
+
+/*
+ * Execute generates the commands for the given options, checks them against
+ * the cluster's Guardrails, and runs them with the cluster's usual retries.
+ * An error is returned if the commands could not be generated or were
+ * rejected; failures of the commands themselves are reported in the
+ * RemoteOutput, and can be turned into an error with CheckErrors.
+ */
+func Execute[T Unit](cluster *Cluster, opts CommandOptions, generator Generator[T]) (*RemoteOutput, error) {
+	commandList, err := GenerateCommands(cluster, opts, generator)
+	if err != nil {
+		return nil, err
+	}
+	return cluster.cluster.ExecuteGuardedCommandList(opts.Scope, commandList, opts.Force)
+}
+
+/*
+ * CheckErrors logs each failed command in remoteOutput, using describe to say
+ * what the command was doing for a given content ID or hostname, as the v1
+ * CheckClusterError does, and returns an error starting with message if any
+ * command failed.
+ */
+func CheckErrors[T Unit](cluster *Cluster, remoteOutput *RemoteOutput, message string, describe Generator[T]) error {
+	cluster.cluster.CheckClusterError(remoteOutput, message, (func(T) string)(describe), true)
+	if remoteOutput.NumErrors == 0 {
+		return nil
+	}
+	unit := "segment"
+	if remoteOutput.Scope&ON_HOSTS == ON_HOSTS {
+		unit = "host"
+	}
+	if remoteOutput.NumErrors != 1 {
+		unit += "s"
+	}
+	return errors.Errorf("%s on %d %s", message, remoteOutput.NumErrors, unit)
+}
+
+// GetSegmentConfiguration queries the segment configuration of the cluster that db is connected to.
+func GetSegmentConfiguration(ctx context.Context, db *dbconn.DB, opts SegmentConfigOptions) ([]SegConfig, error) {
+	return v1.GetSegmentConfigurationContext(ctx, db.V1(), opts)
+}
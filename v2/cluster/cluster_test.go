@@ -0,0 +1,103 @@
+package cluster_test
+
+import (
+	"fmt"
+	"testing"
+
+	v1 "github.com/cloudberrydb/gp-common-go-libs/cluster"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+	"github.com/cloudberrydb/gp-common-go-libs/v2/cluster"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestCluster(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "v2/cluster tests")
+}
+
+var _ = Describe("v2/cluster tests", func() {
+	var (
+		testCluster  *cluster.Cluster
+		testExecutor *testhelper.TestExecutor
+	)
+	BeforeEach(func() {
+		testhelper.SetupTestLogger()
+		testExecutor = &testhelper.TestExecutor{ClusterOutput: &cluster.RemoteOutput{}}
+		testCluster = cluster.New([]cluster.SegConfig{
+			{DbID: 1, ContentID: -1, Port: 5432, Hostname: "localhost", DataDir: "/data/coordinator"},
+			{DbID: 2, ContentID: 0, Port: 20000, Hostname: "remotehost", Address: "remotehost-nic", DataDir: "/data/seg0"},
+		}, v1.WithExecutor(testExecutor))
+	})
+	Describe("GenerateCommands", func() {
+		It("generates one command per segment for a content generator", func() {
+			commands, err := cluster.GenerateCommands(testCluster, cluster.CommandOptions{Scope: cluster.ON_SEGMENTS | cluster.INCLUDE_COORDINATOR},
+				cluster.Generator[int](func(content int) string { return fmt.Sprintf("echo %d", content) }))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(commands).To(HaveLen(2))
+			Expect(commands[0].Content).To(Equal(-1))
+			Expect(commands[1].CommandString).To(ContainSubstring("remotehost"))
+			Expect(commands[1].CommandString).To(ContainSubstring("echo 0"))
+		})
+		It("generates one command per host for a host generator", func() {
+			commands, err := cluster.GenerateCommands(testCluster, cluster.CommandOptions{Scope: cluster.ON_HOSTS},
+				cluster.Generator[string](func(host string) string { return "hostname" }))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(commands).To(HaveLen(1))
+			Expect(commands[0].Host).To(Equal("remotehost"))
+		})
+		It("uses the given target", func() {
+			target := cluster.TARGET_ADDRESS
+			commands, err := cluster.GenerateCommands(testCluster, cluster.CommandOptions{Scope: cluster.ON_SEGMENTS, Target: &target},
+				cluster.Generator[int](func(content int) string { return "ls" }))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(commands[0].CommandString).To(ContainSubstring("remotehost-nic"))
+		})
+		It("returns an error if the scope does not match the generator", func() {
+			_, err := cluster.GenerateCommands(testCluster, cluster.CommandOptions{Scope: cluster.ON_HOSTS},
+				cluster.Generator[int](func(content int) string { return "ls" }))
+			Expect(err).To(MatchError("Cannot generate per-segment commands for scope ON_HOSTS|EXCLUDE_COORDINATOR|ON_REMOTE|EXCLUDE_MIRRORS"))
+		})
+		It("returns an error for an invalid scope", func() {
+			_, err := cluster.GenerateCommands(testCluster, cluster.CommandOptions{Scope: cluster.ON_SEGMENTS | cluster.INCLUDE_MIRRORS},
+				cluster.Generator[int](func(content int) string { return "ls" }))
+			Expect(err).To(MatchError(ContainSubstring("INCLUDE_MIRRORS is only supported with ON_HOSTS")))
+		})
+	})
+	Describe("Execute", func() {
+		It("executes the generated commands", func() {
+			output, err := cluster.Execute(testCluster, cluster.CommandOptions{Scope: cluster.ON_HOSTS},
+				cluster.Generator[string](func(host string) string { return "hostname" }))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(output).To(Equal(testExecutor.ClusterOutput))
+			Expect(testExecutor.ClusterCommands).To(HaveLen(1))
+		})
+		It("does not execute commands rejected by the cluster's guardrails unless forced", func() {
+			testCluster.V1().Guardrails = v1.NewGuardrails(v1.RequireForce{})
+			generator := cluster.Generator[int](func(content int) string { return "rm -rf /data/seg0" })
+
+			_, err := cluster.Execute(testCluster, cluster.CommandOptions{Scope: cluster.ON_SEGMENTS}, generator)
+			Expect(err).To(HaveOccurred())
+			Expect(testExecutor.NumClusterExecutions).To(Equal(0))
+
+			_, err = cluster.Execute(testCluster, cluster.CommandOptions{Scope: cluster.ON_SEGMENTS, Force: true}, generator)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(testExecutor.NumClusterExecutions).To(Equal(1))
+		})
+	})
+	Describe("CheckErrors", func() {
+		It("returns nil if no command failed", func() {
+			err := cluster.CheckErrors(testCluster, &cluster.RemoteOutput{}, "Could not list files",
+				cluster.Generator[int](func(content int) string { return "Could not list files" }))
+			Expect(err).ToNot(HaveOccurred())
+		})
+		It("returns an error if any command failed", func() {
+			failed := cluster.ShellCommand{Scope: cluster.ON_HOSTS, Host: "remotehost", Content: -2}
+			output := &cluster.RemoteOutput{Scope: cluster.ON_HOSTS, NumErrors: 1, FailedCommands: []cluster.ShellCommand{failed}}
+			err := cluster.CheckErrors(testCluster, output, "Could not list files",
+				cluster.Generator[string](func(host string) string { return "Could not list files" }))
+			Expect(err).To(MatchError("Could not list files on 1 host"))
+		})
+	})
+})
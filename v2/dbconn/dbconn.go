@@ -0,0 +1,150 @@
+package dbconn
+
+/*
+ * This package is the next major version of the dbconn API.  Queries are run
+ * through a handle to a single pooled connection rather than by passing a
+ * trailing connection number, every query takes a context, and the generic
+ * Get and Select functions return their results instead of scanning them into
+ * an interface{} destination.
+ *
+ * A DB wraps a v1 dbconn.DBConn and delegates to it, so the two versions share
+ * one connection pool and the same hooks, timeouts, and reconnect and retry
+ * policies.  FromV1 and DB.V1 convert between them, so a program can migrate
+ * one call site at a time.
+ */
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// These v1 types already take the form the v2 API uses, so they are shared.
+type ConnectOptions = dbconn.ConnectOptions
+type GPDBVersion = dbconn.GPDBVersion
+type Tx = dbconn.Tx
+
+type DB struct {
+	conn *dbconn.DBConn
+}
+
+// New creates a DB for the given database using the PG* environment variables, as dbconn.NewDBConnFromEnvironment does.
+func New(dbname string) *DB {
+	return FromV1(dbconn.NewDBConnFromEnvironment(dbname))
+}
+
+func NewFromURI(uri string) (*DB, error) {
+	conn, err := dbconn.NewDBConnFromURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	return FromV1(conn), nil
+}
+
+// FromV1 wraps an existing v1 DBConn, which may already be connected.
+func FromV1(conn *dbconn.DBConn) *DB {
+	return &DB{conn: conn}
+}
+
+// V1 returns the wrapped v1 DBConn, for calling code that has not been migrated.
+func (db *DB) V1() *dbconn.DBConn {
+	return db.conn
+}
+
+func (db *DB) Connect(opts ConnectOptions) error {
+	return db.conn.ConnectWithOptions(opts)
+}
+
+func (db *DB) Close() {
+	db.conn.Close()
+}
+
+func (db *DB) Version() GPDBVersion {
+	return db.conn.Version
+}
+
+func (db *DB) NumConns() int {
+	return db.conn.NumConns
+}
+
+/*
+ * Conn returns a handle to the given pooled connection.  Unlike the v1
+ * DBConn.Conn, an invalid connection number is returned as an error rather
+ * than causing a Fatal error.
+ */
+func (db *DB) Conn(connNum int) (*Conn, error) {
+	if connNum < 0 || connNum >= db.conn.NumConns {
+		return nil, errors.Errorf("Invalid connection number: %d", connNum)
+	}
+	return &Conn{db: db.conn, handle: db.conn.Conn(connNum)}, nil
+}
+
+// Conns returns a handle for each connection in the pool, in order.
+func (db *DB) Conns() ([]*Conn, error) {
+	handles, err := db.conn.Handles()
+	if err != nil {
+		return nil, err
+	}
+	conns := make([]*Conn, len(handles))
+	for i, handle := range handles {
+		conns[i] = &Conn{db: db.conn, handle: handle}
+	}
+	return conns, nil
+}
+
+/*
+ * A Conn refers to a single connection in a DB's pool.  As with the v1
+ * ConnHandle it wraps, queries run as part of the connection's transaction if
+ * one is in progress.
+ */
+type Conn struct {
+	db     *dbconn.DBConn
+	handle *dbconn.ConnHandle
+}
+
+func (conn *Conn) ConnNum() int {
+	return conn.handle.ConnNum()
+}
+
+// V1 returns the wrapped v1 ConnHandle.
+func (conn *Conn) V1() *dbconn.ConnHandle {
+	return conn.handle
+}
+
+func (conn *Conn) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return conn.handle.ExecContext(ctx, query, args...)
+}
+
+func (conn *Conn) Get(ctx context.Context, destination interface{}, query string, args ...interface{}) error {
+	return conn.handle.GetContext(ctx, destination, query, args...)
+}
+
+func (conn *Conn) Select(ctx context.Context, destination interface{}, query string, args ...interface{}) error {
+	return conn.handle.SelectContext(ctx, destination, query, args...)
+}
+
+func (conn *Conn) Query(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	return conn.handle.QueryContext(ctx, query, args...)
+}
+
+// RunInTransaction runs fn in a new transaction on this connection, as the v1 DBConn.RunInTransaction does.
+func (conn *Conn) RunInTransaction(fn func(tx *Tx) error) error {
+	return conn.db.RunInTransaction(fn, conn.ConnNum())
+}
+
+// Get runs a query that returns a single row and returns it scanned into a T.
+func Get[T any](ctx context.Context, conn *Conn, query string, args ...interface{}) (T, error) {
+	var result T
+	err := conn.Get(ctx, &result, query, args...)
+	return result, err
+}
+
+// Select runs a query and returns its rows scanned into a slice of T.
+func Select[T any](ctx context.Context, conn *Conn, query string, args ...interface{}) ([]T, error) {
+	var results []T
+	err := conn.Select(ctx, &results, query, args...)
+	return results, err
+}
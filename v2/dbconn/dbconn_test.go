@@ -0,0 +1,101 @@
+package dbconn_test
+
+import (
+	"context"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+	"github.com/cloudberrydb/gp-common-go-libs/v2/dbconn"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDBConn(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "v2/dbconn tests")
+}
+
+var _ = Describe("v2/dbconn tests", func() {
+	var (
+		db   *dbconn.DB
+		mock sqlmock.Sqlmock
+	)
+	BeforeEach(func() {
+		testhelper.SetupTestLogger()
+		connection, sqlMock := testhelper.CreateAndConnectMockDB(2)
+		db, mock = dbconn.FromV1(connection), sqlMock
+	})
+	Describe("DB.Conn", func() {
+		It("returns a handle that runs queries on the given connection", func() {
+			mock.ExpectExec("DELETE FROM foo").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+			conn, err := db.Conn(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(conn.ConnNum()).To(Equal(1))
+
+			result, err := conn.Exec(context.Background(), "DELETE FROM foo WHERE id = $1", 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.RowsAffected()).To(Equal(int64(1)))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns an error for an invalid connection number", func() {
+			_, err := db.Conn(2)
+			Expect(err).To(MatchError("Invalid connection number: 2"))
+		})
+		It("shares its connections with the v1 DBConn", func() {
+			conn, err := db.Conn(0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(conn.V1().ConnNum()).To(Equal(0))
+			Expect(db.V1().NumConns).To(Equal(db.NumConns()))
+		})
+	})
+	Describe("DB.Conns", func() {
+		It("returns one handle per connection", func() {
+			conns, err := db.Conns()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(conns).To(HaveLen(2))
+			Expect(conns[1].ConnNum()).To(Equal(1))
+		})
+	})
+	Describe("Get and Select", func() {
+		It("return the scanned results", func() {
+			mock.ExpectQuery("SELECT count").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+			mock.ExpectQuery("SELECT name").WithArgs("public").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("foo").AddRow("bar"))
+			conn, err := db.Conn(0)
+			Expect(err).ToNot(HaveOccurred())
+
+			count, err := dbconn.Get[int](context.Background(), conn, "SELECT count(*) FROM tables")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(count).To(Equal(3))
+			names, err := dbconn.Select[string](context.Background(), conn, "SELECT name FROM tables WHERE schema = $1", "public")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(names).To(Equal([]string{"foo", "bar"}))
+		})
+		It("are bounded by the given context", func() {
+			conn, err := db.Conn(0)
+			Expect(err).ToNot(HaveOccurred())
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			_, err = dbconn.Get[int](ctx, conn, "SELECT 1")
+			Expect(err).To(MatchError(context.Canceled))
+		})
+	})
+	Describe("Conn.RunInTransaction", func() {
+		It("runs the function in a transaction on the connection", func() {
+			mock.ExpectBegin()
+			mock.ExpectExec("INSERT").WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+			conn, err := db.Conn(1)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = conn.RunInTransaction(func(tx *dbconn.Tx) error {
+				_, err := conn.Exec(context.Background(), "INSERT INTO foo VALUES (1)")
+				return err
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})